@@ -0,0 +1,39 @@
+package aws
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/mock"
+)
+
+// Ensure the correlation id is passed to ffmpeg.
+func TestTTSService_CorrelationID_Command(t *testing.T) {
+	mock.MustFakeCommand(t, "ffmpeg", `echo "ffmpeg version $PEAPOD_CORRELATION_ID Copyright"`)
+
+	ctx := peapod.NewCorrelationContext(context.Background(), "SM0001")
+	if msg, err := NewTTSService().CheckHealth(ctx); err != nil {
+		t.Fatal(err)
+	} else if msg != "ffmpeg SM0001" {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+}
+
+// Ensure the correlation id is sent as a header to Polly.
+func TestTTSService_CorrelationID_Request(t *testing.T) {
+	r := &request.Request{HTTPRequest: httptest.NewRequest("POST", "/v1/speech", nil)}
+	withCorrelationID(peapod.NewCorrelationContext(context.Background(), "SM0001"))(r)
+	if v := r.HTTPRequest.Header.Get(peapod.CorrelationIDHeader); v != "SM0001" {
+		t.Fatalf("unexpected header: %q", v)
+	}
+
+	// No header is sent without a correlation id.
+	r = &request.Request{HTTPRequest: httptest.NewRequest("POST", "/v1/speech", nil)}
+	withCorrelationID(context.Background())(r)
+	if _, ok := r.HTTPRequest.Header[peapod.CorrelationIDHeader]; ok {
+		t.Fatal("unexpected header")
+	}
+}
@@ -5,14 +5,17 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log/slog"
 	"os"
 	"os/exec"
 	"regexp"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/polly"
 	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/metrics"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -22,22 +25,46 @@ const MaxCharactersPerRequest = 1500
 // DefaultVoiceID is the default voice to use when synthesizing speech.
 const DefaultVoiceID = "Emma"
 
+// Polly metrics.
+var (
+	ttsRequests   = metrics.NewCounterVec("peapod_tts_requests_total", "Polly speech synthesis requests by status.", "status")
+	ttsCharacters = metrics.NewCounterVec("peapod_tts_characters_total", "Characters billed by Polly.")
+)
+
 // Ensure service implements interface.
 var _ peapod.TTSService = &TTSService{}
 
 // TTSService represents a service for performing text-to-speech.
 type TTSService struct {
-	Session   *Session
-	VoiceID   string
-	LogOutput io.Writer
+	Session *Session
+	VoiceID string
+	Logger  *slog.Logger
 }
 
 // NewTTSService returns a new instance of TTSService.
 func NewTTSService() *TTSService {
 	return &TTSService{
-		VoiceID:   DefaultVoiceID,
-		LogOutput: ioutil.Discard,
+		VoiceID: DefaultVoiceID,
+		Logger:  peapod.DiscardLogger(),
+	}
+}
+
+// CheckHealth verifies that ffmpeg, used to join synthesized chunks, is
+// installed and returns its version.
+func (s *TTSService) CheckHealth(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-version")
+	cmd.Env = peapod.CommandEnv(ctx)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg: %s", err)
+	}
+
+	// The first line is formatted as "ffmpeg version X Copyright ...".
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 || fields[1] != "version" {
+		return "", fmt.Errorf("ffmpeg: unexpected version output")
 	}
+	return "ffmpeg " + fields[2], nil
 }
 
 // SynthesizeSpeech encodes text to speech.
@@ -50,7 +77,7 @@ func (s *TTSService) SynthesizeSpeech(ctx context.Context, text string) (io.Read
 	var wg errgroup.Group
 	for i, chunk := range chunks {
 		i, chunk := i, chunk
-		fmt.Fprintf(s.LogOutput, "tts: synthesizing chunk: index=%d, len=%d\n", i, len(chunk))
+		s.Logger.InfoContext(ctx, "synthesizing chunk", "index", i, "len", len(chunk))
 
 		wg.Go(func() error {
 			path, err := s.synthesizeChunk(ctx, i, chunk)
@@ -83,17 +110,28 @@ func (s *TTSService) SynthesizeSpeech(ctx context.Context, text string) (io.Read
 func (s *TTSService) synthesizeChunk(ctx context.Context, index int, text string) (string, error) {
 	svc := polly.New(s.Session.session)
 
-	resp, err := svc.SynthesizeSpeech(&polly.SynthesizeSpeechInput{
+	// Use the requesting user's preferred voice, if set.
+	voiceID := s.VoiceID
+	if u := peapod.FromContext(ctx); u != nil && u.Voice != "" {
+		voiceID = u.Voice
+	}
+
+	resp, err := svc.SynthesizeSpeechWithContext(ctx, &polly.SynthesizeSpeechInput{
 		OutputFormat: aws.String("mp3"),
-		VoiceId:      aws.String(s.VoiceID),
+		VoiceId:      aws.String(voiceID),
 		Text:         aws.String(text),
-	})
+	}, withCorrelationID(ctx))
 	if resp != nil {
-		fmt.Fprintf(s.LogOutput, "tts: response: chars=%d\n", resp.RequestCharacters)
+		if resp.RequestCharacters != nil {
+			s.Logger.DebugContext(ctx, "synthesized chunk", "index", index, "chars", *resp.RequestCharacters)
+			ttsCharacters.Add(float64(*resp.RequestCharacters))
+		}
 	}
 	if err != nil {
+		ttsRequests.Inc("error")
 		return "", err
 	}
+	ttsRequests.Inc("success")
 	defer resp.AudioStream.Close()
 
 	// Write audio to a temporary file.
@@ -114,6 +152,16 @@ func (s *TTSService) synthesizeChunk(ctx context.Context, index int, text string
 	return path, nil
 }
 
+// withCorrelationID returns a request option which sends the correlation id
+// in ctx, if any, as a request header.
+func withCorrelationID(ctx context.Context) request.Option {
+	return func(r *request.Request) {
+		if id := peapod.CorrelationIDFromContext(ctx); id != "" {
+			r.HTTPRequest.Header.Set(peapod.CorrelationIDHeader, id)
+		}
+	}
+}
+
 func (s *TTSService) concatenateFiles(ctx context.Context, paths []string) (string, error) {
 	// Create a temporary path.
 	f, err := ioutil.TempFile("", "peapod-polly-")
@@ -128,9 +176,13 @@ func (s *TTSService) concatenateFiles(ctx context.Context, paths []string) (stri
 
 	// Execute command.
 	args := []string{"-i", "concat:" + strings.Join(paths, "|"), "-c", "copy", path}
-	cmd := exec.Command("ffmpeg", args...)
-	cmd.Stdout = s.LogOutput
-	cmd.Stderr = s.LogOutput
+	output := peapod.NewLogWriter(ctx, s.Logger, "ffmpeg output")
+	defer output.Close()
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Env = peapod.CommandEnv(ctx)
+	cmd.Stdout = output
+	cmd.Stderr = output
 	if err := cmd.Run(); err != nil {
 		return "", err
 	}
@@ -11,8 +11,17 @@ It is generated from these files:
 It has these top-level messages:
 	Job
 	Playlist
+	Retention
+	SmartFilter
+	PlaylistMember
+	PlaylistInvite
 	Track
+	TrackRemoval
 	User
+	LoginCode
+	LoginThrottle
+	UndoAction
+	Session
 */
 package bolt
 
@@ -32,17 +41,19 @@ var _ = math.Inf
 const _ = proto.GoGoProtoPackageIsVersion2 // please upgrade the proto package
 
 type Job struct {
-	ID         int64  `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
-	OwnerID    int64  `protobuf:"varint,2,opt,name=OwnerID,proto3" json:"OwnerID,omitempty"`
-	Type       string `protobuf:"bytes,3,opt,name=Type,proto3" json:"Type,omitempty"`
-	Status     string `protobuf:"bytes,4,opt,name=Status,proto3" json:"Status,omitempty"`
-	PlaylistID int64  `protobuf:"varint,5,opt,name=PlaylistID,proto3" json:"PlaylistID,omitempty"`
-	Title      string `protobuf:"bytes,10,opt,name=Title,proto3" json:"Title,omitempty"`
-	URL        string `protobuf:"bytes,6,opt,name=URL,proto3" json:"URL,omitempty"`
-	Text       string `protobuf:"bytes,11,opt,name=Text,proto3" json:"Text,omitempty"`
-	Error      string `protobuf:"bytes,7,opt,name=Error,proto3" json:"Error,omitempty"`
-	CreatedAt  int64  `protobuf:"varint,8,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
-	UpdatedAt  int64  `protobuf:"varint,9,opt,name=UpdatedAt,proto3" json:"UpdatedAt,omitempty"`
+	ID            int64    `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	OwnerID       int64    `protobuf:"varint,2,opt,name=OwnerID,proto3" json:"OwnerID,omitempty"`
+	Type          string   `protobuf:"bytes,3,opt,name=Type,proto3" json:"Type,omitempty"`
+	Status        string   `protobuf:"bytes,4,opt,name=Status,proto3" json:"Status,omitempty"`
+	PlaylistID    int64    `protobuf:"varint,5,opt,name=PlaylistID,proto3" json:"PlaylistID,omitempty"`
+	Title         string   `protobuf:"bytes,10,opt,name=Title,proto3" json:"Title,omitempty"`
+	URL           string   `protobuf:"bytes,6,opt,name=URL,proto3" json:"URL,omitempty"`
+	Text          string   `protobuf:"bytes,11,opt,name=Text,proto3" json:"Text,omitempty"`
+	Tags          []string `protobuf:"bytes,12,rep,name=Tags" json:"Tags,omitempty"`
+	Error         string   `protobuf:"bytes,7,opt,name=Error,proto3" json:"Error,omitempty"`
+	CreatedAt     int64    `protobuf:"varint,8,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
+	UpdatedAt     int64    `protobuf:"varint,9,opt,name=UpdatedAt,proto3" json:"UpdatedAt,omitempty"`
+	CorrelationID string   `protobuf:"bytes,13,opt,name=CorrelationID,proto3" json:"CorrelationID,omitempty"`
 }
 
 func (m *Job) Reset()                    { *m = Job{} }
@@ -51,12 +62,16 @@ func (*Job) ProtoMessage()               {}
 func (*Job) Descriptor() ([]byte, []int) { return fileDescriptorBolt, []int{0} }
 
 type Playlist struct {
-	ID        int64  `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
-	OwnerID   int64  `protobuf:"varint,2,opt,name=OwnerID,proto3" json:"OwnerID,omitempty"`
-	Token     string `protobuf:"bytes,3,opt,name=Token,proto3" json:"Token,omitempty"`
-	Name      string `protobuf:"bytes,4,opt,name=Name,proto3" json:"Name,omitempty"`
-	CreatedAt int64  `protobuf:"varint,5,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
-	UpdatedAt int64  `protobuf:"varint,6,opt,name=UpdatedAt,proto3" json:"UpdatedAt,omitempty"`
+	ID        int64        `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	OwnerID   int64        `protobuf:"varint,2,opt,name=OwnerID,proto3" json:"OwnerID,omitempty"`
+	Type      string       `protobuf:"bytes,7,opt,name=Type,proto3" json:"Type,omitempty"`
+	Token     string       `protobuf:"bytes,3,opt,name=Token,proto3" json:"Token,omitempty"`
+	Name      string       `protobuf:"bytes,4,opt,name=Name,proto3" json:"Name,omitempty"`
+	Filter    *SmartFilter `protobuf:"bytes,8,opt,name=Filter" json:"Filter,omitempty"`
+	SourceIDs []int64      `protobuf:"varint,9,rep,packed,name=SourceIDs" json:"SourceIDs,omitempty"`
+	Retention *Retention   `protobuf:"bytes,10,opt,name=Retention" json:"Retention,omitempty"`
+	CreatedAt int64        `protobuf:"varint,5,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
+	UpdatedAt int64        `protobuf:"varint,6,opt,name=UpdatedAt,proto3" json:"UpdatedAt,omitempty"`
 }
 
 func (m *Playlist) Reset()                    { *m = Playlist{} }
@@ -64,69 +79,262 @@ func (m *Playlist) String() string            { return proto.CompactTextString(m
 func (*Playlist) ProtoMessage()               {}
 func (*Playlist) Descriptor() ([]byte, []int) { return fileDescriptorBolt, []int{1} }
 
+func (m *Playlist) GetFilter() *SmartFilter {
+	if m != nil {
+		return m.Filter
+	}
+	return nil
+}
+
+func (m *Playlist) GetRetention() *Retention {
+	if m != nil {
+		return m.Retention
+	}
+	return nil
+}
+
+type Retention struct {
+	MaxAge    int64 `protobuf:"varint,1,opt,name=MaxAge,proto3" json:"MaxAge,omitempty"`
+	MaxTracks int64 `protobuf:"varint,2,opt,name=MaxTracks,proto3" json:"MaxTracks,omitempty"`
+	MaxBytes  int64 `protobuf:"varint,3,opt,name=MaxBytes,proto3" json:"MaxBytes,omitempty"`
+}
+
+func (m *Retention) Reset()                    { *m = Retention{} }
+func (m *Retention) String() string            { return proto.CompactTextString(m) }
+func (*Retention) ProtoMessage()               {}
+func (*Retention) Descriptor() ([]byte, []int) { return fileDescriptorBolt, []int{2} }
+
+type SmartFilter struct {
+	Tags        []string `protobuf:"bytes,1,rep,name=Tags" json:"Tags,omitempty"`
+	MaxAge      int64    `protobuf:"varint,2,opt,name=MaxAge,proto3" json:"MaxAge,omitempty"`
+	MinDuration int64    `protobuf:"varint,3,opt,name=MinDuration,proto3" json:"MinDuration,omitempty"`
+	MaxDuration int64    `protobuf:"varint,4,opt,name=MaxDuration,proto3" json:"MaxDuration,omitempty"`
+}
+
+func (m *SmartFilter) Reset()                    { *m = SmartFilter{} }
+func (m *SmartFilter) String() string            { return proto.CompactTextString(m) }
+func (*SmartFilter) ProtoMessage()               {}
+func (*SmartFilter) Descriptor() ([]byte, []int) { return fileDescriptorBolt, []int{3} }
+
+type PlaylistMember struct {
+	PlaylistID int64  `protobuf:"varint,1,opt,name=PlaylistID,proto3" json:"PlaylistID,omitempty"`
+	UserID     int64  `protobuf:"varint,2,opt,name=UserID,proto3" json:"UserID,omitempty"`
+	Role       string `protobuf:"bytes,3,opt,name=Role,proto3" json:"Role,omitempty"`
+	CreatedAt  int64  `protobuf:"varint,4,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
+	UpdatedAt  int64  `protobuf:"varint,5,opt,name=UpdatedAt,proto3" json:"UpdatedAt,omitempty"`
+}
+
+func (m *PlaylistMember) Reset()                    { *m = PlaylistMember{} }
+func (m *PlaylistMember) String() string            { return proto.CompactTextString(m) }
+func (*PlaylistMember) ProtoMessage()               {}
+func (*PlaylistMember) Descriptor() ([]byte, []int) { return fileDescriptorBolt, []int{4} }
+
+type PlaylistInvite struct {
+	ID           int64  `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	PlaylistID   int64  `protobuf:"varint,2,opt,name=PlaylistID,proto3" json:"PlaylistID,omitempty"`
+	InviterID    int64  `protobuf:"varint,3,opt,name=InviterID,proto3" json:"InviterID,omitempty"`
+	MobileNumber string `protobuf:"bytes,4,opt,name=MobileNumber,proto3" json:"MobileNumber,omitempty"`
+	Role         string `protobuf:"bytes,5,opt,name=Role,proto3" json:"Role,omitempty"`
+	CreatedAt    int64  `protobuf:"varint,6,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
+	UpdatedAt    int64  `protobuf:"varint,7,opt,name=UpdatedAt,proto3" json:"UpdatedAt,omitempty"`
+}
+
+func (m *PlaylistInvite) Reset()                    { *m = PlaylistInvite{} }
+func (m *PlaylistInvite) String() string            { return proto.CompactTextString(m) }
+func (*PlaylistInvite) ProtoMessage()               {}
+func (*PlaylistInvite) Descriptor() ([]byte, []int) { return fileDescriptorBolt, []int{5} }
+
 type Track struct {
-	ID          int64  `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
-	PlaylistID  int64  `protobuf:"varint,2,opt,name=PlaylistID,proto3" json:"PlaylistID,omitempty"`
-	Filename    string `protobuf:"bytes,3,opt,name=Filename,proto3" json:"Filename,omitempty"`
-	ContentType string `protobuf:"bytes,4,opt,name=ContentType,proto3" json:"ContentType,omitempty"`
-	Title       string `protobuf:"bytes,5,opt,name=Title,proto3" json:"Title,omitempty"`
-	Description string `protobuf:"bytes,10,opt,name=Description,proto3" json:"Description,omitempty"`
-	Duration    int64  `protobuf:"varint,6,opt,name=Duration,proto3" json:"Duration,omitempty"`
-	FileSize    int64  `protobuf:"varint,7,opt,name=FileSize,proto3" json:"FileSize,omitempty"`
-	CreatedAt   int64  `protobuf:"varint,8,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
-	UpdatedAt   int64  `protobuf:"varint,9,opt,name=UpdatedAt,proto3" json:"UpdatedAt,omitempty"`
+	ID                 int64    `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	PlaylistID         int64    `protobuf:"varint,2,opt,name=PlaylistID,proto3" json:"PlaylistID,omitempty"`
+	Filename           string   `protobuf:"bytes,3,opt,name=Filename,proto3" json:"Filename,omitempty"`
+	ContentType        string   `protobuf:"bytes,4,opt,name=ContentType,proto3" json:"ContentType,omitempty"`
+	Title              string   `protobuf:"bytes,5,opt,name=Title,proto3" json:"Title,omitempty"`
+	Description        string   `protobuf:"bytes,10,opt,name=Description,proto3" json:"Description,omitempty"`
+	Author             string   `protobuf:"bytes,11,opt,name=Author,proto3" json:"Author,omitempty"`
+	Duration           int64    `protobuf:"varint,6,opt,name=Duration,proto3" json:"Duration,omitempty"`
+	FileSize           int64    `protobuf:"varint,7,opt,name=FileSize,proto3" json:"FileSize,omitempty"`
+	TranscriptFilename string   `protobuf:"bytes,12,opt,name=TranscriptFilename,proto3" json:"TranscriptFilename,omitempty"`
+	ChaptersFilename   string   `protobuf:"bytes,13,opt,name=ChaptersFilename,proto3" json:"ChaptersFilename,omitempty"`
+	Tags               []string `protobuf:"bytes,14,rep,name=Tags" json:"Tags,omitempty"`
+	Pinned             bool     `protobuf:"varint,15,opt,name=Pinned,proto3" json:"Pinned,omitempty"`
+	DeletedAt          int64    `protobuf:"varint,16,opt,name=DeletedAt,proto3" json:"DeletedAt,omitempty"`
+	SourceURL          string   `protobuf:"bytes,17,opt,name=SourceURL,proto3" json:"SourceURL,omitempty"`
+	CreatedAt          int64    `protobuf:"varint,8,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
+	UpdatedAt          int64    `protobuf:"varint,9,opt,name=UpdatedAt,proto3" json:"UpdatedAt,omitempty"`
 }
 
 func (m *Track) Reset()                    { *m = Track{} }
 func (m *Track) String() string            { return proto.CompactTextString(m) }
 func (*Track) ProtoMessage()               {}
-func (*Track) Descriptor() ([]byte, []int) { return fileDescriptorBolt, []int{2} }
+func (*Track) Descriptor() ([]byte, []int) { return fileDescriptorBolt, []int{6} }
+
+type TrackRemoval struct {
+	ID         int64  `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	PlaylistID int64  `protobuf:"varint,2,opt,name=PlaylistID,proto3" json:"PlaylistID,omitempty"`
+	TrackID    int64  `protobuf:"varint,3,opt,name=TrackID,proto3" json:"TrackID,omitempty"`
+	Title      string `protobuf:"bytes,4,opt,name=Title,proto3" json:"Title,omitempty"`
+	Filename   string `protobuf:"bytes,5,opt,name=Filename,proto3" json:"Filename,omitempty"`
+	FileSize   int64  `protobuf:"varint,6,opt,name=FileSize,proto3" json:"FileSize,omitempty"`
+	Reason     string `protobuf:"bytes,7,opt,name=Reason,proto3" json:"Reason,omitempty"`
+	CreatedAt  int64  `protobuf:"varint,8,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
+}
+
+func (m *TrackRemoval) Reset()                    { *m = TrackRemoval{} }
+func (m *TrackRemoval) String() string            { return proto.CompactTextString(m) }
+func (*TrackRemoval) ProtoMessage()               {}
+func (*TrackRemoval) Descriptor() ([]byte, []int) { return fileDescriptorBolt, []int{7} }
 
 type User struct {
-	ID           int64  `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
-	MobileNumber string `protobuf:"bytes,2,opt,name=MobileNumber,proto3" json:"MobileNumber,omitempty"`
-	CreatedAt    int64  `protobuf:"varint,3,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
-	UpdatedAt    int64  `protobuf:"varint,4,opt,name=UpdatedAt,proto3" json:"UpdatedAt,omitempty"`
+	ID                      int64  `protobuf:"varint,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	MobileNumber            string `protobuf:"bytes,2,opt,name=MobileNumber,proto3" json:"MobileNumber,omitempty"`
+	CreatedAt               int64  `protobuf:"varint,3,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
+	UpdatedAt               int64  `protobuf:"varint,4,opt,name=UpdatedAt,proto3" json:"UpdatedAt,omitempty"`
+	Name                    string `protobuf:"bytes,5,opt,name=Name,proto3" json:"Name,omitempty"`
+	Timezone                string `protobuf:"bytes,6,opt,name=Timezone,proto3" json:"Timezone,omitempty"`
+	Voice                   string `protobuf:"bytes,7,opt,name=Voice,proto3" json:"Voice,omitempty"`
+	MuteJobNotifications    bool   `protobuf:"varint,8,opt,name=MuteJobNotifications,proto3" json:"MuteJobNotifications,omitempty"`
+	MuteMemberNotifications bool   `protobuf:"varint,9,opt,name=MuteMemberNotifications,proto3" json:"MuteMemberNotifications,omitempty"`
+	DeletionRequestedAt     int64  `protobuf:"varint,10,opt,name=DeletionRequestedAt,proto3" json:"DeletionRequestedAt,omitempty"`
+	Admin                   bool   `protobuf:"varint,11,opt,name=Admin,proto3" json:"Admin,omitempty"`
+	Disabled                bool   `protobuf:"varint,12,opt,name=Disabled,proto3" json:"Disabled,omitempty"`
+	DeletionCodeHash        string `protobuf:"bytes,13,opt,name=DeletionCodeHash,proto3" json:"DeletionCodeHash,omitempty"`
 }
 
 func (m *User) Reset()                    { *m = User{} }
 func (m *User) String() string            { return proto.CompactTextString(m) }
 func (*User) ProtoMessage()               {}
-func (*User) Descriptor() ([]byte, []int) { return fileDescriptorBolt, []int{3} }
+func (*User) Descriptor() ([]byte, []int) { return fileDescriptorBolt, []int{8} }
+
+type LoginCode struct {
+	CodeHash  string `protobuf:"bytes,1,opt,name=CodeHash,proto3" json:"CodeHash,omitempty"`
+	ExpiresAt int64  `protobuf:"varint,3,opt,name=ExpiresAt,proto3" json:"ExpiresAt,omitempty"`
+}
+
+func (m *LoginCode) Reset()                    { *m = LoginCode{} }
+func (m *LoginCode) String() string            { return proto.CompactTextString(m) }
+func (*LoginCode) ProtoMessage()               {}
+func (*LoginCode) Descriptor() ([]byte, []int) { return fileDescriptorBolt, []int{9} }
+
+type LoginThrottle struct {
+	WindowStart int64 `protobuf:"varint,1,opt,name=WindowStart,proto3" json:"WindowStart,omitempty"`
+	Sends       int64 `protobuf:"varint,2,opt,name=Sends,proto3" json:"Sends,omitempty"`
+	Attempts    int64 `protobuf:"varint,3,opt,name=Attempts,proto3" json:"Attempts,omitempty"`
+}
+
+func (m *LoginThrottle) Reset()                    { *m = LoginThrottle{} }
+func (m *LoginThrottle) String() string            { return proto.CompactTextString(m) }
+func (*LoginThrottle) ProtoMessage()               {}
+func (*LoginThrottle) Descriptor() ([]byte, []int) { return fileDescriptorBolt, []int{10} }
+
+type UndoAction struct {
+	Type       string  `protobuf:"bytes,1,opt,name=Type,proto3" json:"Type,omitempty"`
+	PlaylistID int64   `protobuf:"varint,2,opt,name=PlaylistID,proto3" json:"PlaylistID,omitempty"`
+	TrackIDs   []int64 `protobuf:"varint,3,rep,packed,name=TrackIDs" json:"TrackIDs,omitempty"`
+	Reason     string  `protobuf:"bytes,4,opt,name=Reason,proto3" json:"Reason,omitempty"`
+	Token      string  `protobuf:"bytes,5,opt,name=Token,proto3" json:"Token,omitempty"`
+	CreatedAt  int64   `protobuf:"varint,6,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
+}
+
+func (m *UndoAction) Reset()                    { *m = UndoAction{} }
+func (m *UndoAction) String() string            { return proto.CompactTextString(m) }
+func (*UndoAction) ProtoMessage()               {}
+func (*UndoAction) Descriptor() ([]byte, []int) { return fileDescriptorBolt, []int{11} }
+
+type Session struct {
+	UserID    int64 `protobuf:"varint,1,opt,name=UserID,proto3" json:"UserID,omitempty"`
+	CreatedAt int64 `protobuf:"varint,2,opt,name=CreatedAt,proto3" json:"CreatedAt,omitempty"`
+	ExpiresAt int64 `protobuf:"varint,3,opt,name=ExpiresAt,proto3" json:"ExpiresAt,omitempty"`
+}
+
+func (m *Session) Reset()                    { *m = Session{} }
+func (m *Session) String() string            { return proto.CompactTextString(m) }
+func (*Session) ProtoMessage()               {}
+func (*Session) Descriptor() ([]byte, []int) { return fileDescriptorBolt, []int{12} }
 
 func init() {
 	proto.RegisterType((*Job)(nil), "bolt.Job")
 	proto.RegisterType((*Playlist)(nil), "bolt.Playlist")
+	proto.RegisterType((*Retention)(nil), "bolt.Retention")
+	proto.RegisterType((*SmartFilter)(nil), "bolt.SmartFilter")
+	proto.RegisterType((*PlaylistMember)(nil), "bolt.PlaylistMember")
+	proto.RegisterType((*PlaylistInvite)(nil), "bolt.PlaylistInvite")
 	proto.RegisterType((*Track)(nil), "bolt.Track")
+	proto.RegisterType((*TrackRemoval)(nil), "bolt.TrackRemoval")
 	proto.RegisterType((*User)(nil), "bolt.User")
+	proto.RegisterType((*LoginCode)(nil), "bolt.LoginCode")
+	proto.RegisterType((*LoginThrottle)(nil), "bolt.LoginThrottle")
+	proto.RegisterType((*UndoAction)(nil), "bolt.UndoAction")
+	proto.RegisterType((*Session)(nil), "bolt.Session")
 }
 
 func init() { proto.RegisterFile("bolt.proto", fileDescriptorBolt) }
 
 var fileDescriptorBolt = []byte{
-	// 376 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xa4, 0x53, 0xcb, 0x6a, 0xe3, 0x40,
-	0x10, 0x44, 0x4f, 0x5b, 0xed, 0x65, 0x59, 0x86, 0x65, 0x19, 0x96, 0x10, 0x84, 0x4f, 0x3e, 0xe5,
-	0x92, 0x2f, 0x08, 0x56, 0x02, 0x0a, 0x89, 0x13, 0x64, 0xfb, 0x03, 0x24, 0xbb, 0x0f, 0xc2, 0xb2,
-	0x46, 0x8c, 0xc6, 0x89, 0x9d, 0x3f, 0xc8, 0x2f, 0xe4, 0x96, 0x3f, 0x0d, 0xd3, 0x92, 0x65, 0xc9,
-	0x06, 0x43, 0xc8, 0xad, 0xab, 0x9a, 0x56, 0xd5, 0x54, 0x21, 0x80, 0x44, 0x64, 0xea, 0xaa, 0x90,
-	0x42, 0x09, 0x66, 0xeb, 0x79, 0xf8, 0x6e, 0x82, 0x75, 0x2f, 0x12, 0xf6, 0x1b, 0xcc, 0x30, 0xe0,
-	0x86, 0x6f, 0x8c, 0xac, 0xc8, 0x0c, 0x03, 0xc6, 0xa1, 0xf7, 0xf4, 0x9a, 0xa3, 0x0c, 0x03, 0x6e,
-	0x12, 0xb9, 0x87, 0x8c, 0x81, 0x3d, 0xdb, 0x15, 0xc8, 0x2d, 0xdf, 0x18, 0x79, 0x11, 0xcd, 0xec,
-	0x1f, 0xb8, 0x53, 0x15, 0xab, 0x4d, 0xc9, 0x6d, 0x62, 0x6b, 0xc4, 0x2e, 0x01, 0x9e, 0xb3, 0x78,
-	0x97, 0xa5, 0xa5, 0x0a, 0x03, 0xee, 0xd0, 0x87, 0x5a, 0x0c, 0xfb, 0x0b, 0xce, 0x2c, 0x55, 0x19,
-	0x72, 0xa0, 0xb3, 0x0a, 0xb0, 0x3f, 0x60, 0xcd, 0xa3, 0x07, 0xee, 0x12, 0xa7, 0x47, 0xd2, 0xc4,
-	0xad, 0xe2, 0x83, 0x5a, 0x13, 0xb7, 0x4a, 0xdf, 0xde, 0x4a, 0x29, 0x24, 0xef, 0x55, 0xb7, 0x04,
-	0xd8, 0x05, 0x78, 0x63, 0x89, 0xb1, 0xc2, 0xe5, 0x8d, 0xe2, 0x7d, 0x12, 0x3c, 0x10, 0x7a, 0x3b,
-	0x2f, 0x96, 0xf5, 0xd6, 0xab, 0xb6, 0x0d, 0x31, 0xfc, 0x30, 0xa0, 0xbf, 0x37, 0xf7, 0x8d, 0x40,
-	0xf4, 0x23, 0xc4, 0x0a, 0xf3, 0x3a, 0x91, 0x0a, 0x68, 0xcb, 0x93, 0x78, 0x8d, 0x75, 0x20, 0x34,
-	0x77, 0xcd, 0x39, 0x67, 0xcd, 0xb9, 0xc7, 0xe6, 0x3e, 0x4d, 0x70, 0x66, 0x32, 0x5e, 0xac, 0x4e,
-	0x9c, 0x75, 0x43, 0x36, 0x4f, 0x42, 0xfe, 0x0f, 0xfd, 0xbb, 0x34, 0xc3, 0x5c, 0xbb, 0xa9, 0x2c,
-	0x36, 0x98, 0xf9, 0x30, 0x18, 0x8b, 0x5c, 0x61, 0xae, 0xa8, 0xd3, 0xca, 0x6c, 0x9b, 0x3a, 0x54,
-	0xe4, 0xb4, 0x2b, 0xf2, 0x61, 0x10, 0x60, 0xb9, 0x90, 0x69, 0xa1, 0x52, 0x91, 0xd7, 0xf5, 0xb5,
-	0x29, 0xad, 0x1a, 0x6c, 0x64, 0x4c, 0xeb, 0xea, 0x31, 0x0d, 0xde, 0x3b, 0x9a, 0xa6, 0x6f, 0x48,
-	0xed, 0x59, 0x51, 0x83, 0x7f, 0x54, 0xe0, 0x0b, 0xd8, 0xf3, 0x12, 0xe5, 0x49, 0x42, 0x43, 0xf8,
-	0xf5, 0x28, 0x92, 0x34, 0xc3, 0xc9, 0x66, 0x9d, 0xa0, 0xa4, 0x8c, 0xbc, 0xa8, 0xc3, 0x75, 0x75,
-	0xad, 0xb3, 0xba, 0xf6, 0x91, 0x6e, 0xe2, 0xd2, 0x1f, 0x75, 0xfd, 0x15, 0x00, 0x00, 0xff, 0xff,
-	0x14, 0x1e, 0x30, 0x83, 0x5f, 0x03, 0x00, 0x00,
+	// 1027 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xa4, 0x56, 0xdd, 0x6e, 0xe3, 0x44,
+	0x14, 0x96, 0x63, 0x3b, 0x71, 0x4e, 0xda, 0x6e, 0x77, 0x58, 0x15, 0xab, 0x5a, 0xa1, 0xc8, 0xe2,
+	0xa2, 0x20, 0x51, 0xa1, 0x72, 0xc3, 0x6d, 0x68, 0x76, 0x45, 0xca, 0xa6, 0xac, 0x9c, 0x14, 0xae,
+	0xf6, 0x62, 0x92, 0x1c, 0xda, 0xd1, 0x3a, 0x9e, 0x30, 0x9e, 0xec, 0xa6, 0x2b, 0xf1, 0x1e, 0x3c,
+	0x01, 0xe2, 0x19, 0xb8, 0xe5, 0x0d, 0xb8, 0xe6, 0x55, 0x90, 0xd0, 0xfc, 0x78, 0x6c, 0xa7, 0x6d,
+	0xc4, 0xb2, 0x77, 0xfe, 0xbe, 0x19, 0x9f, 0x99, 0xf3, 0x9d, 0xbf, 0x01, 0x98, 0xf1, 0x4c, 0x9e,
+	0xae, 0x04, 0x97, 0x9c, 0x04, 0xea, 0x3b, 0xf9, 0xb3, 0x05, 0xfe, 0x05, 0x9f, 0x91, 0x03, 0x68,
+	0x8d, 0x86, 0xb1, 0xd7, 0xf7, 0x4e, 0xfc, 0xb4, 0x35, 0x1a, 0x92, 0x18, 0x3a, 0xdf, 0xbf, 0xcd,
+	0x51, 0x8c, 0x86, 0x71, 0x4b, 0x93, 0x25, 0x24, 0x04, 0x82, 0xe9, 0xed, 0x0a, 0x63, 0xbf, 0xef,
+	0x9d, 0x74, 0x53, 0xfd, 0x4d, 0x8e, 0xa0, 0x3d, 0x91, 0x54, 0xae, 0x8b, 0x38, 0xd0, 0xac, 0x45,
+	0xe4, 0x13, 0x80, 0x97, 0x19, 0xbd, 0xcd, 0x58, 0x21, 0x47, 0xc3, 0x38, 0xd4, 0x86, 0x6a, 0x0c,
+	0x79, 0x02, 0xe1, 0x94, 0xc9, 0x0c, 0x63, 0xd0, 0xbf, 0x19, 0x40, 0x0e, 0xc1, 0xbf, 0x4a, 0x5f,
+	0xc4, 0x6d, 0xcd, 0xa9, 0x4f, 0x7d, 0x26, 0x6e, 0x64, 0xdc, 0xb3, 0x67, 0xe2, 0x46, 0x6a, 0x8e,
+	0x5e, 0x17, 0xf1, 0x5e, 0xdf, 0xd7, 0x1c, 0xbd, 0x2e, 0x94, 0xbd, 0x67, 0x42, 0x70, 0x11, 0x77,
+	0x8c, 0x3d, 0x0d, 0xc8, 0x53, 0xe8, 0x9e, 0x0b, 0xa4, 0x12, 0x17, 0x03, 0x19, 0x47, 0xfa, 0x12,
+	0x15, 0xa1, 0x56, 0xaf, 0x56, 0x0b, 0xbb, 0xda, 0x35, 0xab, 0x8e, 0x20, 0x9f, 0xc2, 0xfe, 0x39,
+	0x17, 0x02, 0x33, 0x2a, 0x19, 0xcf, 0x47, 0xc3, 0x78, 0x5f, 0x5b, 0x6e, 0x92, 0xc9, 0x6f, 0x2d,
+	0x88, 0x4a, 0xb7, 0xfe, 0x87, 0x94, 0x9d, 0x9a, 0x94, 0x4a, 0x12, 0xfe, 0x1a, 0x73, 0xab, 0xaf,
+	0x01, 0x6a, 0xe7, 0x25, 0x5d, 0xa2, 0x95, 0x57, 0x7f, 0x93, 0xcf, 0xa0, 0xfd, 0x9c, 0x65, 0x12,
+	0x85, 0xf6, 0xa9, 0x77, 0xf6, 0xf8, 0x54, 0x47, 0x77, 0xb2, 0xa4, 0x42, 0x9a, 0x85, 0xd4, 0x6e,
+	0x50, 0x3e, 0x4e, 0xf8, 0x5a, 0xcc, 0x71, 0x34, 0x2c, 0xe2, 0x6e, 0xdf, 0x57, 0x3e, 0x3a, 0x82,
+	0x7c, 0x01, 0xdd, 0x14, 0x25, 0xe6, 0xca, 0x19, 0x1d, 0x89, 0xde, 0xd9, 0x23, 0x63, 0xcb, 0xd1,
+	0x69, 0xb5, 0xa3, 0x29, 0x67, 0xb8, 0x53, 0xce, 0xf6, 0x96, 0x9c, 0xc9, 0xab, 0xda, 0x51, 0x2a,
+	0x6b, 0xc6, 0x74, 0x33, 0xb8, 0x46, 0x2b, 0x96, 0x45, 0xca, 0xc4, 0x98, 0x6e, 0xa6, 0x82, 0xce,
+	0x5f, 0x17, 0x56, 0xb2, 0x8a, 0x20, 0xc7, 0x10, 0x8d, 0xe9, 0xe6, 0x9b, 0x5b, 0x89, 0x85, 0xd6,
+	0xc8, 0x4f, 0x1d, 0x4e, 0x7e, 0x81, 0x5e, 0xcd, 0x7d, 0x97, 0x22, 0x5e, 0x2d, 0x45, 0xaa, 0x43,
+	0x5b, 0x8d, 0x43, 0xfb, 0xd0, 0x1b, 0xb3, 0x7c, 0xb8, 0x16, 0x3a, 0xa6, 0xd6, 0x72, 0x9d, 0xd2,
+	0x3b, 0xe8, 0xc6, 0xed, 0x08, 0xec, 0x8e, 0x8a, 0x4a, 0x7e, 0xf5, 0xe0, 0xa0, 0x4c, 0x83, 0x31,
+	0x2e, 0x67, 0x28, 0xb6, 0x2a, 0xc0, 0xbb, 0x53, 0x01, 0x47, 0xd0, 0xbe, 0x2a, 0x6a, 0xb9, 0x61,
+	0x91, 0xba, 0x7a, 0xca, 0x33, 0x57, 0x65, 0xea, 0xbb, 0x29, 0x7c, 0xb0, 0x53, 0xf8, 0x70, 0x5b,
+	0xf8, 0xbf, 0x6a, 0x57, 0x1b, 0xe5, 0x6f, 0x98, 0xc4, 0x3b, 0x79, 0xda, 0xbc, 0x6a, 0xeb, 0xce,
+	0x55, 0x9f, 0x42, 0xd7, 0xfc, 0xa9, 0x6e, 0x6b, 0xf4, 0xa9, 0x08, 0x92, 0xc0, 0xde, 0x98, 0xcf,
+	0x58, 0x86, 0x97, 0x6b, 0xe5, 0xb8, 0xcd, 0xd4, 0x06, 0xe7, 0x9c, 0x0a, 0x1f, 0x72, 0xaa, 0xbd,
+	0xd3, 0xa9, 0xce, 0xb6, 0x53, 0xff, 0xf8, 0x10, 0xea, 0xac, 0x78, 0x6f, 0x5f, 0x8e, 0x21, 0x7a,
+	0xce, 0x32, 0xcc, 0x55, 0x4d, 0x19, 0x89, 0x1d, 0x56, 0x71, 0x3e, 0xe7, 0xb9, 0x4a, 0x52, 0x5d,
+	0x9c, 0xc6, 0x91, 0x3a, 0x55, 0xb5, 0xad, 0xb0, 0xde, 0xb6, 0xfa, 0xd0, 0x1b, 0x62, 0x31, 0x17,
+	0x6c, 0xe5, 0x0a, 0xa9, 0x9b, 0xd6, 0x29, 0x15, 0xec, 0xc1, 0x5a, 0xde, 0x70, 0x61, 0x1b, 0x99,
+	0x45, 0xea, 0x36, 0x2e, 0xad, 0x8c, 0x04, 0x0e, 0x97, 0x37, 0x9d, 0xb0, 0x77, 0x68, 0x05, 0x70,
+	0x98, 0x9c, 0x02, 0x99, 0x0a, 0x9a, 0x9b, 0x03, 0x9c, 0x3f, 0x7b, 0xda, 0xf6, 0x3d, 0x2b, 0xe4,
+	0x73, 0x38, 0x3c, 0xbf, 0xa1, 0x2b, 0x89, 0xa2, 0x70, 0xbb, 0x4d, 0x3f, 0xbb, 0xc3, 0xbb, 0xda,
+	0x39, 0x68, 0xd6, 0xce, 0x4b, 0x96, 0xe7, 0xb8, 0x88, 0x1f, 0xf5, 0xbd, 0x93, 0x28, 0xb5, 0x48,
+	0x45, 0x69, 0x88, 0x19, 0x9a, 0x28, 0x1d, 0x9a, 0x28, 0x39, 0xa2, 0x6a, 0x3e, 0xaa, 0xa9, 0x3f,
+	0xd6, 0xc7, 0x55, 0xc4, 0x87, 0x34, 0xe7, 0xe4, 0x6f, 0x0f, 0xf6, 0x74, 0xfc, 0x53, 0x5c, 0xf2,
+	0x37, 0x34, 0x7b, 0xef, 0x34, 0x88, 0xa1, 0xa3, 0xff, 0x77, 0x09, 0x5d, 0xc2, 0x2a, 0xc4, 0x41,
+	0x3d, 0xc4, 0xf5, 0xb4, 0x09, 0xb7, 0xd2, 0xa6, 0x1e, 0xa8, 0xf6, 0x56, 0xa0, 0x8e, 0xa0, 0x9d,
+	0x22, 0x2d, 0x78, 0x6e, 0x5b, 0xbd, 0x45, 0xbb, 0x9d, 0x4f, 0xfe, 0xf0, 0x21, 0x50, 0xed, 0xe0,
+	0x8e, 0x5b, 0xdb, 0xb5, 0xd6, 0xba, 0xa7, 0xd6, 0x1a, 0xa6, 0xfd, 0x9d, 0xba, 0x06, 0xdb, 0x43,
+	0xaf, 0x9c, 0x36, 0x61, 0x6d, 0xda, 0x1c, 0x43, 0x34, 0x65, 0x4b, 0x7c, 0xc7, 0x73, 0xb4, 0x93,
+	0xd9, 0x61, 0x25, 0xd6, 0x0f, 0x9c, 0xcd, 0xcb, 0x41, 0x66, 0x00, 0x39, 0x83, 0x27, 0xe3, 0xb5,
+	0xc4, 0x0b, 0x3e, 0xbb, 0xe4, 0x92, 0xfd, 0xc4, 0xe6, 0x3a, 0xa1, 0x0b, 0xed, 0x67, 0x94, 0xde,
+	0xbb, 0x46, 0xbe, 0x86, 0x8f, 0x15, 0x6f, 0x9a, 0x67, 0xf3, 0xb7, 0xae, 0xfe, 0xed, 0xa1, 0x65,
+	0xf2, 0x25, 0x7c, 0xa4, 0x53, 0x4e, 0x0d, 0x2b, 0xfc, 0x79, 0x8d, 0x85, 0xf1, 0x0d, 0xb4, 0x6f,
+	0xf7, 0x2d, 0xa9, 0x5b, 0x0f, 0x16, 0x4b, 0x96, 0xeb, 0x62, 0x8c, 0x52, 0x03, 0x74, 0x2d, 0xb2,
+	0x82, 0xce, 0x32, 0x5c, 0xe8, 0x4a, 0x8a, 0x52, 0x87, 0x55, 0xfd, 0x94, 0x86, 0xce, 0xf9, 0x02,
+	0xbf, 0xa5, 0xc5, 0x4d, 0x59, 0x3f, 0xdb, 0x7c, 0xf2, 0x1d, 0x74, 0x5f, 0xf0, 0x6b, 0xa6, 0x09,
+	0x65, 0xd4, 0xfd, 0xe0, 0x19, 0xf1, 0x4a, 0xac, 0x42, 0xf1, 0x6c, 0xb3, 0x62, 0x02, 0x8b, 0x2a,
+	0x50, 0x8e, 0xb8, 0x08, 0xa2, 0xd6, 0xa1, 0x9f, 0xcc, 0x61, 0x5f, 0x1b, 0x9b, 0xde, 0x08, 0x2e,
+	0x6d, 0xaf, 0xf9, 0x91, 0xe5, 0x0b, 0xfe, 0x76, 0x22, 0xa9, 0x90, 0x36, 0x35, 0xea, 0x94, 0xf2,
+	0x6e, 0x82, 0xf9, 0xa2, 0x1c, 0xa0, 0x06, 0xa8, 0x8b, 0x0c, 0xa4, 0xc4, 0xe5, 0x4a, 0xba, 0xe1,
+	0x59, 0xe2, 0xe4, 0x77, 0x0f, 0xe0, 0x2a, 0x5f, 0xf0, 0xc1, 0x5c, 0x37, 0x9e, 0xf2, 0x71, 0xe2,
+	0xd5, 0x1e, 0x27, 0xff, 0xa1, 0xad, 0xda, 0x02, 0x52, 0xe6, 0xd5, 0x33, 0xc3, 0xe1, 0x5a, 0x0d,
+	0x04, 0x8d, 0x1a, 0x70, 0x0f, 0x9e, 0xb0, 0xfe, 0xe0, 0xd9, 0x39, 0x16, 0x92, 0x57, 0xd0, 0x99,
+	0x60, 0x51, 0xd8, 0x9e, 0x6a, 0x07, 0xa8, 0xd7, 0x18, 0xa0, 0x0d, 0x03, 0xad, 0x7b, 0xf2, 0xff,
+	0x61, 0xd1, 0x67, 0x6d, 0xfd, 0x42, 0xfe, 0xea, 0xdf, 0x01, 0x00, 0xfd, 0x6b, 0xd7, 0x42, 0x2f,
+	0x0b, 0x00, 0x00,
 }
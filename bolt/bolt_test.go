@@ -0,0 +1,29 @@
+package bolt_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/bolt"
+)
+
+// MustCreateUser creates a user with a mobile number and returns a context
+// authenticated as the user along with the user's default playlist.
+func MustCreateUser(tb testing.TB, db *DB, mobileNumber string) (context.Context, *peapod.User, *peapod.Playlist) {
+	tb.Helper()
+
+	user := &peapod.User{MobileNumber: mobileNumber}
+	if err := bolt.NewUserService(db.DB).CreateUser(peapod.NewSystemContext(context.Background()), user); err != nil {
+		tb.Fatal(err)
+	}
+	ctx := peapod.NewContext(context.Background(), user)
+
+	playlists, err := bolt.NewPlaylistService(db.DB).FindPlaylistsByUserID(ctx, user.ID)
+	if err != nil {
+		tb.Fatal(err)
+	} else if len(playlists) == 0 {
+		tb.Fatal("default playlist not found")
+	}
+	return ctx, user, playlists[0]
+}
@@ -2,6 +2,7 @@ package bolt
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"time"
@@ -10,6 +11,10 @@ import (
 	"github.com/middlemost/peapod"
 )
 
+// ErrDatabaseLocked is returned by Open when another process, such as a
+// running daemon, holds the database lock.
+const ErrDatabaseLocked = peapod.Error("database locked by another process")
+
 // DB represents a handle to a Bolt database.
 type DB struct {
 	db *bolt.DB
@@ -36,7 +41,9 @@ func (db *DB) Open() error {
 
 	// Open bolt database.
 	d, err := bolt.Open(db.Path, 0600, &bolt.Options{Timeout: 1 * time.Second})
-	if err != nil {
+	if err == bolt.ErrTimeout {
+		return ErrDatabaseLocked
+	} else if err != nil {
 		return err
 	}
 	db.db = d
@@ -52,6 +59,21 @@ func (db *DB) Close() error {
 	return nil
 }
 
+// CheckHealth verifies that a read transaction can be started.
+func (db *DB) CheckHealth(ctx context.Context) (string, error) {
+	if db.db == nil {
+		return "", bolt.ErrDatabaseNotOpen
+	}
+
+	tx, err := db.db.Begin(false)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	return fmt.Sprintf("size=%d", tx.Size()), nil
+}
+
 // Begin starts a new transaction.
 func (db *DB) Begin(ctx context.Context, writable bool) (*Tx, error) {
 	tx, err := db.db.Begin(writable)
@@ -70,6 +92,14 @@ func (db *DB) BeginAuth(ctx context.Context, writable bool) (*Tx, error) {
 	return db.Begin(ctx, writable)
 }
 
+// BeginSystem starts a new transaction and verifies that ctx is a system context.
+func (db *DB) BeginSystem(ctx context.Context, writable bool) (*Tx, error) {
+	if !peapod.IsSystemContext(ctx) {
+		return nil, peapod.ErrUnauthorized
+	}
+	return db.Begin(ctx, writable)
+}
+
 // Tx is a wrapper for bolt.Tx.
 type Tx struct {
 	*bolt.Tx
@@ -1,10 +1,13 @@
 package bolt_test
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
+	"testing"
 	"time"
 
+	"github.com/middlemost/peapod"
 	"github.com/middlemost/peapod/bolt"
 )
 
@@ -51,3 +54,186 @@ func (db *DB) MustClose() {
 		panic(err)
 	}
 }
+
+// Ensure services reject access to records owned by other users and that
+// system contexts can access all records.
+func TestDB_Authorization(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	jobService := bolt.NewJobService(db.DB)
+	playlistService := bolt.NewPlaylistService(db.DB)
+	sessionService := bolt.NewSessionService(db.DB)
+	trackService := bolt.NewTrackService(db.DB)
+	userService := bolt.NewUserService(db.DB)
+
+	// Create alice with a track & a queued job. Bob is another user.
+	sysCtx := peapod.NewSystemContext(context.Background())
+	aliceCtx, alice, playlist := MustCreateUser(t, db, "+15555550100")
+	bobCtx, bob, _ := MustCreateUser(t, db, "+15555550101")
+	playlistID := playlist.ID
+
+	track := &peapod.Track{PlaylistID: playlistID, Filename: "a.mp3", Title: "A"}
+	if err := trackService.CreateTrack(aliceCtx, track); err != nil {
+		t.Fatal(err)
+	}
+	job := &peapod.Job{OwnerID: alice.ID, Type: peapod.JobTypeCreateTrackFromURL, PlaylistID: playlistID, URL: "https://example.com"}
+	if err := jobService.CreateJob(aliceCtx, job); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		fn   func(ctx context.Context) error
+	}{
+		{"FindPlaylistByID", func(ctx context.Context) error {
+			_, err := playlistService.FindPlaylistByID(ctx, playlistID)
+			return err
+		}},
+		{"FindPlaylistsByUserID", func(ctx context.Context) error {
+			_, err := playlistService.FindPlaylistsByUserID(ctx, alice.ID)
+			return err
+		}},
+		{"FindPlaylistMembers", func(ctx context.Context) error {
+			_, err := playlistService.FindPlaylistMembers(ctx, playlistID)
+			return err
+		}},
+		{"FindPlaylistInvitesByMobileNumber", func(ctx context.Context) error {
+			_, err := playlistService.FindPlaylistInvitesByMobileNumber(ctx, alice.MobileNumber)
+			return err
+		}},
+		{"FindPlaylistsWithRetention", func(ctx context.Context) error {
+			_, err := playlistService.FindPlaylistsWithRetention(ctx)
+			return err
+		}},
+		{"SetPlaylistRetention", func(ctx context.Context) error {
+			return playlistService.SetPlaylistRetention(ctx, playlistID, &peapod.Retention{MaxTracks: 1})
+		}},
+		{"RotateToken", func(ctx context.Context) error {
+			_, err := playlistService.RotateToken(ctx, playlistID)
+			return err
+		}},
+		{"RemoveExpiredTokens", func(ctx context.Context) error {
+			return playlistService.RemoveExpiredTokens(ctx)
+		}},
+		{"CreatePlaylistInvite", func(ctx context.Context) error {
+			return playlistService.CreatePlaylistInvite(ctx, &peapod.PlaylistInvite{PlaylistID: playlistID, MobileNumber: "+15555550102", Role: peapod.PlaylistRoleListener})
+		}},
+		{"FindTrackByID", func(ctx context.Context) error {
+			_, err := trackService.FindTrackByID(ctx, track.ID)
+			return err
+		}},
+		{"FindTrackRemovals", func(ctx context.Context) error {
+			_, err := trackService.FindTrackRemovals(ctx, playlistID)
+			return err
+		}},
+		{"CreateTrack", func(ctx context.Context) error {
+			return trackService.CreateTrack(ctx, &peapod.Track{PlaylistID: playlistID, Filename: "b.mp3"})
+		}},
+		{"DeleteTrack", func(ctx context.Context) error {
+			return trackService.DeleteTrack(ctx, track.ID, peapod.TrackRemovalReasonUser)
+		}},
+		{"SetTrackPinned", func(ctx context.Context) error {
+			return trackService.SetTrackPinned(ctx, track.ID, true)
+		}},
+		{"PurgeTrash", func(ctx context.Context) error {
+			_, err := trackService.PurgeTrash(ctx)
+			return err
+		}},
+		{"FindUserByID", func(ctx context.Context) error {
+			_, err := userService.FindUserByID(ctx, alice.ID)
+			return err
+		}},
+		{"FindUserByMobileNumber", func(ctx context.Context) error {
+			_, err := userService.FindUserByMobileNumber(ctx, alice.MobileNumber)
+			return err
+		}},
+		{"CreateUser", func(ctx context.Context) error {
+			return userService.CreateUser(ctx, &peapod.User{MobileNumber: "+15555550103"})
+		}},
+		{"UpdateUser", func(ctx context.Context) error {
+			name := "Mallory"
+			_, err := userService.UpdateUser(ctx, alice.ID, peapod.UserUpdate{Name: &name})
+			return err
+		}},
+		{"RequestUserDeletion", func(ctx context.Context) error {
+			_, err := userService.RequestUserDeletion(ctx, alice.ID)
+			return err
+		}},
+		{"ConfirmUserDeletion", func(ctx context.Context) error {
+			_, err := userService.ConfirmUserDeletion(ctx, alice.ID, "000000")
+			return err
+		}},
+		{"NormalizeMobileNumbers", func(ctx context.Context) error {
+			_, _, err := userService.NormalizeMobileNumbers(ctx, "US")
+			return err
+		}},
+		{"CreateJob", func(ctx context.Context) error {
+			return jobService.CreateJob(ctx, &peapod.Job{OwnerID: alice.ID, Type: peapod.JobTypeCreateTrackFromURL, PlaylistID: playlistID})
+		}},
+		{"CreateJob_Playlist", func(ctx context.Context) error {
+			return jobService.CreateJob(ctx, &peapod.Job{OwnerID: bob.ID, Type: peapod.JobTypeCreateTrackFromURL, PlaylistID: playlistID})
+		}},
+		{"NextJob", func(ctx context.Context) error {
+			_, err := jobService.NextJob(ctx)
+			return err
+		}},
+		{"CompleteJob", func(ctx context.Context) error {
+			return jobService.CompleteJob(ctx, job.ID, "", nil)
+		}},
+		{"ResetJobQueue", func(ctx context.Context) error {
+			return jobService.ResetJobQueue(ctx)
+		}},
+		{"FindJobs", func(ctx context.Context) error {
+			_, err := jobService.FindJobs(ctx, peapod.JobFilter{})
+			return err
+		}},
+		{"RetryJob", func(ctx context.Context) error {
+			_, err := jobService.RetryJob(ctx, job.ID)
+			return err
+		}},
+		{"FindJobQueueStats", func(ctx context.Context) error {
+			_, err := jobService.FindJobQueueStats(ctx)
+			return err
+		}},
+		{"PurgeTrack", func(ctx context.Context) error {
+			_, err := trackService.PurgeTrack(ctx, track.ID)
+			return err
+		}},
+		{"FindUsers", func(ctx context.Context) error {
+			_, err := userService.FindUsers(ctx, peapod.UserFilter{})
+			return err
+		}},
+		{"UpdateUserAccess", func(ctx context.Context) error {
+			admin := true
+			_, err := userService.UpdateUserAccess(ctx, bob.ID, peapod.UserAccessUpdate{Admin: &admin})
+			return err
+		}},
+		{"RemoveExpiredSessions", func(ctx context.Context) error {
+			return sessionService.RemoveExpiredSessions(ctx)
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(bobCtx); err != peapod.ErrUnauthorized {
+				t.Fatalf("unexpected error for other user: %v", err)
+			} else if err := tt.fn(context.Background()); err != peapod.ErrUnauthorized {
+				t.Fatalf("unexpected error for anonymous user: %v", err)
+			}
+		})
+	}
+
+	// System contexts can read records owned by any user.
+	if p, err := playlistService.FindPlaylistByID(sysCtx, playlistID); err != nil {
+		t.Fatal(err)
+	} else if p == nil || p.OwnerID != alice.ID {
+		t.Fatalf("unexpected playlist: %#v", p)
+	} else if other, err := trackService.FindTrackByID(sysCtx, track.ID); err != nil {
+		t.Fatal(err)
+	} else if other == nil {
+		t.Fatal("expected track")
+	} else if other, err := jobService.NextJob(sysCtx); err != nil {
+		t.Fatal(err)
+	} else if other == nil || other.ID != job.ID {
+		t.Fatalf("unexpected job: %#v", other)
+	}
+}
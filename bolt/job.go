@@ -2,6 +2,9 @@ package bolt
 
 import (
 	"context"
+	"crypto/subtle"
+	"sync"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/middlemost/peapod"
@@ -10,26 +13,45 @@ import (
 // Ensure service implement interface.
 var _ peapod.JobService = &JobService{}
 
+// DefaultJobLeaseTimeout is the default time a job remains claimed without a heartbeat.
+const DefaultJobLeaseTimeout = 2 * time.Minute
+
 // JobService represents a service for creating and processing jobs.
 type JobService struct {
 	db *DB
 
 	c chan struct{}
+
+	// Leases on processing jobs, by job id. Leases are not persisted as the
+	// job queue is reset when the process starts.
+	mu     sync.Mutex
+	leases map[int]jobLease
+
+	// Time after a claim or heartbeat until a processing job is requeued.
+	LeaseTimeout time.Duration
 }
 
 // NewJobService returns a new instance of JobService.
 func NewJobService(db *DB) *JobService {
 	return &JobService{
-		db: db,
-		c:  make(chan struct{}, 1),
+		db:           db,
+		c:            make(chan struct{}, 1),
+		leases:       make(map[int]jobLease),
+		LeaseTimeout: DefaultJobLeaseTimeout,
 	}
 }
 
 // C returns a channel that sends notifications of new jobs.
 func (s *JobService) C() <-chan struct{} { return s.c }
 
-// CreateJob creates adds a job to the job queue.
+// CreateJob creates adds a job to the job queue. The job must be owned by
+// the current user who must be able to contribute to the job's playlist.
+// If not set, the job's correlation id is read from ctx.
 func (s *JobService) CreateJob(ctx context.Context, job *peapod.Job) error {
+	if job.CorrelationID == "" {
+		job.CorrelationID = peapod.CorrelationIDFromContext(ctx)
+	}
+
 	tx, err := s.db.Begin(ctx, true)
 	if err != nil {
 		return err
@@ -38,6 +60,14 @@ func (s *JobService) CreateJob(ctx context.Context, job *peapod.Job) error {
 
 	// Create job & commit.
 	if err := func() error {
+		if err := authorizeUser(ctx, job.OwnerID); err != nil {
+			return err
+		} else if job.PlaylistID != 0 {
+			if err := authorizePlaylistRole(ctx, tx, job.PlaylistID, peapod.CanContribute); err != nil {
+				return err
+			}
+		}
+
 		if err := createJob(ctx, tx, job); err != nil {
 			return err
 		} else if err := tx.Commit(); err != nil {
@@ -59,18 +89,39 @@ func (s *JobService) CreateJob(ctx context.Context, job *peapod.Job) error {
 }
 
 // NextJob returns the next job in the job queue and marks it as started.
+// Processing jobs whose lease has expired are returned to the queue first.
+// Requires a system context.
 func (s *JobService) NextJob(ctx context.Context) (*peapod.Job, error) {
-	tx, err := s.db.Begin(ctx, true)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginSystem(ctx, true)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	// Requeue jobs abandoned by their worker.
+	var expired []int
+	for id, l := range s.leases {
+		if !tx.Now.Before(l.expiresAt) {
+			expired = append(expired, id)
+		}
+	}
+	for _, id := range expired {
+		if err := requeueJob(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+
 	// Retrieve next job id.
 	job, err := nextJob(ctx, tx)
 	if err != nil {
 		return nil, err
 	} else if job == nil {
+		for _, id := range expired {
+			delete(s.leases, id)
+		}
 		return nil, nil
 	}
 
@@ -98,17 +149,64 @@ func (s *JobService) NextJob(ctx context.Context) (*peapod.Job, error) {
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
+
+	// Release expired leases & claim the job.
+	for _, id := range expired {
+		delete(s.leases, id)
+	}
+	job.Lease = tx.GenerateToken()
+	s.leases[job.ID] = jobLease{token: job.Lease, expiresAt: tx.Now.Add(s.LeaseTimeout)}
+
 	return job, nil
 }
 
-// CompleteJob marks a job as completed or failed.
-func (s *JobService) CompleteJob(ctx context.Context, id int, e error) error {
-	tx, err := s.db.Begin(ctx, true)
+// HeartbeatJob extends the lease on a processing job. Returns
+// ErrJobNotProcessing if the job has completed or the lease has expired or
+// been replaced by another claim. Requires a system context.
+func (s *JobService) HeartbeatJob(ctx context.Context, id int, lease string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginSystem(ctx, false)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	if job, err := findJobByID(ctx, tx, id); err != nil {
+		return err
+	} else if job == nil {
+		return peapod.ErrJobNotFound
+	}
+
+	if !s.holdsLease(tx, id, lease) {
+		return peapod.ErrJobNotProcessing
+	}
+	s.leases[id] = jobLease{token: lease, expiresAt: tx.Now.Add(s.LeaseTimeout)}
+	return nil
+}
+
+// CompleteJob marks a job as completed or failed. Returns ErrJobNotProcessing
+// if the lease has expired or been replaced by another claim so that a job
+// requeued from a slow worker is only completed once. Requires a system context.
+func (s *JobService) CompleteJob(ctx context.Context, id int, lease string, e error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.BeginSystem(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if job, err := findJobByID(ctx, tx, id); err != nil {
+		return err
+	} else if job == nil {
+		return peapod.ErrJobNotFound
+	} else if !s.holdsLease(tx, id, lease) {
+		return peapod.ErrJobNotProcessing
+	}
+
 	// Determine status based on error.
 	status := peapod.JobStatusCompleted
 	if e != nil {
@@ -121,14 +219,29 @@ func (s *JobService) CompleteJob(ctx context.Context, id int, e error) error {
 	} else if err := tx.Commit(); err != nil {
 		return err
 	}
+	delete(s.leases, id)
 
 	return nil
 }
 
+// holdsLease returns true if lease is the unexpired lease on a job.
+// Must be called while holding s.mu.
+func (s *JobService) holdsLease(tx *Tx, id int, lease string) bool {
+	l, ok := s.leases[id]
+	return ok && lease != "" && subtle.ConstantTimeCompare([]byte(lease), []byte(l.token)) == 1 && tx.Now.Before(l.expiresAt)
+}
+
+// jobLease represents a worker's claim on a processing job.
+type jobLease struct {
+	token     string
+	expiresAt time.Time
+}
+
 // ResetJobQueue resets all queued jobs to a pending status.
 // This should be called when the process starts so that all jobs are restarted.
+// Requires a system context.
 func (s *JobService) ResetJobQueue(ctx context.Context) error {
-	tx, err := s.db.Begin(ctx, true)
+	tx, err := s.db.BeginSystem(ctx, true)
 	if err != nil {
 		return err
 	}
@@ -148,7 +261,127 @@ func (s *JobService) ResetJobQueue(ctx context.Context) error {
 		}
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.leases = make(map[int]jobLease)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// FindJobs returns jobs matching filter, newest first. Requires a system context.
+func (s *JobService) FindJobs(ctx context.Context, filter peapod.JobFilter) ([]*peapod.Job, error) {
+	tx, err := s.db.BeginSystem(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return findJobs(ctx, tx, filter)
+}
+
+// RetryJob moves a failed job back to the end of the job queue.
+// Requires a system context.
+func (s *JobService) RetryJob(ctx context.Context, id int) (*peapod.Job, error) {
+	tx, err := s.db.BeginSystem(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Only failed jobs can be retried.
+	job, err := findJobByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if job == nil {
+		return nil, peapod.ErrJobNotFound
+	} else if job.Status != peapod.JobStatusFailed {
+		return nil, peapod.ErrJobNotRetryable
+	}
+
+	// Reset status & re-queue.
+	if err := setJobStatus(ctx, tx, job.ID, peapod.JobStatusPending, nil); err != nil {
+		return nil, err
+	} else if err := addJobToQueue(ctx, tx, job.ID); err != nil {
+		return nil, err
+	} else if job, err = findJobByID(ctx, tx, job.ID); err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Signal change notification.
+	select {
+	case s.c <- struct{}{}:
+	default:
+	}
+
+	return job, nil
+}
+
+// CancelJob removes a pending job from the job queue.
+// Requires a system context.
+func (s *JobService) CancelJob(ctx context.Context, id int) (*peapod.Job, error) {
+	tx, err := s.db.BeginSystem(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Jobs which have started cannot be canceled.
+	job, err := findJobByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if job == nil {
+		return nil, peapod.ErrJobNotFound
+	} else if job.Status != peapod.JobStatusPending {
+		return nil, peapod.ErrJobNotCancelable
+	}
+
+	if err := setJobStatus(ctx, tx, job.ID, peapod.JobStatusCanceled, nil); err != nil {
+		return nil, err
+	} else if job, err = findJobByID(ctx, tx, job.ID); err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// FindJobQueueStats returns the number of queued jobs by status.
+// Requires a system context.
+func (s *JobService) FindJobQueueStats(ctx context.Context) (*peapod.JobQueueStats, error) {
+	tx, err := s.db.BeginSystem(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stats := &peapod.JobQueueStats{}
+	bkt := tx.Bucket([]byte("JobQueue"))
+	if bkt == nil {
+		return stats, nil
+	}
+
+	cur := bkt.Cursor()
+	for k, v := cur.First(); k != nil; k, v = cur.Next() {
+		job, err := findJobByID(ctx, tx, btoi(v))
+		if err != nil {
+			return nil, err
+		} else if job == nil {
+			continue
+		}
+
+		switch job.Status {
+		case peapod.JobStatusPending:
+			stats.Pending++
+		case peapod.JobStatusProcessing:
+			stats.Processing++
+		}
+	}
+	return stats, nil
 }
 
 func findJobByID(ctx context.Context, tx *Tx, id int) (*peapod.Job, error) {
@@ -166,6 +399,46 @@ func findJobByID(ctx context.Context, tx *Tx, id int) (*peapod.Job, error) {
 	return &job, nil
 }
 
+// findJobs returns a page of jobs matching filter, newest first.
+func findJobs(ctx context.Context, tx *Tx, filter peapod.JobFilter) ([]*peapod.Job, error) {
+	if filter.Status != "" && !peapod.IsValidJobStatus(filter.Status) {
+		return nil, peapod.ErrInvalidJobStatus
+	}
+
+	// Normalize pagination.
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = peapod.DefaultJobLimit
+	} else if limit > peapod.MaxJobLimit {
+		limit = peapod.MaxJobLimit
+	}
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	a := make([]*peapod.Job, 0)
+	bkt := tx.Bucket([]byte("Jobs"))
+	if bkt == nil {
+		return a, nil
+	}
+
+	cur := bkt.Cursor()
+	for k, v := cur.Last(); k != nil && len(a) < limit; k, v = cur.Prev() {
+		var job peapod.Job
+		if err := unmarshalJob(v, &job); err != nil {
+			return nil, err
+		} else if filter.Status != "" && job.Status != filter.Status {
+			continue
+		} else if offset > 0 {
+			offset--
+			continue
+		}
+		a = append(a, &job)
+	}
+	return a, nil
+}
+
 func jobExists(ctx context.Context, tx *Tx, id int) bool {
 	bkt := tx.Bucket([]byte("Jobs"))
 	if bkt == nil {
@@ -239,7 +512,7 @@ func setJobStatus(ctx context.Context, tx *Tx, id int, status string, e error) e
 
 	// If status is a completion status then remove from the job queue.
 	switch status {
-	case peapod.JobStatusCompleted, peapod.JobStatusFailed:
+	case peapod.JobStatusCompleted, peapod.JobStatusFailed, peapod.JobStatusCanceled:
 		if err := removeJobFromQueue(ctx, tx, job.ID); err != nil {
 			return err
 		}
@@ -255,6 +528,74 @@ func setJobStatus(ctx context.Context, tx *Tx, id int, status string, e error) e
 	return nil
 }
 
+// deleteJobsByOwnerID removes all jobs owned by a user from the job list & queue.
+func deleteJobsByOwnerID(ctx context.Context, tx *Tx, ownerID int) error {
+	bkt := tx.Bucket([]byte("Jobs"))
+	if bkt == nil {
+		return nil
+	}
+
+	var ids []int
+	cur := bkt.Cursor()
+	for k, v := cur.First(); k != nil; k, v = cur.Next() {
+		var job peapod.Job
+		if err := unmarshalJob(v, &job); err != nil {
+			return err
+		} else if job.OwnerID == ownerID {
+			ids = append(ids, job.ID)
+		}
+	}
+
+	for _, id := range ids {
+		if err := removeJobFromQueue(ctx, tx, id); err != nil {
+			return err
+		} else if err := bkt.Delete(itob(id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelJobsByPlaylistIDs cancels pending jobs which add tracks to any of
+// the given playlists. Used when the playlists are deleted so that jobs queued
+// by other members do not run against a missing playlist.
+func cancelJobsByPlaylistIDs(ctx context.Context, tx *Tx, playlistIDs map[int]struct{}) error {
+	bkt := tx.Bucket([]byte("Jobs"))
+	if bkt == nil {
+		return nil
+	}
+
+	var ids []int
+	cur := bkt.Cursor()
+	for k, v := cur.First(); k != nil; k, v = cur.Next() {
+		var job peapod.Job
+		if err := unmarshalJob(v, &job); err != nil {
+			return err
+		} else if _, ok := playlistIDs[job.PlaylistID]; ok && job.Status == peapod.JobStatusPending {
+			ids = append(ids, job.ID)
+		}
+	}
+
+	for _, id := range ids {
+		if err := setJobStatus(ctx, tx, id, peapod.JobStatusCanceled, peapod.ErrPlaylistNotFound); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requeueJob returns a processing job to a pending status. The job keeps
+// its position in the queue. Jobs which are no longer processing are ignored.
+func requeueJob(ctx context.Context, tx *Tx, id int) error {
+	job, err := findJobByID(ctx, tx, id)
+	if err != nil {
+		return err
+	} else if job == nil || job.Status != peapod.JobStatusProcessing {
+		return nil
+	}
+	return setJobStatus(ctx, tx, id, peapod.JobStatusPending, nil)
+}
+
 // nextJob returns the next pending job in the job queue.
 func nextJob(ctx context.Context, tx *Tx) (*peapod.Job, error) {
 	bkt := tx.Bucket([]byte("JobQueue"))
@@ -313,9 +654,12 @@ func marshalJob(v *peapod.Job) ([]byte, error) {
 		Title:      v.Title,
 		URL:        v.URL,
 		Text:       v.Text,
+		Tags:       v.Tags,
 		Error:      v.Error,
 		CreatedAt:  encodeTime(v.CreatedAt),
 		UpdatedAt:  encodeTime(v.UpdatedAt),
+
+		CorrelationID: v.CorrelationID,
 	})
 }
 
@@ -333,9 +677,12 @@ func unmarshalJob(data []byte, v *peapod.Job) error {
 		Title:      pb.Title,
 		URL:        pb.URL,
 		Text:       pb.Text,
+		Tags:       pb.Tags,
 		Error:      pb.Error,
 		CreatedAt:  decodeTime(pb.CreatedAt),
 		UpdatedAt:  decodeTime(pb.UpdatedAt),
+
+		CorrelationID: pb.CorrelationID,
 	}
 	return nil
 }
@@ -0,0 +1,216 @@
+package bolt_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/bolt"
+)
+
+// Ensure a job stores the correlation id of the request which created it.
+func TestJobService_CreateJob_CorrelationID(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewJobService(db.DB)
+	sysCtx := peapod.NewSystemContext(context.Background())
+
+	ctx, user, _ := MustCreateUser(t, db, "+15555550100")
+	ctx = peapod.NewCorrelationContext(ctx, "SM0001")
+
+	// Ids set explicitly are retained.
+	if err := s.CreateJob(ctx, &peapod.Job{OwnerID: user.ID, Type: peapod.JobTypeCreateTrackFromURL}); err != nil {
+		t.Fatal(err)
+	} else if err := s.CreateJob(ctx, &peapod.Job{OwnerID: user.ID, Type: peapod.JobTypeCreateTrackFromURL, CorrelationID: "OTHER"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if job, err := s.NextJob(sysCtx); err != nil {
+		t.Fatal(err)
+	} else if job.CorrelationID != "SM0001" {
+		t.Fatalf("unexpected correlation id: %q", job.CorrelationID)
+	} else if job, err := s.NextJob(sysCtx); err != nil {
+		t.Fatal(err)
+	} else if job.CorrelationID != "OTHER" {
+		t.Fatalf("unexpected correlation id: %q", job.CorrelationID)
+	}
+}
+
+// Ensure failed jobs can be listed & retried and that queue depth is reported.
+func TestJobService_RetryJob(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewJobService(db.DB)
+	sysCtx := peapod.NewSystemContext(context.Background())
+
+	ctx, user, _ := MustCreateUser(t, db, "+15555550100")
+
+	for i := 0; i < 3; i++ {
+		if err := s.CreateJob(ctx, &peapod.Job{OwnerID: user.ID, Type: peapod.JobTypeCreateTrackFromURL}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Start two jobs & fail the first.
+	if job, err := s.NextJob(sysCtx); err != nil {
+		t.Fatal(err)
+	} else if err := s.CompleteJob(sysCtx, job.ID, job.Lease, errors.New("marker")); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.NextJob(sysCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats, err := s.FindJobQueueStats(sysCtx); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(stats, &peapod.JobQueueStats{Pending: 1, Processing: 1}) {
+		t.Fatalf("unexpected stats: %#v", stats)
+	}
+
+	// Only the failed job should be listed.
+	if jobs, err := s.FindJobs(sysCtx, peapod.JobFilter{Status: peapod.JobStatusFailed}); err != nil {
+		t.Fatal(err)
+	} else if len(jobs) != 1 || jobs[0].ID != 1 || jobs[0].Error != "marker" {
+		t.Fatalf("unexpected jobs: %#v", jobs)
+	} else if jobs, err := s.FindJobs(sysCtx, peapod.JobFilter{Offset: 1, Limit: 1}); err != nil {
+		t.Fatal(err)
+	} else if len(jobs) != 1 || jobs[0].ID != 2 {
+		t.Fatalf("unexpected jobs: %#v", jobs)
+	}
+
+	// Retry the failed job. It should be queued after the pending job.
+	if job, err := s.RetryJob(sysCtx, 1); err != nil {
+		t.Fatal(err)
+	} else if job.Status != peapod.JobStatusPending || job.Error != "" {
+		t.Fatalf("unexpected job: %#v", job)
+	} else if _, err := s.RetryJob(sysCtx, 2); err != peapod.ErrJobNotRetryable {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if job, err := s.NextJob(sysCtx); err != nil {
+		t.Fatal(err)
+	} else if job.ID != 3 {
+		t.Fatalf("unexpected job: %d", job.ID)
+	} else if job, err := s.NextJob(sysCtx); err != nil {
+		t.Fatal(err)
+	} else if job.ID != 1 {
+		t.Fatalf("unexpected job: %d", job.ID)
+	}
+}
+
+// Ensure jobs without a heartbeat are returned to the queue.
+func TestJobService_HeartbeatJob(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewJobService(db.DB)
+	sysCtx := peapod.NewSystemContext(context.Background())
+
+	now := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	db.Now = func() time.Time { return now }
+
+	ctx, user, _ := MustCreateUser(t, db, "+15555550100")
+	if err := s.CreateJob(ctx, &peapod.Job{OwnerID: user.ID, Type: peapod.JobTypeCreateTrackFromURL}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Claim the job & extend its lease before it expires.
+	job, err := s.NextJob(sysCtx)
+	if err != nil {
+		t.Fatal(err)
+	} else if job.ID != 1 || job.Lease == "" || job.Owner == nil || job.Owner.ID != user.ID {
+		t.Fatalf("unexpected job: %#v", job)
+	}
+	now = now.Add(s.LeaseTimeout - time.Second)
+	if err := s.HeartbeatJob(sysCtx, 1, job.Lease); err != nil {
+		t.Fatal(err)
+	} else if err := s.HeartbeatJob(sysCtx, 1, "OTHER"); err != peapod.ErrJobNotProcessing {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The job is still claimed.
+	now = now.Add(s.LeaseTimeout - time.Second)
+	if other, err := s.NextJob(sysCtx); err != nil {
+		t.Fatal(err)
+	} else if other != nil {
+		t.Fatalf("unexpected job: %#v", other)
+	}
+
+	// Once the lease expires the job can be claimed again.
+	now = now.Add(time.Second)
+	if err := s.HeartbeatJob(sysCtx, 1, job.Lease); err != peapod.ErrJobNotProcessing {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	other, err := s.NextJob(sysCtx)
+	if err != nil {
+		t.Fatal(err)
+	} else if other == nil || other.ID != 1 || other.Status != peapod.JobStatusProcessing || other.Lease == job.Lease {
+		t.Fatalf("unexpected job: %#v", other)
+	}
+
+	// Completed jobs no longer accept heartbeats.
+	if err := s.CompleteJob(sysCtx, 1, other.Lease, nil); err != nil {
+		t.Fatal(err)
+	} else if err := s.HeartbeatJob(sysCtx, 1, other.Lease); err != peapod.ErrJobNotProcessing {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := s.HeartbeatJob(sysCtx, 100, other.Lease); err != peapod.ErrJobNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Heartbeats require a system context.
+	if err := s.HeartbeatJob(context.Background(), 1, other.Lease); err != peapod.ErrUnauthorized {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a worker whose lease expired cannot complete a job which has been
+// claimed by another worker.
+func TestJobService_CompleteJob_ErrJobNotProcessing(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewJobService(db.DB)
+	sysCtx := peapod.NewSystemContext(context.Background())
+
+	now := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	db.Now = func() time.Time { return now }
+
+	ctx, user, _ := MustCreateUser(t, db, "+15555550100")
+	if err := s.CreateJob(ctx, &peapod.Job{OwnerID: user.ID, Type: peapod.JobTypeCreateTrackFromURL}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Claim the job, let the lease expire & re-claim it.
+	stale, err := s.NextJob(sysCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(s.LeaseTimeout)
+	job, err := s.NextJob(sysCtx)
+	if err != nil {
+		t.Fatal(err)
+	} else if job == nil || job.ID != stale.ID {
+		t.Fatalf("unexpected job: %#v", job)
+	}
+
+	// The original worker can no longer complete the job.
+	if err := s.CompleteJob(sysCtx, stale.ID, stale.Lease, nil); err != peapod.ErrJobNotProcessing {
+		t.Fatalf("unexpected error: %v", err)
+	} else if other, err := s.FindJobs(sysCtx, peapod.JobFilter{}); err != nil {
+		t.Fatal(err)
+	} else if len(other) != 1 || other[0].Status != peapod.JobStatusProcessing {
+		t.Fatalf("unexpected jobs: %#v", other)
+	}
+
+	// An expired lease cannot complete the job even before it is re-claimed.
+	now = now.Add(s.LeaseTimeout)
+	if err := s.CompleteJob(sysCtx, job.ID, job.Lease, nil); err != peapod.ErrJobNotProcessing {
+		t.Fatalf("unexpected error: %v", err)
+	} else if job, err = s.NextJob(sysCtx); err != nil {
+		t.Fatal(err)
+	} else if err := s.CompleteJob(sysCtx, job.ID, job.Lease, nil); err != nil {
+		t.Fatal(err)
+	} else if err := s.CompleteJob(sysCtx, job.ID, job.Lease, nil); err != peapod.ErrJobNotProcessing {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/middlemost/peapod"
+)
+
+// NormalizeMobileNumbers rewrites all user & invite mobile numbers in E.164
+// format using region for numbers without a country code. Users whose
+// numbers normalize to the same value are merged into the oldest account.
+// Numbers which cannot be normalized are left unchanged.
+//
+// Returns the number of users updated & the number of users merged.
+// Requires a system context.
+func (s *UserService) NormalizeMobileNumbers(ctx context.Context, region string) (updated, merged int, err error) {
+	tx, err := s.db.BeginSystem(ctx, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	if updated, merged, err = normalizeMobileNumbers(ctx, tx, region); err != nil {
+		return 0, 0, err
+	} else if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return updated, merged, nil
+}
+
+func normalizeMobileNumbers(ctx context.Context, tx *Tx, region string) (updated, merged int, err error) {
+	if !peapod.IsValidRegion(region) {
+		return 0, 0, peapod.ErrInvalidRegion
+	}
+
+	// Read all users in id order so the oldest account is kept on merge.
+	var users []*peapod.User
+	if bkt := tx.Bucket([]byte("Users")); bkt != nil {
+		cur := bkt.Cursor()
+		for k, v := cur.First(); k != nil; k, v = cur.Next() {
+			var u peapod.User
+			if err := unmarshalUser(v, &u); err != nil {
+				return 0, 0, err
+			}
+			users = append(users, &u)
+		}
+	}
+
+	primaries := make(map[string]*peapod.User)
+	for _, u := range users {
+		mobileNumber, err := peapod.NormalizeMobileNumber(u.MobileNumber, region)
+		if err != nil {
+			continue
+		}
+
+		// Merge into an existing account with the same number.
+		if primary := primaries[mobileNumber]; primary != nil {
+			if err := mergeUser(ctx, tx, primary, u); err != nil {
+				return 0, 0, err
+			}
+			merged++
+			continue
+		}
+		primaries[mobileNumber] = u
+
+		// Rewrite number & index, if changed.
+		if mobileNumber == u.MobileNumber {
+			continue
+		}
+		if err := deleteMobileNumberIndex(ctx, tx, u); err != nil {
+			return 0, 0, err
+		}
+		u.MobileNumber = mobileNumber
+		if err := saveUser(ctx, tx, u); err != nil {
+			return 0, 0, err
+		} else if err := tx.Bucket([]byte("Users.MobileNumber")).Put([]byte(u.MobileNumber), itob(u.ID)); err != nil {
+			return 0, 0, err
+		}
+		updated++
+	}
+
+	if err := normalizeInviteMobileNumbers(ctx, tx, region); err != nil {
+		return 0, 0, err
+	}
+	return updated, merged, nil
+}
+
+// mergeUser moves the playlists, memberships, trash & jobs of dup to
+// primary and then removes dup.
+func mergeUser(ctx context.Context, tx *Tx, primary, dup *peapod.User) error {
+	// Transfer owned playlists.
+	var playlistIDs []int
+	if bkt := tx.Bucket([]byte("Users.Playlists")); bkt != nil {
+		cur := bkt.Cursor()
+		prefix := itob(dup.ID)
+		for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+			playlistIDs = append(playlistIDs, btoi(k[8:]))
+		}
+	}
+	for _, playlistID := range playlistIDs {
+		playlist, err := findPlaylistByID(ctx, tx, playlistID)
+		if err != nil {
+			return err
+		}
+		assert(playlist != nil, "indexed playlist not found: id=%d", playlistID)
+
+		// The new owner no longer needs a separate membership.
+		if member, err := findPlaylistMember(ctx, tx, playlistID, primary.ID); err != nil {
+			return err
+		} else if member != nil {
+			if err := deletePlaylistMember(ctx, tx, playlistID, primary.ID); err != nil {
+				return err
+			}
+		}
+
+		playlist.OwnerID = primary.ID
+		if err := savePlaylist(ctx, tx, playlist); err != nil {
+			return err
+		} else if err := updateIndex(ctx, tx, []byte("Users.Playlists"), dup.ID, playlistID, primary.ID, playlistID); err != nil {
+			return err
+		}
+	}
+
+	// Transfer memberships unless primary already belongs to the playlist.
+	var memberPlaylistIDs []int
+	if bkt := tx.Bucket([]byte("Users.PlaylistMembers")); bkt != nil {
+		cur := bkt.Cursor()
+		prefix := itob(dup.ID)
+		for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+			memberPlaylistIDs = append(memberPlaylistIDs, btoi(k[8:]))
+		}
+	}
+	for _, playlistID := range memberPlaylistIDs {
+		member, err := findPlaylistMember(ctx, tx, playlistID, dup.ID)
+		if err != nil {
+			return err
+		} else if err := deletePlaylistMember(ctx, tx, playlistID, dup.ID); err != nil {
+			return err
+		}
+
+		if role, err := findPlaylistRole(ctx, tx, playlistID, primary.ID); err != nil {
+			return err
+		} else if role != "" {
+			continue
+		}
+		if err := createPlaylistMember(ctx, tx, &peapod.PlaylistMember{
+			PlaylistID: playlistID,
+			UserID:     primary.ID,
+			Role:       member.Role,
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Transfer trash.
+	if bkt := tx.Bucket([]byte("Users.Trash")); bkt != nil {
+		var trackIDs []int
+		cur := bkt.Cursor()
+		prefix := itob(dup.ID)
+		for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+			trackIDs = append(trackIDs, btoi(k[8:]))
+		}
+		for _, trackID := range trackIDs {
+			if err := updateIndex(ctx, tx, []byte("Users.Trash"), dup.ID, trackID, primary.ID, trackID); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Transfer jobs.
+	if bkt := tx.Bucket([]byte("Jobs")); bkt != nil {
+		var jobs []*peapod.Job
+		cur := bkt.Cursor()
+		for k, v := cur.First(); k != nil; k, v = cur.Next() {
+			var job peapod.Job
+			if err := unmarshalJob(v, &job); err != nil {
+				return err
+			} else if job.OwnerID == dup.ID {
+				jobs = append(jobs, &job)
+			}
+		}
+		for _, job := range jobs {
+			job.OwnerID = primary.ID
+			if err := saveJob(ctx, tx, job); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Remove duplicate user, its sessions & its last undoable action.
+	if err := deleteUserSessions(ctx, tx, dup.ID); err != nil {
+		return err
+	} else if err := deleteUndoAction(ctx, tx, dup.ID); err != nil {
+		return err
+	} else if err := deleteMobileNumberIndex(ctx, tx, dup); err != nil {
+		return err
+	}
+	return tx.Bucket([]byte("Users")).Delete(itob(dup.ID))
+}
+
+// deleteMobileNumberIndex removes the user's mobile number index entry if
+// it still refers to the user.
+func deleteMobileNumberIndex(ctx context.Context, tx *Tx, u *peapod.User) error {
+	bkt := tx.Bucket([]byte("Users.MobileNumber"))
+	if bkt == nil {
+		return nil
+	} else if v := bkt.Get([]byte(u.MobileNumber)); v == nil || btoi(v) != u.ID {
+		return nil
+	}
+	return bkt.Delete([]byte(u.MobileNumber))
+}
+
+// normalizeInviteMobileNumbers rewrites pending invites in E.164 format.
+func normalizeInviteMobileNumbers(ctx context.Context, tx *Tx, region string) error {
+	bkt := tx.Bucket([]byte("PlaylistInvites"))
+	if bkt == nil {
+		return nil
+	}
+
+	var invites []*peapod.PlaylistInvite
+	cur := bkt.Cursor()
+	for k, v := cur.First(); k != nil; k, v = cur.Next() {
+		var invite peapod.PlaylistInvite
+		if err := unmarshalPlaylistInvite(v, &invite); err != nil {
+			return err
+		}
+		invites = append(invites, &invite)
+	}
+
+	idx := tx.Bucket([]byte("PlaylistInvites.MobileNumber"))
+	for _, invite := range invites {
+		mobileNumber, err := peapod.NormalizeMobileNumber(invite.MobileNumber, region)
+		if err != nil || mobileNumber == invite.MobileNumber {
+			continue
+		}
+
+		if err := idx.Delete(append(playlistInviteMobileNumberPrefix(invite.MobileNumber), itob(invite.ID)...)); err != nil {
+			return err
+		}
+		invite.MobileNumber = mobileNumber
+		invite.UpdatedAt = tx.Now
+
+		if buf, err := marshalPlaylistInvite(invite); err != nil {
+			return err
+		} else if err := bkt.Put(itob(invite.ID), buf); err != nil {
+			return err
+		} else if err := idx.Put(append(playlistInviteMobileNumberPrefix(invite.MobileNumber), itob(invite.ID)...), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package bolt
 import (
 	"bytes"
 	"context"
+	"sort"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/middlemost/peapod"
@@ -11,14 +13,23 @@ import (
 // Ensure service implements interface.
 var _ peapod.PlaylistService = &PlaylistService{}
 
+// DefaultTokenGracePeriod is the default time that a rotated token continues to work.
+const DefaultTokenGracePeriod = 7 * 24 * time.Hour
+
 // PlaylistService represents a service to manage playlists.
 type PlaylistService struct {
 	db *DB
+
+	// Time after rotation until the previous token is invalidated.
+	TokenGracePeriod time.Duration
 }
 
 // NewPlaylistService returns a new instance of PlaylistService.
 func NewPlaylistService(db *DB) *PlaylistService {
-	return &PlaylistService{db: db}
+	return &PlaylistService{
+		db:               db,
+		TokenGracePeriod: DefaultTokenGracePeriod,
+	}
 }
 
 // FindPlaylistByID returns a playlist and its tracks by id.
@@ -29,23 +40,26 @@ func (s *PlaylistService) FindPlaylistByID(ctx context.Context, id int) (*peapod
 	}
 	defer tx.Rollback()
 
-	// Retrieve playlist.
+	// Retrieve playlist & verify the current user is a member.
 	playlist, err := findPlaylistByID(ctx, tx, id)
 	if err != nil {
 		return nil, err
+	} else if playlist == nil {
+		return nil, nil
+	} else if err := authorizePlaylistRole(ctx, tx, id, peapod.IsValidPlaylistRole); err != nil {
+		return nil, err
 	}
 
 	// Attach tracks.
-	tracks, err := playlistTracks(ctx, tx, playlist.ID)
-	if err != nil {
+	if err := attachPlaylistTracks(ctx, tx, playlist); err != nil {
 		return nil, err
 	}
-	playlist.Tracks = tracks
 
 	return playlist, nil
 }
 
 // FindPlaylistByToken returns a playlist and its tracks by token.
+// The token acts as the credential so no user is required.
 func (s *PlaylistService) FindPlaylistByToken(ctx context.Context, token string) (*peapod.Playlist, error) {
 	tx, err := s.db.Begin(ctx, false)
 	if err != nil {
@@ -66,11 +80,9 @@ func (s *PlaylistService) FindPlaylistByToken(ctx context.Context, token string)
 	}
 
 	// Attach tracks.
-	tracks, err := playlistTracks(ctx, tx, playlist.ID)
-	if err != nil {
+	if err := attachPlaylistTracks(ctx, tx, playlist); err != nil {
 		return nil, err
 	}
-	playlist.Tracks = tracks
 
 	return playlist, nil
 }
@@ -82,9 +94,185 @@ func (s *PlaylistService) FindPlaylistsByUserID(ctx context.Context, id int) ([]
 		return nil, err
 	}
 	defer tx.Rollback()
+
+	if err := authorizeUser(ctx, id); err != nil {
+		return nil, err
+	}
 	return findPlaylistsByUserID(ctx, tx, id)
 }
 
+// CreatePlaylist creates a new playlist owned by the current user.
+func (s *PlaylistService) CreatePlaylist(ctx context.Context, playlist *peapod.Playlist) error {
+	tx, err := s.db.BeginAuth(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Create playlist & commit.
+	if err := func() error {
+		if playlist == nil {
+			return peapod.ErrPlaylistRequired
+		}
+		playlist.OwnerID = peapod.FromContext(ctx).ID
+
+		if err := createPlaylist(ctx, tx, playlist); err != nil {
+			return err
+		} else if err := tx.Commit(); err != nil {
+			return err
+		}
+		return nil
+	}(); err != nil {
+		if playlist != nil {
+			playlist.ID = 0
+		}
+		return err
+	}
+
+	return nil
+}
+
+// SetPlaylistRetention updates the retention limits of a playlist.
+func (s *PlaylistService) SetPlaylistRetention(ctx context.Context, id int, retention *peapod.Retention) error {
+	tx, err := s.db.BeginAuth(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setPlaylistRetention(ctx, tx, id, retention); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindPlaylistsWithRetention returns all playlists with retention limits.
+// Requires a system context.
+func (s *PlaylistService) FindPlaylistsWithRetention(ctx context.Context) ([]*peapod.Playlist, error) {
+	tx, err := s.db.BeginSystem(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return findPlaylistsWithRetention(ctx, tx)
+}
+
+// RotateToken generates a new token for a playlist. The previous token
+// continues to resolve to the playlist until the grace period expires.
+func (s *PlaylistService) RotateToken(ctx context.Context, id int) (*peapod.Playlist, error) {
+	tx, err := s.db.BeginAuth(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Remove previously rotated tokens which have expired.
+	if err := removeExpiredPlaylistTokens(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	// Rotate token & commit.
+	playlist, err := rotatePlaylistToken(ctx, tx, id, tx.Now.Add(s.TokenGracePeriod))
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return playlist, nil
+}
+
+// RemoveExpiredTokens removes rotated tokens whose grace period has expired.
+// Requires a system context.
+func (s *PlaylistService) RemoveExpiredTokens(ctx context.Context) error {
+	tx, err := s.db.BeginSystem(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := removeExpiredPlaylistTokens(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindPlaylistMembers returns all members of a playlist, including the owner.
+func (s *PlaylistService) FindPlaylistMembers(ctx context.Context, playlistID int) ([]*peapod.PlaylistMember, error) {
+	tx, err := s.db.Begin(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	members, err := findPlaylistMembers(ctx, tx, playlistID)
+	if err != nil {
+		return nil, err
+	} else if err := authorizePlaylistRole(ctx, tx, playlistID, peapod.IsValidPlaylistRole); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// CreatePlaylistInvite creates a pending invite to a playlist.
+// Only the playlist owner can invite new members.
+func (s *PlaylistService) CreatePlaylistInvite(ctx context.Context, invite *peapod.PlaylistInvite) error {
+	tx, err := s.db.BeginAuth(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Create invite & commit.
+	if err := func() error {
+		if err := createPlaylistInvite(ctx, tx, invite); err != nil {
+			return err
+		} else if err := tx.Commit(); err != nil {
+			return err
+		}
+		return nil
+	}(); err != nil {
+		invite.ID = 0
+		return err
+	}
+
+	return nil
+}
+
+// FindPlaylistInvitesByMobileNumber returns all pending invites for a mobile number.
+func (s *PlaylistService) FindPlaylistInvitesByMobileNumber(ctx context.Context, mobileNumber string) ([]*peapod.PlaylistInvite, error) {
+	tx, err := s.db.Begin(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Users can only view invites sent to their own mobile number.
+	if !peapod.IsSystemContext(ctx) {
+		if user := peapod.FromContext(ctx); user == nil || user.MobileNumber != mobileNumber {
+			return nil, peapod.ErrUnauthorized
+		}
+	}
+	return findPlaylistInvitesByMobileNumber(ctx, tx, mobileNumber)
+}
+
+// AcceptPlaylistInvite adds the current user to the invite's playlist and
+// removes the invite. The user's mobile number must match the invite.
+func (s *PlaylistService) AcceptPlaylistInvite(ctx context.Context, id int) (*peapod.PlaylistMember, error) {
+	tx, err := s.db.BeginAuth(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	member, err := acceptPlaylistInvite(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
 func findPlaylistByID(ctx context.Context, tx *Tx, id int) (*peapod.Playlist, error) {
 	bkt := tx.Bucket([]byte("Playlists"))
 	if bkt == nil {
@@ -100,6 +288,86 @@ func findPlaylistByID(ctx context.Context, tx *Tx, id int) (*peapod.Playlist, er
 	return &playlist, nil
 }
 
+// attachPlaylistTracks sets the tracks for a playlist. Smart playlists are
+// populated by evaluating their filter against the owner's tracks. Merged
+// playlists are generated from their sources by the caller.
+func attachPlaylistTracks(ctx context.Context, tx *Tx, playlist *peapod.Playlist) error {
+	switch playlist.Type {
+	case peapod.PlaylistTypeMerged:
+		return nil
+	case peapod.PlaylistTypeSmart:
+		tracks, err := smartPlaylistTracks(ctx, tx, playlist)
+		if err != nil {
+			return err
+		}
+		playlist.Tracks = tracks
+		return nil
+	}
+
+	tracks, err := playlistTracks(ctx, tx, playlist.ID)
+	if err != nil {
+		return err
+	}
+	playlist.Tracks = tracks
+	return nil
+}
+
+// smartPlaylistTracks returns tracks from the owner's playlists which match
+// the playlist's filter. The tag index is used to narrow candidates if the
+// filter specifies tags.
+func smartPlaylistTracks(ctx context.Context, tx *Tx, playlist *peapod.Playlist) ([]*peapod.Track, error) {
+	filter := playlist.Filter
+	if filter == nil {
+		return nil, nil
+	}
+
+	// Determine which playlists the owner can read from.
+	playlists, err := findPlaylistsByUserID(ctx, tx, playlist.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	playlistIDs := make(map[int]struct{}, len(playlists))
+	for _, p := range playlists {
+		if p.Type == peapod.PlaylistTypeStandard {
+			playlistIDs[p.ID] = struct{}{}
+		}
+	}
+
+	// Build a list of candidate track ids.
+	var trackIDs []int
+	if len(filter.Tags) > 0 {
+		trackIDs = tagTrackIDs(ctx, tx, filter.Tags[0])
+	} else {
+		for id := range playlistIDs {
+			tracks, err := playlistTracks(ctx, tx, id)
+			if err != nil {
+				return nil, err
+			}
+			for _, track := range tracks {
+				trackIDs = append(trackIDs, track.ID)
+			}
+		}
+		sort.Ints(trackIDs)
+	}
+
+	// Evaluate filter against each candidate.
+	a := make([]*peapod.Track, 0, len(trackIDs))
+	for _, id := range trackIDs {
+		track, err := findTrackByID(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		} else if track == nil {
+			continue
+		} else if _, ok := playlistIDs[track.PlaylistID]; !ok {
+			continue
+		} else if !filter.Match(track, tx.Now) {
+			continue
+		}
+		a = append(a, track)
+	}
+	return a, nil
+}
+
 func playlistExists(ctx context.Context, tx *Tx, id int) bool {
 	bkt := tx.Bucket([]byte("Playlists"))
 	if bkt == nil {
@@ -117,6 +385,14 @@ func findPlaylistIDByToken(ctx context.Context, tx *Tx, token string) int {
 	if v == nil {
 		return 0
 	}
+
+	// Ignore rotated tokens that have expired but not yet been removed.
+	if bkt := tx.Bucket([]byte("Playlists.RetiredToken")); bkt != nil {
+		if v := bkt.Get([]byte(token)); v != nil && !decodeTime(int64(btoi(v[8:16]))).After(tx.Now) {
+			return 0
+		}
+	}
+
 	return btoi(v)
 }
 
@@ -138,12 +414,51 @@ func findPlaylistsByUserID(ctx context.Context, tx *Tx, id int) ([]*peapod.Playl
 		assert(playlist != nil, "indexed playlist not found: id=%d", playlistID)
 		a = append(a, playlist)
 	}
+
+	// Append playlists that the user is a member of.
+	if bkt := tx.Bucket([]byte("Users.PlaylistMembers")); bkt != nil {
+		cur := bkt.Cursor()
+		for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+			playlistID := btoi(k[8:])
+			playlist, err := findPlaylistByID(ctx, tx, playlistID)
+			if err != nil {
+				return nil, err
+			}
+			assert(playlist != nil, "indexed member playlist not found: id=%d", playlistID)
+			a = append(a, playlist)
+		}
+	}
+
 	return a, nil
 }
 
 func createPlaylist(ctx context.Context, tx *Tx, playlist *peapod.Playlist) error {
 	if playlist == nil {
 		return peapod.ErrPlaylistRequired
+	} else if !peapod.IsValidPlaylistType(playlist.Type) {
+		return peapod.ErrInvalidPlaylistType
+	} else if playlist.Type == peapod.PlaylistTypeSmart && playlist.Filter == nil {
+		return peapod.ErrSmartFilterRequired
+	} else if playlist.Type == peapod.PlaylistTypeMerged && len(playlist.SourceIDs) == 0 {
+		return peapod.ErrMergedSourcesRequired
+	}
+
+	// Merged playlists can only combine standard playlists the owner can read.
+	for _, sourceID := range playlist.SourceIDs {
+		if source, err := findPlaylistByID(ctx, tx, sourceID); err != nil {
+			return err
+		} else if source == nil || source.Type != peapod.PlaylistTypeStandard {
+			return peapod.ErrInvalidMergedSource
+		} else if role, err := findPlaylistRole(ctx, tx, sourceID, playlist.OwnerID); err != nil {
+			return err
+		} else if role == "" {
+			return peapod.ErrUnauthorized
+		}
+	}
+
+	// Normalize filter tags.
+	if playlist.Filter != nil {
+		playlist.Filter.Tags = peapod.NormalizeTags(playlist.Filter.Tags)
 	}
 
 	bkt, err := tx.CreateBucketIfNotExists([]byte("Playlists"))
@@ -171,6 +486,13 @@ func createPlaylist(ctx context.Context, tx *Tx, playlist *peapod.Playlist) erro
 		return err
 	}
 
+	// Index merged playlists by source so they are updated with their sources.
+	for _, sourceID := range playlist.SourceIDs {
+		if err := updateIndex(ctx, tx, []byte("Playlists.MergedBy"), 0, 0, sourceID, playlist.ID); err != nil {
+			return err
+		}
+	}
+
 	// Index by token.
 	if bkt, err := tx.CreateBucketIfNotExists([]byte("Playlists.Token")); err != nil {
 		return err
@@ -181,6 +503,140 @@ func createPlaylist(ctx context.Context, tx *Tx, playlist *peapod.Playlist) erro
 	return nil
 }
 
+func setPlaylistRetention(ctx context.Context, tx *Tx, id int, retention *peapod.Retention) error {
+	playlist, err := findPlaylistByID(ctx, tx, id)
+	if err != nil {
+		return err
+	} else if playlist == nil {
+		return peapod.ErrPlaylistNotFound
+	} else if user := peapod.FromContext(ctx); user == nil || user.ID != playlist.OwnerID {
+		return peapod.ErrUnauthorized
+	} else if playlist.Type != peapod.PlaylistTypeStandard {
+		return peapod.ErrPlaylistNotWritable
+	}
+
+	// Clear retention if no limits are set.
+	if retention.IsZero() {
+		retention = nil
+	}
+	playlist.Retention = retention
+
+	if err := savePlaylist(ctx, tx, playlist); err != nil {
+		return err
+	}
+
+	// Index playlists with retention so the sweeper can find them.
+	bkt, err := tx.CreateBucketIfNotExists([]byte("Playlists.Retention"))
+	if err != nil {
+		return err
+	} else if retention == nil {
+		return bkt.Delete(itob(playlist.ID))
+	}
+	return bkt.Put(itob(playlist.ID), nil)
+}
+
+func findPlaylistsWithRetention(ctx context.Context, tx *Tx) ([]*peapod.Playlist, error) {
+	bkt := tx.Bucket([]byte("Playlists.Retention"))
+	if bkt == nil {
+		return nil, nil
+	}
+
+	var a []*peapod.Playlist
+	cur := bkt.Cursor()
+	for k, _ := cur.First(); k != nil; k, _ = cur.Next() {
+		playlist, err := findPlaylistByID(ctx, tx, btoi(k))
+		if err != nil {
+			return nil, err
+		}
+		assert(playlist != nil, "indexed retention playlist not found: id=%d", btoi(k))
+
+		if err := attachPlaylistTracks(ctx, tx, playlist); err != nil {
+			return nil, err
+		}
+		a = append(a, playlist)
+	}
+	return a, nil
+}
+
+// rotatePlaylistToken replaces a playlist's token with a newly generated one.
+// The previous token is retired and remains indexed until expiresAt.
+func rotatePlaylistToken(ctx context.Context, tx *Tx, id int, expiresAt time.Time) (*peapod.Playlist, error) {
+	playlist, err := findPlaylistByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if playlist == nil {
+		return nil, peapod.ErrPlaylistNotFound
+	} else if user := peapod.FromContext(ctx); user == nil || user.ID != playlist.OwnerID {
+		return nil, peapod.ErrUnauthorized
+	}
+
+	// Retire the current token so the rotation can be undone or remove it
+	// if there is no grace period.
+	oldToken, userID := playlist.Token, peapod.FromContext(ctx).ID
+	if expiresAt.After(tx.Now) {
+		if bkt, err := tx.CreateBucketIfNotExists([]byte("Playlists.RetiredToken")); err != nil {
+			return nil, err
+		} else if err := bkt.Put([]byte(oldToken), makeIndexKey(playlist.ID, int(encodeTime(expiresAt)))); err != nil {
+			return nil, err
+		} else if err := saveUndoAction(ctx, tx, userID, &peapod.UndoAction{
+			Type:       peapod.UndoActionTypeRotateToken,
+			PlaylistID: playlist.ID,
+			Token:      oldToken,
+			CreatedAt:  tx.Now,
+		}); err != nil {
+			return nil, err
+		}
+	} else if err := tx.Bucket([]byte("Playlists.Token")).Delete([]byte(oldToken)); err != nil {
+		return nil, err
+	} else if err := deleteUndoAction(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	// Generate new token & save.
+	playlist.Token = tx.GenerateToken()
+	if err := savePlaylist(ctx, tx, playlist); err != nil {
+		return nil, err
+	}
+
+	// Index by new token.
+	if bkt, err := tx.CreateBucketIfNotExists([]byte("Playlists.Token")); err != nil {
+		return nil, err
+	} else if err := bkt.Put([]byte(playlist.Token), itob(playlist.ID)); err != nil {
+		return nil, err
+	}
+
+	return playlist, nil
+}
+
+// removeExpiredPlaylistTokens removes retired tokens from the token index
+// once their grace period has passed.
+func removeExpiredPlaylistTokens(ctx context.Context, tx *Tx) error {
+	bkt := tx.Bucket([]byte("Playlists.RetiredToken"))
+	if bkt == nil {
+		return nil
+	}
+
+	// Find expired tokens.
+	var tokens [][]byte
+	cur := bkt.Cursor()
+	for k, v := cur.First(); k != nil; k, v = cur.Next() {
+		if !decodeTime(int64(btoi(v[8:16]))).After(tx.Now) {
+			tokens = append(tokens, append([]byte(nil), k...))
+		}
+	}
+
+	// Remove from both the retired token list & the token index.
+	for _, token := range tokens {
+		if err := bkt.Delete(token); err != nil {
+			return err
+		} else if err := tx.Bucket([]byte("Playlists.Token")).Delete(token); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func savePlaylist(ctx context.Context, tx *Tx, playlist *peapod.Playlist) error {
 	// Validate record.
 	if playlist.OwnerID == 0 {
@@ -204,18 +660,77 @@ func savePlaylist(ctx context.Context, tx *Tx, playlist *peapod.Playlist) error
 	} else if err := bkt.Put(itob(playlist.ID), buf); err != nil {
 		return err
 	}
+
+	// Mark any merged playlists which include this playlist as updated.
+	if err := touchMergedPlaylists(ctx, tx, playlist.ID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// touchMergedPlaylists updates the timestamp of all merged playlists that
+// include a source playlist. This invalidates any generated merged feeds.
+func touchMergedPlaylists(ctx context.Context, tx *Tx, sourceID int) error {
+	bkt := tx.Bucket([]byte("Playlists.MergedBy"))
+	if bkt == nil {
+		return nil
+	}
+
+	// Collect merged playlists before updating.
+	var ids []int
+	cur := bkt.Cursor()
+	prefix := itob(sourceID)
+	for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+		ids = append(ids, btoi(k[8:]))
+	}
+
+	for _, id := range ids {
+		playlist, err := findPlaylistByID(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		assert(playlist != nil, "indexed merged playlist not found: id=%d", id)
+		playlist.UpdatedAt = tx.Now
+
+		if buf, err := marshalPlaylist(playlist); err != nil {
+			return err
+		} else if err := tx.Bucket([]byte("Playlists")).Put(itob(playlist.ID), buf); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func marshalPlaylist(v *peapod.Playlist) ([]byte, error) {
-	return proto.Marshal(&Playlist{
+	pb := &Playlist{
 		ID:        int64(v.ID),
 		OwnerID:   int64(v.OwnerID),
+		Type:      v.Type,
 		Token:     v.Token,
 		Name:      v.Name,
 		CreatedAt: encodeTime(v.CreatedAt),
 		UpdatedAt: encodeTime(v.UpdatedAt),
-	})
+	}
+	for _, id := range v.SourceIDs {
+		pb.SourceIDs = append(pb.SourceIDs, int64(id))
+	}
+	if r := v.Retention; r != nil {
+		pb.Retention = &Retention{
+			MaxAge:    int64(r.MaxAge),
+			MaxTracks: int64(r.MaxTracks),
+			MaxBytes:  int64(r.MaxBytes),
+		}
+	}
+	if f := v.Filter; f != nil {
+		pb.Filter = &SmartFilter{
+			Tags:        f.Tags,
+			MaxAge:      int64(f.MaxAge),
+			MinDuration: int64(f.MinDuration),
+			MaxDuration: int64(f.MaxDuration),
+		}
+	}
+	return proto.Marshal(pb)
 }
 
 func unmarshalPlaylist(data []byte, v *peapod.Playlist) error {
@@ -226,10 +741,495 @@ func unmarshalPlaylist(data []byte, v *peapod.Playlist) error {
 	*v = peapod.Playlist{
 		ID:        int(pb.ID),
 		OwnerID:   int(pb.OwnerID),
+		Type:      pb.Type,
 		Token:     pb.Token,
 		Name:      pb.Name,
 		CreatedAt: decodeTime(pb.CreatedAt),
 		UpdatedAt: decodeTime(pb.UpdatedAt),
 	}
+	for _, id := range pb.SourceIDs {
+		v.SourceIDs = append(v.SourceIDs, int(id))
+	}
+	if r := pb.Retention; r != nil {
+		v.Retention = &peapod.Retention{
+			MaxAge:    time.Duration(r.MaxAge),
+			MaxTracks: int(r.MaxTracks),
+			MaxBytes:  int(r.MaxBytes),
+		}
+	}
+	if f := pb.Filter; f != nil {
+		v.Filter = &peapod.SmartFilter{
+			Tags:        f.Tags,
+			MaxAge:      time.Duration(f.MaxAge),
+			MinDuration: time.Duration(f.MinDuration),
+			MaxDuration: time.Duration(f.MaxDuration),
+		}
+	}
+	return nil
+}
+
+// findPlaylistRole returns the role of a user on a playlist.
+// Returns a blank string if the user is not a member.
+func findPlaylistRole(ctx context.Context, tx *Tx, playlistID, userID int) (string, error) {
+	playlist, err := findPlaylistByID(ctx, tx, playlistID)
+	if err != nil {
+		return "", err
+	} else if playlist == nil {
+		return "", nil
+	} else if playlist.OwnerID == userID {
+		return peapod.PlaylistRoleOwner, nil
+	}
+
+	member, err := findPlaylistMember(ctx, tx, playlistID, userID)
+	if err != nil {
+		return "", err
+	} else if member == nil {
+		return "", nil
+	}
+	return member.Role, nil
+}
+
+// authorizePlaylistRole returns ErrUnauthorized unless the current user's role
+// on a playlist satisfies fn. System contexts are always authorized.
+func authorizePlaylistRole(ctx context.Context, tx *Tx, playlistID int, fn func(role string) bool) error {
+	if peapod.IsSystemContext(ctx) {
+		return nil
+	}
+
+	user := peapod.FromContext(ctx)
+	if user == nil {
+		return peapod.ErrUnauthorized
+	} else if role, err := findPlaylistRole(ctx, tx, playlistID, user.ID); err != nil {
+		return err
+	} else if !fn(role) {
+		return peapod.ErrUnauthorized
+	}
+	return nil
+}
+
+func findPlaylistMember(ctx context.Context, tx *Tx, playlistID, userID int) (*peapod.PlaylistMember, error) {
+	bkt := tx.Bucket([]byte("PlaylistMembers"))
+	if bkt == nil {
+		return nil, nil
+	}
+
+	var member peapod.PlaylistMember
+	if buf := bkt.Get(makeIndexKey(playlistID, userID)); buf == nil {
+		return nil, nil
+	} else if err := unmarshalPlaylistMember(buf, &member); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// findPlaylistMembers returns the owner followed by all other members.
+func findPlaylistMembers(ctx context.Context, tx *Tx, playlistID int) ([]*peapod.PlaylistMember, error) {
+	playlist, err := findPlaylistByID(ctx, tx, playlistID)
+	if err != nil {
+		return nil, err
+	} else if playlist == nil {
+		return nil, peapod.ErrPlaylistNotFound
+	}
+
+	// The owner is not stored as a member so add them explicitly.
+	a := []*peapod.PlaylistMember{{
+		PlaylistID: playlist.ID,
+		UserID:     playlist.OwnerID,
+		Role:       peapod.PlaylistRoleOwner,
+		CreatedAt:  playlist.CreatedAt,
+		UpdatedAt:  playlist.CreatedAt,
+	}}
+
+	bkt := tx.Bucket([]byte("PlaylistMembers"))
+	if bkt == nil {
+		return a, nil
+	}
+
+	cur := bkt.Cursor()
+	prefix := itob(playlistID)
+	for k, v := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, v = cur.Next() {
+		var member peapod.PlaylistMember
+		if err := unmarshalPlaylistMember(v, &member); err != nil {
+			return nil, err
+		}
+		a = append(a, &member)
+	}
+	return a, nil
+}
+
+func createPlaylistMember(ctx context.Context, tx *Tx, member *peapod.PlaylistMember) error {
+	if !peapod.IsValidPlaylistRole(member.Role) || member.Role == peapod.PlaylistRoleOwner {
+		return peapod.ErrInvalidPlaylistRole
+	} else if !playlistExists(ctx, tx, member.PlaylistID) {
+		return peapod.ErrPlaylistNotFound
+	} else if !userExists(ctx, tx, member.UserID) {
+		return peapod.ErrUserNotFound
+	}
+
+	// Ensure user is not already a member or the owner.
+	if role, err := findPlaylistRole(ctx, tx, member.PlaylistID, member.UserID); err != nil {
+		return err
+	} else if role != "" {
+		return peapod.ErrPlaylistMemberExists
+	}
+
+	// Update timestamps.
+	member.CreatedAt = tx.Now
+	member.UpdatedAt = tx.Now
+
+	// Marshal and insert record.
+	if buf, err := marshalPlaylistMember(member); err != nil {
+		return err
+	} else if bkt, err := tx.CreateBucketIfNotExists([]byte("PlaylistMembers")); err != nil {
+		return err
+	} else if err := bkt.Put(makeIndexKey(member.PlaylistID, member.UserID), buf); err != nil {
+		return err
+	}
+
+	// Index by user.
+	if err := updateIndex(ctx, tx, []byte("Users.PlaylistMembers"), 0, 0, member.UserID, member.PlaylistID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func findPlaylistInviteByID(ctx context.Context, tx *Tx, id int) (*peapod.PlaylistInvite, error) {
+	bkt := tx.Bucket([]byte("PlaylistInvites"))
+	if bkt == nil {
+		return nil, nil
+	}
+
+	var invite peapod.PlaylistInvite
+	if buf := bkt.Get(itob(id)); buf == nil {
+		return nil, nil
+	} else if err := unmarshalPlaylistInvite(buf, &invite); err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+func findPlaylistInvitesByMobileNumber(ctx context.Context, tx *Tx, mobileNumber string) ([]*peapod.PlaylistInvite, error) {
+	bkt := tx.Bucket([]byte("PlaylistInvites.MobileNumber"))
+	if bkt == nil {
+		return nil, nil
+	}
+
+	var a []*peapod.PlaylistInvite
+	cur := bkt.Cursor()
+	prefix := playlistInviteMobileNumberPrefix(mobileNumber)
+	for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+		id := btoi(k[len(prefix):])
+		invite, err := findPlaylistInviteByID(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		assert(invite != nil, "indexed playlist invite not found: id=%d", id)
+		a = append(a, invite)
+	}
+	return a, nil
+}
+
+func createPlaylistInvite(ctx context.Context, tx *Tx, invite *peapod.PlaylistInvite) error {
+	if invite == nil {
+		return peapod.ErrPlaylistInviteRequired
+	} else if invite.MobileNumber == "" {
+		return peapod.ErrInviteMobileNumberRequired
+	} else if !peapod.IsValidPlaylistRole(invite.Role) || invite.Role == peapod.PlaylistRoleOwner {
+		return peapod.ErrInvalidPlaylistRole
+	}
+
+	// Only the owner can invite members.
+	playlist, err := findPlaylistByID(ctx, tx, invite.PlaylistID)
+	if err != nil {
+		return err
+	} else if playlist == nil {
+		return peapod.ErrPlaylistNotFound
+	} else if user := peapod.FromContext(ctx); user == nil || user.ID != playlist.OwnerID {
+		return peapod.ErrUnauthorized
+	}
+	invite.InviterID = playlist.OwnerID
+
+	bkt, err := tx.CreateBucketIfNotExists([]byte("PlaylistInvites"))
+	if err != nil {
+		return err
+	}
+
+	// Retrieve next sequence.
+	id, _ := bkt.NextSequence()
+	invite.ID = int(id)
+
+	// Update timestamps.
+	invite.CreatedAt = tx.Now
+	invite.UpdatedAt = tx.Now
+
+	// Marshal and insert record.
+	if buf, err := marshalPlaylistInvite(invite); err != nil {
+		return err
+	} else if err := bkt.Put(itob(invite.ID), buf); err != nil {
+		return err
+	}
+
+	// Index by mobile number.
+	if bkt, err := tx.CreateBucketIfNotExists([]byte("PlaylistInvites.MobileNumber")); err != nil {
+		return err
+	} else if err := bkt.Put(append(playlistInviteMobileNumberPrefix(invite.MobileNumber), itob(invite.ID)...), nil); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// acceptPlaylistInvite converts an invite into a membership for the current user.
+func acceptPlaylistInvite(ctx context.Context, tx *Tx, id int) (*peapod.PlaylistMember, error) {
+	invite, err := findPlaylistInviteByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if invite == nil {
+		return nil, peapod.ErrPlaylistInviteNotFound
+	}
+
+	user := peapod.FromContext(ctx)
+	if user == nil || user.MobileNumber != invite.MobileNumber {
+		return nil, peapod.ErrUnauthorized
+	}
+
+	// Remove invite & its index.
+	if err := deletePlaylistInvite(ctx, tx, invite); err != nil {
+		return nil, err
+	}
+
+	// Add user as a member.
+	member := &peapod.PlaylistMember{
+		PlaylistID: invite.PlaylistID,
+		UserID:     user.ID,
+		Role:       invite.Role,
+	}
+	if err := createPlaylistMember(ctx, tx, member); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// deletePlaylistInvite removes an invite & its mobile number index entry.
+func deletePlaylistInvite(ctx context.Context, tx *Tx, invite *peapod.PlaylistInvite) error {
+	if err := tx.Bucket([]byte("PlaylistInvites")).Delete(itob(invite.ID)); err != nil {
+		return err
+	}
+	return tx.Bucket([]byte("PlaylistInvites.MobileNumber")).Delete(append(playlistInviteMobileNumberPrefix(invite.MobileNumber), itob(invite.ID)...))
+}
+
+// deletePlaylistMember removes a member from a playlist & the user index.
+func deletePlaylistMember(ctx context.Context, tx *Tx, playlistID, userID int) error {
+	if err := tx.Bucket([]byte("PlaylistMembers")).Delete(makeIndexKey(playlistID, userID)); err != nil {
+		return err
+	}
+	return updateIndex(ctx, tx, []byte("Users.PlaylistMembers"), userID, playlistID, 0, 0)
+}
+
+// deletePlaylist removes a playlist along with its tracks, members, invites,
+// tokens & removal history. Merged playlists which use it as a source are
+// updated. Trashed tracks must be removed separately. Returns the removed tracks.
+func deletePlaylist(ctx context.Context, tx *Tx, id int) ([]*peapod.Track, error) {
+	playlist, err := findPlaylistByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if playlist == nil {
+		return nil, peapod.ErrPlaylistNotFound
+	}
+
+	// Remove tracks.
+	tracks, err := playlistTracks(ctx, tx, playlist.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, track := range tracks {
+		if err := removeTrackIndexes(ctx, tx, track); err != nil {
+			return nil, err
+		} else if err := deleteTrackRecord(ctx, tx, track); err != nil {
+			return nil, err
+		}
+	}
+
+	// Remove removal history.
+	if bkt := tx.Bucket([]byte("Playlists.TrackRemovals")); bkt != nil {
+		var keys [][]byte
+		cur := bkt.Cursor()
+		prefix := itob(playlist.ID)
+		for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := bkt.Delete(k); err != nil {
+				return nil, err
+			} else if err := tx.Bucket([]byte("TrackRemovals")).Delete(k[8:]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Remove members. The first member is always the owner.
+	members, err := findPlaylistMembers(ctx, tx, playlist.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members[1:] {
+		if err := deletePlaylistMember(ctx, tx, playlist.ID, member.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Remove pending invites.
+	if bkt := tx.Bucket([]byte("PlaylistInvites")); bkt != nil {
+		var invites []*peapod.PlaylistInvite
+		cur := bkt.Cursor()
+		for k, v := cur.First(); k != nil; k, v = cur.Next() {
+			var invite peapod.PlaylistInvite
+			if err := unmarshalPlaylistInvite(v, &invite); err != nil {
+				return nil, err
+			} else if invite.PlaylistID == playlist.ID {
+				invites = append(invites, &invite)
+			}
+		}
+		for _, invite := range invites {
+			if err := deletePlaylistInvite(ctx, tx, invite); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Remove as a source of other merged playlists.
+	if bkt := tx.Bucket([]byte("Playlists.MergedBy")); bkt != nil {
+		var mergedIDs []int
+		cur := bkt.Cursor()
+		prefix := itob(playlist.ID)
+		for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+			mergedIDs = append(mergedIDs, btoi(k[8:]))
+		}
+
+		for _, mergedID := range mergedIDs {
+			if err := updateIndex(ctx, tx, []byte("Playlists.MergedBy"), playlist.ID, mergedID, 0, 0); err != nil {
+				return nil, err
+			}
+
+			merged, err := findPlaylistByID(ctx, tx, mergedID)
+			if err != nil {
+				return nil, err
+			}
+			assert(merged != nil, "indexed merged playlist not found: id=%d", mergedID)
+
+			sourceIDs := make([]int, 0, len(merged.SourceIDs))
+			for _, sourceID := range merged.SourceIDs {
+				if sourceID != playlist.ID {
+					sourceIDs = append(sourceIDs, sourceID)
+				}
+			}
+			merged.SourceIDs = sourceIDs
+
+			if err := savePlaylist(ctx, tx, merged); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Remove own merged sources from index.
+	for _, sourceID := range playlist.SourceIDs {
+		if err := updateIndex(ctx, tx, []byte("Playlists.MergedBy"), sourceID, playlist.ID, 0, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	// Remove current & retired tokens.
+	if err := tx.Bucket([]byte("Playlists.Token")).Delete([]byte(playlist.Token)); err != nil {
+		return nil, err
+	}
+	if bkt := tx.Bucket([]byte("Playlists.RetiredToken")); bkt != nil {
+		var tokens [][]byte
+		cur := bkt.Cursor()
+		for k, v := cur.First(); k != nil; k, v = cur.Next() {
+			if btoi(v[:8]) == playlist.ID {
+				tokens = append(tokens, append([]byte(nil), k...))
+			}
+		}
+		for _, token := range tokens {
+			if err := bkt.Delete(token); err != nil {
+				return nil, err
+			} else if err := tx.Bucket([]byte("Playlists.Token")).Delete(token); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Remove retention, owner index & record.
+	if bkt := tx.Bucket([]byte("Playlists.Retention")); bkt != nil {
+		if err := bkt.Delete(itob(playlist.ID)); err != nil {
+			return nil, err
+		}
+	}
+	if err := updateIndex(ctx, tx, []byte("Users.Playlists"), playlist.OwnerID, playlist.ID, 0, 0); err != nil {
+		return nil, err
+	} else if err := tx.Bucket([]byte("Playlists")).Delete(itob(playlist.ID)); err != nil {
+		return nil, err
+	}
+
+	return tracks, nil
+}
+
+// playlistInviteMobileNumberPrefix returns the index key prefix for a mobile number.
+func playlistInviteMobileNumberPrefix(mobileNumber string) []byte {
+	return append([]byte(mobileNumber), 0)
+}
+
+func marshalPlaylistMember(v *peapod.PlaylistMember) ([]byte, error) {
+	return proto.Marshal(&PlaylistMember{
+		PlaylistID: int64(v.PlaylistID),
+		UserID:     int64(v.UserID),
+		Role:       v.Role,
+		CreatedAt:  encodeTime(v.CreatedAt),
+		UpdatedAt:  encodeTime(v.UpdatedAt),
+	})
+}
+
+func unmarshalPlaylistMember(data []byte, v *peapod.PlaylistMember) error {
+	var pb PlaylistMember
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return err
+	}
+	*v = peapod.PlaylistMember{
+		PlaylistID: int(pb.PlaylistID),
+		UserID:     int(pb.UserID),
+		Role:       pb.Role,
+		CreatedAt:  decodeTime(pb.CreatedAt),
+		UpdatedAt:  decodeTime(pb.UpdatedAt),
+	}
+	return nil
+}
+
+func marshalPlaylistInvite(v *peapod.PlaylistInvite) ([]byte, error) {
+	return proto.Marshal(&PlaylistInvite{
+		ID:           int64(v.ID),
+		PlaylistID:   int64(v.PlaylistID),
+		InviterID:    int64(v.InviterID),
+		MobileNumber: v.MobileNumber,
+		Role:         v.Role,
+		CreatedAt:    encodeTime(v.CreatedAt),
+		UpdatedAt:    encodeTime(v.UpdatedAt),
+	})
+}
+
+func unmarshalPlaylistInvite(data []byte, v *peapod.PlaylistInvite) error {
+	var pb PlaylistInvite
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return err
+	}
+	*v = peapod.PlaylistInvite{
+		ID:           int(pb.ID),
+		PlaylistID:   int(pb.PlaylistID),
+		InviterID:    int(pb.InviterID),
+		MobileNumber: pb.MobileNumber,
+		Role:         pb.Role,
+		CreatedAt:    decodeTime(pb.CreatedAt),
+		UpdatedAt:    decodeTime(pb.UpdatedAt),
+	}
 	return nil
 }
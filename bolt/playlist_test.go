@@ -0,0 +1,266 @@
+package bolt_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/bolt"
+)
+
+// Ensure a playlist token can be rotated and the old token expires.
+func TestPlaylistService_RotateToken(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewPlaylistService(db.DB)
+	s.TokenGracePeriod = time.Hour
+
+	// Create a user with a default playlist.
+	ctx, _, playlist := MustCreateUser(t, db, "+15555550100")
+	oldToken := playlist.Token
+
+	// Rotate token.
+	playlist, err := s.RotateToken(ctx, playlist.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if playlist.Token == oldToken {
+		t.Fatal("expected new token")
+	}
+
+	// Both tokens should resolve during the grace period.
+	if other, err := s.FindPlaylistByToken(ctx, oldToken); err != nil {
+		t.Fatal(err)
+	} else if other.Token != playlist.Token {
+		t.Fatalf("unexpected token: %s", other.Token)
+	}
+	if _, err := s.FindPlaylistByToken(ctx, playlist.Token); err != nil {
+		t.Fatal(err)
+	}
+
+	// The old token should be removed after the grace period.
+	db.Now = func() time.Time { return Now.Add(2 * time.Hour) }
+	if err := s.RemoveExpiredTokens(peapod.NewSystemContext(ctx)); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.FindPlaylistByToken(ctx, oldToken); err != peapod.ErrPlaylistNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a playlist token cannot be rotated by another user.
+func TestPlaylistService_RotateToken_ErrUnauthorized(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewPlaylistService(db.DB)
+
+	MustCreateUser(t, db, "+15555550100")
+
+	ctx := peapod.NewContext(context.Background(), &peapod.User{ID: 1000})
+	if _, err := s.RotateToken(ctx, 1); err != peapod.ErrUnauthorized {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a user can be invited to a playlist and join it.
+func TestPlaylistService_AcceptPlaylistInvite(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewPlaylistService(db.DB)
+
+	// Create owner & invitee.
+	ownerCtx, _, playlist := MustCreateUser(t, db, "+15555550100")
+	inviteeCtx, invitee, _ := MustCreateUser(t, db, "+15555550101")
+
+	// Only the owner can invite.
+	invite := &peapod.PlaylistInvite{PlaylistID: playlist.ID, MobileNumber: invitee.MobileNumber, Role: peapod.PlaylistRoleContributor}
+	if err := s.CreatePlaylistInvite(inviteeCtx, invite); err != peapod.ErrUnauthorized {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := s.CreatePlaylistInvite(ownerCtx, invite); err != nil {
+		t.Fatal(err)
+	}
+
+	// Invite should be listed by mobile number.
+	if a, err := s.FindPlaylistInvitesByMobileNumber(inviteeCtx, invitee.MobileNumber); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].ID != invite.ID {
+		t.Fatalf("unexpected invites: %#v", a)
+	}
+
+	// Only the invited user can accept.
+	if _, err := s.AcceptPlaylistInvite(ownerCtx, invite.ID); err != peapod.ErrUnauthorized {
+		t.Fatalf("unexpected error: %v", err)
+	} else if member, err := s.AcceptPlaylistInvite(inviteeCtx, invite.ID); err != nil {
+		t.Fatal(err)
+	} else if member.UserID != invitee.ID || member.Role != peapod.PlaylistRoleContributor {
+		t.Fatalf("unexpected member: %#v", member)
+	}
+
+	// Invite should be removed once accepted.
+	if a, err := s.FindPlaylistInvitesByMobileNumber(inviteeCtx, invitee.MobileNumber); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected invites: %#v", a)
+	}
+
+	// Shared playlist should follow the invitee's own playlist.
+	if a, err := s.FindPlaylistsByUserID(inviteeCtx, invitee.ID); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 || a[1].ID != playlist.ID {
+		t.Fatalf("unexpected playlists: %#v", a)
+	}
+
+	// Members should include the owner & the invitee.
+	if a, err := s.FindPlaylistMembers(ownerCtx, playlist.ID); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 || a[0].Role != peapod.PlaylistRoleOwner || a[1].UserID != invitee.ID {
+		t.Fatalf("unexpected members: %#v", a)
+	}
+}
+
+// Ensure a smart playlist includes only the owner's tracks matching its filter.
+func TestPlaylistService_CreatePlaylist_Smart(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewPlaylistService(db.DB)
+	trackService := bolt.NewTrackService(db.DB)
+
+	ctx, user, playlist := MustCreateUser(t, db, "+15555550100")
+
+	// Add tracks with differing tags & durations.
+	for _, track := range []*peapod.Track{
+		{PlaylistID: playlist.ID, Filename: "a.mp3", Title: "A", Duration: 10 * time.Minute, Tags: []string{"#News"}},
+		{PlaylistID: playlist.ID, Filename: "b.mp3", Title: "B", Duration: 30 * time.Minute, Tags: []string{"news"}},
+		{PlaylistID: playlist.ID, Filename: "c.mp3", Title: "C", Duration: 10 * time.Minute, Tags: []string{"music"}},
+	} {
+		if err := trackService.CreateTrack(ctx, track); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Create a smart playlist for short news tracks.
+	smart := &peapod.Playlist{
+		Name:   "Short News",
+		Type:   peapod.PlaylistTypeSmart,
+		Filter: &peapod.SmartFilter{Tags: []string{"news"}, MaxAge: 7 * 24 * time.Hour, MaxDuration: 20 * time.Minute},
+	}
+	if err := s.CreatePlaylist(ctx, smart); err != nil {
+		t.Fatal(err)
+	} else if smart.OwnerID != user.ID {
+		t.Fatalf("unexpected owner: %d", smart.OwnerID)
+	}
+
+	if other, err := s.FindPlaylistByToken(ctx, smart.Token); err != nil {
+		t.Fatal(err)
+	} else if len(other.Tracks) != 1 || other.Tracks[0].Title != "A" {
+		t.Fatalf("unexpected tracks: %#v", other.Tracks)
+	}
+
+	// Tracks should age out of the playlist.
+	db.Now = func() time.Time { return Now.Add(8 * 24 * time.Hour) }
+	if other, err := s.FindPlaylistByToken(ctx, smart.Token); err != nil {
+		t.Fatal(err)
+	} else if len(other.Tracks) != 0 {
+		t.Fatalf("unexpected tracks: %#v", other.Tracks)
+	}
+
+	// A smart playlist requires a filter.
+	if err := s.CreatePlaylist(ctx, &peapod.Playlist{Name: "X", Type: peapod.PlaylistTypeSmart}); err != peapod.ErrSmartFilterRequired {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a merged playlist is marked as updated when a source changes.
+func TestPlaylistService_CreatePlaylist_Merged(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewPlaylistService(db.DB)
+
+	ctx, _, playlist := MustCreateUser(t, db, "+15555550100")
+
+	// Create a second standard playlist & merge it with the default one.
+	other := &peapod.Playlist{Name: "News"}
+	if err := s.CreatePlaylist(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	merged := &peapod.Playlist{Name: "Everything", Type: peapod.PlaylistTypeMerged, SourceIDs: []int{playlist.ID, other.ID}}
+	if err := s.CreatePlaylist(ctx, merged); err != nil {
+		t.Fatal(err)
+	}
+
+	// Merged playlists cannot be used as sources.
+	if err := s.CreatePlaylist(ctx, &peapod.Playlist{Name: "X", Type: peapod.PlaylistTypeMerged, SourceIDs: []int{merged.ID}}); err != peapod.ErrInvalidMergedSource {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Adding a track to a source should update the merged playlist.
+	db.Now = func() time.Time { return Now.Add(time.Hour) }
+	if err := bolt.NewTrackService(db.DB).CreateTrack(ctx, &peapod.Track{PlaylistID: other.ID, Filename: "a.mp3"}); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := s.FindPlaylistByID(ctx, merged.ID); err != nil {
+		t.Fatal(err)
+	} else if !p.UpdatedAt.Equal(Now.Add(time.Hour)) {
+		t.Fatalf("unexpected updated at: %s", p.UpdatedAt)
+	} else if !reflect.DeepEqual(p.SourceIDs, merged.SourceIDs) {
+		t.Fatalf("unexpected source ids: %v", p.SourceIDs)
+	}
+}
+
+// Ensure retention limits expire the oldest unpinned tracks.
+func TestPlaylistService_SetPlaylistRetention(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewPlaylistService(db.DB)
+	trackService := bolt.NewTrackService(db.DB)
+
+	ctx, _, playlist := MustCreateUser(t, db, "+15555550100")
+	playlistID := playlist.ID
+
+	// Add three tracks an hour apart & pin the oldest.
+	for i, name := range []string{"a.mp3", "b.mp3", "c.mp3"} {
+		db.Now = func() time.Time { return Now.Add(time.Duration(i) * time.Hour) }
+		if err := trackService.CreateTrack(ctx, &peapod.Track{PlaylistID: playlistID, Filename: name, Title: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := trackService.SetTrackPinned(ctx, 1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep a maximum of two tracks.
+	if err := s.SetPlaylistRetention(ctx, playlistID, &peapod.Retention{MaxTracks: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := s.FindPlaylistsWithRetention(peapod.NewSystemContext(context.Background()))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(a) != 1 || a[0].Retention.MaxTracks != 2 {
+		t.Fatalf("unexpected playlists: %#v", a)
+	}
+
+	// The oldest unpinned track should be expired.
+	expired := a[0].Retention.ExpiredTracks(a[0].Tracks, Now)
+	if len(expired) != 1 || expired[0].Filename != "b.mp3" {
+		t.Fatalf("unexpected expired tracks: %#v", expired)
+	}
+
+	// Delete the track & verify the removal is recorded.
+	if err := trackService.DeleteTrack(ctx, expired[0].ID, peapod.TrackRemovalReasonRetention); err != nil {
+		t.Fatal(err)
+	} else if removals, err := trackService.FindTrackRemovals(ctx, playlistID); err != nil {
+		t.Fatal(err)
+	} else if len(removals) != 1 || removals[0].Filename != "b.mp3" || removals[0].Reason != peapod.TrackRemovalReasonRetention {
+		t.Fatalf("unexpected removals: %#v", removals)
+	}
+
+	// Removing limits should remove the playlist from the retention list.
+	if err := s.SetPlaylistRetention(ctx, playlistID, nil); err != nil {
+		t.Fatal(err)
+	} else if a, err := s.FindPlaylistsWithRetention(peapod.NewSystemContext(context.Background())); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 0 {
+		t.Fatalf("unexpected playlists: %#v", a)
+	}
+}
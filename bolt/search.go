@@ -0,0 +1,268 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/middlemost/peapod"
+)
+
+// Term weights by field. Matches in the title rank above other fields.
+const (
+	titleTermWeight       = 3
+	descriptionTermWeight = 1
+	transcriptTermWeight  = 1
+)
+
+// indexTrack updates the full-text index for a track. Transcript terms are
+// retained from the previous index if the track's transcript is not attached.
+//
+// Postings are keyed by the track's playlist so a search only reads the terms
+// of playlists the user can access. Tracks never move between playlists.
+func indexTrack(ctx context.Context, tx *Tx, track *peapod.Track) error {
+	// Determine transcript term weights.
+	var transcriptTerms map[string]int
+	if track.Transcript != nil {
+		transcriptTerms = make(map[string]int)
+		for _, term := range peapod.Tokenize(track.Transcript.Text()) {
+			transcriptTerms[term] += transcriptTermWeight
+		}
+		if err := putTermWeights(tx, []byte("Tracks.TranscriptTerms"), track.ID, transcriptTerms); err != nil {
+			return err
+		}
+	} else {
+		transcriptTerms = getTermWeights(tx, []byte("Tracks.TranscriptTerms"), track.ID)
+	}
+
+	// Combine with title & description terms.
+	terms := make(map[string]int)
+	for _, term := range peapod.Tokenize(track.Title) {
+		terms[term] += titleTermWeight
+	}
+	for _, term := range peapod.Tokenize(track.Description) {
+		terms[term] += descriptionTermWeight
+	}
+	for term, weight := range transcriptTerms {
+		terms[term] += weight
+	}
+
+	// Replace previous index entries.
+	if err := unindexTrack(ctx, tx, track.PlaylistID, track.ID, false); err != nil {
+		return err
+	}
+
+	bkt, err := tx.CreateBucketIfNotExists([]byte("Playlists.Term"))
+	if err != nil {
+		return err
+	}
+	for term, weight := range terms {
+		if err := bkt.Put(makePlaylistTermKey(track.PlaylistID, term, track.ID), itob(weight)); err != nil {
+			return err
+		}
+	}
+	if err := putTermWeights(tx, []byte("Tracks.Terms"), track.ID, terms); err != nil {
+		return err
+	}
+	return updateIndexedTrackN(tx, 1)
+}
+
+// unindexTrack removes a track from the full-text index. Stored transcript
+// terms are also removed if transcript is true.
+func unindexTrack(ctx context.Context, tx *Tx, playlistID, trackID int, transcript bool) error {
+	if bkt := tx.Bucket([]byte("Playlists.Term")); bkt != nil {
+		for term := range getTermWeights(tx, []byte("Tracks.Terms"), trackID) {
+			if err := bkt.Delete(makePlaylistTermKey(playlistID, term, trackID)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if bkt := tx.Bucket([]byte("Tracks.Terms")); bkt != nil && bkt.Get(itob(trackID)) != nil {
+		if err := bkt.Delete(itob(trackID)); err != nil {
+			return err
+		} else if err := updateIndexedTrackN(tx, -1); err != nil {
+			return err
+		}
+	}
+
+	if bkt := tx.Bucket([]byte("Tracks.TranscriptTerms")); transcript && bkt != nil {
+		if err := bkt.Delete(itob(trackID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchTracks returns matching tracks ranked by the sum of each query term's
+// weight multiplied by its inverse document frequency.
+func searchTracks(ctx context.Context, tx *Tx, search peapod.TrackSearch) (*peapod.TrackSearchResult, error) {
+	terms := peapod.Tokenize(search.Query)
+	if len(terms) == 0 {
+		return nil, peapod.ErrSearchQueryRequired
+	}
+
+	// Normalize pagination.
+	limit := search.Limit
+	if limit <= 0 {
+		limit = peapod.DefaultSearchLimit
+	} else if limit > peapod.MaxSearchLimit {
+		limit = peapod.MaxSearchLimit
+	}
+	offset := search.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	// Only search standard playlists the user can read.
+	user := peapod.FromContext(ctx)
+	playlists, err := findPlaylistsByUserID(ctx, tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	var playlistIDs []int
+	for _, p := range playlists {
+		if p.Type == peapod.PlaylistTypeStandard {
+			playlistIDs = append(playlistIDs, p.ID)
+		}
+	}
+
+	bkt := tx.Bucket([]byte("Playlists.Term"))
+	if bkt == nil {
+		return &peapod.TrackSearchResult{}, nil
+	}
+
+	// Total number of indexed tracks used for inverse document frequency.
+	n := indexedTrackN(tx)
+
+	// Score every track in the user's playlists which matches at least one term.
+	scores := make(map[int]float64)
+	for _, term := range uniqueStrings(terms) {
+		type posting struct{ trackID, weight int }
+		var postings []posting
+
+		cur := bkt.Cursor()
+		for _, playlistID := range playlistIDs {
+			prefix := makePlaylistTermKey(playlistID, term, 0)[:8+len(term)+1]
+			for k, v := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, v = cur.Next() {
+				postings = append(postings, posting{btoi(k[len(prefix):]), btoi(v)})
+			}
+		}
+
+		idf := math.Log(1 + float64(n)/float64(len(postings)+1))
+		for _, p := range postings {
+			scores[p.trackID] += float64(p.weight) * idf
+		}
+	}
+
+	// Load matching tracks.
+	type match struct {
+		track *peapod.Track
+		score float64
+	}
+	matches := make([]match, 0, len(scores))
+	for id, score := range scores {
+		track, err := findTrackByID(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		} else if track == nil {
+			continue
+		}
+		matches = append(matches, match{track: track, score: score})
+	}
+
+	// Sort by score and then by newest first.
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].track.ID > matches[j].track.ID
+	})
+
+	// Paginate.
+	result := &peapod.TrackSearchResult{Total: len(matches), Tracks: make([]*peapod.Track, 0, limit)}
+	for i := offset; i < len(matches) && i < offset+limit; i++ {
+		result.Tracks = append(result.Tracks, matches[i].track)
+	}
+	return result, nil
+}
+
+// indexedTrackN returns the number of tracks in the full-text index.
+func indexedTrackN(tx *Tx) int {
+	if bkt := tx.Bucket([]byte("Tracks.TermStats")); bkt != nil {
+		if v := bkt.Get([]byte("N")); v != nil {
+			return btoi(v)
+		}
+	}
+	return 0
+}
+
+// updateIndexedTrackN adjusts the number of tracks in the full-text index.
+func updateIndexedTrackN(tx *Tx, delta int) error {
+	bkt, err := tx.CreateBucketIfNotExists([]byte("Tracks.TermStats"))
+	if err != nil {
+		return err
+	}
+	if n := indexedTrackN(tx) + delta; n > 0 {
+		return bkt.Put([]byte("N"), itob(n))
+	}
+	return bkt.Delete([]byte("N"))
+}
+
+// makePlaylistTermKey returns a posting key for a term in a playlist's track.
+func makePlaylistTermKey(playlistID int, term string, trackID int) []byte {
+	return append(itob(playlistID), makeStringIndexKey(term, trackID)...)
+}
+
+// getTermWeights returns the stored term weights for a track.
+func getTermWeights(tx *Tx, name []byte, trackID int) map[string]int {
+	bkt := tx.Bucket(name)
+	if bkt == nil {
+		return nil
+	}
+	v := bkt.Get(itob(trackID))
+	if v == nil {
+		return nil
+	}
+
+	m := make(map[string]int)
+	for _, line := range strings.Split(string(v), "\n") {
+		if i := strings.LastIndexByte(line, ' '); i != -1 {
+			weight, _ := strconv.Atoi(line[i+1:])
+			m[line[:i]] = weight
+		}
+	}
+	return m
+}
+
+// putTermWeights stores term weights for a track as "term weight" lines.
+func putTermWeights(tx *Tx, name []byte, trackID int, m map[string]int) error {
+	bkt, err := tx.CreateBucketIfNotExists(name)
+	if err != nil {
+		return err
+	}
+
+	lines := make([]string, 0, len(m))
+	for term, weight := range m {
+		lines = append(lines, term+" "+strconv.Itoa(weight))
+	}
+	sort.Strings(lines)
+	return bkt.Put(itob(trackID), []byte(strings.Join(lines, "\n")))
+}
+
+// uniqueStrings returns a with duplicates removed.
+func uniqueStrings(a []string) []string {
+	var other []string
+	seen := make(map[string]struct{})
+	for _, s := range a {
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		other = append(other, s)
+	}
+	return other
+}
@@ -0,0 +1,467 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/middlemost/peapod"
+)
+
+// Ensure service implements interface.
+var _ peapod.SessionService = &SessionService{}
+
+// SessionService represents a service to manage login codes & sessions.
+// Login codes & session tokens act as credentials so no user is required.
+type SessionService struct {
+	db *DB
+}
+
+// NewSessionService returns a new instance of SessionService.
+func NewSessionService(db *DB) *SessionService {
+	return &SessionService{db: db}
+}
+
+// CreateLoginCode generates & stores a new login code for a mobile number.
+func (s *SessionService) CreateLoginCode(ctx context.Context, mobileNumber string) (string, error) {
+	if mobileNumber == "" {
+		return "", peapod.ErrUserMobileNumberRequired
+	}
+
+	tx, err := s.db.Begin(ctx, true)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Disabled users cannot log in.
+	if id := findUserIDByMobileNumber(ctx, tx, mobileNumber); id != 0 {
+		if user, err := findUserByID(ctx, tx, id); err != nil {
+			return "", err
+		} else if user != nil && user.Disabled {
+			return "", peapod.ErrUserDisabled
+		}
+	}
+
+	// Reject numbers locked out by incorrect attempts & limit codes sent to
+	// a number. Counts carry across codes until the throttle window ends.
+	th, err := findLoginThrottle(ctx, tx, mobileNumber)
+	if err != nil {
+		return "", err
+	} else if th.Attempts >= peapod.MaxLoginAttempts {
+		return "", peapod.ErrLoginAttemptsExceeded
+	} else if th.Sends >= peapod.MaxLoginCodes {
+		return "", peapod.ErrLoginCodeLimitExceeded
+	}
+	th.Sends++
+	if err := saveLoginThrottle(ctx, tx, mobileNumber, th); err != nil {
+		return "", err
+	}
+
+	code := peapod.GenerateLoginCode()
+	if err := saveLoginCode(ctx, tx, mobileNumber, &LoginCode{
+		CodeHash:  hashSecret(code),
+		ExpiresAt: encodeTime(tx.Now.Add(peapod.LoginCodeTTL)),
+	}); err != nil {
+		return "", err
+	} else if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// CreateSession verifies a login code and starts a new session.
+//
+// Incorrect attempts are recorded against the mobile number even though an
+// error is returned. The code is removed once it is used, expires or the
+// number exceeds the attempt limit.
+func (s *SessionService) CreateSession(ctx context.Context, mobileNumber, code string) (*peapod.Session, error) {
+	if code == "" {
+		return nil, peapod.ErrLoginCodeRequired
+	}
+
+	tx, err := s.db.Begin(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Failed verifications are committed so that attempts are counted and
+	// spent codes are removed. All other errors are rolled back.
+	session, err := createSession(ctx, tx, mobileNumber, code)
+	switch err {
+	case nil, peapod.ErrInvalidLoginCode, peapod.ErrLoginAttemptsExceeded, peapod.ErrLoginCodeExpired:
+		if e := tx.Commit(); e != nil {
+			return nil, e
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// FindSessionByToken returns an unexpired session with its user attached.
+// Returns nil if the session does not exist or has expired.
+func (s *SessionService) FindSessionByToken(ctx context.Context, token string) (*peapod.Session, error) {
+	tx, err := s.db.Begin(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	session, err := findSessionByTokenHash(ctx, tx, hashSecret(token))
+	if err != nil || session == nil {
+		return nil, err
+	} else if !session.ExpiresAt.After(tx.Now) {
+		return nil, nil
+	}
+
+	if session.User, err = findUserByID(ctx, tx, session.UserID); err != nil {
+		return nil, err
+	} else if session.User == nil || session.User.Disabled {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// DeleteSession removes a session.
+func (s *SessionService) DeleteSession(ctx context.Context, token string) error {
+	tx, err := s.db.Begin(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tokenHash := hashSecret(token)
+	session, err := findSessionByTokenHash(ctx, tx, tokenHash)
+	if err != nil {
+		return err
+	} else if session == nil {
+		return peapod.ErrSessionNotFound
+	} else if err := deleteSession(ctx, tx, session.UserID, tokenHash); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveExpiredSessions removes expired sessions, login codes & throttles.
+// Requires a system context.
+func (s *SessionService) RemoveExpiredSessions(ctx context.Context) error {
+	tx, err := s.db.BeginSystem(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := removeExpiredSessions(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func createSession(ctx context.Context, tx *Tx, mobileNumber, code string) (*peapod.Session, error) {
+	lc, err := findLoginCode(ctx, tx, mobileNumber)
+	if err != nil {
+		return nil, err
+	} else if lc == nil {
+		return nil, peapod.ErrInvalidLoginCode
+	}
+
+	// Remove expired codes.
+	if !decodeTime(lc.ExpiresAt).After(tx.Now) {
+		if err := deleteLoginCode(ctx, tx, mobileNumber); err != nil {
+			return nil, err
+		}
+		return nil, peapod.ErrLoginCodeExpired
+	}
+
+	// Record failed attempt & remove code once the limit is reached.
+	th, err := findLoginThrottle(ctx, tx, mobileNumber)
+	if err != nil {
+		return nil, err
+	} else if th.Attempts >= peapod.MaxLoginAttempts {
+		if err := deleteLoginCode(ctx, tx, mobileNumber); err != nil {
+			return nil, err
+		}
+		return nil, peapod.ErrLoginAttemptsExceeded
+	} else if subtle.ConstantTimeCompare([]byte(hashSecret(code)), []byte(lc.CodeHash)) != 1 {
+		if th.Attempts++; th.Attempts >= peapod.MaxLoginAttempts {
+			if err := deleteLoginCode(ctx, tx, mobileNumber); err != nil {
+				return nil, err
+			} else if err := saveLoginThrottle(ctx, tx, mobileNumber, th); err != nil {
+				return nil, err
+			}
+			return nil, peapod.ErrLoginAttemptsExceeded
+		} else if err := saveLoginThrottle(ctx, tx, mobileNumber, th); err != nil {
+			return nil, err
+		}
+		return nil, peapod.ErrInvalidLoginCode
+	}
+
+	// Code is valid so it can no longer be used & the number's counts reset.
+	if err := deleteLoginCode(ctx, tx, mobileNumber); err != nil {
+		return nil, err
+	} else if err := deleteLoginThrottle(ctx, tx, mobileNumber); err != nil {
+		return nil, err
+	}
+
+	// Find or create user.
+	var user *peapod.User
+	if id := findUserIDByMobileNumber(ctx, tx, mobileNumber); id != 0 {
+		if user, err = findUserByID(ctx, tx, id); err != nil {
+			return nil, err
+		} else if user.Disabled {
+			return nil, peapod.ErrUserDisabled
+		}
+	} else {
+		user = &peapod.User{MobileNumber: mobileNumber}
+		if err := createUser(ctx, tx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	session := &peapod.Session{
+		Token:     tx.GenerateToken(),
+		UserID:    user.ID,
+		User:      user,
+		CreatedAt: tx.Now,
+		ExpiresAt: tx.Now.Add(peapod.SessionTTL),
+	}
+
+	// Save session by token hash & index by user.
+	tokenHash := hashSecret(session.Token)
+	if buf, err := marshalSession(session); err != nil {
+		return nil, err
+	} else if bkt, err := tx.CreateBucketIfNotExists([]byte("Sessions")); err != nil {
+		return nil, err
+	} else if err := bkt.Put([]byte(tokenHash), buf); err != nil {
+		return nil, err
+	} else if bkt, err := tx.CreateBucketIfNotExists([]byte("Users.Sessions")); err != nil {
+		return nil, err
+	} else if err := bkt.Put(makeUserSessionKey(user.ID, tokenHash), nil); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func findSessionByTokenHash(ctx context.Context, tx *Tx, tokenHash string) (*peapod.Session, error) {
+	bkt := tx.Bucket([]byte("Sessions"))
+	if bkt == nil {
+		return nil, nil
+	}
+
+	var session peapod.Session
+	if buf := bkt.Get([]byte(tokenHash)); buf == nil {
+		return nil, nil
+	} else if err := unmarshalSession(buf, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func deleteSession(ctx context.Context, tx *Tx, userID int, tokenHash string) error {
+	if err := tx.Bucket([]byte("Sessions")).Delete([]byte(tokenHash)); err != nil {
+		return err
+	}
+	return tx.Bucket([]byte("Users.Sessions")).Delete(makeUserSessionKey(userID, tokenHash))
+}
+
+// deleteUserSessions removes all sessions for a user.
+func deleteUserSessions(ctx context.Context, tx *Tx, userID int) error {
+	bkt := tx.Bucket([]byte("Users.Sessions"))
+	if bkt == nil {
+		return nil
+	}
+
+	var tokenHashes []string
+	cur := bkt.Cursor()
+	prefix := itob(userID)
+	for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+		tokenHashes = append(tokenHashes, string(k[8:]))
+	}
+
+	for _, tokenHash := range tokenHashes {
+		if err := deleteSession(ctx, tx, userID, tokenHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeExpiredSessions(ctx context.Context, tx *Tx) error {
+	if bkt := tx.Bucket([]byte("Sessions")); bkt != nil {
+		type entry struct {
+			userID    int
+			tokenHash string
+		}
+		var expired []entry
+
+		cur := bkt.Cursor()
+		for k, v := cur.First(); k != nil; k, v = cur.Next() {
+			var session peapod.Session
+			if err := unmarshalSession(v, &session); err != nil {
+				return err
+			} else if !session.ExpiresAt.After(tx.Now) {
+				expired = append(expired, entry{session.UserID, string(k)})
+			}
+		}
+
+		for _, e := range expired {
+			if err := deleteSession(ctx, tx, e.userID, e.tokenHash); err != nil {
+				return err
+			}
+		}
+	}
+
+	if bkt := tx.Bucket([]byte("LoginCodes")); bkt != nil {
+		var expired [][]byte
+		cur := bkt.Cursor()
+		for k, v := cur.First(); k != nil; k, v = cur.Next() {
+			var lc LoginCode
+			if err := proto.Unmarshal(v, &lc); err != nil {
+				return err
+			} else if !decodeTime(lc.ExpiresAt).After(tx.Now) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+		}
+
+		for _, k := range expired {
+			if err := bkt.Delete(k); err != nil {
+				return err
+			}
+		}
+	}
+
+	if bkt := tx.Bucket([]byte("LoginThrottles")); bkt != nil {
+		var expired [][]byte
+		cur := bkt.Cursor()
+		for k, v := cur.First(); k != nil; k, v = cur.Next() {
+			var th LoginThrottle
+			if err := proto.Unmarshal(v, &th); err != nil {
+				return err
+			} else if !loginThrottleActive(tx, &th) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+		}
+
+		for _, k := range expired {
+			if err := bkt.Delete(k); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func findLoginCode(ctx context.Context, tx *Tx, mobileNumber string) (*LoginCode, error) {
+	bkt := tx.Bucket([]byte("LoginCodes"))
+	if bkt == nil {
+		return nil, nil
+	}
+
+	var lc LoginCode
+	if buf := bkt.Get([]byte(mobileNumber)); buf == nil {
+		return nil, nil
+	} else if err := proto.Unmarshal(buf, &lc); err != nil {
+		return nil, err
+	}
+	return &lc, nil
+}
+
+func saveLoginCode(ctx context.Context, tx *Tx, mobileNumber string, lc *LoginCode) error {
+	if buf, err := proto.Marshal(lc); err != nil {
+		return err
+	} else if bkt, err := tx.CreateBucketIfNotExists([]byte("LoginCodes")); err != nil {
+		return err
+	} else if err := bkt.Put([]byte(mobileNumber), buf); err != nil {
+		return err
+	}
+	return nil
+}
+
+func deleteLoginCode(ctx context.Context, tx *Tx, mobileNumber string) error {
+	bkt := tx.Bucket([]byte("LoginCodes"))
+	if bkt == nil {
+		return nil
+	}
+	return bkt.Delete([]byte(mobileNumber))
+}
+
+// findLoginThrottle returns the login throttle for a mobile number. A new
+// throttle is returned if none exists or the previous window has ended.
+func findLoginThrottle(ctx context.Context, tx *Tx, mobileNumber string) (*LoginThrottle, error) {
+	var th LoginThrottle
+	if bkt := tx.Bucket([]byte("LoginThrottles")); bkt != nil {
+		if buf := bkt.Get([]byte(mobileNumber)); buf != nil {
+			if err := proto.Unmarshal(buf, &th); err != nil {
+				return nil, err
+			} else if loginThrottleActive(tx, &th) {
+				return &th, nil
+			}
+		}
+	}
+	return &LoginThrottle{WindowStart: encodeTime(tx.Now)}, nil
+}
+
+func saveLoginThrottle(ctx context.Context, tx *Tx, mobileNumber string, th *LoginThrottle) error {
+	if buf, err := proto.Marshal(th); err != nil {
+		return err
+	} else if bkt, err := tx.CreateBucketIfNotExists([]byte("LoginThrottles")); err != nil {
+		return err
+	} else if err := bkt.Put([]byte(mobileNumber), buf); err != nil {
+		return err
+	}
+	return nil
+}
+
+func deleteLoginThrottle(ctx context.Context, tx *Tx, mobileNumber string) error {
+	bkt := tx.Bucket([]byte("LoginThrottles"))
+	if bkt == nil {
+		return nil
+	}
+	return bkt.Delete([]byte(mobileNumber))
+}
+
+// loginThrottleActive returns true if the throttle's window has not ended.
+func loginThrottleActive(tx *Tx, th *LoginThrottle) bool {
+	return decodeTime(th.WindowStart).Add(peapod.LoginThrottleWindow).After(tx.Now)
+}
+
+// hashSecret returns a hex-encoded SHA-256 hash of a code or token so that
+// secrets are not stored in plain text.
+func hashSecret(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+// makeUserSessionKey returns an index key for a user id & token hash.
+func makeUserSessionKey(userID int, tokenHash string) []byte {
+	return append(itob(userID), tokenHash...)
+}
+
+func marshalSession(v *peapod.Session) ([]byte, error) {
+	return proto.Marshal(&Session{
+		UserID:    int64(v.UserID),
+		CreatedAt: encodeTime(v.CreatedAt),
+		ExpiresAt: encodeTime(v.ExpiresAt),
+	})
+}
+
+func unmarshalSession(data []byte, v *peapod.Session) error {
+	var pb Session
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return err
+	}
+	*v = peapod.Session{
+		UserID:    int(pb.UserID),
+		CreatedAt: decodeTime(pb.CreatedAt),
+		ExpiresAt: decodeTime(pb.ExpiresAt),
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package bolt_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/bolt"
+)
+
+// Ensure a login code can be exchanged for a session.
+func TestSessionService_CreateSession(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewSessionService(db.DB)
+	ctx := context.Background()
+
+	code, err := s.CreateLoginCode(ctx, "+15555550100")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(code) != peapod.LoginCodeLength {
+		t.Fatalf("unexpected code: %s", code)
+	}
+
+	// Exchange code. The user should be created.
+	session, err := s.CreateSession(ctx, "+15555550100", code)
+	if err != nil {
+		t.Fatal(err)
+	} else if session.Token == "" || session.User == nil || session.User.MobileNumber != "+15555550100" {
+		t.Fatalf("unexpected session: %#v", session)
+	} else if !session.ExpiresAt.Equal(Now.Add(peapod.SessionTTL)) {
+		t.Fatalf("unexpected expiration: %s", session.ExpiresAt)
+	}
+
+	// Codes can only be used once.
+	if _, err := s.CreateSession(ctx, "+15555550100", code); err != peapod.ErrInvalidLoginCode {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Session should be found by token until it is deleted.
+	if other, err := s.FindSessionByToken(ctx, session.Token); err != nil {
+		t.Fatal(err)
+	} else if other == nil || other.UserID != session.UserID || other.User.ID != session.UserID {
+		t.Fatalf("unexpected session: %#v", other)
+	} else if err := s.DeleteSession(ctx, session.Token); err != nil {
+		t.Fatal(err)
+	} else if other, err := s.FindSessionByToken(ctx, session.Token); err != nil {
+		t.Fatal(err)
+	} else if other != nil {
+		t.Fatal("expected session to be deleted")
+	}
+}
+
+// Ensure a failed login for a disabled user is rolled back & leaves the
+// login code unspent.
+func TestSessionService_CreateSession_ErrUserDisabled(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewSessionService(db.DB)
+	userService := bolt.NewUserService(db.DB)
+	ctx := context.Background()
+	sysCtx := peapod.NewSystemContext(ctx)
+
+	// Disable the user after the login code is sent.
+	_, user, _ := MustCreateUser(t, db, "+15555550100")
+	code, err := s.CreateLoginCode(ctx, user.MobileNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disabled, enabled := true, false
+	if _, err := userService.UpdateUserAccess(sysCtx, user.ID, peapod.UserAccessUpdate{Disabled: &disabled}); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.CreateSession(ctx, user.MobileNumber, code); err != peapod.ErrUserDisabled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Once re-enabled the same code can be used.
+	if _, err := userService.UpdateUserAccess(sysCtx, user.ID, peapod.UserAccessUpdate{Disabled: &enabled}); err != nil {
+		t.Fatal(err)
+	} else if session, err := s.CreateSession(ctx, user.MobileNumber, code); err != nil {
+		t.Fatal(err)
+	} else if session.UserID != user.ID {
+		t.Fatalf("unexpected session: %#v", session)
+	}
+}
+
+// Ensure login codes are invalidated after too many incorrect attempts.
+func TestSessionService_CreateSession_ErrLoginAttemptsExceeded(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewSessionService(db.DB)
+	ctx := context.Background()
+
+	code, err := s.CreateLoginCode(ctx, "+15555550100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for i := 1; i < peapod.MaxLoginAttempts; i++ {
+		if _, err := s.CreateSession(ctx, "+15555550100", wrong); err != peapod.ErrInvalidLoginCode {
+			t.Fatalf("unexpected error: attempt=%d err=%v", i, err)
+		}
+	}
+	if _, err := s.CreateSession(ctx, "+15555550100", wrong); err != peapod.ErrLoginAttemptsExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The correct code no longer works.
+	if _, err := s.CreateSession(ctx, "+15555550100", code); err != peapod.ErrInvalidLoginCode {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The number is locked out until the throttle window ends.
+	if _, err := s.CreateLoginCode(ctx, "+15555550100"); err != peapod.ErrLoginAttemptsExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	db.Now = func() time.Time { return Now.Add(peapod.LoginThrottleWindow) }
+	if code, err := s.CreateLoginCode(ctx, "+15555550100"); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.CreateSession(ctx, "+15555550100", code); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure incorrect attempts are counted across login codes for a number.
+func TestSessionService_CreateSession_ErrLoginAttemptsExceeded_NewCode(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewSessionService(db.DB)
+	ctx := context.Background()
+
+	for i := 1; i < peapod.MaxLoginAttempts; i++ {
+		code, err := s.CreateLoginCode(ctx, "+15555550100")
+		if err != nil {
+			t.Fatal(err)
+		}
+		wrong := "000000"
+		if code == wrong {
+			wrong = "111111"
+		}
+		if _, err := s.CreateSession(ctx, "+15555550100", wrong); err != peapod.ErrInvalidLoginCode {
+			t.Fatalf("unexpected error: attempt=%d err=%v", i, err)
+		}
+	}
+
+	// Requesting a new code does not reset the attempt count.
+	code, err := s.CreateLoginCode(ctx, "+15555550100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	if _, err := s.CreateSession(ctx, "+15555550100", wrong); err != peapod.ErrLoginAttemptsExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure the number of login codes sent to a number is limited.
+func TestSessionService_CreateLoginCode_ErrLoginCodeLimitExceeded(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewSessionService(db.DB)
+	ctx := context.Background()
+
+	for i := 0; i < peapod.MaxLoginCodes; i++ {
+		if _, err := s.CreateLoginCode(ctx, "+15555550100"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.CreateLoginCode(ctx, "+15555550100"); err != peapod.ErrLoginCodeLimitExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Other numbers are not affected.
+	if _, err := s.CreateLoginCode(ctx, "+15555550101"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Codes can be sent again once the window ends.
+	db.Now = func() time.Time { return Now.Add(peapod.LoginThrottleWindow) }
+	if _, err := s.CreateLoginCode(ctx, "+15555550100"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure login codes & sessions expire.
+func TestSessionService_Expiration(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewSessionService(db.DB)
+	ctx := context.Background()
+
+	code, err := s.CreateLoginCode(ctx, "+15555550100")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Now = func() time.Time { return Now.Add(peapod.LoginCodeTTL) }
+	if _, err := s.CreateSession(ctx, "+15555550100", code); err != peapod.ErrLoginCodeExpired {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Create a session & move past its expiration.
+	if code, err = s.CreateLoginCode(ctx, "+15555550100"); err != nil {
+		t.Fatal(err)
+	}
+	session, err := s.CreateSession(ctx, "+15555550100", code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Now = func() time.Time { return session.ExpiresAt }
+	if other, err := s.FindSessionByToken(ctx, session.Token); err != nil {
+		t.Fatal(err)
+	} else if other != nil {
+		t.Fatal("expected session to be expired")
+	} else if err := s.RemoveExpiredSessions(peapod.NewSystemContext(ctx)); err != nil {
+		t.Fatal(err)
+	} else if err := s.DeleteSession(ctx, session.Token); err != peapod.ErrSessionNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
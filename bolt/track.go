@@ -3,6 +3,9 @@ package bolt
 import (
 	"bytes"
 	"context"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
@@ -12,14 +15,23 @@ import (
 // Ensure service implements interface.
 var _ peapod.TrackService = &TrackService{}
 
+// DefaultTrashPeriod is the default time a deleted track is kept in the trash.
+const DefaultTrashPeriod = 30 * 24 * time.Hour
+
 // TrackService represents a service to manage tracks.
 type TrackService struct {
 	db *DB
+
+	// Time a deleted track remains restorable before it is purged.
+	TrashPeriod time.Duration
 }
 
 // NewTrackService returns a new instance of TrackService.
 func NewTrackService(db *DB) *TrackService {
-	return &TrackService{db: db}
+	return &TrackService{
+		db:          db,
+		TrashPeriod: DefaultTrashPeriod,
+	}
 }
 
 // FindTrackByID returns a track by id.
@@ -30,10 +42,20 @@ func (s *TrackService) FindTrackByID(ctx context.Context, id int) (*peapod.Track
 	}
 	defer tx.Rollback()
 
-	return findTrackByID(ctx, tx, id)
+	// Retrieve track & verify the current user is a member of its playlist.
+	track, err := findTrackByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if track == nil {
+		return nil, nil
+	} else if err := authorizePlaylistRole(ctx, tx, track.PlaylistID, peapod.IsValidPlaylistRole); err != nil {
+		return nil, err
+	}
+	return track, nil
 }
 
 // CreateTrack creates a new track on a playlist.
+// The current user must be able to contribute to the playlist.
 func (s *TrackService) CreateTrack(ctx context.Context, track *peapod.Track) error {
 	tx, err := s.db.BeginAuth(ctx, true)
 	if err != nil {
@@ -51,12 +73,169 @@ func (s *TrackService) CreateTrack(ctx context.Context, track *peapod.Track) err
 		return nil
 	}(); err != nil {
 		track.ID = 0
-		return nil
+		return err
 	}
 
 	return nil
 }
 
+// DeleteTrack moves a track to the current user's trash & records the removal.
+func (s *TrackService) DeleteTrack(ctx context.Context, id int, reason string) error {
+	tx, err := s.db.BeginAuth(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteTrack(ctx, tx, id, reason); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindTrashedTracks returns the tracks in the current user's trash.
+func (s *TrackService) FindTrashedTracks(ctx context.Context) ([]*peapod.Track, error) {
+	tx, err := s.db.BeginAuth(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return findTrashedTracks(ctx, tx, peapod.FromContext(ctx).ID)
+}
+
+// RestoreTrack moves a track from the current user's trash back to its playlist.
+func (s *TrackService) RestoreTrack(ctx context.Context, id int) (*peapod.Track, error) {
+	tx, err := s.db.BeginAuth(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	track, err := restoreTrack(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// UndoDeleteTrack restores the track most recently moved to the current user's trash.
+func (s *TrackService) UndoDeleteTrack(ctx context.Context) (*peapod.Track, error) {
+	tx, err := s.db.BeginAuth(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Find most recently deleted track.
+	tracks, err := findTrashedTracks(ctx, tx, peapod.FromContext(ctx).ID)
+	if err != nil {
+		return nil, err
+	} else if len(tracks) == 0 {
+		return nil, peapod.ErrTrashEmpty
+	}
+
+	track, err := restoreTrack(ctx, tx, tracks[0].ID)
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// PurgeTrash permanently removes all tracks which have been in the trash
+// longer than the trash period. Returns the purged tracks so their files
+// can be removed. Requires a system context.
+func (s *TrackService) PurgeTrash(ctx context.Context) ([]*peapod.Track, error) {
+	tx, err := s.db.BeginSystem(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tracks, err := purgeTrash(ctx, tx, tx.Now.Add(-s.TrashPeriod))
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+// PurgeTrack permanently deletes a track whether or not it is in a trash.
+// Returns the deleted track so its files can be removed. Requires a system context.
+func (s *TrackService) PurgeTrack(ctx context.Context, id int) (*peapod.Track, error) {
+	tx, err := s.db.BeginSystem(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	track, err := purgeTrack(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// SetTrackPinned sets whether a track is exempt from retention limits.
+func (s *TrackService) SetTrackPinned(ctx context.Context, id int, pinned bool) error {
+	tx, err := s.db.BeginAuth(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	track, err := findWritableTrackByID(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	track.Pinned = pinned
+	if err := saveTrack(ctx, tx, track); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindTrackRemovals returns the removal history for a playlist.
+func (s *TrackService) FindTrackRemovals(ctx context.Context, playlistID int) ([]*peapod.TrackRemoval, error) {
+	tx, err := s.db.Begin(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := authorizePlaylistRole(ctx, tx, playlistID, peapod.IsValidPlaylistRole); err != nil {
+		return nil, err
+	}
+	return findTrackRemovals(ctx, tx, playlistID)
+}
+
+// SearchTracks returns the current user's tracks matching a full-text query.
+func (s *TrackService) SearchTracks(ctx context.Context, search peapod.TrackSearch) (*peapod.TrackSearchResult, error) {
+	tx, err := s.db.BeginAuth(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return searchTracks(ctx, tx, search)
+}
+
+// FindTracks returns a page of the current user's tracks which match a filter.
+func (s *TrackService) FindTracks(ctx context.Context, filter peapod.TrackFilter) (*peapod.TrackPage, error) {
+	tx, err := s.db.BeginAuth(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return findTracks(ctx, tx, filter)
+}
+
 func findTrackByID(ctx context.Context, tx *Tx, id int) (*peapod.Track, error) {
 	bkt := tx.Bucket([]byte("Tracks"))
 	if bkt == nil {
@@ -81,6 +260,10 @@ func trackExists(ctx context.Context, tx *Tx, id int) bool {
 }
 
 func createTrack(ctx context.Context, tx *Tx, track *peapod.Track) error {
+	if err := authorizePlaylistRole(ctx, tx, track.PlaylistID, peapod.CanContribute); err != nil {
+		return err
+	}
+
 	bkt, err := tx.CreateBucketIfNotExists([]byte("Tracks"))
 	if err != nil {
 		return err
@@ -93,25 +276,71 @@ func createTrack(ctx context.Context, tx *Tx, track *peapod.Track) error {
 	// Update timestamps.
 	track.CreatedAt = tx.Now
 
-	// Save data & add to index.
+	// Normalize tags.
+	track.Tags = peapod.NormalizeTags(track.Tags)
+
+	// Save data & add to indexes.
 	if err := saveTrack(ctx, tx, track); err != nil {
 		return err
-	} else if err := updateIndex(ctx, tx, []byte("Playlists.Tracks"), 0, 0, track.PlaylistID, track.ID); err != nil {
+	} else if err := addTrackIndexes(ctx, tx, track); err != nil {
 		return err
 	}
 	return nil
 }
 
+// addTrackIndexes adds a track to its playlist & tag indexes.
+func addTrackIndexes(ctx context.Context, tx *Tx, track *peapod.Track) error {
+	if err := updateIndex(ctx, tx, []byte("Playlists.Tracks"), 0, 0, track.PlaylistID, track.ID); err != nil {
+		return err
+	}
+
+	for _, tag := range track.Tags {
+		if bkt, err := tx.CreateBucketIfNotExists([]byte("Tracks.Tag")); err != nil {
+			return err
+		} else if err := bkt.Put(makeStringIndexKey(tag, track.ID), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeTrackIndexes removes a track from its playlist, tag & full-text indexes.
+func removeTrackIndexes(ctx context.Context, tx *Tx, track *peapod.Track) error {
+	if err := updateIndex(ctx, tx, []byte("Playlists.Tracks"), track.PlaylistID, track.ID, 0, 0); err != nil {
+		return err
+	}
+
+	for _, tag := range track.Tags {
+		if err := tx.Bucket([]byte("Tracks.Tag")).Delete(makeStringIndexKey(tag, track.ID)); err != nil {
+			return err
+		}
+	}
+	return unindexTrack(ctx, tx, track.PlaylistID, track.ID, false)
+}
+
 func saveTrack(ctx context.Context, tx *Tx, track *peapod.Track) error {
 	// Validate record.
 	if track.PlaylistID == 0 {
 		return peapod.ErrTrackPlaylistRequired
-	} else if !playlistExists(ctx, tx, track.PlaylistID) {
-		return peapod.ErrPlaylistNotFound
 	} else if track.Filename == "" {
 		return peapod.ErrTrackFilenameRequired
 	}
 
+	// Ensure playlist exists & can hold tracks.
+	playlist, err := findPlaylistByID(ctx, tx, track.PlaylistID)
+	if err != nil {
+		return err
+	} else if playlist == nil {
+		return peapod.ErrPlaylistNotFound
+	} else if playlist.Type != peapod.PlaylistTypeStandard {
+		return peapod.ErrPlaylistNotWritable
+	}
+
+	// Mark playlist as updated whenever one of its tracks changes.
+	if err := savePlaylist(ctx, tx, playlist); err != nil {
+		return err
+	}
+
 	// Update timestamps.
 	track.UpdatedAt = tx.Now
 
@@ -123,9 +352,291 @@ func saveTrack(ctx context.Context, tx *Tx, track *peapod.Track) error {
 	} else if err := bkt.Put(itob(track.ID), buf); err != nil {
 		return err
 	}
+
+	// Update full-text index.
+	if err := indexTrack(ctx, tx, track); err != nil {
+		return err
+	}
 	return nil
 }
 
+// findWritableTrackByID returns a track if the current user can modify it.
+func findWritableTrackByID(ctx context.Context, tx *Tx, id int) (*peapod.Track, error) {
+	track, err := findTrackByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if track == nil || !track.DeletedAt.IsZero() {
+		return nil, peapod.ErrTrackNotFound
+	}
+
+	user := peapod.FromContext(ctx)
+	if user == nil {
+		return nil, peapod.ErrUnauthorized
+	} else if role, err := findPlaylistRole(ctx, tx, track.PlaylistID, user.ID); err != nil {
+		return nil, err
+	} else if !peapod.CanContribute(role) {
+		return nil, peapod.ErrUnauthorized
+	}
+	return track, nil
+}
+
+// deleteTrack moves a track to the current user's trash, removes it from
+// its indexes and records the removal.
+func deleteTrack(ctx context.Context, tx *Tx, id int, reason string) error {
+	if reason == "" {
+		return peapod.ErrTrackReasonRequired
+	}
+
+	track, err := findWritableTrackByID(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	user := peapod.FromContext(ctx)
+
+	// Remove from feeds & search.
+	if err := removeTrackIndexes(ctx, tx, track); err != nil {
+		return err
+	}
+
+	// Mark record as deleted & add to the user's trash.
+	track.DeletedAt = tx.Now
+	if buf, err := marshalTrack(track); err != nil {
+		return err
+	} else if err := tx.Bucket([]byte("Tracks")).Put(itob(track.ID), buf); err != nil {
+		return err
+	} else if err := updateIndex(ctx, tx, []byte("Users.Trash"), 0, 0, user.ID, track.ID); err != nil {
+		return err
+	}
+
+	// Mark playlist as updated.
+	if playlist, err := findPlaylistByID(ctx, tx, track.PlaylistID); err != nil {
+		return err
+	} else if err := savePlaylist(ctx, tx, playlist); err != nil {
+		return err
+	}
+
+	// Record removal & allow it to be undone.
+	if err := createTrackRemoval(ctx, tx, &peapod.TrackRemoval{
+		PlaylistID: track.PlaylistID,
+		TrackID:    track.ID,
+		Title:      track.Title,
+		Filename:   track.Filename,
+		Size:       track.Size,
+		Reason:     reason,
+	}); err != nil {
+		return err
+	}
+	return recordTrackRemovalAction(ctx, tx, user.ID, track, reason)
+}
+
+// restoreTrack moves a track from the current user's trash back into its
+// playlist. The user must still be able to contribute to the playlist.
+func restoreTrack(ctx context.Context, tx *Tx, id int) (*peapod.Track, error) {
+	user := peapod.FromContext(ctx)
+
+	// Ensure track is in the user's trash.
+	if bkt := tx.Bucket([]byte("Users.Trash")); bkt == nil || bkt.Get(makeIndexKey(user.ID, id)) == nil {
+		return nil, peapod.ErrTrackNotFound
+	}
+
+	track, err := findTrackByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if track == nil {
+		return nil, peapod.ErrTrackNotFound
+	}
+
+	// Verify the user can still add to the playlist.
+	if role, err := findPlaylistRole(ctx, tx, track.PlaylistID, user.ID); err != nil {
+		return nil, err
+	} else if !peapod.CanContribute(role) {
+		return nil, peapod.ErrUnauthorized
+	}
+
+	// Remove from trash, save & re-add to indexes.
+	track.DeletedAt = time.Time{}
+	if err := updateIndex(ctx, tx, []byte("Users.Trash"), user.ID, track.ID, 0, 0); err != nil {
+		return nil, err
+	} else if err := saveTrack(ctx, tx, track); err != nil {
+		return nil, err
+	} else if err := addTrackIndexes(ctx, tx, track); err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// findTrashedTracks returns the tracks in a user's trash, most recently deleted first.
+func findTrashedTracks(ctx context.Context, tx *Tx, userID int) ([]*peapod.Track, error) {
+	bkt := tx.Bucket([]byte("Users.Trash"))
+	if bkt == nil {
+		return nil, nil
+	}
+
+	var a []*peapod.Track
+	cur := bkt.Cursor()
+	prefix := itob(userID)
+	for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+		track, err := findTrackByID(ctx, tx, btoi(k[8:]))
+		if err != nil {
+			return nil, err
+		} else if track != nil {
+			a = append(a, track)
+		}
+	}
+
+	sort.Slice(a, func(i, j int) bool {
+		if !a[i].DeletedAt.Equal(a[j].DeletedAt) {
+			return a[i].DeletedAt.After(a[j].DeletedAt)
+		}
+		return a[i].ID > a[j].ID
+	})
+	return a, nil
+}
+
+// purgeTrash permanently removes all trashed tracks deleted before a given time.
+func purgeTrash(ctx context.Context, tx *Tx, before time.Time) ([]*peapod.Track, error) {
+	return deleteTrashedTracks(ctx, tx, func(userID int, track *peapod.Track) bool {
+		return !track.DeletedAt.After(before)
+	})
+}
+
+// purgeTrack permanently removes a track. Tracks which are not in a trash are
+// removed from their indexes and the removal is recorded.
+func purgeTrack(ctx context.Context, tx *Tx, id int) (*peapod.Track, error) {
+	track, err := findTrackByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if track == nil {
+		return nil, peapod.ErrTrackNotFound
+	}
+
+	// Trashed tracks have already been removed from their playlist.
+	if !track.DeletedAt.IsZero() {
+		if _, err := deleteTrashedTracks(ctx, tx, func(userID int, other *peapod.Track) bool {
+			return other.ID == track.ID
+		}); err != nil {
+			return nil, err
+		}
+		return track, nil
+	}
+
+	// Remove from feeds & search and mark playlist as updated.
+	if err := removeTrackIndexes(ctx, tx, track); err != nil {
+		return nil, err
+	} else if playlist, err := findPlaylistByID(ctx, tx, track.PlaylistID); err != nil {
+		return nil, err
+	} else if err := savePlaylist(ctx, tx, playlist); err != nil {
+		return nil, err
+	}
+
+	// Record removal & delete record.
+	if err := createTrackRemoval(ctx, tx, &peapod.TrackRemoval{
+		PlaylistID: track.PlaylistID,
+		TrackID:    track.ID,
+		Title:      track.Title,
+		Filename:   track.Filename,
+		Size:       track.Size,
+		Reason:     peapod.TrackRemovalReasonAdmin,
+	}); err != nil {
+		return nil, err
+	} else if err := deleteTrackRecord(ctx, tx, track); err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// deleteTrashedTracks permanently removes trashed tracks for which fn returns
+// true. fn is called with the id of the user whose trash holds the track.
+func deleteTrashedTracks(ctx context.Context, tx *Tx, fn func(userID int, track *peapod.Track) bool) ([]*peapod.Track, error) {
+	bkt := tx.Bucket([]byte("Users.Trash"))
+	if bkt == nil {
+		return nil, nil
+	}
+
+	// Find matching entries.
+	var keys [][]byte
+	var a []*peapod.Track
+	cur := bkt.Cursor()
+	for k, _ := cur.First(); k != nil; k, _ = cur.Next() {
+		track, err := findTrackByID(ctx, tx, btoi(k[8:]))
+		if err != nil {
+			return nil, err
+		} else if track != nil && !fn(btoi(k[:8]), track) {
+			continue
+		}
+
+		keys = append(keys, append([]byte(nil), k...))
+		if track != nil {
+			a = append(a, track)
+		}
+	}
+
+	// Remove trash entries & records.
+	for _, k := range keys {
+		if err := bkt.Delete(k); err != nil {
+			return nil, err
+		}
+	}
+	for _, track := range a {
+		if err := deleteTrackRecord(ctx, tx, track); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// deleteTrackRecord removes a track record & its stored transcript terms.
+// The track must already be removed from all other indexes.
+func deleteTrackRecord(ctx context.Context, tx *Tx, track *peapod.Track) error {
+	if err := tx.Bucket([]byte("Tracks")).Delete(itob(track.ID)); err != nil {
+		return err
+	}
+	return unindexTrack(ctx, tx, track.PlaylistID, track.ID, true)
+}
+
+func createTrackRemoval(ctx context.Context, tx *Tx, removal *peapod.TrackRemoval) error {
+	bkt, err := tx.CreateBucketIfNotExists([]byte("TrackRemovals"))
+	if err != nil {
+		return err
+	}
+
+	// Retrieve next sequence.
+	id, _ := bkt.NextSequence()
+	removal.ID = int(id)
+
+	// Update timestamps.
+	removal.CreatedAt = tx.Now
+
+	// Marshal and insert record.
+	if buf, err := marshalTrackRemoval(removal); err != nil {
+		return err
+	} else if err := bkt.Put(itob(removal.ID), buf); err != nil {
+		return err
+	}
+
+	// Index by playlist.
+	return updateIndex(ctx, tx, []byte("Playlists.TrackRemovals"), 0, 0, removal.PlaylistID, removal.ID)
+}
+
+func findTrackRemovals(ctx context.Context, tx *Tx, playlistID int) ([]*peapod.TrackRemoval, error) {
+	bkt := tx.Bucket([]byte("Playlists.TrackRemovals"))
+	if bkt == nil {
+		return nil, nil
+	}
+
+	var a []*peapod.TrackRemoval
+	cur := bkt.Cursor()
+	prefix := itob(playlistID)
+	for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+		var removal peapod.TrackRemoval
+		if err := unmarshalTrackRemoval(tx.Bucket([]byte("TrackRemovals")).Get(k[8:]), &removal); err != nil {
+			return nil, err
+		}
+		a = append(a, &removal)
+	}
+	return a, nil
+}
+
 func playlistTracks(ctx context.Context, tx *Tx, playlistID int) ([]*peapod.Track, error) {
 	bkt := tx.Bucket([]byte("Playlists.Tracks"))
 	if bkt == nil {
@@ -146,18 +657,158 @@ func playlistTracks(ctx context.Context, tx *Tx, playlistID int) ([]*peapod.Trac
 	return a, nil
 }
 
+// findTracks returns a page of tracks matching filter from the standard
+// playlists readable by the current user.
+//
+// Candidate ids are read from the tag index when filtering by tag across
+// playlists and from the playlist track index otherwise. Because ids are
+// assigned in creation order, only the tracks up to the end of the page
+// are loaded.
+func findTracks(ctx context.Context, tx *Tx, filter peapod.TrackFilter) (*peapod.TrackPage, error) {
+	// Normalize pagination & ordering.
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = peapod.DefaultTrackLimit
+	} else if limit > peapod.MaxTrackLimit {
+		limit = peapod.MaxTrackLimit
+	}
+
+	newest := true
+	switch filter.Order {
+	case "", peapod.TrackOrderNewest:
+	case peapod.TrackOrderOldest:
+		newest = false
+	default:
+		return nil, peapod.ErrInvalidTrackOrder
+	}
+
+	var cursor int
+	if filter.Cursor != "" {
+		v, err := strconv.Atoi(filter.Cursor)
+		if err != nil || v <= 0 {
+			return nil, peapod.ErrInvalidTrackCursor
+		}
+		cursor = v
+	}
+
+	// Determine the playlists the user can read which match the filter.
+	user := peapod.FromContext(ctx)
+	playlists, err := findPlaylistsByUserID(ctx, tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	playlistIDs := make(map[int]struct{})
+	for _, p := range playlists {
+		if p.Type != peapod.PlaylistTypeStandard {
+			continue
+		} else if filter.PlaylistID != 0 && p.ID != filter.PlaylistID {
+			continue
+		} else if filter.OwnerID != 0 && p.OwnerID != filter.OwnerID {
+			continue
+		}
+		playlistIDs[p.ID] = struct{}{}
+	}
+	if filter.PlaylistID != 0 && len(playlistIDs) == 0 {
+		return nil, peapod.ErrUnauthorized
+	}
+
+	// Read candidate ids from the narrowest index.
+	var ids []int
+	if filter.Tag != "" && filter.PlaylistID == 0 {
+		ids = tagTrackIDs(ctx, tx, strings.ToLower(strings.TrimLeft(filter.Tag, "#")))
+	} else if bkt := tx.Bucket([]byte("Playlists.Tracks")); bkt != nil {
+		cur := bkt.Cursor()
+		for playlistID := range playlistIDs {
+			prefix := itob(playlistID)
+			for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+				ids = append(ids, btoi(k[8:]))
+			}
+		}
+	}
+
+	if newest {
+		sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+	} else {
+		sort.Ints(ids)
+	}
+
+	// Load tracks after the cursor until the page is full.
+	page := &peapod.TrackPage{Tracks: make([]*peapod.Track, 0, limit)}
+	for _, id := range ids {
+		if cursor != 0 && ((newest && id >= cursor) || (!newest && id <= cursor)) {
+			continue
+		}
+
+		track, err := findTrackByID(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		} else if track == nil || !track.DeletedAt.IsZero() {
+			continue
+		} else if _, ok := playlistIDs[track.PlaylistID]; !ok {
+			continue
+		}
+
+		// Stop once past the date range.
+		if newest && !filter.CreatedAfter.IsZero() && track.CreatedAt.Before(filter.CreatedAfter) {
+			break
+		} else if !newest && !filter.CreatedBefore.IsZero() && !track.CreatedAt.Before(filter.CreatedBefore) {
+			break
+		}
+
+		if !filter.Match(track) {
+			continue
+		}
+
+		// Only set the cursor if there are more matches after a full page.
+		if len(page.Tracks) == limit {
+			page.NextCursor = strconv.Itoa(page.Tracks[limit-1].ID)
+			break
+		}
+		page.Tracks = append(page.Tracks, track)
+	}
+	return page, nil
+}
+
+// tagTrackIDs returns the ids of all tracks with a given tag.
+func tagTrackIDs(ctx context.Context, tx *Tx, tag string) []int {
+	bkt := tx.Bucket([]byte("Tracks.Tag"))
+	if bkt == nil {
+		return nil
+	}
+
+	var a []int
+	cur := bkt.Cursor()
+	prefix := makeStringIndexKey(tag, 0)[:len(tag)+1]
+	for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+		a = append(a, btoi(k[len(prefix):]))
+	}
+	return a
+}
+
+// makeStringIndexKey returns an index key for a string value & a track id.
+func makeStringIndexKey(v string, trackID int) []byte {
+	return append(append([]byte(v), 0), itob(trackID)...)
+}
+
 func marshalTrack(v *peapod.Track) ([]byte, error) {
 	return proto.Marshal(&Track{
-		ID:          int64(v.ID),
-		PlaylistID:  int64(v.PlaylistID),
-		Filename:    v.Filename,
-		ContentType: v.ContentType,
-		Title:       v.Title,
-		Description: v.Description,
-		Duration:    int64(v.Duration),
-		FileSize:    int64(v.Size),
-		CreatedAt:   encodeTime(v.CreatedAt),
-		UpdatedAt:   encodeTime(v.UpdatedAt),
+		ID:                 int64(v.ID),
+		PlaylistID:         int64(v.PlaylistID),
+		Filename:           v.Filename,
+		ContentType:        v.ContentType,
+		Title:              v.Title,
+		Description:        v.Description,
+		Author:             v.Author,
+		Duration:           int64(v.Duration),
+		FileSize:           int64(v.Size),
+		TranscriptFilename: v.TranscriptFilename,
+		ChaptersFilename:   v.ChaptersFilename,
+		Tags:               v.Tags,
+		SourceURL:          v.SourceURL,
+		Pinned:             v.Pinned,
+		CreatedAt:          encodeTime(v.CreatedAt),
+		UpdatedAt:          encodeTime(v.UpdatedAt),
+		DeletedAt:          encodeTime(v.DeletedAt),
 	})
 }
 
@@ -167,16 +818,54 @@ func unmarshalTrack(data []byte, v *peapod.Track) error {
 		return err
 	}
 	*v = peapod.Track{
-		ID:          int(pb.ID),
-		PlaylistID:  int(pb.PlaylistID),
-		Filename:    pb.Filename,
-		ContentType: pb.ContentType,
-		Title:       pb.Title,
-		Description: pb.Description,
-		Duration:    time.Duration(pb.Duration),
-		Size:        int(pb.FileSize),
-		CreatedAt:   decodeTime(pb.CreatedAt),
-		UpdatedAt:   decodeTime(pb.UpdatedAt),
+		ID:                 int(pb.ID),
+		PlaylistID:         int(pb.PlaylistID),
+		Filename:           pb.Filename,
+		ContentType:        pb.ContentType,
+		Title:              pb.Title,
+		Description:        pb.Description,
+		Author:             pb.Author,
+		Duration:           time.Duration(pb.Duration),
+		Size:               int(pb.FileSize),
+		TranscriptFilename: pb.TranscriptFilename,
+		ChaptersFilename:   pb.ChaptersFilename,
+		Tags:               pb.Tags,
+		SourceURL:          pb.SourceURL,
+		Pinned:             pb.Pinned,
+		CreatedAt:          decodeTime(pb.CreatedAt),
+		UpdatedAt:          decodeTime(pb.UpdatedAt),
+		DeletedAt:          decodeTime(pb.DeletedAt),
+	}
+	return nil
+}
+
+func marshalTrackRemoval(v *peapod.TrackRemoval) ([]byte, error) {
+	return proto.Marshal(&TrackRemoval{
+		ID:         int64(v.ID),
+		PlaylistID: int64(v.PlaylistID),
+		TrackID:    int64(v.TrackID),
+		Title:      v.Title,
+		Filename:   v.Filename,
+		FileSize:   int64(v.Size),
+		Reason:     v.Reason,
+		CreatedAt:  encodeTime(v.CreatedAt),
+	})
+}
+
+func unmarshalTrackRemoval(data []byte, v *peapod.TrackRemoval) error {
+	var pb TrackRemoval
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return err
+	}
+	*v = peapod.TrackRemoval{
+		ID:         int(pb.ID),
+		PlaylistID: int(pb.PlaylistID),
+		TrackID:    int(pb.TrackID),
+		Title:      pb.Title,
+		Filename:   pb.Filename,
+		Size:       int(pb.FileSize),
+		Reason:     pb.Reason,
+		CreatedAt:  decodeTime(pb.CreatedAt),
 	}
 	return nil
 }
@@ -1 +1,278 @@
 package bolt_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/bolt"
+)
+
+// Ensure tracks can be searched by title, description & transcript.
+func TestTrackService_SearchTracks(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewTrackService(db.DB)
+
+	ctx, _, playlist := MustCreateUser(t, db, "+15555550100")
+	playlistID := playlist.ID
+
+	for _, track := range []*peapod.Track{
+		{PlaylistID: playlistID, Filename: "a.mp3", Title: "Gardening Tips", Description: "Growing tomatoes"},
+		{PlaylistID: playlistID, Filename: "b.mp3", Title: "Cooking Tomatoes"},
+		{PlaylistID: playlistID, Filename: "c.mp3", Title: "Weekly News", Transcript: &peapod.Transcript{
+			Ext:  ".vtt",
+			Body: []byte("WEBVTT\n\n00:00:01.000 --> 00:00:02.000\n<c>tomatoes</c> are in season\n"),
+		}},
+	} {
+		if err := s.CreateTrack(ctx, track); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Title matches should rank first.
+	if result, err := s.SearchTracks(ctx, peapod.TrackSearch{Query: "tomatoes"}); err != nil {
+		t.Fatal(err)
+	} else if result.Total != 3 {
+		t.Fatalf("unexpected total: %d", result.Total)
+	} else if result.Tracks[0].Title != "Cooking Tomatoes" {
+		t.Fatalf("unexpected first match: %s", result.Tracks[0].Title)
+	}
+
+	// Results should be paginated.
+	if result, err := s.SearchTracks(ctx, peapod.TrackSearch{Query: "tomatoes", Offset: 2, Limit: 2}); err != nil {
+		t.Fatal(err)
+	} else if len(result.Tracks) != 1 {
+		t.Fatalf("unexpected page: %d tracks", len(result.Tracks))
+	}
+
+	// Deleted tracks should be removed from the index.
+	if err := s.DeleteTrack(ctx, 2, "test"); err != nil {
+		t.Fatal(err)
+	} else if result, err := s.SearchTracks(ctx, peapod.TrackSearch{Query: "cooking"}); err != nil {
+		t.Fatal(err)
+	} else if result.Total != 0 {
+		t.Fatalf("unexpected total: %d", result.Total)
+	}
+
+	// Transcript terms should survive an update to the track.
+	if err := s.SetTrackPinned(ctx, 3, true); err != nil {
+		t.Fatal(err)
+	} else if result, err := s.SearchTracks(ctx, peapod.TrackSearch{Query: "season"}); err != nil {
+		t.Fatal(err)
+	} else if result.Total != 1 || result.Tracks[0].ID != 3 {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+
+	// Another user should not see these tracks.
+	otherCtx, _, _ := MustCreateUser(t, db, "+15555550101")
+	if result, err := s.SearchTracks(otherCtx, peapod.TrackSearch{Query: "tomatoes"}); err != nil {
+		t.Fatal(err)
+	} else if result.Total != 0 {
+		t.Fatalf("unexpected total: %d", result.Total)
+	}
+}
+
+// Ensure deleted tracks can be restored from the trash until they are purged.
+func TestTrackService_DeleteTrack_Trash(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewTrackService(db.DB)
+	s.TrashPeriod = 24 * time.Hour
+
+	playlistService := bolt.NewPlaylistService(db.DB)
+	ctx, _, playlist := MustCreateUser(t, db, "+15555550100")
+	playlistID := playlist.ID
+
+	for _, track := range []*peapod.Track{
+		{PlaylistID: playlistID, Filename: "a.mp3", Title: "First"},
+		{PlaylistID: playlistID, Filename: "b.mp3", Title: "Second"},
+	} {
+		if err := s.CreateTrack(ctx, track); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Delete both tracks at different times.
+	if err := s.DeleteTrack(ctx, 1, peapod.TrackRemovalReasonUser); err != nil {
+		t.Fatal(err)
+	}
+	db.Now = func() time.Time { return Now.Add(time.Hour) }
+	if err := s.DeleteTrack(ctx, 2, peapod.TrackRemovalReasonUser); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleted tracks should be excluded from the playlist & be in the trash.
+	if p, err := playlistService.FindPlaylistByID(ctx, playlistID); err != nil {
+		t.Fatal(err)
+	} else if len(p.Tracks) != 0 {
+		t.Fatalf("unexpected playlist tracks: %d", len(p.Tracks))
+	} else if tracks, err := s.FindTrashedTracks(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(tracks) != 2 || tracks[0].ID != 2 || !tracks[0].DeletedAt.Equal(Now.Add(time.Hour)) {
+		t.Fatalf("unexpected trash: %#v", tracks)
+	}
+
+	// Trashed tracks cannot be deleted again.
+	if err := s.DeleteTrack(ctx, 2, peapod.TrackRemovalReasonUser); err != peapod.ErrTrackNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Undo should restore the most recently deleted track.
+	if track, err := s.UndoDeleteTrack(ctx); err != nil {
+		t.Fatal(err)
+	} else if track.ID != 2 || !track.DeletedAt.IsZero() {
+		t.Fatalf("unexpected track: %#v", track)
+	} else if p, err := playlistService.FindPlaylistByID(ctx, playlistID); err != nil {
+		t.Fatal(err)
+	} else if len(p.Tracks) != 1 || p.Tracks[0].ID != 2 {
+		t.Fatalf("unexpected playlist tracks: %#v", p.Tracks)
+	}
+
+	// Another user cannot restore from this user's trash.
+	otherCtx, _, _ := MustCreateUser(t, db, "+15555550101")
+	if _, err := s.RestoreTrack(otherCtx, 1); err != peapod.ErrTrackNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Purging after the trash period should permanently remove the track.
+	db.Now = func() time.Time { return Now.Add(25 * time.Hour) }
+	if tracks, err := s.PurgeTrash(peapod.NewSystemContext(context.Background())); err != nil {
+		t.Fatal(err)
+	} else if len(tracks) != 1 || tracks[0].Filename != "a.mp3" {
+		t.Fatalf("unexpected purged tracks: %#v", tracks)
+	} else if track, err := s.FindTrackByID(ctx, 1); err != nil {
+		t.Fatal(err)
+	} else if track != nil {
+		t.Fatalf("expected track to be purged")
+	} else if _, err := s.UndoDeleteTrack(ctx); err != peapod.ErrTrashEmpty {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure tracks can be filtered & paginated.
+func TestTrackService_FindTracks(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewTrackService(db.DB)
+
+	ctx, user, playlist := MustCreateUser(t, db, "+15555550100")
+	playlistID := playlist.ID
+
+	for i, track := range []*peapod.Track{
+		{PlaylistID: playlistID, Filename: "a.mp3", ContentType: "audio/mpeg", SourceURL: "https://www.youtube.com/watch?v=a"},
+		{PlaylistID: playlistID, Filename: "b.mp3", ContentType: "audio/mpeg", Tags: []string{"news"}},
+		{PlaylistID: playlistID, Filename: "c.m4a", ContentType: "audio/mp4", SourceURL: "https://example.com/c", Tags: []string{"news"}},
+		{PlaylistID: playlistID, Filename: "d.mp3", ContentType: "audio/mpeg", SourceURL: "https://youtube.com/watch?v=d"},
+	} {
+		db.Now = func() time.Time { return Now.Add(time.Duration(i) * time.Hour) }
+		if err := s.CreateTrack(ctx, track); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name   string
+		filter peapod.TrackFilter
+		ids    []int
+	}{
+		{name: "All", filter: peapod.TrackFilter{}, ids: []int{4, 3, 2, 1}},
+		{name: "Oldest", filter: peapod.TrackFilter{Order: peapod.TrackOrderOldest}, ids: []int{1, 2, 3, 4}},
+		{name: "Playlist", filter: peapod.TrackFilter{PlaylistID: playlistID, Limit: 1}, ids: []int{4}},
+		{name: "Owner", filter: peapod.TrackFilter{OwnerID: user.ID + 1}, ids: []int{}},
+		{name: "ContentType", filter: peapod.TrackFilter{ContentType: "audio/mp4"}, ids: []int{3}},
+		{name: "SourceDomain", filter: peapod.TrackFilter{SourceDomain: "youtube.com"}, ids: []int{4, 1}},
+		{name: "Tag", filter: peapod.TrackFilter{Tag: "#News"}, ids: []int{3, 2}},
+		{name: "DateRange", filter: peapod.TrackFilter{CreatedAfter: Now.Add(time.Hour), CreatedBefore: Now.Add(3 * time.Hour)}, ids: []int{3, 2}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := s.FindTracks(ctx, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]int, 0, len(page.Tracks))
+			for _, track := range page.Tracks {
+				ids = append(ids, track.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Fatalf("unexpected ids: %v", ids)
+			}
+		})
+	}
+
+	// Pages should continue from the cursor.
+	var ids []int
+	filter := peapod.TrackFilter{Limit: 3, Order: peapod.TrackOrderOldest}
+	for {
+		page, err := s.FindTracks(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, track := range page.Tracks {
+			ids = append(ids, track.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	if !reflect.DeepEqual(ids, []int{1, 2, 3, 4}) {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+
+	// Filtering by another user's playlist is not allowed.
+	otherCtx, _, _ := MustCreateUser(t, db, "+15555550101")
+	if _, err := s.FindTracks(otherCtx, peapod.TrackFilter{PlaylistID: playlistID}); err != peapod.ErrUnauthorized {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a track can be permanently deleted by an administrator.
+func TestTrackService_PurgeTrack(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewTrackService(db.DB)
+	sysCtx := peapod.NewSystemContext(context.Background())
+
+	ctx, _, playlist := MustCreateUser(t, db, "+15555550100")
+	playlistID := playlist.ID
+
+	for _, track := range []*peapod.Track{
+		{PlaylistID: playlistID, Filename: "a.mp3", Title: "First"},
+		{PlaylistID: playlistID, Filename: "b.mp3", Title: "Second"},
+	} {
+		if err := s.CreateTrack(ctx, track); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Purge a track in the playlist & a track in the trash.
+	if err := s.DeleteTrack(ctx, 2, peapod.TrackRemovalReasonUser); err != nil {
+		t.Fatal(err)
+	} else if track, err := s.PurgeTrack(sysCtx, 1); err != nil {
+		t.Fatal(err)
+	} else if track.Filename != "a.mp3" {
+		t.Fatalf("unexpected track: %#v", track)
+	} else if _, err := s.PurgeTrack(sysCtx, 2); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.PurgeTrack(sysCtx, 2); err != peapod.ErrTrackNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Neither track should remain & the purge should be recorded.
+	if track, err := s.FindTrackByID(ctx, 1); err != nil {
+		t.Fatal(err)
+	} else if track != nil {
+		t.Fatal("expected track to be purged")
+	} else if tracks, err := s.FindTrashedTracks(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(tracks) != 0 {
+		t.Fatalf("unexpected trash: %d", len(tracks))
+	} else if removals, err := s.FindTrackRemovals(ctx, playlistID); err != nil {
+		t.Fatal(err)
+	} else if len(removals) != 2 || removals[1].Reason != peapod.TrackRemovalReasonAdmin {
+		t.Fatalf("unexpected removals: %#v", removals)
+	}
+}
@@ -0,0 +1,191 @@
+package bolt
+
+import (
+	"context"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/middlemost/peapod"
+)
+
+// recordTrackRemovalAction records a track moved to the current user's trash
+// as the user's last destructive action. Consecutive retention removals from
+// the same playlist are added to the previous action.
+func recordTrackRemovalAction(ctx context.Context, tx *Tx, userID int, track *peapod.Track, reason string) error {
+	action, err := findUndoAction(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	if action == nil ||
+		reason != peapod.TrackRemovalReasonRetention ||
+		action.Type != peapod.UndoActionTypeDeleteTracks ||
+		action.Reason != reason ||
+		action.PlaylistID != track.PlaylistID {
+		action = &peapod.UndoAction{Type: peapod.UndoActionTypeDeleteTracks, PlaylistID: track.PlaylistID, Reason: reason}
+	}
+	action.TrackIDs = append(action.TrackIDs, track.ID)
+	action.CreatedAt = tx.Now
+
+	return saveUndoAction(ctx, tx, userID, action)
+}
+
+// undoLastAction reverts & removes the current user's last destructive action.
+func undoLastAction(ctx context.Context, tx *Tx, userID int) (*peapod.UndoAction, error) {
+	if user := peapod.FromContext(ctx); user == nil || user.ID != userID {
+		return nil, peapod.ErrUnauthorized
+	}
+
+	action, err := findUndoAction(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	} else if action == nil {
+		return nil, peapod.ErrNothingToUndo
+	}
+
+	switch action.Type {
+	case peapod.UndoActionTypeDeleteTracks:
+		err = undoTrackRemovals(ctx, tx, action)
+	case peapod.UndoActionTypeRotateToken:
+		err = undoRotatePlaylistToken(ctx, tx, action)
+	default:
+		err = peapod.ErrNothingToUndo
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := deleteUndoAction(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
+
+// undoTrackRemovals restores the action's tracks which are still in the
+// user's trash. Tracks removed by retention are pinned so they are kept.
+func undoTrackRemovals(ctx context.Context, tx *Tx, action *peapod.UndoAction) error {
+	var restored []int
+	for _, id := range action.TrackIDs {
+		track, err := restoreTrack(ctx, tx, id)
+		if err == peapod.ErrTrackNotFound {
+			continue // already restored or purged
+		} else if err != nil {
+			return err
+		}
+
+		if action.Reason == peapod.TrackRemovalReasonRetention {
+			track.Pinned = true
+			if err := saveTrack(ctx, tx, track); err != nil {
+				return err
+			}
+		}
+		restored = append(restored, id)
+	}
+
+	if len(restored) == 0 {
+		return peapod.ErrNothingToUndo
+	}
+	action.TrackIDs = restored
+	return nil
+}
+
+// undoRotatePlaylistToken restores a playlist's previous token if it is still
+// within its grace period. The newer token is removed.
+func undoRotatePlaylistToken(ctx context.Context, tx *Tx, action *peapod.UndoAction) error {
+	if err := removeExpiredPlaylistTokens(ctx, tx); err != nil {
+		return err
+	}
+
+	// Ensure the previous token is still retired for the playlist.
+	bkt := tx.Bucket([]byte("Playlists.RetiredToken"))
+	if bkt == nil {
+		return peapod.ErrNothingToUndo
+	} else if v := bkt.Get([]byte(action.Token)); v == nil || btoi(v[0:8]) != action.PlaylistID {
+		return peapod.ErrNothingToUndo
+	}
+
+	playlist, err := findPlaylistByID(ctx, tx, action.PlaylistID)
+	if err != nil {
+		return err
+	} else if playlist == nil {
+		return peapod.ErrNothingToUndo
+	} else if user := peapod.FromContext(ctx); user.ID != playlist.OwnerID {
+		return peapod.ErrUnauthorized
+	}
+
+	// Remove the newer token & reinstate the previous one, which is still indexed.
+	if err := tx.Bucket([]byte("Playlists.Token")).Delete([]byte(playlist.Token)); err != nil {
+		return err
+	} else if err := bkt.Delete([]byte(action.Token)); err != nil {
+		return err
+	}
+	playlist.Token = action.Token
+	return savePlaylist(ctx, tx, playlist)
+}
+
+func findUndoAction(ctx context.Context, tx *Tx, userID int) (*peapod.UndoAction, error) {
+	bkt := tx.Bucket([]byte("Users.UndoAction"))
+	if bkt == nil {
+		return nil, nil
+	}
+
+	var action peapod.UndoAction
+	if buf := bkt.Get(itob(userID)); buf == nil {
+		return nil, nil
+	} else if err := unmarshalUndoAction(buf, &action); err != nil {
+		return nil, err
+	}
+	return &action, nil
+}
+
+func saveUndoAction(ctx context.Context, tx *Tx, userID int, action *peapod.UndoAction) error {
+	if buf, err := marshalUndoAction(action); err != nil {
+		return err
+	} else if bkt, err := tx.CreateBucketIfNotExists([]byte("Users.UndoAction")); err != nil {
+		return err
+	} else if err := bkt.Put(itob(userID), buf); err != nil {
+		return err
+	}
+	return nil
+}
+
+func deleteUndoAction(ctx context.Context, tx *Tx, userID int) error {
+	if bkt := tx.Bucket([]byte("Users.UndoAction")); bkt != nil {
+		return bkt.Delete(itob(userID))
+	}
+	return nil
+}
+
+func marshalUndoAction(v *peapod.UndoAction) ([]byte, error) {
+	trackIDs := make([]int64, len(v.TrackIDs))
+	for i, id := range v.TrackIDs {
+		trackIDs[i] = int64(id)
+	}
+
+	return proto.Marshal(&UndoAction{
+		Type:       v.Type,
+		PlaylistID: int64(v.PlaylistID),
+		TrackIDs:   trackIDs,
+		Reason:     v.Reason,
+		Token:      v.Token,
+		CreatedAt:  encodeTime(v.CreatedAt),
+	})
+}
+
+func unmarshalUndoAction(data []byte, v *peapod.UndoAction) error {
+	var pb UndoAction
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return err
+	}
+
+	*v = peapod.UndoAction{
+		Type:       pb.Type,
+		PlaylistID: int(pb.PlaylistID),
+		Reason:     pb.Reason,
+		Token:      pb.Token,
+		CreatedAt:  decodeTime(pb.CreatedAt),
+	}
+	for _, id := range pb.TrackIDs {
+		v.TrackIDs = append(v.TrackIDs, int(id))
+	}
+	return nil
+}
@@ -1,7 +1,10 @@
 package bolt
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/middlemost/peapod"
@@ -23,6 +26,7 @@ func NewUserService(db *DB) *UserService {
 }
 
 // FindUserByID returns a user with a given id.
+// Users can only look up themselves unless ctx is a system context.
 func (s *UserService) FindUserByID(ctx context.Context, id int) (*peapod.User, error) {
 	tx, err := s.db.Begin(ctx, false)
 	if err != nil {
@@ -30,6 +34,10 @@ func (s *UserService) FindUserByID(ctx context.Context, id int) (*peapod.User, e
 	}
 	defer tx.Rollback()
 
+	if err := authorizeUser(ctx, id); err != nil {
+		return nil, err
+	}
+
 	user, err := findUserByID(ctx, tx, id)
 	if err != nil {
 		return nil, err
@@ -38,6 +46,7 @@ func (s *UserService) FindUserByID(ctx context.Context, id int) (*peapod.User, e
 }
 
 // FindUserByMobileNumber returns a user by mobile number.
+// Users can only look up themselves unless ctx is a system context.
 func (s *UserService) FindUserByMobileNumber(ctx context.Context, mobileNumber string) (*peapod.User, error) {
 	tx, err := s.db.Begin(ctx, false)
 	if err != nil {
@@ -45,6 +54,12 @@ func (s *UserService) FindUserByMobileNumber(ctx context.Context, mobileNumber s
 	}
 	defer tx.Rollback()
 
+	if !peapod.IsSystemContext(ctx) {
+		if u := peapod.FromContext(ctx); u == nil || u.MobileNumber != mobileNumber {
+			return nil, peapod.ErrUnauthorized
+		}
+	}
+
 	id := findUserIDByMobileNumber(ctx, tx, mobileNumber)
 	if id == 0 {
 		return nil, nil
@@ -57,9 +72,9 @@ func (s *UserService) FindUserByMobileNumber(ctx context.Context, mobileNumber s
 	return user, nil
 }
 
-// CreateUser creates a new user.
+// CreateUser creates a new user. Requires a system context.
 func (s *UserService) CreateUser(ctx context.Context, user *peapod.User) error {
-	tx, err := s.db.Begin(ctx, true)
+	tx, err := s.db.BeginSystem(ctx, true)
 	if err != nil {
 		return err
 	}
@@ -78,6 +93,157 @@ func (s *UserService) CreateUser(ctx context.Context, user *peapod.User) error {
 	return nil
 }
 
+// UpdateUser updates the profile & settings of the current user.
+func (s *UserService) UpdateUser(ctx context.Context, id int, upd peapod.UserUpdate) (*peapod.User, error) {
+	tx, err := s.db.BeginAuth(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user, err := findCurrentUserByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := upd.Apply(user); err != nil {
+		return nil, err
+	} else if err := saveUser(ctx, tx, user); err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UndoLastAction reverts the current user's last destructive action.
+func (s *UserService) UndoLastAction(ctx context.Context, id int) (*peapod.UndoAction, error) {
+	tx, err := s.db.BeginAuth(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := findCurrentUserByID(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	action, err := undoLastAction(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
+
+// RequestUserDeletion marks the current user as wanting to delete their
+// account and returns a confirmation code. Any previous code is replaced.
+func (s *UserService) RequestUserDeletion(ctx context.Context, id int) (string, error) {
+	tx, err := s.db.BeginAuth(ctx, true)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	user, err := findCurrentUserByID(ctx, tx, id)
+	if err != nil {
+		return "", err
+	}
+
+	code := peapod.GenerateLoginCode()
+	user.DeletionRequestedAt = tx.Now
+	user.DeletionCodeHash = hashSecret(code)
+	if err := saveUser(ctx, tx, user); err != nil {
+		return "", err
+	} else if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// ConfirmUserDeletion deletes the current user if code matches an unexpired
+// deletion request. An incorrect code cancels the request so that codes
+// cannot be guessed, even though an error is returned.
+func (s *UserService) ConfirmUserDeletion(ctx context.Context, id int, code string) ([]*peapod.Track, error) {
+	if code == "" {
+		return nil, peapod.ErrDeletionCodeRequired
+	}
+
+	tx, err := s.db.BeginAuth(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user, err := findCurrentUserByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if user.DeletionCodeHash == "" {
+		return nil, peapod.ErrInvalidDeletionCode
+	}
+
+	// Cancel the request if it has expired or the code is incorrect.
+	if tx.Now.Sub(user.DeletionRequestedAt) > peapod.UserDeletionConfirmPeriod ||
+		subtle.ConstantTimeCompare([]byte(hashSecret(code)), []byte(user.DeletionCodeHash)) != 1 {
+		user.DeletionRequestedAt, user.DeletionCodeHash = time.Time{}, ""
+		if err := saveUser(ctx, tx, user); err != nil {
+			return nil, err
+		} else if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, peapod.ErrInvalidDeletionCode
+	}
+
+	tracks, err := deleteUser(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+// FindUsers returns users matching filter, ordered by id.
+// Requires a system context.
+func (s *UserService) FindUsers(ctx context.Context, filter peapod.UserFilter) ([]*peapod.User, error) {
+	tx, err := s.db.BeginSystem(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return findUsers(ctx, tx, filter)
+}
+
+// UpdateUserAccess changes a user's admin & disabled flags. Sessions are
+// removed when a user is disabled. Requires a system context.
+func (s *UserService) UpdateUserAccess(ctx context.Context, id int, upd peapod.UserAccessUpdate) (*peapod.User, error) {
+	tx, err := s.db.BeginSystem(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user, err := findUserByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if user == nil {
+		return nil, peapod.ErrUserNotFound
+	}
+
+	upd.Apply(user)
+	if err := saveUser(ctx, tx, user); err != nil {
+		return nil, err
+	} else if user.Disabled {
+		if err := deleteUserSessions(ctx, tx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
 func findUserByID(ctx context.Context, tx *Tx, id int) (*peapod.User, error) {
 	bkt := tx.Bucket([]byte("Users"))
 	if bkt == nil {
@@ -93,6 +259,68 @@ func findUserByID(ctx context.Context, tx *Tx, id int) (*peapod.User, error) {
 	return &u, nil
 }
 
+// findCurrentUserByID returns a user if it is the current user.
+func findCurrentUserByID(ctx context.Context, tx *Tx, id int) (*peapod.User, error) {
+	if err := authorizeUser(ctx, id); err != nil {
+		return nil, err
+	}
+
+	user, err := findUserByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	} else if user == nil {
+		return nil, peapod.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// findUsers returns a page of users matching filter.
+func findUsers(ctx context.Context, tx *Tx, filter peapod.UserFilter) ([]*peapod.User, error) {
+	// Normalize pagination.
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = peapod.DefaultUserLimit
+	} else if limit > peapod.MaxUserLimit {
+		limit = peapod.MaxUserLimit
+	}
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	a := make([]*peapod.User, 0)
+	bkt := tx.Bucket([]byte("Users"))
+	if bkt == nil {
+		return a, nil
+	}
+
+	cur := bkt.Cursor()
+	for k, v := cur.First(); k != nil && len(a) < limit; k, v = cur.Next() {
+		var user peapod.User
+		if err := unmarshalUser(v, &user); err != nil {
+			return nil, err
+		} else if !filter.Match(&user) {
+			continue
+		} else if offset > 0 {
+			offset--
+			continue
+		}
+		a = append(a, &user)
+	}
+	return a, nil
+}
+
+// authorizeUser returns ErrUnauthorized unless id is the current user.
+// System contexts are always authorized.
+func authorizeUser(ctx context.Context, id int) error {
+	if peapod.IsSystemContext(ctx) {
+		return nil
+	} else if u := peapod.FromContext(ctx); u == nil || u.ID != id {
+		return peapod.ErrUnauthorized
+	}
+	return nil
+}
+
 func userExists(ctx context.Context, tx *Tx, id int) bool {
 	bkt := tx.Bucket([]byte("Users"))
 	if bkt == nil {
@@ -155,6 +383,98 @@ func createUser(ctx context.Context, tx *Tx, user *peapod.User) error {
 	return nil
 }
 
+// deleteUser removes a user, their playlists & tracks, their jobs, their
+// memberships & invites and every index entry which refers to them.
+// Returns all removed tracks, including trashed tracks.
+func deleteUser(ctx context.Context, tx *Tx, id int) ([]*peapod.Track, error) {
+	user, err := findCurrentUserByID(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove owned playlists along with their tracks.
+	playlistIDs := make(map[int]struct{})
+	if bkt := tx.Bucket([]byte("Users.Playlists")); bkt != nil {
+		cur := bkt.Cursor()
+		prefix := itob(user.ID)
+		for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+			playlistIDs[btoi(k[8:])] = struct{}{}
+		}
+	}
+
+	var tracks []*peapod.Track
+	for playlistID := range playlistIDs {
+		a, err := deletePlaylist(ctx, tx, playlistID)
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, a...)
+	}
+
+	// Permanently remove tracks in the user's trash & trashed tracks from
+	// the user's playlists which are in other users' trash.
+	a, err := deleteTrashedTracks(ctx, tx, func(userID int, track *peapod.Track) bool {
+		_, ok := playlistIDs[track.PlaylistID]
+		return ok || userID == user.ID
+	})
+	if err != nil {
+		return nil, err
+	}
+	tracks = append(tracks, a...)
+
+	// Remove memberships of other playlists.
+	if bkt := tx.Bucket([]byte("Users.PlaylistMembers")); bkt != nil {
+		var ids []int
+		cur := bkt.Cursor()
+		prefix := itob(user.ID)
+		for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+			ids = append(ids, btoi(k[8:]))
+		}
+		for _, playlistID := range ids {
+			if err := deletePlaylistMember(ctx, tx, playlistID, user.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Remove pending invites sent to the user.
+	invites, err := findPlaylistInvitesByMobileNumber(ctx, tx, user.MobileNumber)
+	if err != nil {
+		return nil, err
+	}
+	for _, invite := range invites {
+		if err := deletePlaylistInvite(ctx, tx, invite); err != nil {
+			return nil, err
+		}
+	}
+
+	// Remove jobs, sessions, the last undoable action & any outstanding
+	// login code or throttle. Other users' pending jobs for the deleted
+	// playlists are canceled.
+	if err := deleteJobsByOwnerID(ctx, tx, user.ID); err != nil {
+		return nil, err
+	} else if err := cancelJobsByPlaylistIDs(ctx, tx, playlistIDs); err != nil {
+		return nil, err
+	} else if err := deleteUserSessions(ctx, tx, user.ID); err != nil {
+		return nil, err
+	} else if err := deleteUndoAction(ctx, tx, user.ID); err != nil {
+		return nil, err
+	} else if err := deleteLoginCode(ctx, tx, user.MobileNumber); err != nil {
+		return nil, err
+	} else if err := deleteLoginThrottle(ctx, tx, user.MobileNumber); err != nil {
+		return nil, err
+	}
+
+	// Remove record & mobile number index.
+	if err := tx.Bucket([]byte("Users")).Delete(itob(user.ID)); err != nil {
+		return nil, err
+	} else if err := tx.Bucket([]byte("Users.MobileNumber")).Delete([]byte(user.MobileNumber)); err != nil {
+		return nil, err
+	}
+
+	return tracks, nil
+}
+
 func saveUser(ctx context.Context, tx *Tx, user *peapod.User) error {
 	// Validate record.
 	if user.MobileNumber == "" {
@@ -179,8 +499,18 @@ func marshalUser(v *peapod.User) ([]byte, error) {
 	return proto.Marshal(&User{
 		ID:           int64(v.ID),
 		MobileNumber: v.MobileNumber,
+		Name:         v.Name,
+		Timezone:     v.Timezone,
+		Voice:        v.Voice,
 		CreatedAt:    encodeTime(v.CreatedAt),
 		UpdatedAt:    encodeTime(v.UpdatedAt),
+
+		MuteJobNotifications:    v.MuteJobNotifications,
+		MuteMemberNotifications: v.MuteMemberNotifications,
+		DeletionRequestedAt:     encodeTime(v.DeletionRequestedAt),
+		DeletionCodeHash:        v.DeletionCodeHash,
+		Admin:                   v.Admin,
+		Disabled:                v.Disabled,
 	})
 }
 
@@ -192,8 +522,18 @@ func unmarshalUser(data []byte, v *peapod.User) error {
 	*v = peapod.User{
 		ID:           int(pb.ID),
 		MobileNumber: pb.MobileNumber,
+		Name:         pb.Name,
+		Timezone:     pb.Timezone,
+		Voice:        pb.Voice,
 		CreatedAt:    decodeTime(pb.CreatedAt),
 		UpdatedAt:    decodeTime(pb.UpdatedAt),
+
+		MuteJobNotifications:    pb.MuteJobNotifications,
+		MuteMemberNotifications: pb.MuteMemberNotifications,
+		DeletionRequestedAt:     decodeTime(pb.DeletionRequestedAt),
+		DeletionCodeHash:        pb.DeletionCodeHash,
+		Admin:                   pb.Admin,
+		Disabled:                pb.Disabled,
 	}
 	return nil
 }
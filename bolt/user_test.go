@@ -0,0 +1,470 @@
+package bolt_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	boltdb "github.com/boltdb/bolt"
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/bolt"
+)
+
+// Ensure a user's profile & settings can be updated.
+func TestUserService_UpdateUser(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewUserService(db.DB)
+
+	ctx, user, _ := MustCreateUser(t, db, "+15555550100")
+
+	name, tz, voice, mute := "Susy", "America/Denver", "Joanna", true
+	if _, err := s.UpdateUser(ctx, user.ID, peapod.UserUpdate{Name: &name, Timezone: &tz, Voice: &voice, MuteJobNotifications: &mute}); err != nil {
+		t.Fatal(err)
+	} else if other, err := s.FindUserByID(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	} else if other.Name != "Susy" || other.Timezone != "America/Denver" || other.Voice != "Joanna" || !other.MuteJobNotifications {
+		t.Fatalf("unexpected user: %#v", other)
+	}
+
+	// Invalid time zones are rejected.
+	tz = "Mars/Olympus_Mons"
+	if _, err := s.UpdateUser(ctx, user.ID, peapod.UserUpdate{Timezone: &tz}); err != peapod.ErrInvalidTimezone {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Unsupported voices are rejected.
+	voice = "HAL"
+	if _, err := s.UpdateUser(ctx, user.ID, peapod.UserUpdate{Voice: &voice}); err != peapod.ErrInvalidVoice {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Users cannot update other users.
+	if _, err := s.UpdateUser(ctx, user.ID+1, peapod.UserUpdate{Name: &name}); err != peapod.ErrUnauthorized {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure deleting users removes all of their data and leaves no index entries.
+func TestUserService_DeleteUser(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+
+	userService := bolt.NewUserService(db.DB)
+	playlistService := bolt.NewPlaylistService(db.DB)
+	trackService := bolt.NewTrackService(db.DB)
+	jobService := bolt.NewJobService(db.DB)
+
+	// Create two users which are members of each other's default playlists.
+	aliceCtx, alice, alicePlaylist := MustCreateUser(t, db, "+15555550100")
+	bobCtx, bob, bobPlaylist := MustCreateUser(t, db, "+15555550101")
+	alicePlaylistID, bobPlaylistID := alicePlaylist.ID, bobPlaylist.ID
+
+	for _, tt := range []struct {
+		ctx        context.Context
+		playlistID int
+		invitee    *peapod.User
+		inviteeCtx context.Context
+	}{
+		{aliceCtx, alicePlaylistID, bob, bobCtx},
+		{bobCtx, bobPlaylistID, alice, aliceCtx},
+	} {
+		invite := &peapod.PlaylistInvite{PlaylistID: tt.playlistID, MobileNumber: tt.invitee.MobileNumber, Role: peapod.PlaylistRoleContributor}
+		if err := playlistService.CreatePlaylistInvite(tt.ctx, invite); err != nil {
+			t.Fatal(err)
+		} else if _, err := playlistService.AcceptPlaylistInvite(tt.inviteeCtx, invite.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Add a pending invite, a retention policy, a retired token & a merged playlist.
+	if err := playlistService.CreatePlaylistInvite(aliceCtx, &peapod.PlaylistInvite{PlaylistID: alicePlaylistID, MobileNumber: "+15555550199", Role: peapod.PlaylistRoleListener}); err != nil {
+		t.Fatal(err)
+	} else if err := playlistService.SetPlaylistRetention(aliceCtx, alicePlaylistID, &peapod.Retention{MaxTracks: 10}); err != nil {
+		t.Fatal(err)
+	} else if _, err := playlistService.RotateToken(aliceCtx, alicePlaylistID); err != nil {
+		t.Fatal(err)
+	} else if err := playlistService.CreatePlaylist(aliceCtx, &peapod.Playlist{Name: "Merged", Type: peapod.PlaylistTypeMerged, SourceIDs: []int{alicePlaylistID, bobPlaylistID}}); err != nil {
+		t.Fatal(err)
+	} else if err := playlistService.CreatePlaylist(bobCtx, &peapod.Playlist{Name: "Merged", Type: peapod.PlaylistTypeMerged, SourceIDs: []int{alicePlaylistID, bobPlaylistID}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add tracks to both playlists by both users & trash some of them.
+	for _, tt := range []struct {
+		ctx   context.Context
+		track *peapod.Track
+		trash bool
+	}{
+		{aliceCtx, &peapod.Track{PlaylistID: alicePlaylistID, Filename: "a.mp3", Title: "Alice One", Tags: []string{"news"}, Transcript: &peapod.Transcript{Ext: ".txt", Body: []byte("hello world")}}, false},
+		{aliceCtx, &peapod.Track{PlaylistID: alicePlaylistID, Filename: "b.mp3", Title: "Alice Two"}, true},
+		{bobCtx, &peapod.Track{PlaylistID: alicePlaylistID, Filename: "c.mp3", Title: "Bob Three"}, true},
+		{aliceCtx, &peapod.Track{PlaylistID: bobPlaylistID, Filename: "d.mp3", Title: "Alice Four"}, true},
+		{bobCtx, &peapod.Track{PlaylistID: bobPlaylistID, Filename: "e.mp3", Title: "Bob Five", Tags: []string{"news"}}, false},
+	} {
+		if err := trackService.CreateTrack(tt.ctx, tt.track); err != nil {
+			t.Fatal(err)
+		} else if tt.trash {
+			if err := trackService.DeleteTrack(tt.ctx, tt.track.ID, peapod.TrackRemovalReasonUser); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Log alice in and leave an unused login code for bob.
+	sessionService := bolt.NewSessionService(db.DB)
+	if code, err := sessionService.CreateLoginCode(context.Background(), alice.MobileNumber); err != nil {
+		t.Fatal(err)
+	} else if _, err := sessionService.CreateSession(context.Background(), alice.MobileNumber, code); err != nil {
+		t.Fatal(err)
+	} else if _, err := sessionService.CreateLoginCode(context.Background(), bob.MobileNumber); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a job by each user to alice's playlist.
+	bobJob := &peapod.Job{OwnerID: bob.ID, Type: peapod.JobTypeCreateTrackFromURL, PlaylistID: alicePlaylistID, URL: "https://example.com"}
+	if err := jobService.CreateJob(aliceCtx, &peapod.Job{OwnerID: alice.ID, Type: peapod.JobTypeCreateTrackFromURL, PlaylistID: alicePlaylistID, URL: "https://example.com"}); err != nil {
+		t.Fatal(err)
+	} else if err := jobService.CreateJob(bobCtx, bobJob); err != nil {
+		t.Fatal(err)
+	}
+
+	// Users cannot delete other users.
+	if _, err := userService.RequestUserDeletion(aliceCtx, bob.ID); err != peapod.ErrUnauthorized {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Delete alice. Her playlist's tracks & her trashed track should be returned.
+	if code, err := userService.RequestUserDeletion(aliceCtx, alice.ID); err != nil {
+		t.Fatal(err)
+	} else if tracks, err := userService.ConfirmUserDeletion(aliceCtx, alice.ID, code); err != nil {
+		t.Fatal(err)
+	} else if len(tracks) != 4 {
+		t.Fatalf("unexpected removed tracks: %d", len(tracks))
+	} else if u, err := userService.FindUserByMobileNumber(peapod.NewSystemContext(context.Background()), alice.MobileNumber); err != nil {
+		t.Fatal(err)
+	} else if u != nil {
+		t.Fatal("expected user to be deleted")
+	}
+
+	// Bob's job for alice's playlist should be canceled & dequeued.
+	sysCtx := peapod.NewSystemContext(context.Background())
+	if jobs, err := jobService.FindJobs(sysCtx, peapod.JobFilter{}); err != nil {
+		t.Fatal(err)
+	} else if len(jobs) != 1 || jobs[0].ID != bobJob.ID || jobs[0].Status != peapod.JobStatusCanceled {
+		t.Fatalf("unexpected jobs: %#v", jobs)
+	} else if job, err := jobService.NextJob(sysCtx); err != nil {
+		t.Fatal(err)
+	} else if job != nil {
+		t.Fatalf("unexpected job: %#v", job)
+	}
+
+	// Bob's playlists should remain with alice removed.
+	if members, err := playlistService.FindPlaylistMembers(bobCtx, bobPlaylistID); err != nil {
+		t.Fatal(err)
+	} else if len(members) != 1 {
+		t.Fatalf("unexpected members: %d", len(members))
+	} else if playlist, err := playlistService.FindPlaylistByID(bobCtx, 4); err != nil {
+		t.Fatal(err)
+	} else if len(playlist.SourceIDs) != 1 || playlist.SourceIDs[0] != bobPlaylistID {
+		t.Fatalf("unexpected merged sources: %v", playlist.SourceIDs)
+	} else if playlists, err := playlistService.FindPlaylistsByUserID(bobCtx, bob.ID); err != nil {
+		t.Fatal(err)
+	} else if len(playlists) != 2 {
+		t.Fatalf("unexpected playlists: %d", len(playlists))
+	}
+
+	// Delete bob. No keys should remain in any bucket.
+	if code, err := userService.RequestUserDeletion(bobCtx, bob.ID); err != nil {
+		t.Fatal(err)
+	} else if tracks, err := userService.ConfirmUserDeletion(bobCtx, bob.ID, code); err != nil {
+		t.Fatal(err)
+	} else if len(tracks) != 1 {
+		t.Fatalf("unexpected removed tracks: %d", len(tracks))
+	}
+	if err := db.DB.Close(); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := boltdb.Open(db.Path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	if err := raw.View(func(tx *boltdb.Tx) error {
+		return tx.ForEach(func(name []byte, bkt *boltdb.Bucket) error {
+			if n := bkt.Stats().KeyN; n != 0 {
+				t.Errorf("orphaned keys in %s: %d", name, n)
+			}
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure a user's last track removal or token rotation can be undone.
+func TestUserService_UndoLastAction(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewUserService(db.DB)
+	playlistService := bolt.NewPlaylistService(db.DB)
+	playlistService.TokenGracePeriod = time.Hour
+	trackService := bolt.NewTrackService(db.DB)
+
+	ctx, user, playlist := MustCreateUser(t, db, "+15555550100")
+	if _, err := s.UndoLastAction(ctx, user.ID); err != peapod.ErrNothingToUndo {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, filename := range []string{"a.mp3", "b.mp3", "c.mp3"} {
+		if err := trackService.CreateTrack(ctx, &peapod.Track{PlaylistID: playlist.ID, Filename: filename}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Undoing a user deletion restores the track.
+	if err := trackService.DeleteTrack(ctx, 1, peapod.TrackRemovalReasonUser); err != nil {
+		t.Fatal(err)
+	} else if action, err := s.UndoLastAction(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	} else if action.Type != peapod.UndoActionTypeDeleteTracks || len(action.TrackIDs) != 1 || action.TrackIDs[0] != 1 {
+		t.Fatalf("unexpected action: %#v", action)
+	} else if track, err := trackService.FindTrackByID(ctx, 1); err != nil {
+		t.Fatal(err)
+	} else if track == nil || !track.DeletedAt.IsZero() || track.Pinned {
+		t.Fatalf("unexpected track: %#v", track)
+	} else if _, err := s.UndoLastAction(ctx, user.ID); err != peapod.ErrNothingToUndo {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Consecutive retention removals are undone together & pinned.
+	for _, id := range []int{2, 3} {
+		if err := trackService.DeleteTrack(ctx, id, peapod.TrackRemovalReasonRetention); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if action, err := s.UndoLastAction(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	} else if len(action.TrackIDs) != 2 || action.Reason != peapod.TrackRemovalReasonRetention {
+		t.Fatalf("unexpected action: %#v", action)
+	} else if track, err := trackService.FindTrackByID(ctx, 3); err != nil {
+		t.Fatal(err)
+	} else if track == nil || !track.Pinned {
+		t.Fatalf("unexpected track: %#v", track)
+	}
+
+	// Undoing a rotation restores the previous token until it expires.
+	for i := 0; i < 2; i++ {
+		if i == 1 {
+			db.Now = func() time.Time { return Now.Add(2 * time.Hour) }
+		}
+		oldToken := playlist.Token
+		if _, err := playlistService.RotateToken(ctx, playlist.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if i == 1 {
+			db.Now = func() time.Time { return Now.Add(4 * time.Hour) }
+			if _, err := s.UndoLastAction(ctx, user.ID); err != peapod.ErrNothingToUndo {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		} else if action, err := s.UndoLastAction(ctx, user.ID); err != nil {
+			t.Fatal(err)
+		} else if action.Type != peapod.UndoActionTypeRotateToken {
+			t.Fatalf("unexpected action: %#v", action)
+		} else if other, err := playlistService.FindPlaylistByID(ctx, playlist.ID); err != nil {
+			t.Fatal(err)
+		} else if other.Token != oldToken {
+			t.Fatalf("unexpected token: %s", other.Token)
+		}
+	}
+
+	// Another user cannot undo the user's actions.
+	otherCtx, _, _ := MustCreateUser(t, db, "+15555550101")
+	if _, err := s.UndoLastAction(otherCtx, user.ID); err != peapod.ErrUnauthorized {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure an account deletion must be confirmed with its code in time and
+// that an incorrect code cancels the request.
+func TestUserService_ConfirmUserDeletion(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewUserService(db.DB)
+
+	ctx, user, _ := MustCreateUser(t, db, "+15555550100")
+
+	// Deletion must be requested first.
+	if _, err := s.ConfirmUserDeletion(ctx, user.ID, "000000"); err != peapod.ErrInvalidDeletionCode {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// An incorrect code cancels the request.
+	if code, err := s.RequestUserDeletion(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.ConfirmUserDeletion(ctx, user.ID, "x"+code); err != peapod.ErrInvalidDeletionCode {
+		t.Fatalf("unexpected error: %v", err)
+	} else if _, err := s.ConfirmUserDeletion(ctx, user.ID, code); err != peapod.ErrInvalidDeletionCode {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Expired requests cannot be confirmed.
+	code, err := s.RequestUserDeletion(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Now = func() time.Time { return Now.Add(peapod.UserDeletionConfirmPeriod + time.Second) }
+	if _, err := s.ConfirmUserDeletion(ctx, user.ID, code); err != peapod.ErrInvalidDeletionCode {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A valid code deletes the user.
+	if code, err := s.RequestUserDeletion(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	} else if _, err := s.ConfirmUserDeletion(ctx, user.ID, code); err != nil {
+		t.Fatal(err)
+	} else if other, err := s.FindUserByID(peapod.NewSystemContext(ctx), user.ID); err != nil {
+		t.Fatal(err)
+	} else if other != nil {
+		t.Fatal("expected user to be deleted")
+	}
+}
+
+// Ensure mobile numbers are rewritten in E.164 format & duplicates are merged.
+func TestUserService_NormalizeMobileNumbers(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewUserService(db.DB)
+
+	users := []*peapod.User{
+		{MobileNumber: "(555) 555-0100"},
+		{MobileNumber: "+15555550100"},
+		{MobileNumber: "1-555-555-0101"},
+		{MobileNumber: "+44 7911 123456"},
+		{MobileNumber: "not a number"},
+	}
+	for _, u := range users {
+		if err := s.CreateUser(peapod.NewSystemContext(context.Background()), u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Give the duplicate account a track so it can be verified after the merge.
+	dupCtx := peapod.NewContext(context.Background(), users[1])
+	if err := bolt.NewTrackService(db.DB).CreateTrack(dupCtx, &peapod.Track{PlaylistID: 2, Filename: "a.mp3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if updated, merged, err := s.NormalizeMobileNumbers(peapod.NewSystemContext(context.Background()), "US"); err != nil {
+		t.Fatal(err)
+	} else if updated != 3 || merged != 1 {
+		t.Fatalf("unexpected counts: updated=%d merged=%d", updated, merged)
+	}
+
+	for _, tt := range []struct {
+		mobileNumber string
+		id           int
+	}{
+		{"+15555550100", 1},
+		{"+15555550101", 3},
+		{"+447911123456", 4},
+		{"not a number", 5},
+		{"(555) 555-0100", 0},
+		{"1-555-555-0101", 0},
+	} {
+		if u, err := s.FindUserByMobileNumber(peapod.NewSystemContext(context.Background()), tt.mobileNumber); err != nil {
+			t.Fatal(err)
+		} else if tt.id == 0 && u != nil {
+			t.Fatalf("unexpected user for %s: %d", tt.mobileNumber, u.ID)
+		} else if tt.id != 0 && (u == nil || u.ID != tt.id) {
+			t.Fatalf("unexpected user for %s: %#v", tt.mobileNumber, u)
+		}
+	}
+
+	// The duplicate's playlist should now belong to the original account.
+	ctx := peapod.NewContext(context.Background(), &peapod.User{ID: 1})
+	if playlists, err := bolt.NewPlaylistService(db.DB).FindPlaylistsByUserID(ctx, 1); err != nil {
+		t.Fatal(err)
+	} else if len(playlists) != 2 || playlists[1].OwnerID != 1 {
+		t.Fatalf("unexpected playlists: %#v", playlists)
+	} else if u, err := s.FindUserByID(peapod.NewSystemContext(ctx), 2); err != nil {
+		t.Fatal(err)
+	} else if u != nil {
+		t.Fatal("expected duplicate user to be removed")
+	}
+}
+
+// Ensure users can be listed & disabled by an administrator.
+func TestUserService_UpdateUserAccess(t *testing.T) {
+	db := MustOpenDB()
+	defer db.MustClose()
+	s := bolt.NewUserService(db.DB)
+	sessionService := bolt.NewSessionService(db.DB)
+	ctx := peapod.NewSystemContext(context.Background())
+
+	for _, u := range []*peapod.User{
+		{MobileNumber: "+15555550100"},
+		{MobileNumber: "+15555550101"},
+		{MobileNumber: "+442071838750"},
+	} {
+		if err := s.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	name := "Alice"
+	if _, err := s.UpdateUser(peapod.NewContext(ctx, &peapod.User{ID: 3}), 3, peapod.UserUpdate{Name: &name}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Search by mobile number & name.
+	if users, err := s.FindUsers(ctx, peapod.UserFilter{Query: "555"}); err != nil {
+		t.Fatal(err)
+	} else if len(users) != 2 || users[0].ID != 1 || users[1].ID != 2 {
+		t.Fatalf("unexpected users: %#v", users)
+	} else if users, err := s.FindUsers(ctx, peapod.UserFilter{Query: "alice"}); err != nil {
+		t.Fatal(err)
+	} else if len(users) != 1 || users[0].ID != 3 {
+		t.Fatalf("unexpected users: %#v", users)
+	} else if users, err := s.FindUsers(ctx, peapod.UserFilter{Offset: 1, Limit: 1}); err != nil {
+		t.Fatal(err)
+	} else if len(users) != 1 || users[0].ID != 2 {
+		t.Fatalf("unexpected users: %#v", users)
+	}
+
+	// Log in & then disable the user.
+	code, err := sessionService.CreateLoginCode(ctx, "+15555550100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := sessionService.CreateSession(ctx, "+15555550100", code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disabled := true
+	if user, err := s.UpdateUserAccess(ctx, 1, peapod.UserAccessUpdate{Disabled: &disabled}); err != nil {
+		t.Fatal(err)
+	} else if !user.Disabled || user.Admin {
+		t.Fatalf("unexpected user: %#v", user)
+	}
+
+	// Sessions should be removed & new logins rejected.
+	if other, err := sessionService.FindSessionByToken(ctx, session.Token); err != nil {
+		t.Fatal(err)
+	} else if other != nil {
+		t.Fatal("expected session to be removed")
+	} else if _, err := sessionService.CreateLoginCode(ctx, "+15555550100"); err != peapod.ErrUserDisabled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Re-enable the user.
+	disabled = false
+	if user, err := s.UpdateUserAccess(ctx, 1, peapod.UserAccessUpdate{Disabled: &disabled}); err != nil {
+		t.Fatal(err)
+	} else if user.Disabled {
+		t.Fatal("expected user to be enabled")
+	} else if _, err := sessionService.CreateLoginCode(ctx, "+15555550100"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,224 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/middlemost/peapod"
+	peapodhttp "github.com/middlemost/peapod/http"
+)
+
+// ErrNotSupported is returned by service methods which are not available
+// over the HTTP API, such as system-only maintenance.
+const ErrNotSupported = peapod.Error("not supported by remote service")
+
+// Retry settings.
+const (
+	DefaultMaxRetries    = 3
+	DefaultRetryInterval = 250 * time.Millisecond
+)
+
+// Client represents a client to a remote peapod server. Requests are
+// authenticated with a session token. The admin token is required for
+// administrative methods unless the session belongs to an admin user.
+// The worker token is used by remote workers to process jobs.
+type Client struct {
+	URL         url.URL
+	Token       string // session token
+	AdminToken  string
+	WorkerToken string
+
+	// Idempotent requests are retried after network errors & gateway
+	// errors. The interval is doubled after each attempt.
+	MaxRetries    int
+	RetryInterval time.Duration
+
+	HTTPClient *http.Client
+}
+
+// NewClient returns a new instance of Client.
+func NewClient() *Client {
+	return &Client{
+		MaxRetries:    DefaultMaxRetries,
+		RetryInterval: DefaultRetryInterval,
+		HTTPClient:    http.DefaultClient,
+	}
+}
+
+// SendLoginCode requests a login code to be sent to a mobile number.
+func (c *Client) SendLoginCode(ctx context.Context, mobileNumber string) error {
+	return c.do(ctx, "POST", "/sessions/code", &peapodhttp.PostCodeRequest{MobileNumber: mobileNumber}, nil)
+}
+
+// Login exchanges a login code for a new session. The session token is not
+// assigned to the client.
+func (c *Client) Login(ctx context.Context, mobileNumber, code string) (*peapod.Session, error) {
+	var session peapod.Session
+	if err := c.do(ctx, "POST", "/sessions/", &peapodhttp.PostSessionRequest{MobileNumber: mobileNumber, Code: code}, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Logout ends the client's session.
+func (c *Client) Logout(ctx context.Context) error {
+	return c.do(ctx, "DELETE", "/sessions/current", nil, nil)
+}
+
+// FindFeeds returns the feed URLs for the current user's playlists.
+func (c *Client) FindFeeds(ctx context.Context) ([]*peapodhttp.PlaylistFeed, error) {
+	var feeds []*peapodhttp.PlaylistFeed
+	if err := c.do(ctx, "GET", "/playlists/feeds", nil, &feeds); err != nil {
+		return nil, err
+	}
+	return feeds, nil
+}
+
+// do sends a request to the server and decodes the JSON response into v.
+// Readers are streamed as plain text bodies & all other bodies are sent as
+// JSON. Reader bodies are only retried if they implement io.Seeker so that
+// uploads are never buffered in memory. API errors are returned as
+// peapod.Error so they compare to service errors.
+func (c *Client) do(ctx context.Context, method, path string, body, v interface{}) error {
+	var r io.Reader
+	var contentType string
+	switch body := body.(type) {
+	case nil:
+	case io.Reader:
+		r, contentType = body, "text/plain"
+	default:
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r, contentType = bytes.NewReader(b), "application/json"
+	}
+
+	// Record the body's starting offset so it can be rewound on retry.
+	var offset int64
+	seeker, rewindable := r.(io.Seeker)
+	if r == nil {
+		rewindable = true
+	} else if rewindable {
+		var err error
+		if offset, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			rewindable = false
+		}
+	}
+
+	interval := c.RetryInterval
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, contentType, r)
+		if err == nil && !isRetryableStatus(resp.StatusCode) {
+			defer resp.Body.Close()
+			return decodeResponse(resp, v)
+		} else if err == nil {
+			err = fmt.Errorf("peapod: unexpected status: %d", resp.StatusCode)
+			resp.Body.Close()
+		}
+
+		// Only retry idempotent requests which have not been canceled and
+		// whose body can be resent.
+		if attempt >= c.MaxRetries || !isIdempotent(method) || !rewindable || ctx.Err() != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		interval *= 2
+
+		if seeker != nil {
+			if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// send performs a single request attempt. The body is not closed so that it
+// can be rewound for another attempt.
+func (c *Client) send(ctx context.Context, method, path, contentType string, body io.Reader) (*http.Response, error) {
+	r := body
+	if _, ok := body.(io.Closer); ok {
+		r = ioutil.NopCloser(body)
+	}
+
+	req, err := http.NewRequest(method, c.URL.String()+path, r)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if id := peapod.CorrelationIDFromContext(ctx); id != "" {
+		req.Header.Set(peapodhttp.CorrelationIDHeader, id)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if c.AdminToken != "" {
+		req.Header.Set(peapodhttp.AdminTokenHeader, c.AdminToken)
+	}
+	if c.WorkerToken != "" {
+		req.Header.Set(peapodhttp.WorkerTokenHeader, c.WorkerToken)
+
+		// Workers act as the user in ctx unless it is a system context.
+		if user := peapod.FromContext(ctx); user != nil && !peapod.IsSystemContext(ctx) {
+			req.Header.Set(peapodhttp.WorkerUserHeader, strconv.Itoa(user.ID))
+		}
+	}
+	return c.HTTPClient.Do(req)
+}
+
+// decodeResponse decodes a successful response into v or returns the
+// error from an error response.
+func decodeResponse(resp *http.Response, v interface{}) error {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errResp peapodhttp.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err == nil && errResp.Err != "" {
+			return peapod.Error(errResp.Err)
+		} else if resp.StatusCode == http.StatusUnauthorized {
+			return peapod.ErrUnauthorized
+		}
+		return fmt.Errorf("peapod: unexpected status: %d", resp.StatusCode)
+	}
+
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// isIdempotent returns true if requests with method can be safely resent.
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE":
+		return true
+	default:
+		return false
+	}
+}
+
+// isRetryableStatus returns true if the status indicates a transient error
+// between the client & the server.
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
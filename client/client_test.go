@@ -0,0 +1,325 @@
+package client_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/client"
+	peapodhttp "github.com/middlemost/peapod/http"
+	"github.com/middlemost/peapod/mock"
+)
+
+// Test session, admin & worker tokens.
+const (
+	SessionToken = "SESSION"
+	AdminToken   = "ADMIN"
+	WorkerToken  = "WORKER"
+)
+
+// Server represents a test wrapper for an API server backed by mock services.
+type Server struct {
+	*httptest.Server
+	Handler *peapodhttp.Server
+
+	// Current user of the session token.
+	User *peapod.User
+
+	FileService     mock.FileService
+	JobService      mock.JobService
+	PlaylistService mock.PlaylistService
+	SessionService  mock.SessionService
+	SMSService      mock.SMSService
+	TrackService    mock.TrackService
+	UserService     mock.UserService
+}
+
+// MustOpenServer returns a running server. Requests with the session token
+// are authenticated as user #1.
+func MustOpenServer() *Server {
+	s := &Server{
+		Handler: peapodhttp.NewServer(),
+		User:    &peapod.User{ID: 1, MobileNumber: "+15555550100"},
+	}
+	s.Handler.AdminToken = AdminToken
+	s.Handler.WorkerToken = WorkerToken
+	s.Handler.FileService = &s.FileService
+	s.Handler.JobService = &s.JobService
+	s.Handler.PlaylistService = &s.PlaylistService
+	s.Handler.SessionService = &s.SessionService
+	s.Handler.SMSService = &s.SMSService
+	s.Handler.TrackService = &s.TrackService
+	s.Handler.UserService = &s.UserService
+
+	s.SessionService.FindSessionByTokenFn = func(ctx context.Context, token string) (*peapod.Session, error) {
+		if token != SessionToken {
+			return nil, nil
+		}
+		return &peapod.Session{UserID: s.User.ID, User: s.User}, nil
+	}
+
+	s.Server = httptest.NewServer(s.Handler.Handler())
+	return s
+}
+
+// Client returns a client authenticated with the session token.
+func (s *Server) Client() *client.Client {
+	c := NewClient(s.Server.URL)
+	c.Token = SessionToken
+	return c
+}
+
+// AdminClient returns a client authenticated with the admin token.
+func (s *Server) AdminClient() *client.Client {
+	c := NewClient(s.Server.URL)
+	c.AdminToken = AdminToken
+	return c
+}
+
+// WorkerClient returns a client authenticated with the worker token.
+func (s *Server) WorkerClient() *client.Client {
+	c := NewClient(s.Server.URL)
+	c.WorkerToken = WorkerToken
+	return c
+}
+
+// NewClient returns a client for rawurl which retries without delay.
+func NewClient(rawurl string) *client.Client {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		panic(err)
+	}
+
+	c := client.NewClient()
+	c.URL = *u
+	c.RetryInterval = time.Millisecond
+	return c
+}
+
+// Ensure API errors are returned as the matching service errors.
+func TestClient_Error(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	s.TrackService.RestoreTrackFn = func(ctx context.Context, id int) (*peapod.Track, error) {
+		return nil, peapod.ErrTrackNotFound
+	}
+	if _, err := client.NewTrackService(s.Client()).RestoreTrack(context.Background(), 100); err != peapod.ErrTrackNotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Validation errors are client errors & are returned unmasked.
+	for _, e := range []error{
+		peapod.ErrUserRequired,
+		peapod.ErrUserMobileNumberInUse,
+		peapod.ErrPlaylistRequired,
+		peapod.ErrPlaylistOwnerRequired,
+		peapod.ErrPlaylistInviteRequired,
+		peapod.ErrTrackRequired,
+		peapod.ErrTrackPlaylistRequired,
+		peapod.ErrTrackFilenameRequired,
+		peapod.ErrFilenameRequired,
+		peapod.ErrJobRequired,
+		peapod.ErrJobOwnerRequired,
+		peapod.ErrJobOwnerNotFound,
+		peapod.ErrInvalidJobType,
+		peapod.ErrInvalidRegion,
+	} {
+		if code := peapodhttp.ErrorStatusCode(e); code < 400 || code >= 500 {
+			t.Fatalf("unexpected status for %q: %d", e, code)
+		}
+		s.TrackService.RestoreTrackFn = func(ctx context.Context, id int) (*peapod.Track, error) {
+			return nil, e
+		}
+		if _, err := client.NewTrackService(s.Client()).RestoreTrack(context.Background(), 100); err != e {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// Unrecognized errors are masked by the server.
+	s.TrackService.RestoreTrackFn = func(ctx context.Context, id int) (*peapod.Track, error) {
+		return nil, peapod.Error("marker")
+	}
+	if _, err := client.NewTrackService(s.Client()).RestoreTrack(context.Background(), 100); err != peapod.ErrInternal {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure requests with an unknown session token are rejected.
+func TestClient_Unauthorized(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	c := s.Client()
+	c.Token = "BAD"
+	if _, err := client.NewTrackService(c).FindTrashedTracks(context.Background()); err != peapod.ErrUnauthorized {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a mobile number header does not authenticate a request, even for
+// the mobile number of an admin.
+func TestServer_MobileNumberHeader(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	s.User.Admin = true
+	s.UserService.FindUserByMobileNumberFn = func(ctx context.Context, mobileNumber string) (*peapod.User, error) {
+		return s.User, nil
+	}
+	s.UserService.FindUsersFn = func(ctx context.Context, filter peapod.UserFilter) ([]*peapod.User, error) {
+		return []*peapod.User{s.User}, nil
+	}
+
+	for _, path := range []string{"/users/me", "/admin/users"} {
+		req, err := http.NewRequest("GET", s.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-MOBILE-NUMBER", s.User.MobileNumber)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("unexpected status: path=%s status=%d", path, resp.StatusCode)
+		}
+	}
+}
+
+// Ensure idempotent requests are retried after gateway errors.
+func TestClient_Retry(t *testing.T) {
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"pending":2}`))
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+	if stats, err := client.NewJobService(c).FindJobQueueStats(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if stats.Pending != 2 {
+		t.Fatalf("unexpected stats: %#v", stats)
+	} else if n != 3 {
+		t.Fatalf("unexpected attempts: %d", n)
+	}
+}
+
+// Ensure idempotent requests fail once retries are exhausted.
+func TestClient_Retry_Exhausted(t *testing.T) {
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+	c.MaxRetries = 2
+	if _, err := client.NewJobService(c).FindJobQueueStats(context.Background()); err == nil || err.Error() != "peapod: unexpected status: 502" {
+		t.Fatalf("unexpected error: %v", err)
+	} else if n != 3 {
+		t.Fatalf("unexpected attempts: %d", n)
+	}
+}
+
+// Ensure requests which are not idempotent are only sent once.
+func TestClient_Retry_NotIdempotent(t *testing.T) {
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	if _, err := client.NewJobService(NewClient(ts.URL)).RetryJob(context.Background(), 1); err == nil {
+		t.Fatal("expected error")
+	} else if n != 1 {
+		t.Fatalf("unexpected attempts: %d", n)
+	}
+}
+
+// Ensure uploads are streamed & only retried if the body can be rewound.
+func TestClient_Retry_Upload(t *testing.T) {
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if buf, _ := ioutil.ReadAll(r.Body); string(buf) != "DATA" {
+			t.Errorf("unexpected body: %q", buf)
+		}
+		if atomic.AddInt32(&n, 1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"size":4}`))
+	}))
+	defer ts.Close()
+
+	// Seekable bodies are rewound & resent.
+	c := NewClient(ts.URL)
+	file := &peapod.File{Name: "a.mp3"}
+	if err := client.NewFileService(c).CreateFile(context.Background(), file, strings.NewReader("DATA")); err != nil {
+		t.Fatal(err)
+	} else if file.Size != 4 {
+		t.Fatalf("unexpected size: %d", file.Size)
+	} else if n != 2 {
+		t.Fatalf("unexpected attempts: %d", n)
+	}
+
+	// Streamed bodies are only sent once.
+	n = 0
+	r := ioutil.NopCloser(strings.NewReader("DATA"))
+	if err := client.NewFileService(c).CreateFile(context.Background(), file, r); err == nil {
+		t.Fatal("expected error")
+	} else if n != 1 {
+		t.Fatalf("unexpected attempts: %d", n)
+	}
+}
+
+// Ensure a login code can be exchanged for a session.
+func TestClient_Login(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	var sent string
+	s.SessionService.CreateLoginCodeFn = func(ctx context.Context, mobileNumber string) (string, error) {
+		return "123456", nil
+	}
+	s.SMSService.SendSMSFn = func(ctx context.Context, msg *peapod.SMS) error {
+		sent = msg.To
+		return nil
+	}
+	s.SessionService.CreateSessionFn = func(ctx context.Context, mobileNumber, code string) (*peapod.Session, error) {
+		if mobileNumber != "+15555550100" || code != "123456" {
+			return nil, peapod.ErrInvalidLoginCode
+		}
+		return &peapod.Session{Token: "NEWTOKEN", UserID: 1}, nil
+	}
+
+	c := NewClient(s.Server.URL)
+	if err := c.SendLoginCode(context.Background(), "555-555-0100"); err != nil {
+		t.Fatal(err)
+	} else if sent != "+15555550100" {
+		t.Fatalf("unexpected recipient: %s", sent)
+	}
+
+	if _, err := c.Login(context.Background(), "555-555-0100", "000000"); err != peapod.ErrInvalidLoginCode {
+		t.Fatalf("unexpected error: %v", err)
+	} else if session, err := c.Login(context.Background(), "555-555-0100", "123456"); err != nil {
+		t.Fatal(err)
+	} else if session.Token != "NEWTOKEN" {
+		t.Fatalf("unexpected token: %s", session.Token)
+	}
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"io"
+
+	"github.com/middlemost/peapod"
+)
+
+// Ensure service implements interface.
+var _ peapod.FileService = &FileService{}
+
+// FileService represents a service for uploading files to a remote server.
+// Uploads require a worker token.
+type FileService struct {
+	client *Client
+}
+
+// NewFileService returns a new instance of FileService.
+func NewFileService(client *Client) *FileService {
+	return &FileService{client: client}
+}
+
+// GenerateName returns a randomly generated name with the given extension.
+func (s *FileService) GenerateName(ext string) string {
+	return peapod.GenerateToken() + ext
+}
+
+// FindFileByName is not supported. Files are served by the server's feeds.
+func (s *FileService) FindFileByName(ctx context.Context, name string) (*peapod.File, io.ReadCloser, error) {
+	return nil, nil, ErrNotSupported
+}
+
+// CreateFile uploads the contents of r as a new file. The file's size is
+// set once the upload completes.
+func (s *FileService) CreateFile(ctx context.Context, f *peapod.File, r io.Reader) error {
+	if f.Name == "" {
+		return peapod.ErrFilenameRequired
+	}
+	return s.client.do(ctx, "PUT", "/worker/files/"+f.Name, r, f)
+}
+
+// DeleteFile is not supported. Files are removed by the server.
+func (s *FileService) DeleteFile(ctx context.Context, name string) error {
+	return ErrNotSupported
+}
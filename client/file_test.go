@@ -0,0 +1,43 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/client"
+)
+
+// Ensure workers can upload files.
+func TestFileService_CreateFile(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	s.FileService.CreateFileFn = func(ctx context.Context, f *peapod.File, r io.Reader) error {
+		if buf, err := ioutil.ReadAll(r); err != nil {
+			return err
+		} else if string(buf) != "DATA" {
+			t.Fatalf("unexpected data: %q", buf)
+		}
+		f.Size = 4
+		return nil
+	}
+
+	fs := client.NewFileService(s.WorkerClient())
+	file := &peapod.File{Name: fs.GenerateName(".mp3")}
+	if !peapod.IsValidFilename(file.Name) {
+		t.Fatalf("invalid name: %s", file.Name)
+	} else if err := fs.CreateFile(context.Background(), file, strings.NewReader("DATA")); err != nil {
+		t.Fatal(err)
+	} else if file.Size != 4 {
+		t.Fatalf("unexpected size: %d", file.Size)
+	}
+
+	// Names are validated by the server.
+	if err := fs.CreateFile(context.Background(), &peapod.File{Name: "a.b.c"}, strings.NewReader("DATA")); err != peapod.ErrInvalidFilename {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
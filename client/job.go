@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/middlemost/peapod"
+	peapodhttp "github.com/middlemost/peapod/http"
+)
+
+// Ensure service implements interface.
+var _ peapod.JobService = &JobService{}
+
+// JobService represents a service for submitting & managing jobs on a
+// remote server. Jobs are executed by the server or by remote workers.
+type JobService struct {
+	client *Client
+}
+
+// NewJobService returns a new instance of JobService.
+func NewJobService(client *Client) *JobService {
+	return &JobService{client: client}
+}
+
+// C returns a nil channel. Job notifications are not sent to remote clients.
+func (s *JobService) C() <-chan struct{} { return nil }
+
+// CreateJob adds a job for the current user to the job processing queue.
+// The owner is always the current user. If no playlist is specified then
+// the user's default playlist is used.
+func (s *JobService) CreateJob(ctx context.Context, job *peapod.Job) error {
+	switch job.Type {
+	case peapod.JobTypeCreateTrackFromURL:
+		return s.client.do(ctx, "POST", "/tracks/", &peapodhttp.PostTrackRequest{
+			URL:        job.URL,
+			PlaylistID: job.PlaylistID,
+			Tags:       job.Tags,
+		}, job)
+
+	case peapod.JobTypeCreateTrackFromTTS:
+		q := url.Values{"title": {job.Title}}
+		if job.PlaylistID != 0 {
+			q.Set("playlist_id", strconv.Itoa(job.PlaylistID))
+		}
+		if len(job.Tags) > 0 {
+			q.Set("tags", strings.Join(job.Tags, ","))
+		}
+		return s.client.do(ctx, "POST", "/tracks/tts?"+q.Encode(), strings.NewReader(job.Text), job)
+
+	default:
+		return peapod.ErrInvalidJobType
+	}
+}
+
+// NextJob claims the next job in the queue. Returns nil if there are no
+// pending jobs. Requires a worker token.
+func (s *JobService) NextJob(ctx context.Context) (*peapod.Job, error) {
+	var job *peapod.Job
+	if err := s.client.do(ctx, "POST", "/worker/jobs/next", nil, &job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// CompleteJob marks a claimed job as completed or failed. Requires a worker token.
+func (s *JobService) CompleteJob(ctx context.Context, id int, lease string, err error) error {
+	req := peapodhttp.PostCompleteRequest{Lease: lease}
+	if err != nil {
+		req.Error = err.Error()
+	}
+	return s.client.do(ctx, "POST", fmt.Sprintf("/worker/jobs/%d/complete", id), &req, nil)
+}
+
+// HeartbeatJob extends the claim on a job. Requires a worker token.
+func (s *JobService) HeartbeatJob(ctx context.Context, id int, lease string) error {
+	return s.client.do(ctx, "POST", fmt.Sprintf("/worker/jobs/%d/heartbeat", id), &peapodhttp.PostHeartbeatRequest{Lease: lease}, nil)
+}
+
+// FindJobs returns jobs matching filter, newest first. Requires admin access.
+func (s *JobService) FindJobs(ctx context.Context, filter peapod.JobFilter) ([]*peapod.Job, error) {
+	q := url.Values{"status": {filter.Status}, "offset": {strconv.Itoa(filter.Offset)}, "limit": {strconv.Itoa(filter.Limit)}}
+	var jobs []*peapod.Job
+	if err := s.client.do(ctx, "GET", "/admin/jobs?"+q.Encode(), nil, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// RetryJob moves a failed job back onto the job queue. Requires admin access.
+func (s *JobService) RetryJob(ctx context.Context, id int) (*peapod.Job, error) {
+	var job peapod.Job
+	if err := s.client.do(ctx, "POST", fmt.Sprintf("/admin/jobs/%d/retry", id), nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CancelJob removes a pending job from the job queue. Requires admin access.
+func (s *JobService) CancelJob(ctx context.Context, id int) (*peapod.Job, error) {
+	var job peapod.Job
+	if err := s.client.do(ctx, "POST", fmt.Sprintf("/admin/jobs/%d/cancel", id), nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// FindJobQueueStats returns the depth of the job queue. Requires admin access.
+func (s *JobService) FindJobQueueStats(ctx context.Context) (*peapod.JobQueueStats, error) {
+	var stats peapod.JobQueueStats
+	if err := s.client.do(ctx, "GET", "/admin/queue", nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
@@ -0,0 +1,307 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/client"
+	peapodhttp "github.com/middlemost/peapod/http"
+	"github.com/middlemost/peapod/mock"
+)
+
+// Ensure URL jobs are submitted for the current user.
+func TestJobService_CreateJob_URL(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	s.JobService.CreateJobFn = func(ctx context.Context, job *peapod.Job) error {
+		if !reflect.DeepEqual(job, &peapod.Job{
+			OwnerID:    1,
+			Type:       peapod.JobTypeCreateTrackFromURL,
+			PlaylistID: 2,
+			URL:        "https://example.com/a",
+			Tags:       []string{"news"},
+		}) {
+			t.Fatalf("unexpected job: %#v", job)
+		}
+		job.ID, job.Status = 100, peapod.JobStatusPending
+		return nil
+	}
+
+	job := &peapod.Job{Type: peapod.JobTypeCreateTrackFromURL, PlaylistID: 2, URL: "https://example.com/a", Tags: []string{"news"}}
+	if err := client.NewJobService(s.Client()).CreateJob(context.Background(), job); err != nil {
+		t.Fatal(err)
+	} else if job.ID != 100 || job.Status != peapod.JobStatusPending {
+		t.Fatalf("unexpected job: %#v", job)
+	}
+}
+
+// Ensure TTS jobs use the user's default playlist if one is not specified.
+func TestJobService_CreateJob_TTS(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	s.PlaylistService.FindPlaylistsByUserIDFn = func(ctx context.Context, id int) ([]*peapod.Playlist, error) {
+		return []*peapod.Playlist{{ID: 3}}, nil
+	}
+	s.JobService.CreateJobFn = func(ctx context.Context, job *peapod.Job) error {
+		if job.Type != peapod.JobTypeCreateTrackFromTTS || job.PlaylistID != 3 || job.Title != "TITLE" || job.Text != "hello world" {
+			t.Fatalf("unexpected job: %#v", job)
+		}
+		job.ID = 100
+		return nil
+	}
+
+	job := &peapod.Job{Type: peapod.JobTypeCreateTrackFromTTS, Title: "TITLE", Text: "hello world"}
+	if err := client.NewJobService(s.Client()).CreateJob(context.Background(), job); err != nil {
+		t.Fatal(err)
+	} else if job.ID != 100 {
+		t.Fatalf("unexpected id: %d", job.ID)
+	}
+}
+
+// Ensure jobs without a playlist fail if the user has no default playlist.
+func TestJobService_CreateJob_ErrPlaylistNotFound(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	s.PlaylistService.FindPlaylistsByUserIDFn = func(ctx context.Context, id int) ([]*peapod.Playlist, error) {
+		return nil, nil
+	}
+
+	for _, job := range []*peapod.Job{
+		{Type: peapod.JobTypeCreateTrackFromURL, URL: "https://example.com/a"},
+		{Type: peapod.JobTypeCreateTrackFromTTS, Title: "TITLE", Text: "hello world"},
+	} {
+		if err := client.NewJobService(s.Client()).CreateJob(context.Background(), job); err != peapod.ErrPlaylistNotFound {
+			t.Fatalf("unexpected error: type=%s err=%v", job.Type, err)
+		}
+	}
+}
+
+// Ensure invalid job types are rejected before sending.
+func TestJobService_CreateJob_ErrInvalidJobType(t *testing.T) {
+	if err := client.NewJobService(client.NewClient()).CreateJob(context.Background(), &peapod.Job{}); err != peapod.ErrInvalidJobType {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure administrative methods require admin access.
+func TestJobService_FindJobs(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	s.JobService.FindJobsFn = func(ctx context.Context, filter peapod.JobFilter) ([]*peapod.Job, error) {
+		if !peapod.IsSystemContext(ctx) {
+			t.Fatal("expected system context")
+		} else if filter != (peapod.JobFilter{Status: peapod.JobStatusFailed, Offset: 1, Limit: 2}) {
+			t.Fatalf("unexpected filter: %#v", filter)
+		}
+		return []*peapod.Job{{ID: 100}}, nil
+	}
+
+	filter := peapod.JobFilter{Status: peapod.JobStatusFailed, Offset: 1, Limit: 2}
+	if jobs, err := client.NewJobService(s.AdminClient()).FindJobs(context.Background(), filter); err != nil {
+		t.Fatal(err)
+	} else if len(jobs) != 1 || jobs[0].ID != 100 {
+		t.Fatalf("unexpected jobs: %#v", jobs)
+	}
+
+	// Regular users cannot access the admin API.
+	if _, err := client.NewJobService(s.Client()).FindJobs(context.Background(), filter); err != peapodhttp.ErrAdminRequired {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure workers can claim, extend & complete jobs.
+func TestJobService_NextJob(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	var n int
+	s.JobService.NextJobFn = func(ctx context.Context) (*peapod.Job, error) {
+		if !peapod.IsSystemContext(ctx) {
+			t.Fatal("expected system context")
+		}
+		if n++; n > 1 {
+			return nil, nil
+		}
+		return &peapod.Job{ID: 100, OwnerID: 1, Owner: s.User, Status: peapod.JobStatusProcessing, Lease: "LEASE"}, nil
+	}
+	s.JobService.HeartbeatJobFn = func(ctx context.Context, id int, lease string) error {
+		if id != 100 || lease != "LEASE" {
+			return peapod.ErrJobNotProcessing
+		}
+		return nil
+	}
+	s.JobService.CompleteJobFn = func(ctx context.Context, id int, lease string, err error) error {
+		if id != 100 || lease != "LEASE" || err == nil || err.Error() != "marker" {
+			t.Fatalf("unexpected args: id=%d lease=%s err=%v", id, lease, err)
+		}
+		return nil
+	}
+
+	js := client.NewJobService(s.WorkerClient())
+	if job, err := js.NextJob(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if job.ID != 100 || job.Lease != "LEASE" || job.Owner == nil || job.Owner.MobileNumber != "+15555550100" {
+		t.Fatalf("unexpected job: %#v", job)
+	} else if job, err := js.NextJob(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if job != nil {
+		t.Fatalf("expected nil job: %#v", job)
+	}
+
+	if err := js.HeartbeatJob(context.Background(), 100, "LEASE"); err != nil {
+		t.Fatal(err)
+	} else if err := js.HeartbeatJob(context.Background(), 100, "OTHER"); err != peapod.ErrJobNotProcessing {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := js.CompleteJob(context.Background(), 100, "LEASE", errors.New("marker")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sessions cannot be used to claim jobs.
+	if _, err := client.NewJobService(s.Client()).NextJob(context.Background()); err != peapod.ErrUnauthorized {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a job scheduler can execute jobs from a remote server. Results are
+// uploaded & the track is created as the job owner.
+func TestJobService_RemoteScheduler(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	// Server side: queue a single TTS job & accept its results.
+	var n int
+	s.JobService.NextJobFn = func(ctx context.Context) (*peapod.Job, error) {
+		if n++; n > 1 {
+			return nil, nil
+		}
+		return &peapod.Job{
+			ID:         100,
+			OwnerID:    1,
+			Owner:      s.User,
+			Type:       peapod.JobTypeCreateTrackFromTTS,
+			PlaylistID: 2,
+			Title:      "TITLE",
+			Text:       "hello world",
+
+			CorrelationID: "SM0001",
+			Lease:         "LEASE",
+		}, nil
+	}
+	s.UserService.FindUserByIDFn = func(ctx context.Context, id int) (*peapod.User, error) {
+		if id != s.User.ID {
+			return nil, nil
+		}
+		return s.User, nil
+	}
+	files := make(map[string]string)
+	s.FileService.CreateFileFn = func(ctx context.Context, f *peapod.File, r io.Reader) error {
+		buf, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		files[f.Name] = string(buf)
+		f.Size = int64(len(buf))
+		return nil
+	}
+	s.PlaylistService.FindPlaylistByIDFn = func(ctx context.Context, id int) (*peapod.Playlist, error) {
+		return &peapod.Playlist{ID: 2, OwnerID: 1, Name: "NAME"}, nil
+	}
+	s.PlaylistService.FindPlaylistMembersFn = func(ctx context.Context, id int) ([]*peapod.PlaylistMember, error) {
+		return []*peapod.PlaylistMember{{PlaylistID: 2, UserID: 1}}, nil
+	}
+	s.TrackService.CreateTrackFn = func(ctx context.Context, track *peapod.Track) error {
+		if peapod.IsSystemContext(ctx) || peapod.FromContext(ctx).ID != 1 {
+			t.Fatal("expected job owner context")
+		} else if id := peapod.CorrelationIDFromContext(ctx); id != "SM0001" {
+			t.Fatalf("unexpected correlation id: %q", id)
+		} else if files[track.Filename] != "AUDIO" || files[track.TranscriptFilename] != "hello world" {
+			t.Fatalf("unexpected track files: %#v", track)
+		} else if track.Title != "TITLE" || track.PlaylistID != 2 || track.Size != 5 {
+			t.Fatalf("unexpected track: %#v", track)
+		}
+		track.ID = 200
+		return nil
+	}
+	done := make(chan error, 1)
+	s.JobService.CompleteJobFn = func(ctx context.Context, id int, lease string, err error) error {
+		if id != 100 || lease != "LEASE" {
+			t.Fatalf("unexpected args: id=%d lease=%s", id, lease)
+		} else if cid := peapod.CorrelationIDFromContext(ctx); cid != "SM0001" {
+			t.Fatalf("unexpected correlation id: %q", cid)
+		}
+		done <- err
+		return nil
+	}
+
+	// Worker side: generate audio & send notifications locally.
+	// Log from the executing job to verify the job fields are attached.
+	var logs bytes.Buffer
+	logger, err := peapod.NewLogger(&logs, peapod.LogFormatLogfmt, slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ttsService mock.TTSService
+	ttsService.SynthesizeSpeechFn = func(ctx context.Context, text string) (io.ReadCloser, error) {
+		logger.InfoContext(ctx, "synthesizing")
+		return ioutil.NopCloser(strings.NewReader("AUDIO")), nil
+	}
+	var smsService mock.SMSService
+	smsService.SendSMSFn = func(ctx context.Context, msg *peapod.SMS) error {
+		if msg.To != "+15555550100" || msg.Body != `"TITLE" has been added to your playlist.` {
+			t.Fatalf("unexpected message: %#v", msg)
+		}
+		return nil
+	}
+
+	c := s.WorkerClient()
+	scheduler := peapod.NewJobScheduler()
+	scheduler.FileService = client.NewFileService(c)
+	scheduler.JobService = client.NewJobService(c)
+	scheduler.PlaylistService = client.NewPlaylistService(c)
+	scheduler.SMSService = &smsService
+	scheduler.TrackService = client.NewTrackService(c)
+	scheduler.TTSService = &ttsService
+	scheduler.UserService = client.NewUserService(c)
+	scheduler.Logger = logger
+	if err := scheduler.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer scheduler.Close()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	// Wait for the job to complete before reading the logs.
+	scheduler.Close()
+	for _, msg := range []string{`msg="job started"`, `msg=synthesizing`, `msg="job completed"`} {
+		var found bool
+		for _, line := range strings.Split(logs.String(), "\n") {
+			if strings.Contains(line, msg) && strings.HasSuffix(line, "correlation_id=SM0001 job_id=100 user_id=1") {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("expected job fields on %s: %s", msg, logs.String())
+		}
+	}
+}
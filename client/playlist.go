@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/middlemost/peapod"
+	peapodhttp "github.com/middlemost/peapod/http"
+)
+
+// Ensure service implements interface.
+var _ peapod.PlaylistService = &PlaylistService{}
+
+// PlaylistService represents a service for managing playlists on a remote server.
+type PlaylistService struct {
+	client *Client
+}
+
+// NewPlaylistService returns a new instance of PlaylistService.
+func NewPlaylistService(client *Client) *PlaylistService {
+	return &PlaylistService{client: client}
+}
+
+// FindPlaylistByID returns a playlist & its tracks by id. Returns nil if
+// the playlist does not exist. The admin or worker API is used if the client
+// has the matching token.
+func (s *PlaylistService) FindPlaylistByID(ctx context.Context, id int) (*peapod.Playlist, error) {
+	path := fmt.Sprintf("/playlists/%d/info", id)
+	if s.client.AdminToken != "" {
+		path = fmt.Sprintf("/admin/playlists/%d", id)
+	} else if s.client.WorkerToken != "" {
+		path = fmt.Sprintf("/worker/playlists/%d", id)
+	}
+
+	var playlist peapod.Playlist
+	if err := s.client.do(ctx, "GET", path, nil, &playlist); err == peapod.ErrPlaylistNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &playlist, nil
+}
+
+// FindPlaylistByToken is not supported. Tokens are only exposed as feeds.
+func (s *PlaylistService) FindPlaylistByToken(ctx context.Context, token string) (*peapod.Playlist, error) {
+	return nil, ErrNotSupported
+}
+
+// FindPlaylistsByUserID returns the playlists owned by the user followed
+// by the playlists the user is a member of.
+func (s *PlaylistService) FindPlaylistsByUserID(ctx context.Context, id int) ([]*peapod.Playlist, error) {
+	var resp peapodhttp.GetPlaylistsResponse
+	if err := s.client.do(ctx, "GET", fmt.Sprintf("/users/%d/playlists", id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Playlists, nil
+}
+
+// CreatePlaylist creates a new playlist owned by the current user.
+func (s *PlaylistService) CreatePlaylist(ctx context.Context, playlist *peapod.Playlist) error {
+	var resp peapodhttp.PostPlaylistResponse
+	if err := s.client.do(ctx, "POST", "/playlists/", &peapodhttp.PostPlaylistRequest{
+		Name:      playlist.Name,
+		Type:      playlist.Type,
+		Filter:    peapodhttp.NewSmartFilterRequest(playlist.Filter),
+		SourceIDs: playlist.SourceIDs,
+	}, &resp); err != nil {
+		return err
+	}
+	*playlist = *resp.Playlist
+	return nil
+}
+
+// SetPlaylistRetention sets the retention limits for a playlist.
+func (s *PlaylistService) SetPlaylistRetention(ctx context.Context, id int, retention *peapod.Retention) error {
+	return s.client.do(ctx, "PUT", fmt.Sprintf("/playlists/%d/retention", id), peapodhttp.NewPutRetentionRequest(retention), nil)
+}
+
+// FindPlaylistsWithRetention is not supported. It is only used by the
+// retention sweeper.
+func (s *PlaylistService) FindPlaylistsWithRetention(ctx context.Context) ([]*peapod.Playlist, error) {
+	return nil, ErrNotSupported
+}
+
+// RotateToken generates a new token for a playlist.
+func (s *PlaylistService) RotateToken(ctx context.Context, id int) (*peapod.Playlist, error) {
+	var resp peapodhttp.PostTokenResponse
+	if err := s.client.do(ctx, "POST", fmt.Sprintf("/playlists/%d/token", id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Playlist, nil
+}
+
+// FindPlaylistMembers returns the members of a playlist.
+func (s *PlaylistService) FindPlaylistMembers(ctx context.Context, playlistID int) ([]*peapod.PlaylistMember, error) {
+	path := fmt.Sprintf("/playlists/%d/members", playlistID)
+	if s.client.WorkerToken != "" {
+		path = "/worker" + path
+	}
+
+	var resp peapodhttp.GetMembersResponse
+	if err := s.client.do(ctx, "GET", path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Members, nil
+}
+
+// CreatePlaylistInvite invites a mobile number to join a playlist.
+func (s *PlaylistService) CreatePlaylistInvite(ctx context.Context, invite *peapod.PlaylistInvite) error {
+	return s.client.do(ctx, "POST", fmt.Sprintf("/playlists/%d/invites", invite.PlaylistID), &peapodhttp.PostInviteRequest{
+		MobileNumber: invite.MobileNumber,
+		Role:         invite.Role,
+	}, invite)
+}
+
+// FindPlaylistInvitesByMobileNumber returns the pending invites for the
+// current user. Only the current user's mobile number can be used.
+func (s *PlaylistService) FindPlaylistInvitesByMobileNumber(ctx context.Context, mobileNumber string) ([]*peapod.PlaylistInvite, error) {
+	var resp peapodhttp.GetInvitesResponse
+	if err := s.client.do(ctx, "GET", "/playlists/invites", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Invites, nil
+}
+
+// AcceptPlaylistInvite accepts an invite for the current user.
+func (s *PlaylistService) AcceptPlaylistInvite(ctx context.Context, id int) (*peapod.PlaylistMember, error) {
+	var member peapod.PlaylistMember
+	if err := s.client.do(ctx, "POST", fmt.Sprintf("/playlists/invites/%d/accept", id), nil, &member); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
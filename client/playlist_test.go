@@ -0,0 +1,115 @@
+package client_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/client"
+)
+
+// Ensure a playlist can be found by id & that missing playlists return nil.
+func TestPlaylistService_FindPlaylistByID(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	s.PlaylistService.FindPlaylistByIDFn = func(ctx context.Context, id int) (*peapod.Playlist, error) {
+		if peapod.FromContext(ctx).ID != 1 {
+			t.Fatalf("unexpected user: %#v", peapod.FromContext(ctx))
+		} else if id != 2 {
+			return nil, nil
+		}
+		return &peapod.Playlist{ID: 2, Name: "NAME", Tracks: []*peapod.Track{{ID: 3}}}, nil
+	}
+
+	ps := client.NewPlaylistService(s.Client())
+	if playlist, err := ps.FindPlaylistByID(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	} else if playlist.Name != "NAME" || len(playlist.Tracks) != 1 || playlist.Tracks[0].ID != 3 {
+		t.Fatalf("unexpected playlist: %#v", playlist)
+	}
+
+	if playlist, err := ps.FindPlaylistByID(context.Background(), 100); err != nil {
+		t.Fatal(err)
+	} else if playlist != nil {
+		t.Fatalf("expected nil playlist: %#v", playlist)
+	}
+}
+
+// Ensure a smart playlist can be created with its filter.
+func TestPlaylistService_CreatePlaylist(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	filter := &peapod.SmartFilter{Tags: []string{"news"}, MaxAge: 7 * 24 * time.Hour}
+	s.PlaylistService.CreatePlaylistFn = func(ctx context.Context, playlist *peapod.Playlist) error {
+		if playlist.Name != "News" || playlist.Type != peapod.PlaylistTypeSmart {
+			t.Fatalf("unexpected playlist: %#v", playlist)
+		} else if !reflect.DeepEqual(playlist.Filter, filter) {
+			t.Fatalf("unexpected filter: %#v", playlist.Filter)
+		}
+		playlist.ID, playlist.OwnerID, playlist.Token = 100, 1, "TOKEN"
+		return nil
+	}
+
+	playlist := &peapod.Playlist{Name: "News", Type: peapod.PlaylistTypeSmart, Filter: filter}
+	if err := client.NewPlaylistService(s.Client()).CreatePlaylist(context.Background(), playlist); err != nil {
+		t.Fatal(err)
+	} else if playlist.ID != 100 || playlist.OwnerID != 1 || playlist.Token != "TOKEN" {
+		t.Fatalf("unexpected playlist: %#v", playlist)
+	}
+}
+
+// Ensure retention limits can be set & removed.
+func TestPlaylistService_SetPlaylistRetention(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	var retention *peapod.Retention
+	s.PlaylistService.SetPlaylistRetentionFn = func(ctx context.Context, id int, r *peapod.Retention) error {
+		if id != 2 {
+			t.Fatalf("unexpected id: %d", id)
+		}
+		retention = r
+		return nil
+	}
+
+	ps := client.NewPlaylistService(s.Client())
+	want := &peapod.Retention{MaxAge: 48 * time.Hour, MaxTracks: 10}
+	if err := ps.SetPlaylistRetention(context.Background(), 2, want); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(retention, want) {
+		t.Fatalf("unexpected retention: %#v", retention)
+	}
+
+	if err := ps.SetPlaylistRetention(context.Background(), 2, nil); err != nil {
+		t.Fatal(err)
+	} else if !retention.IsZero() {
+		t.Fatalf("unexpected retention: %#v", retention)
+	}
+}
+
+// Ensure rotating a token returns the updated playlist.
+func TestPlaylistService_RotateToken(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	s.PlaylistService.RotateTokenFn = func(ctx context.Context, id int) (*peapod.Playlist, error) {
+		return &peapod.Playlist{ID: id, Token: "NEWTOKEN"}, nil
+	}
+
+	if playlist, err := client.NewPlaylistService(s.Client()).RotateToken(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	} else if playlist.ID != 2 || playlist.Token != "NEWTOKEN" {
+		t.Fatalf("unexpected playlist: %#v", playlist)
+	}
+}
+
+// Ensure system-only methods are not available remotely.
+func TestPlaylistService_ErrNotSupported(t *testing.T) {
+	if _, err := client.NewPlaylistService(client.NewClient()).FindPlaylistsWithRetention(context.Background()); err != client.ErrNotSupported {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
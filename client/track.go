@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/middlemost/peapod"
+	peapodhttp "github.com/middlemost/peapod/http"
+)
+
+// Ensure service implements interface.
+var _ peapod.TrackService = &TrackService{}
+
+// TrackService represents a service for managing tracks on a remote server.
+type TrackService struct {
+	client *Client
+}
+
+// NewTrackService returns a new instance of TrackService.
+func NewTrackService(client *Client) *TrackService {
+	return &TrackService{client: client}
+}
+
+// FindTrackByID returns a track by id. Returns nil if the track does not exist.
+func (s *TrackService) FindTrackByID(ctx context.Context, id int) (*peapod.Track, error) {
+	var track peapod.Track
+	if err := s.client.do(ctx, "GET", fmt.Sprintf("/tracks/%d", id), nil, &track); err == peapod.ErrTrackNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &track, nil
+}
+
+// CreateTrack creates a track from uploaded files. Tracks are only created
+// by jobs so this requires a worker token.
+func (s *TrackService) CreateTrack(ctx context.Context, track *peapod.Track) error {
+	return s.client.do(ctx, "POST", "/worker/tracks", track, track)
+}
+
+// DeleteTrack moves a track to the current user's trash. Only removals by
+// the user are supported.
+func (s *TrackService) DeleteTrack(ctx context.Context, id int, reason string) error {
+	if reason != peapod.TrackRemovalReasonUser {
+		return ErrNotSupported
+	}
+	return s.client.do(ctx, "DELETE", fmt.Sprintf("/tracks/%d", id), nil, nil)
+}
+
+// FindTrashedTracks returns the tracks in the current user's trash.
+func (s *TrackService) FindTrashedTracks(ctx context.Context) ([]*peapod.Track, error) {
+	var tracks []*peapod.Track
+	if err := s.client.do(ctx, "GET", "/tracks/trash", nil, &tracks); err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+// RestoreTrack moves a track from the current user's trash back to its playlist.
+func (s *TrackService) RestoreTrack(ctx context.Context, id int) (*peapod.Track, error) {
+	var track peapod.Track
+	if err := s.client.do(ctx, "POST", fmt.Sprintf("/tracks/%d/restore", id), nil, &track); err != nil {
+		return nil, err
+	}
+	return &track, nil
+}
+
+// UndoDeleteTrack restores the track most recently deleted by the current user.
+func (s *TrackService) UndoDeleteTrack(ctx context.Context) (*peapod.Track, error) {
+	var track peapod.Track
+	if err := s.client.do(ctx, "POST", "/tracks/undo", nil, &track); err != nil {
+		return nil, err
+	}
+	return &track, nil
+}
+
+// PurgeTrash is not supported. The trash is purged by the server.
+func (s *TrackService) PurgeTrash(ctx context.Context) ([]*peapod.Track, error) {
+	return nil, ErrNotSupported
+}
+
+// PurgeTrack permanently deletes a track. The server also removes the
+// track's files. Requires admin access.
+func (s *TrackService) PurgeTrack(ctx context.Context, id int) (*peapod.Track, error) {
+	var track peapod.Track
+	if err := s.client.do(ctx, "DELETE", fmt.Sprintf("/admin/tracks/%d", id), nil, &track); err != nil {
+		return nil, err
+	}
+	return &track, nil
+}
+
+// SetTrackPinned exempts a track from retention limits or removes the exemption.
+func (s *TrackService) SetTrackPinned(ctx context.Context, id int, pinned bool) error {
+	method := "DELETE"
+	if pinned {
+		method = "POST"
+	}
+	return s.client.do(ctx, method, fmt.Sprintf("/tracks/%d/pin", id), nil, nil)
+}
+
+// FindTrackRemovals returns the history of tracks removed from a playlist.
+func (s *TrackService) FindTrackRemovals(ctx context.Context, playlistID int) ([]*peapod.TrackRemoval, error) {
+	var resp peapodhttp.GetRemovalsResponse
+	if err := s.client.do(ctx, "GET", fmt.Sprintf("/playlists/%d/removals", playlistID), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Removals, nil
+}
+
+// SearchTracks returns the current user's tracks matching a full-text query.
+func (s *TrackService) SearchTracks(ctx context.Context, search peapod.TrackSearch) (*peapod.TrackSearchResult, error) {
+	q := url.Values{"q": {search.Query}, "offset": {strconv.Itoa(search.Offset)}, "limit": {strconv.Itoa(search.Limit)}}
+	var result peapod.TrackSearchResult
+	if err := s.client.do(ctx, "GET", "/tracks/search?"+q.Encode(), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// FindTracks returns a page of the current user's tracks.
+func (s *TrackService) FindTracks(ctx context.Context, filter peapod.TrackFilter) (*peapod.TrackPage, error) {
+	var page peapod.TrackPage
+	if err := s.client.do(ctx, "GET", "/tracks/?"+encodeTrackFilter(filter).Encode(), nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// encodeTrackFilter returns filter as query parameters for the track index.
+func encodeTrackFilter(filter peapod.TrackFilter) url.Values {
+	q := make(url.Values)
+	if filter.PlaylistID != 0 {
+		q.Set("playlist_id", strconv.Itoa(filter.PlaylistID))
+	}
+	if filter.OwnerID != 0 {
+		q.Set("owner_id", strconv.Itoa(filter.OwnerID))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		q.Set("after", filter.CreatedAfter.Format(time.RFC3339))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		q.Set("before", filter.CreatedBefore.Format(time.RFC3339))
+	}
+	if filter.ContentType != "" {
+		q.Set("content_type", filter.ContentType)
+	}
+	if filter.SourceDomain != "" {
+		q.Set("domain", filter.SourceDomain)
+	}
+	if filter.Tag != "" {
+		q.Set("tag", filter.Tag)
+	}
+	if filter.Cursor != "" {
+		q.Set("cursor", filter.Cursor)
+	}
+	if filter.Limit != 0 {
+		q.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.Order != "" {
+		q.Set("order", filter.Order)
+	}
+	return q
+}
@@ -0,0 +1,106 @@
+package client_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/client"
+)
+
+// Ensure a track can be found by id & that missing tracks return nil.
+func TestTrackService_FindTrackByID(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	s.TrackService.FindTrackByIDFn = func(ctx context.Context, id int) (*peapod.Track, error) {
+		if id != 2 {
+			return nil, nil
+		}
+		return &peapod.Track{ID: 2, Title: "TITLE", Duration: time.Minute}, nil
+	}
+
+	ts := client.NewTrackService(s.Client())
+	if track, err := ts.FindTrackByID(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	} else if track.Title != "TITLE" || track.Duration != time.Minute {
+		t.Fatalf("unexpected track: %#v", track)
+	}
+
+	if track, err := ts.FindTrackByID(context.Background(), 100); err != nil {
+		t.Fatal(err)
+	} else if track != nil {
+		t.Fatalf("expected nil track: %#v", track)
+	}
+}
+
+// Ensure the track filter is passed to the server.
+func TestTrackService_FindTracks(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	filter := peapod.TrackFilter{
+		PlaylistID:   2,
+		CreatedAfter: time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC),
+		SourceDomain: "example.com",
+		Tag:          "news",
+		Cursor:       "CURSOR",
+		Limit:        5,
+		Order:        peapod.TrackOrderOldest,
+	}
+	s.TrackService.FindTracksFn = func(ctx context.Context, f peapod.TrackFilter) (*peapod.TrackPage, error) {
+		if !reflect.DeepEqual(f, filter) {
+			t.Fatalf("unexpected filter: %#v", f)
+		}
+		return &peapod.TrackPage{Tracks: []*peapod.Track{{ID: 3}}, NextCursor: "NEXT"}, nil
+	}
+
+	if page, err := client.NewTrackService(s.Client()).FindTracks(context.Background(), filter); err != nil {
+		t.Fatal(err)
+	} else if len(page.Tracks) != 1 || page.Tracks[0].ID != 3 || page.NextCursor != "NEXT" {
+		t.Fatalf("unexpected page: %#v", page)
+	}
+}
+
+// Ensure tracks can be deleted by the user & that other reasons are rejected.
+func TestTrackService_DeleteTrack(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	s.TrackService.DeleteTrackFn = func(ctx context.Context, id int, reason string) error {
+		if id != 2 || reason != peapod.TrackRemovalReasonUser {
+			t.Fatalf("unexpected args: id=%d reason=%s", id, reason)
+		}
+		return nil
+	}
+
+	ts := client.NewTrackService(s.Client())
+	if err := ts.DeleteTrack(context.Background(), 2, peapod.TrackRemovalReasonUser); err != nil {
+		t.Fatal(err)
+	} else if err := ts.DeleteTrack(context.Background(), 2, peapod.TrackRemovalReasonRetention); err != client.ErrNotSupported {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure tracks can be pinned & unpinned.
+func TestTrackService_SetTrackPinned(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	var pinned []bool
+	s.TrackService.SetTrackPinnedFn = func(ctx context.Context, id int, v bool) error {
+		pinned = append(pinned, v)
+		return nil
+	}
+
+	ts := client.NewTrackService(s.Client())
+	if err := ts.SetTrackPinned(context.Background(), 2, true); err != nil {
+		t.Fatal(err)
+	} else if err := ts.SetTrackPinned(context.Background(), 2, false); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(pinned, []bool{true, false}) {
+		t.Fatalf("unexpected calls: %v", pinned)
+	}
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/middlemost/peapod"
+	peapodhttp "github.com/middlemost/peapod/http"
+)
+
+// Ensure service implements interface.
+var _ peapod.UserService = &UserService{}
+
+// UserService represents a service for managing users on a remote server.
+type UserService struct {
+	client *Client
+}
+
+// NewUserService returns a new instance of UserService.
+func NewUserService(client *Client) *UserService {
+	return &UserService{client: client}
+}
+
+// FindUserByID returns a user by id. Returns nil if the user does not exist.
+// The admin or worker API is used if the client has the matching token.
+func (s *UserService) FindUserByID(ctx context.Context, id int) (*peapod.User, error) {
+	path := fmt.Sprintf("/users/%d", id)
+	if s.client.AdminToken != "" {
+		path = "/admin" + path
+	} else if s.client.WorkerToken != "" {
+		path = "/worker" + path
+	}
+
+	var user peapod.User
+	if err := s.client.do(ctx, "GET", path, nil, &user); err == peapod.ErrUserNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindUserByMobileNumber returns a user by mobile number. Returns nil if
+// the user does not exist.
+func (s *UserService) FindUserByMobileNumber(ctx context.Context, mobileNumber string) (*peapod.User, error) {
+	var user peapod.User
+	q := url.Values{"mobile_number": {mobileNumber}}
+	if err := s.client.do(ctx, "GET", "/users/?"+q.Encode(), nil, &user); err == peapod.ErrUserNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateUser is not supported. Users are created when they first log in.
+func (s *UserService) CreateUser(ctx context.Context, user *peapod.User) error {
+	return ErrNotSupported
+}
+
+// UpdateUser updates the profile & settings of the current user.
+func (s *UserService) UpdateUser(ctx context.Context, id int, upd peapod.UserUpdate) (*peapod.User, error) {
+	var user peapod.User
+	if err := s.client.do(ctx, "PATCH", fmt.Sprintf("/users/%d", id), upd, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RequestUserDeletion starts an account deletion. The server sends the
+// confirmation code to the user by SMS so no code is returned.
+func (s *UserService) RequestUserDeletion(ctx context.Context, id int) (string, error) {
+	return "", s.client.do(ctx, "POST", fmt.Sprintf("/users/%d/deletion", id), nil, nil)
+}
+
+// ConfirmUserDeletion deletes the current user & all their data using the
+// code sent by SMS. The server removes the user's files so no tracks are
+// returned.
+func (s *UserService) ConfirmUserDeletion(ctx context.Context, id int, code string) ([]*peapod.Track, error) {
+	return nil, s.client.do(ctx, "DELETE", fmt.Sprintf("/users/%d", id), &peapodhttp.DeleteUserRequest{Code: code}, nil)
+}
+
+// UndoLastAction reverts the current user's last destructive action.
+func (s *UserService) UndoLastAction(ctx context.Context, id int) (*peapod.UndoAction, error) {
+	var action peapod.UndoAction
+	if err := s.client.do(ctx, "POST", fmt.Sprintf("/users/%d/undo", id), nil, &action); err != nil {
+		return nil, err
+	}
+	return &action, nil
+}
+
+// FindUsers returns users matching filter. Requires admin access.
+func (s *UserService) FindUsers(ctx context.Context, filter peapod.UserFilter) ([]*peapod.User, error) {
+	q := url.Values{"q": {filter.Query}, "offset": {strconv.Itoa(filter.Offset)}, "limit": {strconv.Itoa(filter.Limit)}}
+	var users []*peapod.User
+	if err := s.client.do(ctx, "GET", "/admin/users?"+q.Encode(), nil, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// UpdateUserAccess grants or revokes admin access & disables or enables a
+// user. Requires admin access.
+func (s *UserService) UpdateUserAccess(ctx context.Context, id int, upd peapod.UserAccessUpdate) (*peapod.User, error) {
+	var user peapod.User
+	if err := s.client.do(ctx, "PATCH", fmt.Sprintf("/admin/users/%d", id), upd, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,104 @@
+package client_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/client"
+)
+
+// Ensure a user can be found by id through the user & admin APIs.
+func TestUserService_FindUserByID(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	s.UserService.FindUserByIDFn = func(ctx context.Context, id int) (*peapod.User, error) {
+		if id != 1 {
+			return nil, nil
+		}
+		return &peapod.User{ID: 1, Name: "NAME", Admin: peapod.IsSystemContext(ctx)}, nil
+	}
+
+	if user, err := client.NewUserService(s.Client()).FindUserByID(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	} else if user.Name != "NAME" || user.Admin {
+		t.Fatalf("unexpected user: %#v", user)
+	}
+
+	// Admin lookups are performed with a system context.
+	if user, err := client.NewUserService(s.AdminClient()).FindUserByID(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	} else if !user.Admin {
+		t.Fatalf("expected system context: %#v", user)
+	}
+
+	if user, err := client.NewUserService(s.Client()).FindUserByID(context.Background(), 100); err != nil {
+		t.Fatal(err)
+	} else if user != nil {
+		t.Fatalf("expected nil user: %#v", user)
+	}
+}
+
+// Ensure a user's settings can be updated.
+func TestUserService_UpdateUser(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	s.UserService.UpdateUserFn = func(ctx context.Context, id int, upd peapod.UserUpdate) (*peapod.User, error) {
+		if id != 1 || upd.Name == nil || upd.Timezone != nil {
+			t.Fatalf("unexpected args: id=%d upd=%#v", id, upd)
+		}
+		return &peapod.User{ID: 1, Name: *upd.Name}, nil
+	}
+
+	name := "NAME"
+	if user, err := client.NewUserService(s.Client()).UpdateUser(context.Background(), 1, peapod.UserUpdate{Name: &name}); err != nil {
+		t.Fatal(err)
+	} else if user.Name != "NAME" {
+		t.Fatalf("unexpected user: %#v", user)
+	}
+}
+
+// Ensure an account deletion is requested & then confirmed with the code
+// sent to the user by SMS.
+func TestUserService_ConfirmUserDeletion(t *testing.T) {
+	s := MustOpenServer()
+	defer s.Close()
+
+	s.UserService.RequestUserDeletionFn = func(ctx context.Context, id int) (string, error) {
+		if id != 1 {
+			t.Fatalf("unexpected id: %d", id)
+		}
+		return "123456", nil
+	}
+	s.SMSService.SendSMSFn = func(ctx context.Context, msg *peapod.SMS) error {
+		if msg.To != "+15555550100" || !strings.Contains(msg.Body, "123456") {
+			t.Fatalf("unexpected message: %#v", msg)
+		}
+		return nil
+	}
+	s.UserService.ConfirmUserDeletionFn = func(ctx context.Context, id int, code string) ([]*peapod.Track, error) {
+		if id != 1 {
+			t.Fatalf("unexpected id: %d", id)
+		} else if code != "123456" {
+			return nil, peapod.ErrInvalidDeletionCode
+		}
+		return nil, nil
+	}
+
+	userService := client.NewUserService(s.Client())
+	if code, err := userService.RequestUserDeletion(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	} else if code != "" {
+		t.Fatalf("unexpected code: %q", code)
+	}
+
+	// Deletion requires the code.
+	if _, err := userService.ConfirmUserDeletion(context.Background(), 1, "000000"); err != peapod.ErrInvalidDeletionCode {
+		t.Fatalf("unexpected error: %v", err)
+	} else if _, err := userService.ConfirmUserDeletion(context.Background(), 1, "123456"); err != nil {
+		t.Fatal(err)
+	}
+}
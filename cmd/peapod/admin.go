@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/url"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/bolt"
+	"github.com/middlemost/peapod/client"
+	"github.com/middlemost/peapod/local"
+)
+
+// AdminClient represents the operations available to administrative subcommands.
+// It is implemented directly against the database or through the admin API.
+type AdminClient interface {
+	FindUsers(ctx context.Context, filter peapod.UserFilter) ([]*peapod.User, error)
+	FindUserByID(ctx context.Context, id int) (*peapod.User, error)
+	UpdateUserAccess(ctx context.Context, id int, upd peapod.UserAccessUpdate) (*peapod.User, error)
+
+	FindJobs(ctx context.Context, filter peapod.JobFilter) ([]*peapod.Job, error)
+	RetryJob(ctx context.Context, id int) (*peapod.Job, error)
+	CancelJob(ctx context.Context, id int) (*peapod.Job, error)
+
+	FindPlaylistByID(ctx context.Context, id int) (*peapod.Playlist, error)
+
+	// Permanently deletes a track & its files.
+	PurgeTrack(ctx context.Context, id int) error
+
+	Close() error
+}
+
+// boltAdminClient implements AdminClient using the bolt database directly.
+// All operations are performed with a system context.
+type boltAdminClient struct {
+	db *bolt.DB
+
+	fileService     *local.FileService
+	jobService      *bolt.JobService
+	playlistService *bolt.PlaylistService
+	trackService    *bolt.TrackService
+	userService     *bolt.UserService
+}
+
+// newBoltAdminClient returns a client for an opened database.
+func newBoltAdminClient(db *bolt.DB, fileService *local.FileService) *boltAdminClient {
+	return &boltAdminClient{
+		db:              db,
+		fileService:     fileService,
+		jobService:      bolt.NewJobService(db),
+		playlistService: bolt.NewPlaylistService(db),
+		trackService:    bolt.NewTrackService(db),
+		userService:     bolt.NewUserService(db),
+	}
+}
+
+// Close closes the underlying database.
+func (c *boltAdminClient) Close() error { return c.db.Close() }
+
+func (c *boltAdminClient) FindUsers(ctx context.Context, filter peapod.UserFilter) ([]*peapod.User, error) {
+	return c.userService.FindUsers(peapod.NewSystemContext(ctx), filter)
+}
+
+func (c *boltAdminClient) FindUserByID(ctx context.Context, id int) (*peapod.User, error) {
+	return c.userService.FindUserByID(peapod.NewSystemContext(ctx), id)
+}
+
+func (c *boltAdminClient) UpdateUserAccess(ctx context.Context, id int, upd peapod.UserAccessUpdate) (*peapod.User, error) {
+	return c.userService.UpdateUserAccess(peapod.NewSystemContext(ctx), id, upd)
+}
+
+func (c *boltAdminClient) FindJobs(ctx context.Context, filter peapod.JobFilter) ([]*peapod.Job, error) {
+	return c.jobService.FindJobs(peapod.NewSystemContext(ctx), filter)
+}
+
+func (c *boltAdminClient) RetryJob(ctx context.Context, id int) (*peapod.Job, error) {
+	return c.jobService.RetryJob(peapod.NewSystemContext(ctx), id)
+}
+
+func (c *boltAdminClient) CancelJob(ctx context.Context, id int) (*peapod.Job, error) {
+	return c.jobService.CancelJob(peapod.NewSystemContext(ctx), id)
+}
+
+func (c *boltAdminClient) FindPlaylistByID(ctx context.Context, id int) (*peapod.Playlist, error) {
+	return c.playlistService.FindPlaylistByID(peapod.NewSystemContext(ctx), id)
+}
+
+func (c *boltAdminClient) PurgeTrack(ctx context.Context, id int) error {
+	ctx = peapod.NewSystemContext(ctx)
+	track, err := c.trackService.PurgeTrack(ctx, id)
+	if err != nil {
+		return err
+	}
+	return peapod.DeleteTrackFiles(ctx, c.fileService, []*peapod.Track{track})
+}
+
+// httpAdminClient implements AdminClient using the admin API of a running daemon.
+type httpAdminClient struct {
+	jobService      *client.JobService
+	playlistService *client.PlaylistService
+	trackService    *client.TrackService
+	userService     *client.UserService
+}
+
+// newHTTPAdminClient returns a client for the daemon described by config.
+func newHTTPAdminClient(config Config) (*httpAdminClient, error) {
+	if config.HTTP.AdminToken == "" {
+		return nil, ErrAdminTokenRequired
+	}
+
+	c := client.NewClient()
+	c.AdminToken = config.HTTP.AdminToken
+	if config.HTTP.Autocert {
+		c.URL = url.URL{Scheme: "https", Host: config.HTTP.Host}
+	} else {
+		host, port, err := net.SplitHostPort(config.HTTP.Addr)
+		if err != nil {
+			return nil, err
+		} else if host == "" {
+			host = "localhost"
+		}
+		c.URL = url.URL{Scheme: "http", Host: net.JoinHostPort(host, port)}
+	}
+
+	return &httpAdminClient{
+		jobService:      client.NewJobService(c),
+		playlistService: client.NewPlaylistService(c),
+		trackService:    client.NewTrackService(c),
+		userService:     client.NewUserService(c),
+	}, nil
+}
+
+// Close is a no-op.
+func (c *httpAdminClient) Close() error { return nil }
+
+func (c *httpAdminClient) FindUsers(ctx context.Context, filter peapod.UserFilter) ([]*peapod.User, error) {
+	return c.userService.FindUsers(ctx, filter)
+}
+
+func (c *httpAdminClient) FindUserByID(ctx context.Context, id int) (*peapod.User, error) {
+	return c.userService.FindUserByID(ctx, id)
+}
+
+func (c *httpAdminClient) UpdateUserAccess(ctx context.Context, id int, upd peapod.UserAccessUpdate) (*peapod.User, error) {
+	return c.userService.UpdateUserAccess(ctx, id, upd)
+}
+
+func (c *httpAdminClient) FindJobs(ctx context.Context, filter peapod.JobFilter) ([]*peapod.Job, error) {
+	return c.jobService.FindJobs(ctx, filter)
+}
+
+func (c *httpAdminClient) RetryJob(ctx context.Context, id int) (*peapod.Job, error) {
+	return c.jobService.RetryJob(ctx, id)
+}
+
+func (c *httpAdminClient) CancelJob(ctx context.Context, id int) (*peapod.Job, error) {
+	return c.jobService.CancelJob(ctx, id)
+}
+
+func (c *httpAdminClient) FindPlaylistByID(ctx context.Context, id int) (*peapod.Playlist, error) {
+	return c.playlistService.FindPlaylistByID(ctx, id)
+}
+
+// PurgeTrack deletes the track. The daemon removes the track's files.
+func (c *httpAdminClient) PurgeTrack(ctx context.Context, id int) error {
+	_, err := c.trackService.PurgeTrack(ctx, id)
+	return err
+}
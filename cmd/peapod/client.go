@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/client"
+)
+
+// Client command errors.
+const (
+	ErrNotLoggedIn       = peapod.Error("not logged in; run peapod login")
+	ErrServerURLRequired = peapod.Error("server url required")
+)
+
+// DefaultClientConfigPath is the default client configuration path.
+const DefaultClientConfigPath = "~/.peapod/client"
+
+// ClientConfig represents the configuration used by end-user commands to
+// connect to a remote server. It is written by the login command.
+type ClientConfig struct {
+	URL   string `toml:"url"`
+	Token string `toml:"token"` // session token
+}
+
+// clientConfigPath returns the interpolated client configuration path.
+func (m *Main) clientConfigPath() (string, error) {
+	path := m.ClientConfigPath
+	if path == "" {
+		path = DefaultClientConfigPath
+	}
+	if err := InterpolatePaths(&path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// loadClientConfig reads the client configuration. A missing file returns
+// an empty configuration.
+func (m *Main) loadClientConfig() (ClientConfig, error) {
+	var config ClientConfig
+	path, err := m.clientConfigPath()
+	if err != nil {
+		return config, err
+	}
+	if _, err := toml.DecodeFile(path, &config); err != nil && !os.IsNotExist(err) {
+		return config, err
+	}
+	return config, nil
+}
+
+// saveClientConfig writes the client configuration. The file is only
+// readable by the current user as it contains the session token.
+func (m *Main) saveClientConfig(config ClientConfig) error {
+	path, err := m.clientConfigPath()
+	if err != nil {
+		return err
+	} else if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := toml.NewEncoder(f).Encode(config); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// openClient returns a client for the server in the client configuration.
+func (m *Main) openClient() (*client.Client, error) {
+	config, err := m.loadClientConfig()
+	if err != nil {
+		return nil, err
+	} else if config.URL == "" || config.Token == "" {
+		return nil, ErrNotLoggedIn
+	}
+	return newClient(config.URL, config.Token)
+}
+
+// newClient returns a client for the server at rawurl.
+func newClient(rawurl, token string) (*client.Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(rawurl, "/"))
+	if err != nil {
+		return nil, err
+	} else if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid server url: %s", rawurl)
+	}
+
+	c := client.NewClient()
+	c.URL, c.Token = *u, token
+	return c, nil
+}
+
+// runLogin sends a login code to a mobile number, prompts for the code and
+// saves the resulting session token to the client configuration.
+func (m *Main) runLogin(ctx context.Context, args []string) error {
+	config, err := m.loadClientConfig()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.StringVar(&config.URL, "url", config.URL, "server url")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if fs.NArg() == 0 {
+		return ErrArgRequired
+	} else if config.URL == "" {
+		return ErrServerURLRequired
+	}
+	mobileNumber := fs.Arg(0)
+
+	c, err := newClient(config.URL, "")
+	if err != nil {
+		return err
+	} else if err := c.SendLoginCode(ctx, mobileNumber); err != nil {
+		return err
+	}
+
+	// Prompt for the code sent by SMS.
+	fmt.Fprintf(m.Stdout, "Enter the code sent to %s: ", mobileNumber)
+	code, err := bufio.NewReader(m.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	session, err := c.Login(ctx, mobileNumber, strings.TrimSpace(code))
+	if err != nil {
+		return err
+	}
+
+	config.Token = session.Token
+	if err := m.saveClientConfig(config); err != nil {
+		return err
+	}
+	fmt.Fprintf(m.Stdout, "logged in: expires=%s\n", session.ExpiresAt.Format(timeFormat))
+	return nil
+}
+
+// runLogout ends the current session and removes its token from the
+// client configuration.
+func (m *Main) runLogout(ctx context.Context, args []string) error {
+	config, err := m.loadClientConfig()
+	if err != nil {
+		return err
+	} else if config.Token == "" {
+		return ErrNotLoggedIn
+	}
+
+	c, err := newClient(config.URL, config.Token)
+	if err != nil {
+		return err
+	} else if err := c.Logout(ctx); err != nil && err != peapod.ErrSessionNotFound && err != peapod.ErrUnauthorized {
+		return err
+	}
+
+	config.Token = ""
+	return m.saveClientConfig(config)
+}
+
+// runAdd adds URLs to the current user's playlist.
+func (m *Main) runAdd(ctx context.Context, args []string) error {
+	var playlistID int
+	var tags string
+	fs, err := parseCommandFlags("add", args, false, func(fs *flag.FlagSet) {
+		fs.IntVar(&playlistID, "playlist", 0, "playlist id")
+		fs.StringVar(&tags, "tags", "", "comma-separated tags")
+	})
+	if err != nil {
+		return err
+	} else if fs.NArg() == 0 {
+		return ErrArgRequired
+	}
+
+	c, err := m.openClient()
+	if err != nil {
+		return err
+	}
+
+	jobService := client.NewJobService(c)
+	jobs := make([]*peapod.Job, 0, fs.NArg())
+	for _, rawurl := range fs.Args() {
+		job := &peapod.Job{
+			Type:       peapod.JobTypeCreateTrackFromURL,
+			PlaylistID: playlistID,
+			URL:        rawurl,
+			Tags:       splitTags(tags),
+		}
+		if err := jobService.CreateJob(ctx, job); err != nil {
+			return fmt.Errorf("%s: %s", rawurl, err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if fs.JSON {
+		return writeJSON(m.Stdout, jobs)
+	}
+	for _, job := range jobs {
+		fmt.Fprintf(m.Stdout, "queued: job=%d url=%s\n", job.ID, job.URL)
+	}
+	return nil
+}
+
+// runSay converts text read from stdin to speech & adds it to the current
+// user's playlist.
+func (m *Main) runSay(ctx context.Context, args []string) error {
+	var title, tags string
+	var playlistID int
+	fs, err := parseCommandFlags("say", args, false, func(fs *flag.FlagSet) {
+		fs.IntVar(&playlistID, "playlist", 0, "playlist id")
+		fs.StringVar(&title, "title", "", "track title")
+		fs.StringVar(&tags, "tags", "", "comma-separated tags")
+	})
+	if err != nil {
+		return err
+	} else if title == "" {
+		return peapod.ErrTrackTitleRequired
+	}
+
+	text, err := ioutil.ReadAll(m.Stdin)
+	if err != nil {
+		return err
+	}
+
+	c, err := m.openClient()
+	if err != nil {
+		return err
+	}
+
+	job := &peapod.Job{
+		Type:       peapod.JobTypeCreateTrackFromTTS,
+		PlaylistID: playlistID,
+		Title:      title,
+		Text:       string(text),
+		Tags:       splitTags(tags),
+	}
+	if err := client.NewJobService(c).CreateJob(ctx, job); err != nil {
+		return err
+	} else if fs.JSON {
+		return writeJSON(m.Stdout, job)
+	}
+	fmt.Fprintf(m.Stdout, "queued: job=%d title=%q\n", job.ID, job.Title)
+	return nil
+}
+
+// runLs prints a page of the current user's tracks, newest first.
+func (m *Main) runLs(ctx context.Context, args []string) error {
+	var filter peapod.TrackFilter
+	fs, err := parseCommandFlags("ls", args, false, func(fs *flag.FlagSet) {
+		fs.IntVar(&filter.PlaylistID, "playlist", 0, "playlist id")
+		fs.StringVar(&filter.Tag, "tag", "", "tag")
+		fs.StringVar(&filter.Cursor, "cursor", "", "page cursor")
+		fs.IntVar(&filter.Limit, "limit", 0, "limit")
+	})
+	if err != nil {
+		return err
+	}
+
+	c, err := m.openClient()
+	if err != nil {
+		return err
+	}
+
+	page, err := client.NewTrackService(c).FindTracks(ctx, filter)
+	if err != nil {
+		return err
+	} else if fs.JSON {
+		return writeJSON(m.Stdout, page)
+	}
+
+	tw := tabwriter.NewWriter(m.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tPLAYLIST\tTITLE\tDURATION\tTAGS\tCREATED")
+	for _, t := range page.Tracks {
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t%s\n", t.ID, t.PlaylistID, t.Title, t.Duration.Round(time.Second), strings.Join(t.Tags, ","), t.CreatedAt.Format(timeFormat))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if page.NextCursor != "" {
+		fmt.Fprintf(m.Stdout, "\nmore tracks: peapod ls -cursor %s\n", page.NextCursor)
+	}
+	return nil
+}
+
+// runFeed prints the feed URL of each of the current user's playlists.
+func (m *Main) runFeed(ctx context.Context, args []string) error {
+	fs, err := parseCommandFlags("feed", args, false, nil)
+	if err != nil {
+		return err
+	}
+
+	c, err := m.openClient()
+	if err != nil {
+		return err
+	}
+
+	feeds, err := c.FindFeeds(ctx)
+	if err != nil {
+		return err
+	} else if fs.JSON {
+		return writeJSON(m.Stdout, feeds)
+	}
+
+	tw := tabwriter.NewWriter(m.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "PLAYLIST\tNAME\tURL")
+	for _, f := range feeds {
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", f.PlaylistID, f.Name, f.URL)
+	}
+	return tw.Flush()
+}
+
+// splitTags splits a comma-separated list of tags.
+func splitTags(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return peapod.NormalizeTags(strings.Split(s, ","))
+}
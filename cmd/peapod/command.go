@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/bolt"
+	"github.com/middlemost/peapod/local"
+)
+
+// Command errors.
+const (
+	ErrAdminTokenRequired = peapod.Error("database is locked; set http admin-token to use the running daemon")
+	ErrArgRequired        = peapod.Error("argument required")
+)
+
+// timeFormat is the format used for times in table output.
+const timeFormat = "2006-01-02 15:04"
+
+// commands maps subcommand names to their implementations.
+var commands = map[string]func(m *Main, ctx context.Context, args []string) error{
+	"login":          (*Main).runLogin,
+	"logout":         (*Main).runLogout,
+	"add":            (*Main).runAdd,
+	"say":            (*Main).runSay,
+	"ls":             (*Main).runLs,
+	"feed":           (*Main).runFeed,
+	"users list":     (*Main).runUsersList,
+	"users show":     (*Main).runUsersShow,
+	"users disable":  (*Main).runUsersDisable,
+	"users enable":   (*Main).runUsersEnable,
+	"jobs list":      (*Main).runJobsList,
+	"jobs retry":     (*Main).runJobsRetry,
+	"jobs cancel":    (*Main).runJobsCancel,
+	"tracks rm":      (*Main).runTracksRm,
+	"playlists show": (*Main).runPlaylistsShow,
+}
+
+// RunCommand executes the subcommand set by ParseFlags.
+func (m *Main) RunCommand(ctx context.Context) error {
+	fn, ok := commands[m.Command]
+	if !ok {
+		return fmt.Errorf("unknown command: %s", m.Command)
+	}
+	return fn(m, ctx, m.Args)
+}
+
+// openAdminClient opens the database directly. If the daemon holds the
+// database lock then its admin API is used instead.
+func (m *Main) openAdminClient() (AdminClient, error) {
+	dbPath := m.Config.Database.Path
+	filePath := m.Config.File.Path
+	if err := InterpolatePaths(&dbPath, &filePath); err != nil {
+		return nil, err
+	}
+
+	db := bolt.NewDB()
+	db.Path = dbPath
+	if err := db.Open(); err == bolt.ErrDatabaseLocked {
+		return newHTTPAdminClient(m.Config)
+	} else if err != nil {
+		return nil, err
+	}
+
+	fileService := local.NewFileService()
+	fileService.Path = filePath
+	return newBoltAdminClient(db, fileService), nil
+}
+
+// commandFlags represents the flags shared by subcommands.
+type commandFlags struct {
+	*flag.FlagSet
+
+	JSON   bool
+	Offset int
+	Limit  int
+}
+
+// parseCommandFlags parses the flags for a subcommand. Pagination flags are
+// only registered if paginate is true.
+func parseCommandFlags(name string, args []string, paginate bool, fn func(fs *flag.FlagSet)) (*commandFlags, error) {
+	fs := &commandFlags{FlagSet: flag.NewFlagSet(name, flag.ContinueOnError)}
+	fs.SetOutput(ioutil.Discard)
+	fs.BoolVar(&fs.JSON, "json", false, "json output")
+	if paginate {
+		fs.IntVar(&fs.Offset, "offset", 0, "offset")
+		fs.IntVar(&fs.Limit, "limit", 0, "limit")
+	}
+	if fn != nil {
+		fn(fs.FlagSet)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// runUsersList prints users, optionally matching the -q flag.
+func (m *Main) runUsersList(ctx context.Context, args []string) error {
+	var query string
+	fs, err := parseCommandFlags("users list", args, true, func(fs *flag.FlagSet) {
+		fs.StringVar(&query, "q", "", "search query")
+	})
+	if err != nil {
+		return err
+	}
+
+	client, err := m.openAdminClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	users, err := client.FindUsers(ctx, peapod.UserFilter{Query: query, Offset: fs.Offset, Limit: fs.Limit})
+	if err != nil {
+		return err
+	} else if fs.JSON {
+		return writeJSON(m.Stdout, users)
+	}
+	return writeUsers(m.Stdout, users)
+}
+
+// runUsersShow prints a single user by id or mobile number.
+func (m *Main) runUsersShow(ctx context.Context, args []string) error {
+	fs, err := parseCommandFlags("users show", args, false, nil)
+	if err != nil {
+		return err
+	} else if fs.NArg() != 1 {
+		return ErrArgRequired
+	}
+
+	client, err := m.openAdminClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	user, err := m.findUser(ctx, client, fs.Arg(0))
+	if err != nil {
+		return err
+	} else if fs.JSON {
+		return writeJSON(m.Stdout, user)
+	}
+	return writeUsers(m.Stdout, []*peapod.User{user})
+}
+
+// runUsersDisable disables a user & ends their sessions.
+func (m *Main) runUsersDisable(ctx context.Context, args []string) error {
+	return m.setUserDisabled(ctx, "users disable", args, true)
+}
+
+// runUsersEnable re-enables a disabled user.
+func (m *Main) runUsersEnable(ctx context.Context, args []string) error {
+	return m.setUserDisabled(ctx, "users enable", args, false)
+}
+
+func (m *Main) setUserDisabled(ctx context.Context, name string, args []string, disabled bool) error {
+	fs, err := parseCommandFlags(name, args, false, nil)
+	if err != nil {
+		return err
+	} else if fs.NArg() == 0 {
+		return ErrArgRequired
+	}
+
+	client, err := m.openAdminClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	user, err := m.findUser(ctx, client, fs.Arg(0))
+	if err != nil {
+		return err
+	} else if user, err = client.UpdateUserAccess(ctx, user.ID, peapod.UserAccessUpdate{Disabled: &disabled}); err != nil {
+		return err
+	} else if fs.JSON {
+		return writeJSON(m.Stdout, user)
+	}
+	return writeUsers(m.Stdout, []*peapod.User{user})
+}
+
+// findUser returns a user by id or mobile number. Numeric arguments are
+// tried as an id before being treated as a mobile number.
+func (m *Main) findUser(ctx context.Context, client AdminClient, s string) (*peapod.User, error) {
+	if id, err := strconv.Atoi(s); err == nil {
+		if user, err := client.FindUserByID(ctx, id); err != nil {
+			return nil, err
+		} else if user != nil {
+			return user, nil
+		}
+	}
+
+	mobileNumber, err := peapod.NormalizeMobileNumber(s, m.Config.Phone.Region)
+	if err != nil {
+		return nil, peapod.ErrUserNotFound
+	}
+	users, err := client.FindUsers(ctx, peapod.UserFilter{Query: mobileNumber})
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if user.MobileNumber == mobileNumber {
+			return user, nil
+		}
+	}
+	return nil, peapod.ErrUserNotFound
+}
+
+// runJobsList prints jobs, newest first, optionally matching the -status flag.
+func (m *Main) runJobsList(ctx context.Context, args []string) error {
+	var status string
+	fs, err := parseCommandFlags("jobs list", args, true, func(fs *flag.FlagSet) {
+		fs.StringVar(&status, "status", "", "job status")
+	})
+	if err != nil {
+		return err
+	}
+
+	client, err := m.openAdminClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	jobs, err := client.FindJobs(ctx, peapod.JobFilter{Status: status, Offset: fs.Offset, Limit: fs.Limit})
+	if err != nil {
+		return err
+	} else if fs.JSON {
+		return writeJSON(m.Stdout, jobs)
+	}
+	return writeJobs(m.Stdout, jobs)
+}
+
+// runJobsRetry moves failed jobs back onto the job queue.
+func (m *Main) runJobsRetry(ctx context.Context, args []string) error {
+	return m.updateJobs(ctx, "jobs retry", args, AdminClient.RetryJob)
+}
+
+// runJobsCancel removes pending jobs from the job queue.
+func (m *Main) runJobsCancel(ctx context.Context, args []string) error {
+	return m.updateJobs(ctx, "jobs cancel", args, AdminClient.CancelJob)
+}
+
+// updateJobs applies fn to each job id in args and prints the updated jobs.
+func (m *Main) updateJobs(ctx context.Context, name string, args []string, fn func(AdminClient, context.Context, int) (*peapod.Job, error)) error {
+	fs, err := parseCommandFlags(name, args, false, nil)
+	if err != nil {
+		return err
+	}
+	ids, err := parseIDs(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	client, err := m.openAdminClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	jobs := make([]*peapod.Job, 0, len(ids))
+	for _, id := range ids {
+		job, err := fn(client, ctx, id)
+		if err != nil {
+			return fmt.Errorf("job %d: %s", id, err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if fs.JSON {
+		return writeJSON(m.Stdout, jobs)
+	}
+	return writeJobs(m.Stdout, jobs)
+}
+
+// runTracksRm permanently deletes tracks & their files.
+func (m *Main) runTracksRm(ctx context.Context, args []string) error {
+	fs, err := parseCommandFlags("tracks rm", args, false, nil)
+	if err != nil {
+		return err
+	}
+	ids, err := parseIDs(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	client, err := m.openAdminClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	for _, id := range ids {
+		if err := client.PurgeTrack(ctx, id); err != nil {
+			return fmt.Errorf("track %d: %s", id, err)
+		}
+		if !fs.JSON {
+			fmt.Fprintf(m.Stdout, "track removed: id=%d\n", id)
+		}
+	}
+
+	if fs.JSON {
+		return writeJSON(m.Stdout, map[string][]int{"removed": ids})
+	}
+	return nil
+}
+
+// runPlaylistsShow prints a playlist & its tracks.
+func (m *Main) runPlaylistsShow(ctx context.Context, args []string) error {
+	fs, err := parseCommandFlags("playlists show", args, false, nil)
+	if err != nil {
+		return err
+	} else if fs.NArg() != 1 {
+		return ErrArgRequired
+	}
+	ids, err := parseIDs(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	client, err := m.openAdminClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	playlist, err := client.FindPlaylistByID(ctx, ids[0])
+	if err != nil {
+		return err
+	} else if playlist == nil {
+		return peapod.ErrPlaylistNotFound
+	} else if fs.JSON {
+		return writeJSON(m.Stdout, playlist)
+	}
+
+	tw := tabwriter.NewWriter(m.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tTYPE\tOWNER\tTRACKS\tUPDATED")
+	fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t%s\n", playlist.ID, playlist.Name, playlist.Type, playlist.OwnerID, len(playlist.Tracks), playlist.UpdatedAt.Format(timeFormat))
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "TRACK\tTITLE\tSIZE\tTAGS\tCREATED")
+	for _, t := range playlist.Tracks {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\n", t.ID, t.Title, t.Size, strings.Join(t.Tags, ","), t.CreatedAt.Format(timeFormat))
+	}
+	return tw.Flush()
+}
+
+// parseIDs parses one or more numeric ids.
+func parseIDs(args []string) ([]int, error) {
+	if len(args) == 0 {
+		return nil, ErrArgRequired
+	}
+
+	ids := make([]int, len(args))
+	for i, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id: %s", arg)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// writeJSON writes v as indented JSON.
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeUsers writes users as a table.
+func writeUsers(w io.Writer, users []*peapod.User) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tMOBILE NUMBER\tNAME\tADMIN\tDISABLED\tCREATED")
+	for _, u := range users {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%t\t%t\t%s\n", u.ID, u.MobileNumber, u.Name, u.Admin, u.Disabled, u.CreatedAt.Format(timeFormat))
+	}
+	return tw.Flush()
+}
+
+// writeJobs writes jobs as a table.
+func writeJobs(w io.Writer, jobs []*peapod.Job) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tOWNER\tTYPE\tSTATUS\tPLAYLIST\tCREATED\tCORRELATION\tERROR")
+	for _, j := range jobs {
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%d\t%s\t%s\t%s\n", j.ID, j.OwnerID, j.Type, j.Status, j.PlaylistID, j.CreatedAt.Format(timeFormat), j.CorrelationID, j.Error)
+	}
+	return tw.Flush()
+}
@@ -7,11 +7,14 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log/slog"
 	"os"
 	"os/signal"
 	"os/user"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/middlemost/peapod"
@@ -28,6 +31,7 @@ func main() {
 
 	// Parse command line flags.
 	if err := m.ParseFlags(os.Args[1:]); err == flag.ErrHelp {
+		fmt.Fprintln(m.Stderr, m.Usage())
 		os.Exit(1)
 	} else if err != nil {
 		fmt.Fprintln(m.Stderr, err)
@@ -46,24 +50,49 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Shutdown on SIGINT (CTRL-C).
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	<-c
-	fmt.Fprintln(m.Stdout, "received interrupt, shutting down...")
+	// Subcommands exit once they complete.
+	if m.Command != "" {
+		return
+	}
+
+	// Shutdown on SIGINT (CTRL-C) or SIGTERM.
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	if err := m.Wait(c); err != nil {
+		fmt.Fprintln(m.Stderr, err)
+		os.Exit(1)
+	}
 }
 
+// ErrShutdownForced is returned by Wait if a second signal is received
+// before shutdown completes.
+const ErrShutdownForced = peapod.Error("shutdown forced")
+
 // Main represents the main program execution.
 type Main struct {
 	ConfigPath string
 	Config     Config
 
+	// Path to the configuration used by end-user commands.
+	ClientConfigPath string
+
+	// Subcommand (e.g. "add" or "users list") & its arguments.
+	// The daemon is run if no subcommand is specified.
+	Command string
+	Args    []string
+
+	// If true, runs a remote job worker instead of the daemon.
+	Worker bool
+
 	// Input/output streams
 	Stdin  io.Reader
 	Stdout io.Writer
 	Stderr io.Writer
 
-	closeFn func() error
+	// Logger used by the daemon & worker. Initialized by Run.
+	logger *slog.Logger
+
+	shutdownFn func(ctx context.Context) error
 }
 
 // NewMain returns a new instance of Main.
@@ -72,23 +101,71 @@ func NewMain() *Main {
 		ConfigPath: DefaultConfigPath,
 		Config:     DefaultConfig(),
 
+		ClientConfigPath: DefaultClientConfigPath,
+
 		Stdin:  os.Stdin,
 		Stdout: os.Stdout,
 		Stderr: os.Stderr,
 
-		closeFn: func() error { return nil },
+		logger: peapod.DiscardLogger(),
+
+		shutdownFn: func(ctx context.Context) error { return nil },
 	}
 }
 
-// Close cleans up the program.
-func (m *Main) Close() error { return m.closeFn() }
+// Close stops the program immediately. Running jobs are interrupted.
+func (m *Main) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return m.Shutdown(ctx)
+}
+
+// Shutdown stops the program once active requests & running jobs have
+// completed. If ctx is done first then they are interrupted.
+func (m *Main) Shutdown(ctx context.Context) error { return m.shutdownFn(ctx) }
+
+// Wait blocks until a signal is received on c and then shuts down. Requests
+// & jobs are interrupted once the shutdown timeout elapses. If a second
+// signal is received then ErrShutdownForced is returned immediately.
+func (m *Main) Wait(c <-chan os.Signal) error {
+	sig := <-c
+	m.logger.Info("shutting down", "signal", sig.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.Config.Shutdown.Timeout))
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- m.Shutdown(ctx) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("shutdown: %s", err)
+		}
+		m.logger.Info("shutdown complete")
+		return nil
+	case <-c:
+		return ErrShutdownForced
+	}
+}
 
 // Usage returns the usage message.
 func (m *Main) Usage() string {
 	return strings.TrimSpace(`
-usage: peapod [flags]
+usage: peapod [flags] [worker | command] [args]
 
 The daemon process for managing peapod API requests and processing.
+If a command is specified then it is run instead of the daemon.
+
+The "worker" argument runs a process which executes jobs for a remote
+daemon. It requires the worker url & token settings. The daemon's http
+worker-token must match and its worker remote-only setting disables
+job execution by the daemon itself. Workers use their own aws, twilio
+& youtube-dl settings.
+
+On SIGINT or SIGTERM, new requests & jobs are refused and running ones
+are given the shutdown timeout to complete. Interrupted jobs are retried
+after a restart. A second signal exits immediately.
 
 The following flags are available:
 
@@ -96,15 +173,78 @@ The following flags are available:
 		Specifies the configuration file to read.
 		Defaults to ~/.peapod/config
 
+	-client-config PATH
+		Specifies the client configuration file used by end-user commands.
+		Defaults to ~/.peapod/client
+
+The following end-user commands use a remote server:
+
+	login [-url URL] MOBILE       Log in with a code sent by SMS
+	logout                        End the current session
+	add [-tags TAGS] URL...       Add URLs to your playlist
+	say -title TITLE [-tags TAGS] Add text from stdin as speech
+	ls [-tag TAG] [-cursor C]     List your tracks, newest first
+	feed                          Show your playlist feed URLs
+
+Add, say & ls accept -playlist ID to use a playlist other than your own.
+
+The following administrative commands are available:
+
+	users list [-q QUERY]         List & search users
+	users show ID|MOBILE          Show a user
+	users disable ID|MOBILE       Disable a user & end their sessions
+	users enable ID|MOBILE        Re-enable a disabled user
+	jobs list [-status STATUS]    List jobs, newest first
+	jobs retry ID...              Re-queue failed jobs
+	jobs cancel ID...             Remove pending jobs from the queue
+	tracks rm ID...               Permanently delete tracks & their files
+	playlists show ID             Show a playlist & its tracks
+
+Commands accept -json for JSON output. List commands accept -offset & -limit.
+Commands use the database directly. If the daemon is running then its
+admin API is used instead, which requires the http admin-token setting.
+
 `)
 }
 
-// ParseFlags parses the command line flags.
+// ParseFlags parses the command line flags and the subcommand, if any.
+// Subcommand flags are parsed when the subcommand is run.
 func (m *Main) ParseFlags(args []string) error {
 	fs := flag.NewFlagSet("peapod", flag.ContinueOnError)
 	fs.SetOutput(ioutil.Discard)
 	fs.StringVar(&m.ConfigPath, "config", "", "config file")
-	return fs.Parse(args)
+	fs.StringVar(&m.ClientConfigPath, "client-config", DefaultClientConfigPath, "client config file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Run the daemon if there is no subcommand.
+	args = fs.Args()
+	if len(args) == 0 {
+		return nil
+	} else if args[0] == "worker" {
+		if len(args) > 1 {
+			return fmt.Errorf("unexpected argument: %s", args[1])
+		}
+		m.Worker = true
+		return nil
+	}
+
+	// End-user commands are a single word, e.g. "add".
+	if _, ok := commands[args[0]]; ok {
+		m.Command, m.Args = args[0], args[1:]
+		return nil
+	} else if len(args) == 1 {
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+
+	// Administrative commands are named by their group & action, e.g. "users list".
+	name := args[0] + " " + args[1]
+	if _, ok := commands[name]; !ok {
+		return fmt.Errorf("unknown command: %s", name)
+	}
+	m.Command, m.Args = name, args[2:]
+	return nil
 }
 
 // LoadConfig parses the configuration file.
@@ -128,11 +268,31 @@ func (m *Main) LoadConfig() error {
 	} else if err != nil {
 		return err
 	}
+
+	// Validate settings which would otherwise fail after startup.
+	if m.Config.Retention.SweepInterval <= 0 {
+		return peapod.ErrInvalidSweepInterval
+	}
 	return nil
 }
 
-// Run executes the program.
+// Run executes the subcommand, if set, or starts the daemon.
 func (m *Main) Run() error {
+	if m.Command != "" {
+		return m.RunCommand(context.Background())
+	}
+
+	// Initialize the logger used by the daemon & worker.
+	logger, err := m.newLogger()
+	if err != nil {
+		return err
+	}
+	m.logger = logger
+
+	if m.Worker {
+		return m.runWorker()
+	}
+
 	// Interpolate config paths.
 	dbPath := m.Config.Database.Path
 	filePath := m.Config.File.Path
@@ -143,34 +303,16 @@ func (m *Main) Run() error {
 	// Initialize file service.
 	fileService := local.NewFileService()
 	fileService.Path = filePath
-	fmt.Fprintf(m.Stdout, "file storage: path=%s\n", m.Config.File.Path)
+	fileService.MinFreeSpace = m.Config.File.MinFreeSpace
+	m.logger.Info("file storage initialized", "path", m.Config.File.Path)
 
-	// Initialize AWS session.
-	var awsSession *aws.Session
-	if m.Config.AWS.AccessKeyID != "" && m.Config.AWS.SecretAccessKey != "" {
-		session, err := aws.NewSession(m.Config.AWS.AccessKeyID, m.Config.AWS.SecretAccessKey, m.Config.AWS.Region)
-		if err != nil {
-			return err
-		}
-		awsSession = session
+	// Initialize external services.
+	ttsService, err := m.newTTSService()
+	if err != nil {
+		return err
 	}
-
-	// Initialize TTS service.
-	ttsService := aws.NewTTSService()
-	ttsService.Session = awsSession
-	ttsService.LogOutput = m.Stdout
-
-	// Initialize Twilio service.
-	smsService := twilio.NewSMSService()
-	smsService.AccountSID = m.Config.Twilio.AccountSID
-	smsService.AuthToken = m.Config.Twilio.AuthToken
-	smsService.From = m.Config.Twilio.From
-	smsService.LogOutput = m.Stdout
-
-	// Initialize youtube-dl.
-	urlTrackGenerator := youtube_dl.NewURLTrackGenerator()
-	urlTrackGenerator.Proxy = m.Config.YoutubeDL.Proxy
-	urlTrackGenerator.LogOutput = m.Stdout
+	smsService := m.newSMSService()
+	urlTrackGenerator := m.newURLTrackGenerator()
 
 	// Open database.
 	db := bolt.NewDB()
@@ -178,70 +320,193 @@ func (m *Main) Run() error {
 	if err := db.Open(); err != nil {
 		return err
 	}
-	fmt.Fprintf(m.Stdout, "database initialized: path=%s\n", m.Config.Database.Path)
+	m.logger.Info("database initialized", "path", m.Config.Database.Path)
 
 	// Instantiate bolt services.
 	jobService := bolt.NewJobService(db)
 	playlistService := bolt.NewPlaylistService(db)
+	playlistService.TokenGracePeriod = time.Duration(m.Config.Playlist.TokenGracePeriod)
+	sessionService := bolt.NewSessionService(db)
 	trackService := bolt.NewTrackService(db)
+	trackService.TrashPeriod = time.Duration(m.Config.Retention.TrashDays) * 24 * time.Hour
 	userService := bolt.NewUserService(db)
 
+	// Startup maintenance runs with a system context.
+	ctx := peapod.NewSystemContext(context.Background())
+
 	// Reset job queue.
-	if err := jobService.ResetJobQueue(context.Background()); err != nil {
+	if err := jobService.ResetJobQueue(ctx); err != nil {
 		return fmt.Errorf("error: reset job queue: %s", err)
 	}
 
-	// Start job scheduler.
+	// Normalize mobile numbers & merge duplicate accounts.
+	if updated, merged, err := userService.NormalizeMobileNumbers(ctx, m.Config.Phone.Region); err != nil {
+		return fmt.Errorf("error: normalize mobile numbers: %s", err)
+	} else if updated > 0 || merged > 0 {
+		m.logger.Info("mobile numbers normalized", "updated", updated, "merged", merged)
+	}
+
+	// Remove expired sessions & login codes.
+	if err := sessionService.RemoveExpiredSessions(ctx); err != nil {
+		return fmt.Errorf("error: remove expired sessions: %s", err)
+	}
+
+	// Remove rotated playlist tokens which have expired.
+	if err := playlistService.RemoveExpiredTokens(ctx); err != nil {
+		return fmt.Errorf("error: remove expired tokens: %s", err)
+	}
+
+	// Start job scheduler, unless jobs are only executed by remote workers.
 	jobScheduler := peapod.NewJobScheduler()
 	jobScheduler.FileService = fileService
 	jobScheduler.JobService = jobService
+	jobScheduler.PlaylistService = playlistService
 	jobScheduler.SMSService = smsService
 	jobScheduler.TrackService = trackService
 	jobScheduler.TTSService = ttsService
 	jobScheduler.UserService = userService
 	jobScheduler.URLTrackGenerator = urlTrackGenerator
-	jobScheduler.LogOutput = m.Stdout
+	jobScheduler.Logger = m.logger.With("component", "scheduler")
 
-	if err := jobScheduler.Open(); err != nil {
+	if m.Config.Worker.RemoteOnly {
+		m.logger.Info("job scheduler disabled, jobs are executed by remote workers")
+	} else if err := jobScheduler.Open(); err != nil {
 		return fmt.Errorf("error: open job scheduler: %s", err)
 	}
 
+	// Start retention sweeper.
+	retentionSweeper := peapod.NewRetentionSweeper()
+	retentionSweeper.Interval = time.Duration(m.Config.Retention.SweepInterval)
+	retentionSweeper.FileService = fileService
+	retentionSweeper.PlaylistService = playlistService
+	retentionSweeper.TrackService = trackService
+	retentionSweeper.UserService = userService
+	retentionSweeper.Logger = m.logger.With("component", "retention")
+
+	if err := retentionSweeper.Open(); err != nil {
+		return fmt.Errorf("error: open retention sweeper: %s", err)
+	}
+
+	// Generate a temporary signing secret if one is not configured.
+	// Signed links will not be valid across restarts.
+	if m.Config.HTTP.Secret == "" {
+		m.Config.HTTP.Secret = peapod.GenerateToken()
+		m.logger.Warn("http secret not configured, using temporary secret")
+	}
+
 	// Initialize HTTP server.
 	httpServer := http.NewServer()
 	httpServer.Addr = m.Config.HTTP.Addr
 	httpServer.Host = m.Config.HTTP.Host
 	httpServer.Autocert = m.Config.HTTP.Autocert
+	httpServer.Secret = m.Config.HTTP.Secret
+	httpServer.AdminToken = m.Config.HTTP.AdminToken
+	httpServer.WorkerToken = m.Config.HTTP.WorkerToken
+	httpServer.MetricsToken = m.Config.HTTP.MetricsToken
+	httpServer.Region = m.Config.Phone.Region
 	httpServer.Twilio.AccountSID = m.Config.Twilio.AccountSID
-	httpServer.LogOutput = m.Stdout
+	httpServer.Twilio.AuthToken = m.Config.Twilio.AuthToken
+	httpServer.Logger = m.logger.With("component", "http")
 
 	httpServer.FileService = fileService
 	httpServer.JobService = jobService
 	httpServer.PlaylistService = playlistService
+	httpServer.SessionService = sessionService
 	httpServer.SMSService = smsService
 	httpServer.TrackService = trackService
 	httpServer.UserService = userService
 
+	// Report readiness of the database & files, and of the job scheduler
+	// and its external commands if jobs are executed locally. Command
+	// versions are cached so that probes do not start a process each time.
+	httpServer.HealthCheckers = map[string]peapod.HealthChecker{
+		"database": db,
+		"files":    fileService,
+	}
+	if !m.Config.Worker.RemoteOnly {
+		httpServer.HealthCheckers["scheduler"] = jobScheduler
+		httpServer.HealthCheckers["youtube-dl"] = peapod.NewCachedHealthChecker(urlTrackGenerator)
+		httpServer.HealthCheckers["ffmpeg"] = peapod.NewCachedHealthChecker(ttsService)
+	}
+
 	// Open HTTP server.
 	if err := httpServer.Open(); err != nil {
 		return err
 	}
 	u := httpServer.URL()
-	fmt.Fprintf(m.Stdout, "http listening: %s\n", u.String())
-
-	// Assign close function.
-	m.closeFn = func() error {
-		httpServer.Close()
-		jobScheduler.Close()
-		db.Close()
-		return nil
+	m.logger.Info("http listening", "url", u.String())
+
+	// Assign shutdown function. Requests & jobs finish before the
+	// database is closed.
+	m.shutdownFn = func(ctx context.Context) error {
+		var err error
+		if e := httpServer.Shutdown(ctx); e != nil {
+			httpServer.Close()
+			err = e
+		}
+		retentionSweeper.Close()
+		if e := jobScheduler.Shutdown(ctx); e != nil && err == nil {
+			err = e
+		}
+		if e := db.Close(); e != nil && err == nil {
+			err = e
+		}
+		return err
 	}
 
 	return nil
 }
 
+// newLogger returns a logger which writes to stdout using the log settings.
+func (m *Main) newLogger() (*slog.Logger, error) {
+	level, err := peapod.ParseLogLevel(m.Config.Log.Level)
+	if err != nil {
+		return nil, err
+	}
+	return peapod.NewLogger(m.Stdout, m.Config.Log.Format, level)
+}
+
+// newTTSService returns a text-to-speech service from the aws settings.
+func (m *Main) newTTSService() (*aws.TTSService, error) {
+	var awsSession *aws.Session
+	if m.Config.AWS.AccessKeyID != "" && m.Config.AWS.SecretAccessKey != "" {
+		session, err := aws.NewSession(m.Config.AWS.AccessKeyID, m.Config.AWS.SecretAccessKey, m.Config.AWS.Region)
+		if err != nil {
+			return nil, err
+		}
+		awsSession = session
+	}
+
+	s := aws.NewTTSService()
+	s.Session = awsSession
+	s.Logger = m.logger.With("component", "tts")
+	return s, nil
+}
+
+// newSMSService returns an SMS service from the twilio settings.
+func (m *Main) newSMSService() *twilio.SMSService {
+	s := twilio.NewSMSService()
+	s.AccountSID = m.Config.Twilio.AccountSID
+	s.AuthToken = m.Config.Twilio.AuthToken
+	s.From = m.Config.Twilio.From
+	s.Logger = m.logger.With("component", "sms")
+	return s
+}
+
+// newURLTrackGenerator returns a youtube-dl track generator.
+func (m *Main) newURLTrackGenerator() *youtube_dl.URLTrackGenerator {
+	g := youtube_dl.NewURLTrackGenerator()
+	g.Proxy = m.Config.YoutubeDL.Proxy
+	g.Logger = m.logger.With("component", "youtube-dl")
+	return g
+}
+
 // DefaultConfigPath is the default configuration path.
 const DefaultConfigPath = "~/.peapod/config"
 
+// DefaultShutdownTimeout is the default time allowed for a graceful shutdown.
+const DefaultShutdownTimeout = 30 * time.Second
+
 // Config represents a configuration file.
 type Config struct {
 	Database struct {
@@ -250,14 +515,45 @@ type Config struct {
 
 	File struct {
 		Path string `toml:"path"`
+
+		// Bytes which must be free for the server to report it is ready.
+		MinFreeSpace int64 `toml:"min-free-space"`
 	} `toml:"file"`
 
 	HTTP struct {
 		Addr     string `toml:"addr"`
 		Host     string `toml:"host"`
 		Autocert bool   `toml:"autocert"`
+		Secret   string `toml:"secret"`
+
+		// Grants access to the admin API. Disabled if blank.
+		AdminToken string `toml:"admin-token"`
+
+		// Grants access to the worker API. Disabled if blank.
+		WorkerToken string `toml:"worker-token"`
+
+		// Bearer token required to read /metrics. Public if blank.
+		MetricsToken string `toml:"metrics-token"`
 	} `toml:"http"`
 
+	Log struct {
+		Level  string `toml:"level"`  // debug, info, warn or error
+		Format string `toml:"format"` // logfmt or json
+	} `toml:"log"`
+
+	Phone struct {
+		Region string `toml:"region"` // default region for numbers without a country code
+	} `toml:"phone"`
+
+	Playlist struct {
+		TokenGracePeriod Duration `toml:"token-grace-period"`
+	} `toml:"playlist"`
+
+	Retention struct {
+		SweepInterval Duration `toml:"sweep-interval"`
+		TrashDays     int      `toml:"trash-days"`
+	} `toml:"retention"`
+
 	AWS struct {
 		AccessKeyID     string `toml:"access-key-id"`
 		SecretAccessKey string `toml:"secret-access-key"`
@@ -270,9 +566,24 @@ type Config struct {
 		From       string `toml:"from"`
 	} `toml:"twilio"`
 
+	Shutdown struct {
+		// Time allowed for requests & jobs to complete before they are interrupted.
+		Timeout Duration `toml:"timeout"`
+	} `toml:"shutdown"`
+
 	YoutubeDL struct {
 		Proxy string `toml:"proxy"`
 	} `toml:"youtube-dl"`
+
+	Worker struct {
+		// Daemon URL & worker token used by the worker process.
+		URL          string   `toml:"url"`
+		Token        string   `toml:"token"`
+		PollInterval Duration `toml:"poll-interval"`
+
+		// If true, the daemon leaves all jobs to remote workers.
+		RemoteOnly bool `toml:"remote-only"`
+	} `toml:"worker"`
 }
 
 // NewConfig returns a configuration with default settings.
@@ -280,10 +591,32 @@ func DefaultConfig() Config {
 	var c Config
 	c.Database.Path = "~/.peapod/db"
 	c.File.Path = "~/.peapod/file"
+	c.File.MinFreeSpace = local.DefaultMinFreeSpace
 	c.HTTP.Addr = ":3000"
+	c.Log.Level = "info"
+	c.Log.Format = peapod.LogFormatLogfmt
+	c.Phone.Region = peapod.DefaultRegion
+	c.Playlist.TokenGracePeriod = Duration(bolt.DefaultTokenGracePeriod)
+	c.Retention.SweepInterval = Duration(peapod.DefaultRetentionSweepInterval)
+	c.Retention.TrashDays = int(bolt.DefaultTrashPeriod / (24 * time.Hour))
+	c.Shutdown.Timeout = Duration(DefaultShutdownTimeout)
+	c.Worker.PollInterval = Duration(peapod.DefaultJobPollInterval)
 	return c
 }
 
+// Duration is a helper type for unmarshaling durations from TOML.
+type Duration time.Duration
+
+// UnmarshalText parses a duration string such as "72h".
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
 // InterpolatePaths replaces the tilde prefix with the user's home directory.
 func InterpolatePaths(a ...*string) error {
 	for _, s := range a {
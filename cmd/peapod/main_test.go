@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/bolt"
+)
+
+// Ensure the daemon drains & closes its listener and database on a signal.
+func TestMain_Wait(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peapod-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	m := NewMain()
+	m.Stdout, m.Stderr = &buf, &buf
+	m.Config.Database.Path = filepath.Join(dir, "db")
+	m.Config.File.Path = filepath.Join(dir, "file")
+	m.Config.HTTP.Addr = "127.0.0.1:0"
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Extract the server URL from the startup output.
+	a := regexp.MustCompile(`msg="http listening" url=(\S+)`).FindStringSubmatch(buf.String())
+	if a == nil {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+	u := a[1]
+
+	if resp, err := http.Get(u + "/healthz"); err != nil {
+		t.Fatal(err)
+	} else {
+		resp.Body.Close()
+	}
+
+	// Send a fake interrupt.
+	c := make(chan os.Signal, 1)
+	c <- os.Interrupt
+	if err := m.Wait(c); err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify listener is closed.
+	if resp, err := http.Get(u + "/healthz"); err == nil {
+		resp.Body.Close()
+		t.Fatal("expected connection error")
+	}
+
+	// Verify database lock was released.
+	db := bolt.NewDB()
+	db.Path = m.Config.Database.Path
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+}
+
+// Ensure a second signal forces shutdown while the scheduler is still busy.
+func TestMain_Wait_Forced(t *testing.T) {
+	// Block the worker's claim request until the test completes.
+	release := make(chan struct{})
+	claimed := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case claimed <- struct{}{}:
+		default:
+		}
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("null\n"))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	var buf bytes.Buffer
+	m := NewMain()
+	m.Stdout, m.Stderr = &buf, &buf
+	m.Worker = true
+	m.Config.Worker.URL = srv.URL
+	m.Config.Worker.Token = "WORKER"
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-claimed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for claim")
+	}
+
+	// Send two fake interrupts.
+	c := make(chan os.Signal, 2)
+	c <- os.Interrupt
+	c <- os.Interrupt
+	if err := m.Wait(c); err != ErrShutdownForced {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a non-positive retention sweep interval is rejected on load.
+func TestMain_LoadConfig_InvalidSweepInterval(t *testing.T) {
+	f, err := ioutil.TempFile("", "peapod-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("[retention]\nsweep-interval = \"0s\"\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	m := NewMain()
+	m.ConfigPath = f.Name()
+	if err := m.LoadConfig(); err != peapod.ErrInvalidSweepInterval {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure subcommands are parsed by their single or two-word names.
+func TestMain_ParseFlags(t *testing.T) {
+	for _, tt := range []struct {
+		args    []string
+		command string
+		cmdArgs []string
+		worker  bool
+		err     string
+	}{
+		{args: []string{}},
+		{args: []string{"worker"}, worker: true},
+		{args: []string{"worker", "x"}, err: "unexpected argument: x"},
+		{args: []string{"add", "https://example.com"}, command: "add", cmdArgs: []string{"https://example.com"}},
+		{args: []string{"-config", "x.toml", "ls"}, command: "ls", cmdArgs: []string{}},
+		{args: []string{"users", "list", "-json"}, command: "users list", cmdArgs: []string{"-json"}},
+		{args: []string{"playlists", "show", "1"}, command: "playlists show", cmdArgs: []string{"1"}},
+		{args: []string{"nope"}, err: "unknown command: nope"},
+		{args: []string{"users"}, err: "unknown command: users"},
+		{args: []string{"users", "nope"}, err: "unknown command: users nope"},
+	} {
+		m := NewMain()
+		if err := m.ParseFlags(tt.args); tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("unexpected error: args=%v err=%v", tt.args, err)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("unexpected error: args=%v err=%v", tt.args, err)
+		}
+
+		if m.Command != tt.command || m.Worker != tt.worker {
+			t.Fatalf("unexpected command: args=%v command=%q worker=%v", tt.args, m.Command, m.Worker)
+		} else if tt.command != "" && !reflect.DeepEqual(m.Args, tt.cmdArgs) {
+			t.Fatalf("unexpected args: args=%v cmdArgs=%#v", tt.args, m.Args)
+		}
+	}
+}
+
+// Ensure show commands accept exactly one id.
+func TestMain_RunCommand_Show_ErrArgRequired(t *testing.T) {
+	for _, args := range [][]string{
+		{"users", "show"},
+		{"users", "show", "1", "2"},
+		{"playlists", "show"},
+		{"playlists", "show", "1", "2"},
+	} {
+		m := NewMain()
+		if err := m.ParseFlags(args); err != nil {
+			t.Fatal(err)
+		} else if err := m.RunCommand(context.Background()); err != ErrArgRequired {
+			t.Fatalf("unexpected error: args=%v err=%v", args, err)
+		}
+	}
+}
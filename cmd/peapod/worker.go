@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/client"
+)
+
+// Worker errors.
+const (
+	ErrWorkerURLRequired   = peapod.Error("worker url required")
+	ErrWorkerTokenRequired = peapod.Error("worker token required")
+)
+
+// runWorker starts a job scheduler which claims jobs from a remote daemon.
+// Generated files & tracks are uploaded to the daemon while text-to-speech,
+// youtube-dl & SMS notifications use the worker's own settings.
+func (m *Main) runWorker() error {
+	if m.Config.Worker.URL == "" {
+		return ErrWorkerURLRequired
+	} else if m.Config.Worker.Token == "" {
+		return ErrWorkerTokenRequired
+	}
+
+	u, err := url.Parse(m.Config.Worker.URL)
+	if err != nil {
+		return err
+	}
+
+	c := client.NewClient()
+	c.URL = *u
+	c.WorkerToken = m.Config.Worker.Token
+
+	// Initialize external services.
+	ttsService, err := m.newTTSService()
+	if err != nil {
+		return err
+	}
+
+	// Start job scheduler against the remote services.
+	jobScheduler := peapod.NewJobScheduler()
+	jobScheduler.FileService = client.NewFileService(c)
+	jobScheduler.JobService = client.NewJobService(c)
+	jobScheduler.PlaylistService = client.NewPlaylistService(c)
+	jobScheduler.SMSService = m.newSMSService()
+	jobScheduler.TrackService = client.NewTrackService(c)
+	jobScheduler.TTSService = ttsService
+	jobScheduler.UserService = client.NewUserService(c)
+	jobScheduler.URLTrackGenerator = m.newURLTrackGenerator()
+	jobScheduler.PollInterval = time.Duration(m.Config.Worker.PollInterval)
+	jobScheduler.Logger = m.logger.With("component", "scheduler")
+
+	if err := jobScheduler.Open(); err != nil {
+		return fmt.Errorf("error: open job scheduler: %s", err)
+	}
+	m.logger.Info("worker started", "url", u.String())
+
+	// Assign shutdown function.
+	m.shutdownFn = jobScheduler.Shutdown
+
+	return nil
+}
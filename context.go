@@ -1,6 +1,11 @@
 package peapod
 
-import "context"
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+)
 
 // NewContext returns a new Context that carries the authenticated user.
 func NewContext(ctx context.Context, user *User) context.Context {
@@ -15,13 +20,96 @@ func FromContext(ctx context.Context) *User {
 	return v.user
 }
 
+// NewSystemContext returns a new Context which is authorized to access all
+// records. It is used by background processes such as the job scheduler and
+// by administrative tools. The user in ctx, if any, is retained.
+func NewSystemContext(ctx context.Context) context.Context {
+	v, _ := ctx.Value(valueKey).(contextValue)
+	v.system = true
+	return context.WithValue(ctx, valueKey, v)
+}
+
+// IsSystemContext returns true if ctx was created by NewSystemContext.
+func IsSystemContext(ctx context.Context) bool {
+	v, _ := ctx.Value(valueKey).(contextValue)
+	return v.system
+}
+
+// NewCorrelationContext returns a new Context that carries a correlation id.
+// The id links the logs, jobs & outgoing requests which result from a single
+// inbound request so it is also added to the context's log fields.
+func NewCorrelationContext(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, correlationIDKey, id)
+	return WithLogFields(ctx, "correlation_id", id)
+}
+
+// CorrelationIDFromContext returns the correlation id stored in ctx, if any.
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey).(string)
+	return id
+}
+
+// CorrelationIDHeader is the header used to pass the correlation id in
+// HTTP requests & responses.
+const CorrelationIDHeader = "X-Correlation-ID"
+
+// CorrelationIDEnv is the environment variable used to pass the correlation
+// id to external commands.
+const CorrelationIDEnv = "PEAPOD_CORRELATION_ID"
+
+// CommandEnv returns the environment for an external command executed for
+// ctx. The correlation id in ctx, if any, is added to the environment of
+// the current process.
+func CommandEnv(ctx context.Context) []string {
+	env := os.Environ()
+	if id := CorrelationIDFromContext(ctx); id != "" {
+		env = append(env, CorrelationIDEnv+"="+id)
+	}
+	return env
+}
+
+// GenerateCorrelationID returns a random correlation id.
+func GenerateCorrelationID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%x", buf)
+}
+
+// MaxCorrelationIDLen is the maximum length of a correlation id.
+const MaxCorrelationIDLen = 64
+
+// IsValidCorrelationID returns true if id is non-blank, no longer than
+// MaxCorrelationIDLen and only contains letters, digits, dashes, underscores
+// & periods. Ids received from clients are only used if they are valid.
+func IsValidCorrelationID(id string) bool {
+	if id == "" || len(id) > MaxCorrelationIDLen {
+		return false
+	}
+	for _, ch := range id {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-', ch == '_', ch == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // contextValue is the set of data passed with Context.
 type contextValue struct {
-	user *User
+	user   *User
+	system bool
 }
 
 // contextKey is an unexported type for preventing context key collisions.
 type contextKey int
 
-// valueKey is the key used to store the context value.
-const valueKey contextKey = 0
+// Keys used to store the context value, log fields & correlation id.
+const (
+	valueKey         contextKey = 0
+	logFieldsKey     contextKey = 1
+	correlationIDKey contextKey = 2
+)
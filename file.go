@@ -23,6 +23,23 @@ type FileService interface {
 	GenerateName(ext string) string
 	FindFileByName(ctx context.Context, name string) (*File, io.ReadCloser, error)
 	CreateFile(ctx context.Context, f *File, r io.Reader) error
+
+	// Removes a file. Deleting a file that does not exist is not an error.
+	DeleteFile(ctx context.Context, name string) error
+}
+
+// DeleteTrackFiles removes the audio, transcript & chapters files of tracks.
+func DeleteTrackFiles(ctx context.Context, s FileService, tracks []*Track) error {
+	for _, track := range tracks {
+		for _, name := range []string{track.Filename, track.TranscriptFilename, track.ChaptersFilename} {
+			if name == "" {
+				continue
+			} else if err := s.DeleteFile(ctx, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // IsValidFilename returns true if the name is in a valid format.
@@ -0,0 +1,64 @@
+package peapod
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultHealthCheckCacheTTL is the default time a cached health check
+// result is reused.
+const DefaultHealthCheckCacheTTL = 5 * time.Minute
+
+// HealthChecker represents a component which can verify it is ready to
+// serve requests. CheckHealth returns a short description of the component,
+// such as a version, or an error if the component is not ready.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) (string, error)
+}
+
+// HealthCheckerFunc is an adapter to allow a function to be used as a
+// HealthChecker.
+type HealthCheckerFunc func(ctx context.Context) (string, error)
+
+// CheckHealth calls fn(ctx).
+func (fn HealthCheckerFunc) CheckHealth(ctx context.Context) (string, error) { return fn(ctx) }
+
+// CachedHealthChecker wraps a HealthChecker and reuses its result until TTL
+// has elapsed. This limits how often expensive checks, such as those which
+// run external commands, are executed.
+type CachedHealthChecker struct {
+	mu        sync.Mutex
+	checker   HealthChecker
+	msg       string
+	err       error
+	checkedAt time.Time
+
+	TTL time.Duration
+}
+
+// NewCachedHealthChecker returns a new instance of CachedHealthChecker.
+func NewCachedHealthChecker(checker HealthChecker) *CachedHealthChecker {
+	return &CachedHealthChecker{
+		checker: checker,
+		TTL:     DefaultHealthCheckCacheTTL,
+	}
+}
+
+// CheckHealth returns the cached result or runs the underlying check if the
+// result has expired. Results of checks interrupted by ctx are not cached.
+func (c *CachedHealthChecker) CheckHealth(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.TTL {
+		return c.msg, c.err
+	}
+
+	msg, err := c.checker.CheckHealth(ctx)
+	if ctx.Err() != nil {
+		return msg, err
+	}
+	c.msg, c.err, c.checkedAt = msg, err, time.Now()
+	return msg, err
+}
@@ -0,0 +1,260 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/middlemost/peapod"
+	"github.com/pressly/chi"
+)
+
+const (
+	ErrAdminRequired = peapod.Error("admin required")
+	ErrInvalidUserID = peapod.Error("invalid user id")
+	ErrInvalidJobID  = peapod.Error("invalid job id")
+)
+
+// AdminTokenHeader is the request header used to pass the admin token.
+const AdminTokenHeader = "X-ADMIN-TOKEN"
+
+// adminHandler represents an HTTP handler for operating the system.
+// All requests are performed with a system context.
+type adminHandler struct {
+	router chi.Router
+
+	// Shared secret which grants admin access. Disabled if blank.
+	token string
+
+	// Services
+	fileService     peapod.FileService
+	jobService      peapod.JobService
+	playlistService peapod.PlaylistService
+	trackService    peapod.TrackService
+	userService     peapod.UserService
+}
+
+// newAdminHandler returns a new instance of adminHandler.
+func newAdminHandler() *adminHandler {
+	h := &adminHandler{router: chi.NewRouter()}
+	h.router.Get("/users", h.handleGetUsers)
+	h.router.Get("/users/:id", h.handleGetUser)
+	h.router.Patch("/users/:id", h.handlePatchUser)
+	h.router.Get("/jobs", h.handleGetJobs)
+	h.router.Post("/jobs/:id/retry", h.handlePostJobRetry)
+	h.router.Post("/jobs/:id/cancel", h.handlePostJobCancel)
+	h.router.Get("/queue", h.handleGetQueue)
+	h.router.Get("/playlists/:id", h.handleGetPlaylist)
+	h.router.Delete("/tracks/:id", h.handleDeleteTrack)
+	return h
+}
+
+// ServeHTTP implements http.Handler. Requests are only routed once the
+// caller has been verified as an administrator.
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, err := h.authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	h.router.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// authenticate returns a system context if the request carries the admin
+// token or a session of a user with the admin role.
+func (h *adminHandler) authenticate(r *http.Request) (context.Context, error) {
+	if token := r.Header.Get(AdminTokenHeader); token != "" {
+		if h.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			return nil, peapod.ErrUnauthorized
+		}
+		return peapod.NewSystemContext(r.Context()), nil
+	}
+
+	ctx, err := authenticate(r)
+	if err != nil {
+		return nil, err
+	} else if !peapod.FromContext(ctx).Admin {
+		return nil, ErrAdminRequired
+	}
+	return peapod.NewSystemContext(ctx), nil
+}
+
+// handleGetUsers returns a list of users. Users can be searched with the
+// "q" query parameter and paginated with "offset" & "limit".
+func (h *adminHandler) handleGetUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+
+	users, err := h.userService.FindUsers(r.Context(), peapod.UserFilter{
+		Query:  q.Get("q"),
+		Offset: offset,
+		Limit:  limit,
+	})
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+// handleGetUser returns a single user.
+func (h *adminHandler) handleGetUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidUserID)
+		return
+	}
+
+	user, err := h.userService.FindUserByID(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	} else if user == nil {
+		Error(w, r, peapod.ErrUserNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// handlePatchUser grants or revokes admin access and disables or enables a user.
+func (h *adminHandler) handlePatchUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidUserID)
+		return
+	}
+
+	var upd peapod.UserAccessUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		Error(w, r, ErrInvalidJSON)
+		return
+	}
+
+	user, err := h.userService.UpdateUserAccess(r.Context(), id, upd)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// handleGetJobs returns jobs, newest first. Jobs can be filtered by the
+// "status" query parameter and paginated with "offset" & "limit".
+func (h *adminHandler) handleGetJobs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+
+	jobs, err := h.jobService.FindJobs(r.Context(), peapod.JobFilter{
+		Status: q.Get("status"),
+		Offset: offset,
+		Limit:  limit,
+	})
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// handlePostJobRetry moves a failed job back onto the job queue.
+func (h *adminHandler) handlePostJobRetry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidJobID)
+		return
+	}
+
+	job, err := h.jobService.RetryJob(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// handlePostJobCancel removes a pending job from the job queue.
+func (h *adminHandler) handlePostJobCancel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidJobID)
+		return
+	}
+
+	job, err := h.jobService.CancelJob(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// handleGetQueue returns the depth of the job queue.
+func (h *adminHandler) handleGetQueue(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.jobService.FindJobQueueStats(r.Context())
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// handleGetPlaylist returns any playlist along with its tracks.
+func (h *adminHandler) handleGetPlaylist(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidPlaylistID)
+		return
+	}
+
+	playlist, err := h.playlistService.FindPlaylistByID(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	} else if playlist == nil {
+		Error(w, r, peapod.ErrPlaylistNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(playlist)
+}
+
+// handleDeleteTrack permanently deletes a track & its files. The deleted
+// track is returned.
+func (h *adminHandler) handleDeleteTrack(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidTrackID)
+		return
+	}
+
+	track, err := h.trackService.PurgeTrack(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	} else if err := peapod.DeleteTrackFiles(r.Context(), h.fileService, []*peapod.Track{track}); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(track)
+}
@@ -2,25 +2,25 @@ package http
 
 import (
 	"context"
-	"io"
+	"log/slog"
 )
 
-// NewContext returns a new Context that carries the log output.
-func NewContext(ctx context.Context, logOutput io.Writer) context.Context {
+// NewContext returns a new Context that carries the logger.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
 	return context.WithValue(ctx, valueKey, contextValue{
-		logOutput: logOutput,
+		logger: logger,
 	})
 }
 
-// FromContext returns the log output stored in ctx, if any.
-func FromContext(ctx context.Context) io.Writer {
+// FromContext returns the logger stored in ctx, if any.
+func FromContext(ctx context.Context) *slog.Logger {
 	v, _ := ctx.Value(valueKey).(contextValue)
-	return v.logOutput
+	return v.logger
 }
 
 // contextValue is the set of data passed with Context.
 type contextValue struct {
-	logOutput io.Writer
+	logger *slog.Logger
 }
 
 // contextKey is an unexported type for preventing context key collisions.
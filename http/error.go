@@ -2,7 +2,7 @@ package http
 
 import (
 	"encoding/json"
-	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 
@@ -12,13 +12,87 @@ import (
 const (
 	ErrNotAcceptable = peapod.Error("not acceptable")
 	ErrAssetNotFound = peapod.Error("asset not found")
+	ErrInvalidJSON   = peapod.Error("invalid json")
 )
 
 // errorMap is a whitelist that maps errors to status codes.
 var errorMap = map[error]int{
-	ErrNotAcceptable:         http.StatusNotAcceptable,
-	ErrTwilioAccountMismatch: http.StatusBadRequest,
-	ErrInvalidSMSRequestBody: http.StatusBadRequest,
+	peapod.ErrUnauthorized:     http.StatusUnauthorized,
+	peapod.ErrUserNotFound:     http.StatusNotFound,
+	peapod.ErrPlaylistNotFound: http.StatusNotFound,
+
+	peapod.ErrUserRequired:           http.StatusBadRequest,
+	peapod.ErrUserMobileNumberInUse:  http.StatusConflict,
+	peapod.ErrPlaylistRequired:       http.StatusBadRequest,
+	peapod.ErrPlaylistOwnerRequired:  http.StatusBadRequest,
+	peapod.ErrPlaylistInviteRequired: http.StatusBadRequest,
+	peapod.ErrTrackRequired:          http.StatusBadRequest,
+	peapod.ErrTrackPlaylistRequired:  http.StatusBadRequest,
+	peapod.ErrTrackFilenameRequired:  http.StatusBadRequest,
+	peapod.ErrFilenameRequired:       http.StatusBadRequest,
+	peapod.ErrJobRequired:            http.StatusBadRequest,
+	peapod.ErrJobOwnerRequired:       http.StatusBadRequest,
+	peapod.ErrJobOwnerNotFound:       http.StatusNotFound,
+	peapod.ErrInvalidJobType:         http.StatusBadRequest,
+	peapod.ErrInvalidRegion:          http.StatusBadRequest,
+
+	peapod.ErrPlaylistInviteNotFound:     http.StatusNotFound,
+	peapod.ErrPlaylistMemberExists:       http.StatusConflict,
+	peapod.ErrInvalidPlaylistRole:        http.StatusBadRequest,
+	peapod.ErrInviteMobileNumberRequired: http.StatusBadRequest,
+	peapod.ErrInvalidPlaylistType:        http.StatusBadRequest,
+	peapod.ErrSmartFilterRequired:        http.StatusBadRequest,
+	peapod.ErrPlaylistNameRequired:       http.StatusBadRequest,
+	peapod.ErrPlaylistNotWritable:        http.StatusBadRequest,
+	peapod.ErrMergedSourcesRequired:      http.StatusBadRequest,
+	peapod.ErrInvalidMergedSource:        http.StatusBadRequest,
+	peapod.ErrTrackNotFound:              http.StatusNotFound,
+	peapod.ErrSearchQueryRequired:        http.StatusBadRequest,
+	peapod.ErrTrackReasonRequired:        http.StatusBadRequest,
+	peapod.ErrTrashEmpty:                 http.StatusNotFound,
+	peapod.ErrNothingToUndo:              http.StatusNotFound,
+	peapod.ErrLoginCodeRequired:          http.StatusBadRequest,
+	peapod.ErrInvalidLoginCode:           http.StatusUnauthorized,
+	peapod.ErrLoginCodeExpired:           http.StatusUnauthorized,
+	peapod.ErrLoginAttemptsExceeded:      http.StatusTooManyRequests,
+	peapod.ErrLoginCodeLimitExceeded:     http.StatusTooManyRequests,
+	peapod.ErrSessionNotFound:            http.StatusNotFound,
+	peapod.ErrUserMobileNumberRequired:   http.StatusBadRequest,
+	peapod.ErrInvalidMobileNumber:        http.StatusBadRequest,
+	peapod.ErrInvalidTimezone:            http.StatusBadRequest,
+	peapod.ErrInvalidVoice:               http.StatusBadRequest,
+	peapod.ErrInvalidTrackCursor:         http.StatusBadRequest,
+	peapod.ErrInvalidTrackOrder:          http.StatusBadRequest,
+	peapod.ErrUserDisabled:               http.StatusForbidden,
+	peapod.ErrDeletionCodeRequired:       http.StatusBadRequest,
+	peapod.ErrInvalidDeletionCode:        http.StatusUnauthorized,
+	peapod.ErrJobNotFound:                http.StatusNotFound,
+	peapod.ErrJobNotRetryable:            http.StatusConflict,
+	peapod.ErrJobNotCancelable:           http.StatusConflict,
+	peapod.ErrJobNotProcessing:           http.StatusConflict,
+	peapod.ErrInvalidFilename:            http.StatusBadRequest,
+	peapod.ErrInvalidJobStatus:           http.StatusBadRequest,
+	peapod.ErrInvalidURL:                 http.StatusBadRequest,
+	peapod.ErrTrackTitleRequired:         http.StatusBadRequest,
+
+	ErrNotAcceptable:           http.StatusNotAcceptable,
+	ErrInvalidPlaylistID:       http.StatusBadRequest,
+	ErrInvalidPlaylistInviteID: http.StatusBadRequest,
+	ErrInvalidJSON:             http.StatusBadRequest,
+	ErrInvalidSmartFilter:      http.StatusBadRequest,
+	ErrInvalidRetention:        http.StatusBadRequest,
+	ErrInvalidTrackID:          http.StatusBadRequest,
+	ErrInvalidTrackFilter:      http.StatusBadRequest,
+	ErrTwilioAccountMismatch:   http.StatusBadRequest,
+	ErrInvalidSMSRequestBody:   http.StatusBadRequest,
+	ErrSignatureRequired:       http.StatusUnauthorized,
+	ErrInvalidSignature:        http.StatusUnauthorized,
+	ErrSignatureExpired:        http.StatusUnauthorized,
+	ErrAdminRequired:           http.StatusForbidden,
+	ErrInvalidUserID:           http.StatusBadRequest,
+	ErrInvalidJobID:            http.StatusBadRequest,
+	ErrTrackURLRequired:        http.StatusBadRequest,
+	ErrTTSTextRequired:         http.StatusBadRequest,
 }
 
 // ErrorStatusCode returns the HTTP status code for an error object.
@@ -34,9 +108,13 @@ func Error(w http.ResponseWriter, r *http.Request, err error) {
 	// Determine status code.
 	code := ErrorStatusCode(err)
 
-	// Log error.
-	if logOutput := FromContext(r.Context()); logOutput != nil {
-		fmt.Fprintf(logOutput, "http error: %d %s\n", code, err.Error())
+	// Log error. Client errors are expected so they are logged at a lower level.
+	if logger := FromContext(r.Context()); logger != nil {
+		level := slog.LevelInfo
+		if code >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.Log(r.Context(), level, "http error", "status", code, "err", err)
 	}
 
 	// Mask unrecognized errors from end users.
@@ -49,7 +127,10 @@ func Error(w http.ResponseWriter, r *http.Request, err error) {
 	case strings.Contains(r.Header.Get("Accept"), "application/json"):
 		w.Header().Set("Context-Type", "application/json")
 		w.WriteHeader(code)
-		json.NewEncoder(w).Encode(&errorResponse{Err: err.Error()})
+		json.NewEncoder(w).Encode(&ErrorResponse{
+			Err:           err.Error(),
+			CorrelationID: peapod.CorrelationIDFromContext(r.Context()),
+		})
 
 	default:
 		w.Header().Set("Context-Type", "text/plain")
@@ -58,6 +139,8 @@ func Error(w http.ResponseWriter, r *http.Request, err error) {
 	}
 }
 
-type errorResponse struct {
-	Err string `json:"error,omitempty"`
+// ErrorResponse is the body of a JSON error response.
+type ErrorResponse struct {
+	Err           string `json:"error,omitempty"`
+	CorrelationID string `json:"correlation_id,omitempty"`
 }
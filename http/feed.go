@@ -0,0 +1,457 @@
+package http
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/middlemost/peapod"
+)
+
+// Feed content types.
+const (
+	RSSContentType      = "application/rss+xml"
+	AtomContentType     = "application/atom+xml"
+	JSONFeedContentType = "application/feed+json"
+)
+
+// DefaultFeedDescription is the description used for all playlist feeds.
+const DefaultFeedDescription = "Your personal podcast."
+
+// Podcasting 2.0 namespace & content types.
+const (
+	PodcastNamespace     = "https://podcastindex.org/namespace/1.0"
+	ChaptersContentType  = "application/json+chapters"
+	podcastGUIDNamespace = "ead4c236-bf58-58c6-a2c6-a6b28d128cb6"
+)
+
+// feed represents a format-independent podcast feed.
+// It is converted to RSS, Atom, or JSON Feed when encoded.
+type feed struct {
+	ID          string
+	GUID        string
+	Title       string
+	Description string
+	URL         string
+	ImageURL    string
+	Locked      bool
+	UpdatedAt   time.Time
+	Items       []feedItem
+}
+
+// feedItem represents a single episode within a feed.
+type feedItem struct {
+	ID          string
+	Title       string
+	Description string
+	Author      string
+	PublishedAt time.Time
+	UpdatedAt   time.Time
+	Duration    time.Duration
+	Enclosure   feedEnclosure
+
+	TranscriptURL  string
+	TranscriptType string
+	ChaptersURL    string
+}
+
+// feedEnclosure represents the audio file attached to a feed item.
+type feedEnclosure struct {
+	URL    string
+	Type   string
+	Length int
+}
+
+// newPlaylistFeed returns a feed for a playlist. The feed URL is generated
+// using baseURL and the file extension of the requested format.
+func newPlaylistFeed(playlist *peapod.Playlist, baseURL url.URL, ext string) *feed {
+	// Determine feed & logo image URLs.
+	feedURL := playlistFeedURL(baseURL, playlist.Token, ext)
+
+	imageURL := baseURL
+	imageURL.Path = "/assets/logo-1024x1024.png"
+
+	f := &feed{
+		ID:          feedURL.String(),
+		GUID:        playlistGUID(baseURL, playlist.ID),
+		Title:       playlist.Name,
+		Description: DefaultFeedDescription,
+		URL:         feedURL.String(),
+		ImageURL:    imageURL.String(),
+		Locked:      true,
+		UpdatedAt:   playlist.LastTrackUpdatedAt(),
+		Items:       make([]feedItem, len(playlist.Tracks)),
+	}
+	if f.UpdatedAt.IsZero() {
+		f.UpdatedAt = playlist.UpdatedAt
+	}
+
+	// Convert tracks to feed items.
+	for i, track := range playlist.Tracks {
+		enclosureURL := baseURL
+		enclosureURL.Path = fmt.Sprintf("/files/%s", track.Filename)
+
+		f.Items[i] = feedItem{
+			ID:          enclosureURL.String(),
+			Title:       track.Title,
+			Description: track.Description,
+			Author:      track.Author,
+			PublishedAt: track.CreatedAt,
+			UpdatedAt:   track.UpdatedAt,
+			Duration:    track.Duration,
+			Enclosure: feedEnclosure{
+				URL:    enclosureURL.String(),
+				Type:   mime.TypeByExtension(path.Ext(track.Filename)),
+				Length: track.Size,
+			},
+		}
+
+		// Attach transcript & chapters, if available.
+		if track.TranscriptFilename != "" {
+			u := baseURL
+			u.Path = fmt.Sprintf("/transcripts/%s", track.TranscriptFilename)
+			f.Items[i].TranscriptURL = u.String()
+			f.Items[i].TranscriptType = fileContentType(track.TranscriptFilename)
+		}
+		if track.ChaptersFilename != "" {
+			u := baseURL
+			u.Path = fmt.Sprintf("/chapters/%s", track.ChaptersFilename)
+			f.Items[i].ChaptersURL = u.String()
+		}
+	}
+
+	return f
+}
+
+// playlistGUID returns a Podcasting 2.0 GUID for a playlist.
+//
+// The GUID is a UUIDv5 generated from the playlist's host & id instead of
+// the feed URL so that it does not expose the token and does not change if
+// the token changes.
+func playlistGUID(baseURL url.URL, id int) string {
+	ns, _ := hex.DecodeString(strings.Replace(podcastGUIDNamespace, "-", "", -1))
+
+	h := sha1.New()
+	h.Write(ns)
+	h.Write([]byte(fmt.Sprintf("%s/playlists/%d", baseURL.Host, id)))
+	b := h.Sum(nil)[:16]
+	b[6] = (b[6] & 0x0f) | 0x50 // version 5
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// playlistFeedURL returns the URL of a playlist feed in the format of ext.
+func playlistFeedURL(baseURL url.URL, token, ext string) url.URL {
+	u := baseURL
+	u.Path = fmt.Sprintf("/p/%s%s", token, ext)
+	return u
+}
+
+// encodeRSS writes f to w as an RSS 2.0 document.
+func encodeRSS(w io.Writer, f *feed) error {
+	rss := playlistRSS{
+		Channel: channelRSS{
+			Title:       f.Title,
+			Description: cdata{f.Description},
+			Summary:     cdata{f.Description},
+			Image:       imageRSS{Href: f.ImageURL},
+			GUID:        f.GUID,
+			Items:       make([]itemRSS, len(f.Items)),
+		},
+	}
+	if f.Locked {
+		rss.Channel.Locked = "yes"
+	}
+	if !f.UpdatedAt.IsZero() {
+		rss.Channel.LastBuildDate = f.UpdatedAt.Format(time.RFC1123Z)
+	}
+
+	for i, item := range f.Items {
+		rss.Channel.Items[i] = itemRSS{
+			Title:       item.Title,
+			Description: cdata{item.Description},
+			Summary:     cdata{item.Description},
+			PubDate:     item.PublishedAt.Format(time.RFC1123Z),
+			Duration:    formatDuration(item.Duration),
+			Enclosure: enclosureRSS{
+				URL:    item.Enclosure.URL,
+				Type:   item.Enclosure.Type,
+				Length: item.Enclosure.Length,
+			},
+		}
+
+		if item.TranscriptURL != "" {
+			rss.Channel.Items[i].Transcripts = []transcriptRSS{{URL: item.TranscriptURL, Type: item.TranscriptType}}
+		}
+		if item.ChaptersURL != "" {
+			rss.Channel.Items[i].Chapters = &chaptersRSS{URL: item.ChaptersURL, Type: ChaptersContentType}
+		}
+		if item.Author != "" {
+			rss.Channel.Items[i].Persons = []string{item.Author}
+		}
+	}
+
+	return xml.NewEncoder(w).EncodeElement(
+		rss,
+		xml.StartElement{
+			Name: xml.Name{Local: "rss"},
+			Attr: []xml.Attr{
+				{Name: xml.Name{Local: "xmlns:itunes"}, Value: "http://www.itunes.com/dtds/podcast-1.0.dtd"},
+				{Name: xml.Name{Local: "xmlns:atom"}, Value: "http://www.w3.org/2005/Atom"},
+				{Name: xml.Name{Local: "xmlns:podcast"}, Value: PodcastNamespace},
+				{Name: xml.Name{Local: "version"}, Value: "2.0"},
+			},
+		},
+	)
+}
+
+// encodeAtom writes f to w as an Atom 1.0 document.
+func encodeAtom(w io.Writer, f *feed) error {
+	atom := playlistAtom{
+		ID:       f.ID,
+		Title:    f.Title,
+		Subtitle: f.Description,
+		Updated:  formatAtomTime(f.UpdatedAt),
+		Icon:     f.ImageURL,
+		Logo:     f.ImageURL,
+		Links:    []linkAtom{{Rel: "self", Href: f.URL, Type: AtomContentType}},
+		Entries:  make([]entryAtom, len(f.Items)),
+	}
+
+	for i, item := range f.Items {
+		atom.Entries[i] = entryAtom{
+			ID:        item.ID,
+			Title:     item.Title,
+			Summary:   item.Description,
+			Published: formatAtomTime(item.PublishedAt),
+			Updated:   formatAtomTime(item.UpdatedAt),
+			Duration:  formatDuration(item.Duration),
+			Author:    newAuthorAtom(item.Author),
+			Links: []linkAtom{{
+				Rel:    "enclosure",
+				Href:   item.Enclosure.URL,
+				Type:   item.Enclosure.Type,
+				Length: item.Enclosure.Length,
+			}},
+		}
+	}
+
+	return xml.NewEncoder(w).EncodeElement(
+		atom,
+		xml.StartElement{
+			Name: xml.Name{Local: "feed"},
+			Attr: []xml.Attr{
+				{Name: xml.Name{Local: "xmlns"}, Value: "http://www.w3.org/2005/Atom"},
+				{Name: xml.Name{Local: "xmlns:itunes"}, Value: "http://www.itunes.com/dtds/podcast-1.0.dtd"},
+			},
+		},
+	)
+}
+
+// encodeJSONFeed writes f to w as a JSON Feed 1.1 document.
+func encodeJSONFeed(w io.Writer, f *feed) error {
+	jf := playlistJSONFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		FeedURL:     f.URL,
+		Description: f.Description,
+		Icon:        f.ImageURL,
+		Items:       make([]itemJSONFeed, len(f.Items)),
+	}
+
+	for i, item := range f.Items {
+		jf.Items[i] = itemJSONFeed{
+			ID:            item.ID,
+			Title:         item.Title,
+			ContentText:   item.Description,
+			DatePublished: formatAtomTime(item.PublishedAt),
+			DateModified:  formatAtomTime(item.UpdatedAt),
+			Authors:       newAuthorsJSONFeed(item.Author),
+			Attachments: []attachmentJSONFeed{{
+				URL:               item.Enclosure.URL,
+				MimeType:          item.Enclosure.Type,
+				SizeInBytes:       item.Enclosure.Length,
+				DurationInSeconds: int(item.Duration / time.Second),
+			}},
+		}
+	}
+
+	return json.NewEncoder(w).Encode(jf)
+}
+
+// playlistRSS represents an RSS feed for a playlist.
+type playlistRSS struct {
+	Channel channelRSS `xml:"channel"`
+}
+
+type channelRSS struct {
+	Title         string    `xml:"title"`
+	Description   cdata     `xml:"description"`
+	Summary       cdata     `xml:"itunes:summary"`
+	Image         imageRSS  `xml:"itunes:image"`
+	GUID          string    `xml:"podcast:guid,omitempty"`
+	Locked        string    `xml:"podcast:locked,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []itemRSS `xml:"item"`
+}
+
+type imageRSS struct {
+	Href string `xml:"href,attr"`
+}
+
+type itemRSS struct {
+	Title       string          `xml:"title"`
+	Description cdata           `xml:"description"`
+	Summary     cdata           `xml:"itunes:summary"`
+	Link        string          `xml:"link"`
+	PubDate     string          `xml:"pubDate"`
+	Duration    string          `xml:"itunes:duration,omitempty"`
+	Enclosure   enclosureRSS    `xml:"enclosure"`
+	Transcripts []transcriptRSS `xml:"podcast:transcript,omitempty"`
+	Chapters    *chaptersRSS    `xml:"podcast:chapters,omitempty"`
+	Persons     []string        `xml:"podcast:person,omitempty"`
+}
+
+type enclosureRSS struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int    `xml:"length,attr"`
+}
+
+type transcriptRSS struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type chaptersRSS struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+// playlistAtom represents an Atom feed for a playlist.
+type playlistAtom struct {
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Icon     string      `xml:"icon,omitempty"`
+	Logo     string      `xml:"logo,omitempty"`
+	Links    []linkAtom  `xml:"link"`
+	Entries  []entryAtom `xml:"entry"`
+}
+
+type entryAtom struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Summary   string      `xml:"summary,omitempty"`
+	Published string      `xml:"published,omitempty"`
+	Updated   string      `xml:"updated"`
+	Duration  string      `xml:"itunes:duration,omitempty"`
+	Author    *authorAtom `xml:"author,omitempty"`
+	Links     []linkAtom  `xml:"link"`
+}
+
+type authorAtom struct {
+	Name string `xml:"name"`
+}
+
+// newAuthorAtom returns an author element for name. Returns nil if blank.
+func newAuthorAtom(name string) *authorAtom {
+	if name == "" {
+		return nil
+	}
+	return &authorAtom{Name: name}
+}
+
+type linkAtom struct {
+	Rel    string `xml:"rel,attr,omitempty"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int    `xml:"length,attr,omitempty"`
+}
+
+// playlistJSONFeed represents a JSON Feed for a playlist.
+type playlistJSONFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	FeedURL     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Icon        string         `json:"icon,omitempty"`
+	Items       []itemJSONFeed `json:"items"`
+}
+
+type itemJSONFeed struct {
+	ID            string               `json:"id"`
+	Title         string               `json:"title,omitempty"`
+	ContentText   string               `json:"content_text"`
+	DatePublished string               `json:"date_published,omitempty"`
+	DateModified  string               `json:"date_modified,omitempty"`
+	Authors       []authorJSONFeed     `json:"authors,omitempty"`
+	Attachments   []attachmentJSONFeed `json:"attachments,omitempty"`
+}
+
+type authorJSONFeed struct {
+	Name string `json:"name"`
+}
+
+// newAuthorsJSONFeed returns a list containing a single author. Returns nil if blank.
+func newAuthorsJSONFeed(name string) []authorJSONFeed {
+	if name == "" {
+		return nil
+	}
+	return []authorJSONFeed{{Name: name}}
+}
+
+type attachmentJSONFeed struct {
+	URL               string `json:"url"`
+	MimeType          string `json:"mime_type"`
+	SizeInBytes       int    `json:"size_in_bytes,omitempty"`
+	DurationInSeconds int    `json:"duration_in_seconds,omitempty"`
+}
+
+// formatDuration formats d in HH:MM:SS format.
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+
+	s := (d / time.Second) % 60
+	m := (d / time.Minute) % 60
+	h := d / time.Hour
+	return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
+}
+
+// fileContentType returns the content type for a file based on its extension.
+func fileContentType(name string) string {
+	switch ext := path.Ext(name); ext {
+	case ".vtt":
+		return "text/vtt"
+	case ".srt":
+		return "application/x-subrip"
+	case ".txt":
+		return "text/plain"
+	default:
+		return mime.TypeByExtension(ext)
+	}
+}
+
+// formatAtomTime formats t in RFC 3339 format. Returns blank if t is zero.
+func formatAtomTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
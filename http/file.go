@@ -2,10 +2,8 @@ package http
 
 import (
 	"io"
-	"mime"
 	"net/http"
 	"net/url"
-	"path"
 	"strconv"
 
 	"github.com/middlemost/peapod"
@@ -18,6 +16,9 @@ type fileHandler struct {
 
 	baseURL     url.URL
 	fileService peapod.FileService
+
+	// If set, overrides the content type determined from the file extension.
+	contentType string
 }
 
 // newFileHandler returns a new instance of fileHandler.
@@ -45,7 +46,11 @@ func (h *fileHandler) handleGet(w http.ResponseWriter, r *http.Request) {
 	defer rc.Close()
 
 	// Set headers.
-	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(f.Name)))
+	contentType := h.contentType
+	if contentType == "" {
+		contentType = fileContentType(f.Name)
+	}
+	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Content-Length", strconv.FormatInt(f.Size, 10))
 
 	// Write file contents to response.
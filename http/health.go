@@ -0,0 +1,129 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHealthCheckTimeout is the time allowed for each readiness check.
+const DefaultHealthCheckTimeout = 5 * time.Second
+
+// Health check statuses.
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// HealthResponse represents the overall status and the result of each check.
+type HealthResponse struct {
+	Status string         `json:"status"`
+	Checks []*HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck represents the result of a single readiness check.
+type HealthCheck struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// handleHealthz reports that the process is serving requests. It does not
+// check dependencies so that a restart is not triggered by an outage of
+// something the process cannot fix itself.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&HealthResponse{Status: HealthStatusOK})
+}
+
+// handleReadyz runs all health checks and returns a 503 if any fail so that
+// traffic is only routed to instances which can serve it. The result of each
+// check includes versions & sizes so it is only returned to requests with
+// the metrics or admin token.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	resp := &HealthResponse{Status: HealthStatusOK}
+	for _, check := range s.checkHealth(r.Context()) {
+		if check.Status != HealthStatusOK {
+			resp.Status = HealthStatusFail
+		}
+		resp.Checks = append(resp.Checks, check)
+	}
+	if !s.isOperator(r) {
+		resp.Checks = nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Status != HealthStatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// isOperator returns true if the request carries the metrics token as a
+// bearer token or the admin token. Tokens which are not configured are ignored.
+func (s *Server) isOperator(r *http.Request) bool {
+	if s.MetricsToken != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.MetricsToken)) == 1 {
+			return true
+		}
+	}
+	if s.AdminToken != "" {
+		token := r.Header.Get(AdminTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.AdminToken)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHealth runs all health checks in parallel and returns their results
+// sorted by name.
+func (s *Server) checkHealth(ctx context.Context) []*HealthCheck {
+	names := make([]string, 0, len(s.HealthCheckers))
+	for name := range s.HealthCheckers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	checks := make([]*HealthCheck, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			checks[i] = s.runHealthCheck(ctx, name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	return checks
+}
+
+// runHealthCheck executes a single named check with a timeout.
+func (s *Server) runHealthCheck(ctx context.Context, name string) *HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, s.HealthCheckTimeout)
+	defer cancel()
+
+	t := time.Now()
+	msg, err := s.HealthCheckers[name].CheckHealth(ctx)
+
+	check := &HealthCheck{
+		Name:     name,
+		Status:   HealthStatusOK,
+		Message:  msg,
+		Duration: time.Since(t).String(),
+	}
+	if err != nil {
+		check.Status = HealthStatusFail
+		check.Error = err.Error()
+	}
+	return check
+}
@@ -1,10 +1,22 @@
 package http
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/mock"
 )
 
 // Ensure playlist can encode to RSS.
@@ -45,3 +57,599 @@ func TestPlaylistRSS(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// Ensure playlist can encode to Atom.
+func TestPlaylistAtom(t *testing.T) {
+	f := newPlaylistFeed(newTestPlaylist(), url.URL{Scheme: "https", Host: "peapod.io"}, ".atom")
+
+	var buf bytes.Buffer
+	if err := encodeAtom(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+
+	var other playlistAtom
+	if err := xml.Unmarshal(buf.Bytes(), &other); err != nil {
+		t.Fatal(err)
+	} else if other.ID != "https://peapod.io/p/TOKEN.atom" {
+		t.Fatalf("unexpected id: %s", other.ID)
+	} else if len(other.Entries) != 1 {
+		t.Fatalf("unexpected entry count: %d", len(other.Entries))
+	} else if link := other.Entries[0].Links[0]; link.Href != "https://peapod.io/files/0001.mp3" || link.Length != 100 {
+		t.Fatalf("unexpected enclosure: %#v", link)
+	} else if !strings.Contains(buf.String(), "<itunes:duration>00:01:03</itunes:duration>") {
+		t.Fatalf("expected duration: %s", buf.String())
+	}
+}
+
+// Ensure playlist can encode to JSON Feed.
+func TestPlaylistJSONFeed(t *testing.T) {
+	f := newPlaylistFeed(newTestPlaylist(), url.URL{Scheme: "https", Host: "peapod.io"}, ".json")
+
+	var buf bytes.Buffer
+	if err := encodeJSONFeed(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+
+	var other playlistJSONFeed
+	if err := json.Unmarshal(buf.Bytes(), &other); err != nil {
+		t.Fatal(err)
+	} else if other.Version != "https://jsonfeed.org/version/1.1" {
+		t.Fatalf("unexpected version: %s", other.Version)
+	} else if len(other.Items) != 1 {
+		t.Fatalf("unexpected item count: %d", len(other.Items))
+	} else if a := other.Items[0].Attachments[0]; a.URL != "https://peapod.io/files/0001.mp3" || a.SizeInBytes != 100 || a.DurationInSeconds != 63 {
+		t.Fatalf("unexpected attachment: %#v", a)
+	}
+}
+
+// Ensure a merged playlist's feed can be fetched by token without a user.
+func TestServer_MergedPlaylistFeed(t *testing.T) {
+	var playlistService mock.PlaylistService
+	playlistService.FindPlaylistByTokenFn = func(ctx context.Context, token string) (*peapod.Playlist, error) {
+		return &peapod.Playlist{ID: 1, OwnerID: 100, Token: token, Name: "MERGED", Type: peapod.PlaylistTypeMerged, SourceIDs: []int{2, 3, 4}}, nil
+	}
+	playlistService.FindPlaylistByIDFn = func(ctx context.Context, id int) (*peapod.Playlist, error) {
+		// Sources are looked up as the owner, who can no longer access #4.
+		if u := peapod.FromContext(ctx); peapod.IsSystemContext(ctx) || u == nil || u.ID != 100 || id == 4 {
+			return nil, peapod.ErrUnauthorized
+		}
+		return &peapod.Playlist{ID: id, Tracks: []*peapod.Track{{ID: id * 10, Filename: "0001.mp3"}}}, nil
+	}
+
+	var userService mock.UserService
+	userService.FindUserByIDFn = func(ctx context.Context, id int) (*peapod.User, error) {
+		if !peapod.IsSystemContext(ctx) {
+			return nil, peapod.ErrUnauthorized
+		}
+		return &peapod.User{ID: id}, nil
+	}
+
+	s := NewServer()
+	s.PlaylistService = &playlistService
+	s.UserService = &userService
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/p/TOKEN.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	var feed playlistJSONFeed
+	if err := json.NewDecoder(w.Body).Decode(&feed); err != nil {
+		t.Fatal(err)
+	} else if feed.Title != "MERGED" || len(feed.Items) != 2 {
+		t.Fatalf("unexpected feed: %#v", feed)
+	}
+}
+
+// Ensure the merged playlist cache evicts the least recently used & failed entries.
+func TestMergedPlaylistCache_Evict(t *testing.T) {
+	var playlistService mock.PlaylistService
+	playlistService.FindPlaylistByIDFn = func(ctx context.Context, id int) (*peapod.Playlist, error) {
+		if id == 0 {
+			return nil, errors.New("marker")
+		}
+		return &peapod.Playlist{ID: id, Tracks: []*peapod.Track{{ID: id}}}, nil
+	}
+	var userService mock.UserService
+	userService.FindUserByIDFn = func(ctx context.Context, id int) (*peapod.User, error) {
+		return &peapod.User{ID: id}, nil
+	}
+
+	c := newMergedPlaylistCache()
+	c.size = 2
+	for id := 1; id <= 3; id++ {
+		if _, err := c.tracks(context.Background(), &playlistService, &userService, &peapod.Playlist{ID: id, SourceIDs: []int{id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(c.entries) != 2 || c.entries[1] != nil {
+		t.Fatalf("unexpected entries: %#v", c.entries)
+	}
+
+	// A failed generation removes the stale entry.
+	if _, err := c.tracks(context.Background(), &playlistService, &userService, &peapod.Playlist{ID: 3, SourceIDs: []int{0}, UpdatedAt: time.Now()}); err == nil || err.Error() != "marker" {
+		t.Fatalf("unexpected error: %v", err)
+	} else if len(c.entries) != 1 || c.entries[3] != nil {
+		t.Fatalf("unexpected entries: %#v", c.entries)
+	}
+}
+
+// Ensure the /p alias only serves playlist feeds.
+func TestServer_PlaylistFeedAlias(t *testing.T) {
+	var playlistService mock.PlaylistService
+	playlistService.FindPlaylistByTokenFn = func(ctx context.Context, token string) (*peapod.Playlist, error) {
+		return newTestPlaylist(), nil
+	}
+
+	s := NewServer()
+	s.PlaylistService = &playlistService
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/p/TOKEN.rss", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	for _, tt := range []struct{ method, path string }{
+		{"POST", "/p/"},
+		{"POST", "/p/1/token"},
+		{"GET", "/p/1/members"},
+	} {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != http.StatusNotFound && w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("%s %s: unexpected status: %d", tt.method, tt.path, w.Code)
+		}
+	}
+}
+
+// newTestPlaylist returns a playlist with a single track.
+func newTestPlaylist() *peapod.Playlist {
+	return &peapod.Playlist{
+		Token: "TOKEN",
+		Name:  "NAME",
+		Tracks: []*peapod.Track{{
+			Filename:  "0001.mp3",
+			Title:     "TITLE",
+			Duration:  63 * time.Second,
+			Size:      100,
+			CreatedAt: time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC),
+			UpdatedAt: time.Date(2000, time.January, 2, 0, 0, 0, 0, time.UTC),
+		}},
+	}
+}
+
+// Ensure a signed URL can be verified and rejects tampering & expiration.
+func TestSignURL(t *testing.T) {
+	now := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	u := signURL(url.URL{Path: "/playlists.opml"}, "SECRET", 100, now.Add(time.Hour))
+
+	if userID, err := verifySignedURL(&u, "SECRET", now); err != nil {
+		t.Fatal(err)
+	} else if userID != 100 {
+		t.Fatalf("unexpected user id: %d", userID)
+	}
+
+	if _, err := verifySignedURL(&u, "SECRET", now.Add(2*time.Hour)); err != ErrSignatureExpired {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	q := u.Query()
+	q.Set("user", "200")
+	u.RawQuery = q.Encode()
+	if _, err := verifySignedURL(&u, "SECRET", now); err != ErrInvalidSignature {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure a Twilio webhook signature is verified against the public URL & form.
+func TestVerifyTwilioSignature(t *testing.T) {
+	form := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+	newRequest := func(sig string, form url.Values) *http.Request {
+		r := httptest.NewRequest("POST", "/myapp.php?foo=1&bar=2", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if sig != "" {
+			r.Header.Set(TwilioSignatureHeader, sig)
+		}
+		return r
+	}
+	baseURL := url.URL{Scheme: "https", Host: "mycompany.com"}
+
+	// Example from the Twilio documentation.
+	if err := verifyTwilioSignature(newRequest("0/KCTR6DLpKmkAf8muzZqo1nDgQ=", form), baseURL, "12345"); err != nil {
+		t.Fatal(err)
+	} else if err := verifyTwilioSignature(newRequest("", form), baseURL, "12345"); err != ErrSignatureRequired {
+		t.Fatalf("unexpected error: %v", err)
+	} else if err := verifyTwilioSignature(newRequest("0/KCTR6DLpKmkAf8muzZqo1nDgQ=", form), baseURL, "54321"); err != ErrInvalidSignature {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	form.Set("From", "+15555550100")
+	if err := verifyTwilioSignature(newRequest("0/KCTR6DLpKmkAf8muzZqo1nDgQ=", form), baseURL, "12345"); err != ErrInvalidSignature {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// Ensure an account is only deleted over SMS with the code sent to the user.
+func TestServer_TwilioDeleteAccount(t *testing.T) {
+	user := &peapod.User{ID: 100, MobileNumber: "+15555550100"}
+
+	var userService mock.UserService
+	userService.FindUserByMobileNumberFn = func(ctx context.Context, mobileNumber string) (*peapod.User, error) {
+		return user, nil
+	}
+	userService.RequestUserDeletionFn = func(ctx context.Context, id int) (string, error) {
+		return "123456", nil
+	}
+	var deleted bool
+	userService.ConfirmUserDeletionFn = func(ctx context.Context, id int, code string) ([]*peapod.Track, error) {
+		if code != "123456" {
+			return nil, peapod.ErrInvalidDeletionCode
+		}
+		deleted = true
+		return nil, nil
+	}
+
+	var playlistService mock.PlaylistService
+	playlistService.FindPlaylistsByUserIDFn = func(ctx context.Context, id int) ([]*peapod.Playlist, error) {
+		return []*peapod.Playlist{{ID: 1, OwnerID: id}}, nil
+	}
+
+	var smsService mock.SMSService
+	var sms string
+	smsService.SendSMSFn = func(ctx context.Context, msg *peapod.SMS) error {
+		sms = msg.Body
+		return nil
+	}
+
+	s := NewServer()
+	s.Twilio.AccountSID, s.Twilio.AuthToken = "AC0001", "TOKEN"
+	s.UserService = &userService
+	s.PlaylistService = &playlistService
+	s.SMSService = &smsService
+
+	post := func(body string, sign bool) int {
+		form := url.Values{"AccountSid": {"AC0001"}, "From": {user.MobileNumber}, "Body": {body}}
+		r := httptest.NewRequest("POST", "/twilio/sms", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if sign {
+			r.Header.Set(TwilioSignatureHeader, computeTwilioSignature("TOKEN", "/twilio/sms", form))
+		}
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// Unsigned requests are rejected before any command runs.
+	if code := post("CONFIRM 123456", false); code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d", code)
+	} else if deleted {
+		t.Fatal("expected user to remain")
+	}
+
+	// The deletion code is sent to the user & must be confirmed.
+	if code := post("DELETE ACCOUNT", true); code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", code)
+	} else if !strings.Contains(sms, "CONFIRM 123456") {
+		t.Fatalf("unexpected sms: %q", sms)
+	} else if code := post("CONFIRM 000000", true); code != http.StatusOK || deleted {
+		t.Fatalf("unexpected status: %d", code)
+	} else if code := post("CONFIRM 123456", true); code != http.StatusOK || !deleted {
+		t.Fatalf("unexpected status: %d", code)
+	}
+}
+
+// Ensure playlist RSS includes Podcasting 2.0 elements.
+func TestPlaylistRSS_Podcast(t *testing.T) {
+	playlist := newTestPlaylist()
+	playlist.ID = 1
+	playlist.Tracks[0].Author = "AUTHOR"
+	playlist.Tracks[0].TranscriptFilename = "0002.vtt"
+	playlist.Tracks[0].ChaptersFilename = "0003.json"
+
+	var buf bytes.Buffer
+	if err := encodeRSS(&buf, newPlaylistFeed(playlist, url.URL{Scheme: "https", Host: "peapod.io"}, ".rss")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		`xmlns:podcast="https://podcastindex.org/namespace/1.0"`,
+		`<podcast:guid>` + playlistGUID(url.URL{Host: "peapod.io"}, 1) + `</podcast:guid>`,
+		`<podcast:locked>yes</podcast:locked>`,
+		`<podcast:transcript url="https://peapod.io/transcripts/0002.vtt" type="text/vtt"></podcast:transcript>`,
+		`<podcast:chapters url="https://peapod.io/chapters/0003.json" type="application/json+chapters"></podcast:chapters>`,
+		`<podcast:person>AUTHOR</podcast:person>`,
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Fatalf("expected %s in: %s", s, buf.String())
+		}
+	}
+}
+
+// Ensure the playlist GUID is a valid UUIDv5.
+func TestPlaylistGUID(t *testing.T) {
+	if guid := playlistGUID(url.URL{Host: "peapod.io"}, 1); len(guid) != 36 || guid[14] != '5' {
+		t.Fatalf("unexpected guid: %s", guid)
+	}
+}
+
+// Ensure an SMS submission can target a playlist by name & include tags.
+func TestParseSMSSubmission(t *testing.T) {
+	for _, tt := range []struct {
+		body string
+		url  string
+		name string
+		tags []string
+		err  error
+	}{
+		{body: "https://example.com/watch?v=1", url: "https://example.com/watch?v=1"},
+		{body: "Road Trip: https://example.com/a", url: "https://example.com/a", name: "Road Trip"},
+		{body: "https://example.com/a road trip", url: "https://example.com/a", name: "road trip"},
+		{body: "https://example.com/a #News #tech Commute", url: "https://example.com/a", name: "Commute", tags: []string{"news", "tech"}},
+		{body: "hello there", err: ErrInvalidSMSRequestBody},
+	} {
+		u, name, tags, err := parseSMSSubmission(tt.body)
+		if err != tt.err {
+			t.Errorf("%q: unexpected error: %v", tt.body, err)
+		} else if err != nil {
+			continue
+		} else if u.String() != tt.url {
+			t.Errorf("%q: unexpected url: %s", tt.body, u)
+		} else if name != tt.name {
+			t.Errorf("%q: unexpected name: %q", tt.body, name)
+		} else if !reflect.DeepEqual(tags, tt.tags) {
+			t.Errorf("%q: unexpected tags: %v", tt.body, tags)
+		}
+	}
+}
+
+// Ensure SMS commands are matched case-insensitively with their arguments.
+func TestParseSMSCommand(t *testing.T) {
+	for _, tt := range []struct {
+		body string
+		cmd  string
+		args string
+	}{
+		{body: "restore", cmd: SMSCommandRestore},
+		{body: " Find  tomato soup ", cmd: SMSCommandFind, args: "tomato soup"},
+		{body: "undo", cmd: SMSCommandUndo},
+		{body: "undelete", cmd: ""},
+		{body: "https://example.com/a", cmd: ""},
+	} {
+		if cmd, args := parseSMSCommand(tt.body); cmd != tt.cmd || args != tt.args {
+			t.Errorf("%q: unexpected command: %q %q", tt.body, cmd, args)
+		}
+	}
+}
+
+// Ensure liveness is reported without running health checks.
+func TestServer_Healthz(t *testing.T) {
+	s := NewServer()
+	s.HealthCheckers = map[string]peapod.HealthChecker{
+		"db": peapod.HealthCheckerFunc(func(ctx context.Context) (string, error) {
+			t.Fatal("unexpected check")
+			return "", nil
+		}),
+	}
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if body := strings.TrimSpace(w.Body.String()); body != `{"status":"ok"}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+}
+
+// Ensure readiness reports each check and fails if any check fails.
+func TestServer_Readyz(t *testing.T) {
+	var sessionService mock.SessionService
+	sessionService.FindSessionByTokenFn = func(ctx context.Context, token string) (*peapod.Session, error) {
+		return nil, nil
+	}
+
+	s := NewServer()
+	s.SessionService = &sessionService
+	s.MetricsToken = "METRICS"
+	s.AdminToken = "ADMIN"
+	s.HealthCheckers = map[string]peapod.HealthChecker{
+		"db": peapod.HealthCheckerFunc(func(ctx context.Context) (string, error) {
+			return "size=100", nil
+		}),
+		"files": peapod.HealthCheckerFunc(func(ctx context.Context) (string, error) {
+			return "free=10", errors.New("marker")
+		}),
+	}
+
+	// Checks are only included with the metrics or admin token.
+	for _, header := range []string{"Authorization", AdminTokenHeader} {
+		r := httptest.NewRequest("GET", "/readyz", nil)
+		if header == "Authorization" {
+			r.Header.Set(header, "Bearer METRICS")
+		} else {
+			r.Header.Set(header, "ADMIN")
+		}
+
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, r)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("unexpected status: %d", w.Code)
+		}
+
+		var resp HealthResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		} else if resp.Status != HealthStatusFail {
+			t.Fatalf("unexpected status: %s", resp.Status)
+		} else if len(resp.Checks) != 2 {
+			t.Fatalf("unexpected checks: %d", len(resp.Checks))
+		} else if c := resp.Checks[0]; c.Name != "db" || c.Status != HealthStatusOK || c.Message != "size=100" || c.Error != "" {
+			t.Fatalf("unexpected check: %#v", c)
+		} else if c := resp.Checks[1]; c.Name != "files" || c.Status != HealthStatusFail || c.Message != "free=10" || c.Error != "marker" {
+			t.Fatalf("unexpected check: %#v", c)
+		}
+	}
+
+	// Anonymous requests only receive the status.
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if body := strings.TrimSpace(w.Body.String()); body != `{"status":"fail"}` {
+		t.Fatalf("unexpected body: %s", body)
+	}
+
+	// Remove failing check.
+	delete(s.HealthCheckers, "files")
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+}
+
+// Ensure cached checks are only executed once within their TTL.
+func TestServer_Readyz_Cached(t *testing.T) {
+	var n int
+	s := NewServer()
+	s.HealthCheckers = map[string]peapod.HealthChecker{
+		"ffmpeg": peapod.NewCachedHealthChecker(peapod.HealthCheckerFunc(func(ctx context.Context) (string, error) {
+			n++
+			return "ffmpeg 4.0", nil
+		})),
+	}
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status: %d", w.Code)
+		}
+	}
+	if n != 1 {
+		t.Fatalf("unexpected check count: %d", n)
+	}
+}
+
+// Ensure metrics require the token and include request & queue metrics.
+func TestServer_Metrics(t *testing.T) {
+	var jobService mock.JobService
+	jobService.FindJobQueueStatsFn = func(ctx context.Context) (*peapod.JobQueueStats, error) {
+		if !peapod.IsSystemContext(ctx) {
+			t.Fatal("expected system context")
+		}
+		return &peapod.JobQueueStats{Pending: 3, Processing: 1}, nil
+	}
+
+	s := NewServer()
+	s.JobService = &jobService
+	s.MetricsToken = "METRICS"
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	r.Header.Set("Authorization", "Bearer METRICS")
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	} else if body := w.Body.String(); !strings.Contains(body, `peapod_job_queue_jobs{status="pending"} 3`) {
+		t.Fatalf("expected queue size: %s", body)
+	} else if !strings.Contains(body, `peapod_http_request_duration_seconds_count{method="GET",route="/metrics",code="401"} 1`) {
+		t.Fatalf("expected request latency: %s", body)
+	}
+}
+
+// Ensure requests are labeled by matched route & that unknown methods are
+// grouped together.
+func TestServer_Metrics_Labels(t *testing.T) {
+	s := NewServer()
+	for _, req := range []struct{ method, path string }{
+		{"GET", "/playlists/1/members"},
+		{"GET", "/playlists/2/members"},
+		{"GET", "/tracks/3"},
+		{"BREW", "/tracks/3"},
+		{"GET", "/no/such/route"},
+	} {
+		s.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, line := range []string{
+		`peapod_http_request_duration_seconds_count{method="GET",route="/playlists",code="401"} 2`,
+		`peapod_http_request_duration_seconds_count{method="GET",route="/tracks",code="401"} 1`,
+		`peapod_http_request_duration_seconds_count{method="other",route="/tracks",code="405"} 1`,
+		`peapod_http_request_duration_seconds_count{method="GET",route="other",code="404"} 1`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("expected %s: %s", line, body)
+		}
+	}
+	if strings.Contains(body, `method="BREW"`) {
+		t.Errorf("unexpected method label: %s", body)
+	}
+}
+
+// Ensure each request is assigned a correlation id.
+func TestServer_CorrelationID(t *testing.T) {
+	s := NewServer()
+	s.Twilio.AccountSID = "AC0001"
+
+	// Generate an id if one is not sent by the client.
+	r := httptest.NewRequest("GET", "/healthz", nil)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+	if id := w.Header().Get(CorrelationIDHeader); !peapod.IsValidCorrelationID(id) {
+		t.Fatalf("unexpected id: %q", id)
+	}
+
+	// Use a valid id sent by the client.
+	r = httptest.NewRequest("GET", "/healthz", nil)
+	r.Header.Set(CorrelationIDHeader, "abc-123")
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+	if id := w.Header().Get(CorrelationIDHeader); id != "abc-123" {
+		t.Fatalf("unexpected id: %q", id)
+	}
+
+	// Replace an invalid id.
+	r = httptest.NewRequest("GET", "/healthz", nil)
+	r.Header.Set(CorrelationIDHeader, "bad id\n")
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+	if id := w.Header().Get(CorrelationIDHeader); id == "bad id\n" || !peapod.IsValidCorrelationID(id) {
+		t.Fatalf("unexpected id: %q", id)
+	}
+
+	// Use the message sid for Twilio webhooks. It is returned in errors too.
+	r = httptest.NewRequest("POST", "/twilio/sms", strings.NewReader(url.Values{
+		"AccountSid": {"AC9999"},
+		"MessageSid": {"SM0001"},
+	}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+
+	var resp ErrorResponse
+	if id := w.Header().Get(CorrelationIDHeader); id != "SM0001" {
+		t.Fatalf("unexpected id: %q", id)
+	} else if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	} else if resp.Err != ErrTwilioAccountMismatch.Error() || resp.CorrelationID != "SM0001" {
+		t.Fatalf("unexpected response: %#v", resp)
+	}
+}
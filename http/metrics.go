@@ -0,0 +1,135 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/metrics"
+)
+
+// MetricsContentType is the content type of the Prometheus text format.
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// HTTP & queue metrics.
+var (
+	requestDuration = metrics.NewHistogramVec(
+		"peapod_http_request_duration_seconds",
+		"HTTP request latency by method, route & status code.",
+		metrics.DefaultBuckets, "method", "route", "code",
+	)
+	jobQueueSize = metrics.NewGaugeVec(
+		"peapod_job_queue_jobs",
+		"Jobs in the queue by status.",
+		"status",
+	)
+)
+
+// routeLabelKey is the context key for the route label of a request.
+const routeLabelKey contextKey = 1
+
+// labelRoute returns a handler which labels requests with the route pattern
+// that dispatched to h, such as "/playlists", so that requests are labeled by
+// endpoint group rather than by path.
+func labelRoute(pattern string, h http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if label, ok := r.Context().Value(routeLabelKey).(*string); ok {
+			*label = pattern
+		}
+		h.ServeHTTP(w, r)
+	}
+}
+
+// methodLabel returns the request method. Nonstandard methods are labeled as
+// "other" as the method is set by the client.
+func methodLabel(method string) string {
+	switch method {
+	case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS":
+		return method
+	default:
+		return "other"
+	}
+}
+
+// instrumentRequests logs each request and records its latency & status code.
+func (s *Server) instrumentRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Requests which match no route are labeled as "other".
+		route := "other"
+		r = r.WithContext(context.WithValue(r.Context(), routeLabelKey, &route))
+
+		t := time.Now()
+		sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		d := time.Since(t)
+
+		requestDuration.Observe(d.Seconds(), methodLabel(r.Method), route, strconv.Itoa(sw.status))
+		s.Logger.InfoContext(r.Context(), "http request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.status,
+			"duration_ms", float64(d.Microseconds())/1000,
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+// handleMetrics writes all metrics in the Prometheus text format. Requires
+// the metrics token as a bearer token, if one is configured.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.MetricsToken != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.MetricsToken)) != 1 {
+			Error(w, r, peapod.ErrUnauthorized)
+			return
+		}
+	}
+
+	// Update queue size. Stats are unavailable if jobs are only read remotely.
+	if s.JobService != nil {
+		stats, err := s.JobService.FindJobQueueStats(peapod.NewSystemContext(r.Context()))
+		if err != nil {
+			s.Logger.ErrorContext(r.Context(), "job queue stats error", "err", err)
+		} else {
+			jobQueueSize.Set(float64(stats.Pending), peapod.JobStatusPending)
+			jobQueueSize.Set(float64(stats.Processing), peapod.JobStatusProcessing)
+		}
+	}
+
+	w.Header().Set("Content-Type", MetricsContentType)
+	metrics.DefaultRegistry.WriteTo(w)
+}
+
+// statusResponseWriter records the status code written to a response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before writing it.
+func (w *statusResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher if the underlying writer supports it.
+func (w *statusResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the underlying writer supports it.
+func (w *statusResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("hijack not supported")
+}
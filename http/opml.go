@@ -0,0 +1,116 @@
+package http
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/middlemost/peapod"
+	"github.com/pressly/chi"
+)
+
+// OPMLContentType is the content type of OPML documents.
+const OPMLContentType = "text/x-opml"
+
+// opmlHandler represents an HTTP handler for exporting a user's feeds as OPML.
+type opmlHandler struct {
+	router chi.Router
+
+	baseURL url.URL
+	secret  string
+
+	// Services
+	playlistService peapod.PlaylistService
+	userService     peapod.UserService
+}
+
+// newOPMLHandler returns a new instance of opmlHandler.
+func newOPMLHandler() *opmlHandler {
+	h := &opmlHandler{router: chi.NewRouter()}
+	h.router.Get("/", h.handleGet)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *opmlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h *opmlHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Authenticate user from the signed link.
+	userID, err := verifySignedURL(r.URL, h.secret, time.Now())
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	// Lookup user. The signed link authorizes the lookup.
+	user, err := h.userService.FindUserByID(peapod.NewSystemContext(ctx), userID)
+	if err != nil {
+		Error(w, r, err)
+		return
+	} else if user == nil {
+		Error(w, r, peapod.ErrUserNotFound)
+		return
+	} else if user.Disabled {
+		Error(w, r, peapod.ErrUserDisabled)
+		return
+	}
+	ctx = peapod.NewContext(ctx, user)
+
+	// Fetch user playlists.
+	playlists, err := h.playlistService.FindPlaylistsByUserID(ctx, user.ID)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	// Build an outline for each playlist feed.
+	doc := opml{
+		Version: "2.0",
+		Head: headOPML{
+			Title:       "Peapod Feeds",
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+		Outlines: make([]outlineOPML, len(playlists)),
+	}
+	for i, playlist := range playlists {
+		feedURL := playlistFeedURL(h.baseURL, playlist.Token, ".rss")
+		doc.Outlines[i] = outlineOPML{
+			Type:   "rss",
+			Text:   playlist.Name,
+			Title:  playlist.Name,
+			XMLURL: feedURL.String(),
+		}
+	}
+
+	w.Header().Set("Content-Type", OPMLContentType)
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(doc); err != nil {
+		Error(w, r, err)
+		return
+	}
+}
+
+// opml represents an OPML 2.0 document.
+type opml struct {
+	XMLName  xml.Name      `xml:"opml"`
+	Version  string        `xml:"version,attr"`
+	Head     headOPML      `xml:"head"`
+	Outlines []outlineOPML `xml:"body>outline"`
+}
+
+type headOPML struct {
+	Title       string `xml:"title"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type outlineOPML struct {
+	Type   string `xml:"type,attr"`
+	Text   string `xml:"text,attr"`
+	Title  string `xml:"title,attr,omitempty"`
+	XMLURL string `xml:"xmlUrl,attr"`
+}
@@ -1,31 +1,69 @@
 package http
 
 import (
-	"encoding/xml"
-	"fmt"
-	"mime"
+	"container/list"
+	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/url"
 	"path"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/middlemost/peapod"
 	"github.com/pressly/chi"
 )
 
+const (
+	ErrInvalidPlaylistID       = peapod.Error("invalid playlist id")
+	ErrInvalidPlaylistInviteID = peapod.Error("invalid playlist invite id")
+	ErrInvalidSmartFilter      = peapod.Error("invalid smart filter")
+	ErrInvalidRetention        = peapod.Error("invalid retention")
+)
+
 // playlistHandler represents an HTTP handler for playlists.
 type playlistHandler struct {
 	router chi.Router
 
 	baseURL         url.URL
+	region          string
+	mergedCache     *mergedPlaylistCache
 	playlistService peapod.PlaylistService
+	trackService    peapod.TrackService
+	userService     peapod.UserService
 }
 
 // newPlaylistHandler returns a new instance of playlistHandler.
 func newPlaylistHandler() *playlistHandler {
-	h := &playlistHandler{router: chi.NewRouter()}
+	h := &playlistHandler{
+		router:      chi.NewRouter(),
+		mergedCache: newMergedPlaylistCache(),
+	}
+	h.router.Post("/", h.handlePost)
+	h.router.Get("/feeds", h.handleGetFeeds)
+	h.router.Get("/invites", h.handleGetInvites)
+	h.router.Post("/invites/:id/accept", h.handlePostInviteAccept)
+	h.router.Get("/:token", h.handleGet)
+	h.router.Get("/:id/info", h.handleGetInfo)
+	h.router.Post("/:id/token", h.handlePostToken)
+	h.router.Get("/:id/members", h.handleGetMembers)
+	h.router.Put("/:id/retention", h.handlePutRetention)
+	h.router.Get("/:id/removals", h.handleGetRemovals)
+	h.router.Post("/:id/invites", h.handlePostInvite)
+	return h
+}
+
+// newPlaylistFeedHandler returns a new instance of playlistHandler which only
+// serves playlist feeds by token.
+func newPlaylistFeedHandler() *playlistHandler {
+	h := &playlistHandler{
+		router:      chi.NewRouter(),
+		mergedCache: newMergedPlaylistCache(),
+	}
 	h.router.Get("/:token", h.handleGet)
 	return h
 }
@@ -37,128 +75,620 @@ func (h *playlistHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (h *playlistHandler) handleGet(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	token := strings.TrimSuffix(chi.URLParam(r, "token"), ".rss")
+	token := chi.URLParam(r, "token")
+	ext := path.Ext(token)
+	token = strings.TrimSuffix(token, ext)
 
 	// Fetch playlist by token.
 	playlist, err := h.playlistService.FindPlaylistByToken(ctx, token)
 
+	// Redirect to the current feed URL if a rotated token was used.
+	if err == nil && playlist.Token != token {
+		u := playlistFeedURL(h.baseURL, playlist.Token, ext)
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+		return
+	}
+
+	// Generate tracks for merged playlists from their sources.
+	if err == nil && playlist.Type == peapod.PlaylistTypeMerged {
+		playlist.Tracks, err = h.mergedCache.tracks(ctx, h.playlistService, h.userService, playlist)
+	}
+
 	// Reverse track order.
 	if playlist != nil {
 		sort.Slice(playlist.Tracks, func(i, j int) bool { return i >= j })
 	}
 
-	// Encode response.
-	switch {
-	case strings.Contains(r.Header.Get("Accept"), "text/xml"):
+	// Determine the encoder for the requested feed format.
+	var contentType string
+	var encode func(io.Writer, *feed) error
+	switch accept := r.Header.Get("Accept"); {
+	case strings.Contains(accept, AtomContentType):
+		contentType, encode = AtomContentType, encodeAtom
+	case strings.Contains(accept, JSONFeedContentType), strings.Contains(accept, "application/json"):
+		contentType, encode = JSONFeedContentType, encodeJSONFeed
+	case strings.Contains(accept, RSSContentType), strings.Contains(accept, "text/xml"):
+		contentType, encode = "text/xml", encodeRSS
+	default:
+		Error(w, r, ErrNotAcceptable)
+		return
+	}
+
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	// Convert playlist to a feed & encode.
+	w.Header().Set("Content-Type", contentType)
+	if err := encode(w, newPlaylistFeed(playlist, h.baseURL, ext)); err != nil {
+		Error(w, r, err)
+		return
+	}
+}
+
+// handlePost creates a new playlist for the current user.
+func (h *playlistHandler) handlePost(w http.ResponseWriter, r *http.Request) {
+	var req PostPlaylistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ErrInvalidJSON)
+		return
+	}
+
+	// Convert request to a playlist.
+	playlist := &peapod.Playlist{Name: req.Name, Type: req.Type, SourceIDs: req.SourceIDs}
+	if req.Filter != nil {
+		filter, err := req.Filter.smartFilter()
 		if err != nil {
 			Error(w, r, err)
 			return
 		}
+		playlist.Filter = filter
+	}
+
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if err := h.playlistService.CreatePlaylist(ctx, playlist); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	feedURL := playlistFeedURL(h.baseURL, playlist.Token, ".rss")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&PostPlaylistResponse{
+		Playlist: playlist,
+		FeedURL:  feedURL.String(),
+	})
+}
+
+// PostPlaylistRequest is the body of a request to create a playlist.
+type PostPlaylistRequest struct {
+	Name      string              `json:"name"`
+	Type      string              `json:"type"`
+	Filter    *SmartFilterRequest `json:"filter"`
+	SourceIDs []int               `json:"source_ids"`
+}
+
+// SmartFilterRequest represents a smart playlist filter with durations
+// specified as strings (e.g. "168h", "20m").
+type SmartFilterRequest struct {
+	Tags        []string `json:"tags"`
+	MaxAge      string   `json:"max_age"`
+	MinDuration string   `json:"min_duration"`
+	MaxDuration string   `json:"max_duration"`
+}
+
+// NewSmartFilterRequest returns a request for filter. Returns nil if filter is nil.
+func NewSmartFilterRequest(filter *peapod.SmartFilter) *SmartFilterRequest {
+	if filter == nil {
+		return nil
+	}
+	return &SmartFilterRequest{
+		Tags:        filter.Tags,
+		MaxAge:      formatRequestDuration(filter.MaxAge),
+		MinDuration: formatRequestDuration(filter.MinDuration),
+		MaxDuration: formatRequestDuration(filter.MaxDuration),
+	}
+}
 
-		// Determine logo image.
-		imageURL := h.baseURL
-		imageURL.Path = "/assets/logo-1024x1024.png"
-
-		// Convert playlist to RSS feed.
-		rss := playlistRSS{
-			Channel: channelRSS{
-				Title:       playlist.Name,
-				Description: cdata{"Your personal podcast."},
-				Summary:     cdata{"Your personal podcast."},
-				Image:       imageRSS{Href: imageURL.String()},
-				Items:       make([]itemRSS, len(playlist.Tracks)),
-			},
+// formatRequestDuration formats d for a request. Zero durations are blank.
+func formatRequestDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
+
+// smartFilter converts the request into a filter.
+func (req *SmartFilterRequest) smartFilter() (*peapod.SmartFilter, error) {
+	f := &peapod.SmartFilter{Tags: req.Tags}
+	for _, v := range []struct {
+		s string
+		d *time.Duration
+	}{
+		{req.MaxAge, &f.MaxAge},
+		{req.MinDuration, &f.MinDuration},
+		{req.MaxDuration, &f.MaxDuration},
+	} {
+		if v.s == "" {
+			continue
 		}
-		if t := playlist.LastTrackUpdatedAt(); !t.IsZero() {
-			rss.Channel.LastBuildDate = t.Format(time.RFC1123Z)
+		d, err := time.ParseDuration(v.s)
+		if err != nil {
+			return nil, ErrInvalidSmartFilter
 		}
+		*v.d = d
+	}
+	return f, nil
+}
+
+// PostPlaylistResponse is the body of a response to creating a playlist.
+type PostPlaylistResponse struct {
+	Playlist *peapod.Playlist `json:"playlist"`
+	FeedURL  string           `json:"feed_url"`
+}
+
+// DefaultMergedPlaylistCacheSize is the default number of merged playlists
+// whose generated tracks are cached.
+const DefaultMergedPlaylistCacheSize = 1000
+
+// mergedPlaylistCache holds generated tracks for merged playlists. Entries
+// are invalidated when the merged playlist's timestamp changes, which occurs
+// whenever one of its source playlists changes. The least recently used
+// entries are evicted once the cache is full.
+type mergedPlaylistCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[int]*list.Element
+	lru     *list.List
+}
+
+type mergedPlaylistCacheEntry struct {
+	playlistID int
+	updatedAt  time.Time
+	tracks     []*peapod.Track
+}
+
+// newMergedPlaylistCache returns a new instance of mergedPlaylistCache.
+func newMergedPlaylistCache() *mergedPlaylistCache {
+	return &mergedPlaylistCache{
+		size:    DefaultMergedPlaylistCacheSize,
+		entries: make(map[int]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// tracks returns the interleaved tracks of the merged playlist's sources.
+func (c *mergedPlaylistCache) tracks(ctx context.Context, playlistService peapod.PlaylistService, userService peapod.UserService, playlist *peapod.Playlist) ([]*peapod.Track, error) {
+	if tracks := c.get(playlist); tracks != nil {
+		return tracks, nil
+	}
+
+	tracks, err := generateMergedPlaylistTracks(ctx, playlistService, userService, playlist)
+	if err != nil {
+		c.remove(playlist.ID)
+		return nil, err
+	}
+	c.put(playlist, tracks)
+
+	return append([]*peapod.Track(nil), tracks...), nil
+}
+
+// get returns a copy of the cached tracks for playlist, if still current.
+func (c *mergedPlaylistCache) get(playlist *peapod.Playlist) []*peapod.Track {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem := c.entries[playlist.ID]
+	if elem == nil {
+		return nil
+	}
+	entry := elem.Value.(*mergedPlaylistCacheEntry)
+	if !entry.updatedAt.Equal(playlist.UpdatedAt) {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return append([]*peapod.Track{}, entry.tracks...)
+}
+
+// put caches the tracks for playlist & evicts the least recently used entries.
+func (c *mergedPlaylistCache) put(playlist *peapod.Playlist, tracks []*peapod.Track) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &mergedPlaylistCacheEntry{playlistID: playlist.ID, updatedAt: playlist.UpdatedAt, tracks: tracks}
+	if elem := c.entries[playlist.ID]; elem != nil {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[playlist.ID] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.size {
+		elem := c.lru.Back()
+		c.lru.Remove(elem)
+		delete(c.entries, elem.Value.(*mergedPlaylistCacheEntry).playlistID)
+	}
+}
+
+// remove evicts the entry for a playlist, if any.
+func (c *mergedPlaylistCache) remove(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem := c.entries[id]; elem != nil {
+		c.lru.Remove(elem)
+		delete(c.entries, id)
+	}
+}
 
-		// Conver tracks to RSS.
-		for i, track := range playlist.Tracks {
-			enclosureURL := h.baseURL
-			enclosureURL.Path = fmt.Sprintf("/files/%s", track.Filename)
-
-			rss.Channel.Items[i] = itemRSS{
-				Title:       track.Title,
-				Description: cdata{track.Description},
-				Summary:     cdata{track.Description},
-				PubDate:     track.CreatedAt.Format(time.RFC1123Z),
-				Duration:    formatDuration(track.Duration),
-				Enclosure: enclosureRSS{
-					URL:    enclosureURL.String(),
-					Type:   mime.TypeByExtension(path.Ext(track.Filename)),
-					Length: track.Size,
-				},
-			}
+// generateMergedPlaylistTracks fetches & interleaves the tracks of a merged
+// playlist's sources. Sources are looked up as the merged playlist's owner so
+// sources the owner can no longer access are left out of the feed.
+func generateMergedPlaylistTracks(ctx context.Context, playlistService peapod.PlaylistService, userService peapod.UserService, playlist *peapod.Playlist) ([]*peapod.Track, error) {
+	owner, err := userService.FindUserByID(peapod.NewSystemContext(ctx), playlist.OwnerID)
+	if err != nil {
+		return nil, err
+	} else if owner == nil {
+		return nil, peapod.ErrPlaylistNotFound
+	} else if owner.Disabled {
+		return nil, peapod.ErrUserDisabled
+	}
+	ctx = peapod.NewContext(ctx, owner)
+
+	sources := make([]*peapod.Playlist, 0, len(playlist.SourceIDs))
+	for _, id := range playlist.SourceIDs {
+		source, err := playlistService.FindPlaylistByID(ctx, id)
+		if err == peapod.ErrUnauthorized || err == peapod.ErrPlaylistNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		} else if source == nil {
+			continue
 		}
+		sources = append(sources, source)
+	}
+	return peapod.MergeTracks(sources...), nil
+}
 
-		w.Header().Set("Content-Type", "text/xml")
-		if err := xml.NewEncoder(w).EncodeElement(
-			rss,
-			xml.StartElement{
-				Name: xml.Name{Local: "rss"},
-				Attr: []xml.Attr{
-					{Name: xml.Name{Local: "xmlns:itunes"}, Value: "http://www.itunes.com/dtds/podcast-1.0.dtd"},
-					{Name: xml.Name{Local: "xmlns:atom"}, Value: "http://www.w3.org/2005/Atom"},
-					{Name: xml.Name{Local: "version"}, Value: "2.0"},
-				},
-			},
-		); err != nil {
-			Error(w, r, err)
+// handleGetInfo returns a playlist along with its tracks. Unlike the feed,
+// the playlist is looked up by id & requires a role on the playlist.
+func (h *playlistHandler) handleGetInfo(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidPlaylistID)
+		return
+	}
+
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	playlist, err := h.playlistService.FindPlaylistByID(ctx, id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	} else if playlist == nil {
+		Error(w, r, peapod.ErrPlaylistNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(playlist)
+}
+
+// handlePostToken rotates the playlist's token and returns the new feed URL.
+func (h *playlistHandler) handlePostToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidPlaylistID)
+		return
+	}
+
+	// Lookup user.
+	ctx, err = authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	// Generate new token.
+	playlist, err := h.playlistService.RotateToken(ctx, id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	feedURL := playlistFeedURL(h.baseURL, playlist.Token, ".rss")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&PostTokenResponse{
+		Token:    playlist.Token,
+		FeedURL:  feedURL.String(),
+		Playlist: playlist,
+	})
+}
+
+// PostTokenResponse is the body of a response to rotating a playlist token.
+type PostTokenResponse struct {
+	Token    string           `json:"token"`
+	FeedURL  string           `json:"feed_url"`
+	Playlist *peapod.Playlist `json:"playlist"`
+}
+
+// handlePutRetention sets the retention limits for a playlist.
+func (h *playlistHandler) handlePutRetention(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidPlaylistID)
+		return
+	}
+
+	var req PutRetentionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ErrInvalidJSON)
+		return
+	}
+
+	retention := &peapod.Retention{MaxTracks: req.MaxTracks, MaxBytes: req.MaxBytes}
+	if req.MaxAge != "" {
+		if retention.MaxAge, err = time.ParseDuration(req.MaxAge); err != nil {
+			Error(w, r, ErrInvalidRetention)
 			return
 		}
+	}
 
-	default:
-		Error(w, r, ErrNotAcceptable)
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if err := h.playlistService.SetPlaylistRetention(ctx, id, retention); err != nil {
+		Error(w, r, err)
+		return
 	}
+
+	w.WriteHeader(http.StatusOK)
 }
 
-// playlistRSS represents an RSS feed for a playlist.
-type playlistRSS struct {
-	Channel channelRSS `xml:"channel"`
+// PutRetentionRequest is the body of a request to set retention limits.
+type PutRetentionRequest struct {
+	MaxAge    string `json:"max_age"`
+	MaxTracks int    `json:"max_tracks"`
+	MaxBytes  int    `json:"max_bytes"`
 }
 
-type channelRSS struct {
-	Title         string    `xml:"title"`
-	Description   cdata     `xml:"description"`
-	Summary       cdata     `xml:"itunes:summary"`
-	Image         imageRSS  `xml:"itunes:image"`
-	LastBuildDate string    `xml:"lastBuildDate"`
-	Items         []itemRSS `xml:"item"`
+// NewPutRetentionRequest returns a request for retention. A nil retention
+// returns an empty request which removes the limits.
+func NewPutRetentionRequest(retention *peapod.Retention) *PutRetentionRequest {
+	if retention == nil {
+		return &PutRetentionRequest{}
+	}
+	return &PutRetentionRequest{
+		MaxAge:    formatRequestDuration(retention.MaxAge),
+		MaxTracks: retention.MaxTracks,
+		MaxBytes:  retention.MaxBytes,
+	}
 }
 
-type imageRSS struct {
-	Href string `xml:"href,attr"`
+// handleGetRemovals returns the history of tracks removed from a playlist.
+func (h *playlistHandler) handleGetRemovals(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidPlaylistID)
+		return
+	}
+
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	// Only members can view the removal history.
+	members, err := h.playlistService.FindPlaylistMembers(ctx, id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	} else if !isPlaylistMember(members, peapod.FromContext(ctx).ID) {
+		Error(w, r, peapod.ErrUnauthorized)
+		return
+	}
+
+	removals, err := h.trackService.FindTrackRemovals(ctx, id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&GetRemovalsResponse{Removals: removals})
 }
 
-type itemRSS struct {
-	Title       string       `xml:"title"`
-	Description cdata        `xml:"description"`
-	Summary     cdata        `xml:"itunes:summary"`
-	Link        string       `xml:"link"`
-	PubDate     string       `xml:"pubDate"`
-	Duration    string       `xml:"itunes:duration,omitempty"`
-	Enclosure   enclosureRSS `xml:"enclosure"`
+// GetRemovalsResponse is the body of a response listing track removals.
+type GetRemovalsResponse struct {
+	Removals []*peapod.TrackRemoval `json:"removals"`
 }
 
-type enclosureRSS struct {
-	URL    string `xml:"url,attr"`
-	Type   string `xml:"type,attr"`
-	Length int    `xml:"length,attr"`
+// isPlaylistMember returns true if userID is in members.
+func isPlaylistMember(members []*peapod.PlaylistMember, userID int) bool {
+	for _, m := range members {
+		if m.UserID == userID {
+			return true
+		}
+	}
+	return false
 }
 
-type cdata struct {
-	Value string `xml:",cdata"`
+// handleGetMembers returns the members of a playlist.
+func (h *playlistHandler) handleGetMembers(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidPlaylistID)
+		return
+	}
+
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	members, err := h.playlistService.FindPlaylistMembers(ctx, id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&GetMembersResponse{Members: members})
 }
 
-// formatDuration formats d in HH:MM:SS format.
-func formatDuration(d time.Duration) string {
-	if d == 0 {
-		return ""
+// GetMembersResponse is the body of a response listing playlist members.
+type GetMembersResponse struct {
+	Members []*peapod.PlaylistMember `json:"members"`
+}
+
+// handlePostInvite invites a mobile number to join a playlist.
+func (h *playlistHandler) handlePostInvite(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidPlaylistID)
+		return
+	}
+
+	var req PostInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ErrInvalidJSON)
+		return
+	}
+
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if req.MobileNumber != "" {
+		if req.MobileNumber, err = peapod.NormalizeMobileNumber(req.MobileNumber, h.region); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+
+	invite := &peapod.PlaylistInvite{
+		PlaylistID:   id,
+		MobileNumber: req.MobileNumber,
+		Role:         req.Role,
+	}
+	if invite.Role == "" {
+		invite.Role = peapod.PlaylistRoleContributor
+	}
+	if err := h.playlistService.CreatePlaylistInvite(ctx, invite); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invite)
+}
+
+// PostInviteRequest is the body of a request to invite a mobile number.
+type PostInviteRequest struct {
+	MobileNumber string `json:"mobile_number"`
+	Role         string `json:"role"`
+}
+
+// handleGetFeeds returns the feed URL of each of the current user's playlists.
+func (h *playlistHandler) handleGetFeeds(w http.ResponseWriter, r *http.Request) {
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	playlists, err := h.playlistService.FindPlaylistsByUserID(ctx, peapod.FromContext(ctx).ID)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	feeds := make([]*PlaylistFeed, len(playlists))
+	for i, playlist := range playlists {
+		feedURL := playlistFeedURL(h.baseURL, playlist.Token, ".rss")
+		feeds[i] = &PlaylistFeed{
+			PlaylistID: playlist.ID,
+			Name:       playlist.Name,
+			Type:       playlist.Type,
+			URL:        feedURL.String(),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feeds)
+}
+
+// PlaylistFeed represents the feed URL of a playlist.
+type PlaylistFeed struct {
+	PlaylistID int    `json:"playlist_id"`
+	Name       string `json:"name"`
+	Type       string `json:"type,omitempty"`
+	URL        string `json:"url"`
+}
+
+// handleGetInvites returns the pending invites for the current user.
+func (h *playlistHandler) handleGetInvites(w http.ResponseWriter, r *http.Request) {
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	invites, err := h.playlistService.FindPlaylistInvitesByMobileNumber(ctx, peapod.FromContext(ctx).MobileNumber)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&GetInvitesResponse{Invites: invites})
+}
+
+// GetInvitesResponse is the body of a response listing playlist invites.
+type GetInvitesResponse struct {
+	Invites []*peapod.PlaylistInvite `json:"invites"`
+}
+
+// handlePostInviteAccept accepts an invite for the current user.
+func (h *playlistHandler) handlePostInviteAccept(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidPlaylistInviteID)
+		return
+	}
+
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	member, err := h.playlistService.AcceptPlaylistInvite(ctx, id)
+	if err != nil {
+		Error(w, r, err)
+		return
 	}
 
-	s := (d / time.Second) % 60
-	m := (d / time.Minute) % 60
-	h := d / time.Hour
-	return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
 }
@@ -1,12 +1,14 @@
 package http
 
 import (
-	"io"
-	"io/ioutil"
+	"context"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
+	"time"
 
 	"github.com/middlemost/peapod"
 	"github.com/pressly/chi"
@@ -14,37 +16,61 @@ import (
 	"golang.org/x/crypto/acme/autocert"
 )
 
+// CorrelationIDHeader is the header used to send & return the correlation id
+// which links a request with the logs & jobs which result from it.
+const CorrelationIDHeader = peapod.CorrelationIDHeader
+
 // Server represents an HTTP server.
 type Server struct {
-	ln net.Listener
+	ln     net.Listener
+	server *http.Server
+
+	// Generated merged playlist tracks, shared by playlist handlers.
+	mergedCache *mergedPlaylistCache
 
 	// Services
 	FileService     peapod.FileService
 	JobService      peapod.JobService
 	PlaylistService peapod.PlaylistService
+	SessionService  peapod.SessionService
 	SMSService      peapod.SMSService
 	TrackService    peapod.TrackService
 	UserService     peapod.UserService
 
+	// Components verified by the readiness check, keyed by name.
+	HealthCheckers     map[string]peapod.HealthChecker
+	HealthCheckTimeout time.Duration
+
 	// Server options.
 	Addr        string // bind address
 	Host        string // external hostname
 	Autocert    bool   // ACME autocert
 	Recoverable bool   // panic recovery
+	Secret      string // secret used to sign links
+	Region      string // default region for mobile numbers
+	AdminToken  string // token granting admin access, disabled if blank
+	WorkerToken string // token granting remote worker access, disabled if blank
+
+	// Bearer token required to read metrics. Metrics are public if blank.
+	MetricsToken string
 
 	// Twilio specific options.
 	Twilio struct {
 		AccountSID string // twilio account number
+		AuthToken  string // used to verify webhook signatures
 	}
 
-	LogOutput io.Writer
+	Logger *slog.Logger
 }
 
 // NewServer returns a new instance of Server.
 func NewServer() *Server {
 	return &Server{
-		Recoverable: true,
-		LogOutput:   ioutil.Discard,
+		mergedCache:        newMergedPlaylistCache(),
+		HealthCheckTimeout: DefaultHealthCheckTimeout,
+		Recoverable:        true,
+		Region:             peapod.DefaultRegion,
+		Logger:             peapod.DiscardLogger(),
 	}
 }
 
@@ -63,15 +89,26 @@ func (s *Server) Open() error {
 	}
 
 	// Start HTTP server.
-	go http.Serve(s.ln, s.router())
+	s.server = &http.Server{Handler: s.router()}
+	go s.server.Serve(s.ln)
 
 	return nil
 }
 
-// Close closes the socket.
+// Close closes the socket and all active connections immediately.
 func (s *Server) Close() error {
-	if s.ln != nil {
-		s.ln.Close()
+	if s.server != nil {
+		return s.server.Close()
+	}
+	return nil
+}
+
+// Shutdown closes the socket and waits for active requests to complete.
+// If ctx is done first then ctx's error is returned and connections are left
+// open. Close can then be used to close them.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.server != nil {
+		return s.server.Shutdown(ctx)
 	}
 	return nil
 }
@@ -89,43 +126,91 @@ func (s *Server) URL() url.URL {
 	return url.URL{Scheme: "http", Host: s.ln.Addr().String()}
 }
 
+// Handler returns the server's routes without opening a listener. This
+// allows the API to be served by another server, such as in tests.
+func (s *Server) Handler() http.Handler {
+	return s.router()
+}
+
 func (s *Server) router() http.Handler {
 	r := chi.NewRouter()
 
 	// Attach router middleware.
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(s.attachCorrelationID)
+	r.Use(s.attachLoggerToContext)
+	r.Use(s.instrumentRequests)
 	if s.Recoverable {
 		r.Use(middleware.Recoverer)
 	}
 	// r.Mount("/debug", middleware.Profiler())
-	r.Use(s.attachLogOutputToContext)
 	r.Use(s.detectAccept)
+	r.Use(s.attachSessionUserToContext)
 
-	// Create API routes.
+	// Create API routes. Requests are labeled by route for metrics.
 	r.Route("/", func(r chi.Router) {
 		r.Use(middleware.DefaultCompress)
-		r.Get("/ping", s.handlePing)
-		r.Mount("/assets", newAssetHandler())
-		r.Mount("/p", s.playlistHandler()) // alias
-		r.Mount("/playlists", s.playlistHandler())
-		r.Mount("/files", s.fileHandler())
-		r.Mount("/tracks", s.trackHandler())
-		r.Mount("/twilio", s.twilioHandler())
+		get := func(pattern string, fn http.HandlerFunc) { r.Get(pattern, labelRoute(pattern, fn)) }
+		mount := func(pattern string, h http.Handler) { r.Mount(pattern, labelRoute(pattern, h)) }
+
+		get("/ping", s.handleHealthz) // alias
+		get("/healthz", s.handleHealthz)
+		get("/readyz", s.handleReadyz)
+		get("/metrics", s.handleMetrics)
+		mount("/assets", newAssetHandler())
+		mount("/p", s.playlistFeedHandler()) // feed alias
+		mount("/playlists.opml", s.opmlHandler())
+		mount("/playlists", s.playlistHandler())
+		mount("/files", s.fileHandler())
+		mount("/transcripts", s.fileHandler())
+		mount("/chapters", s.chaptersHandler())
+		mount("/tracks", s.trackHandler())
+		mount("/users", s.userHandler())
+		mount("/sessions", s.sessionHandler())
+		mount("/twilio", s.twilioHandler())
+		mount("/admin", s.adminHandler())
+		mount("/worker", s.workerHandler())
 	})
 
 	return r
 }
 
-// handlePing verifies the database connection and returns a success.
-func (s *Server) handlePing(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte(`{"status:":"ok"}` + "\n"))
+// authenticate returns the request context if it carries a user attached by
+// a valid session. Disabled users are rejected.
+func authenticate(r *http.Request) (context.Context, error) {
+	u := peapod.FromContext(r.Context())
+	if u == nil {
+		return nil, peapod.ErrUnauthorized
+	} else if u.Disabled {
+		return nil, peapod.ErrUserDisabled
+	}
+	return r.Context(), nil
 }
 
 func (s *Server) playlistHandler() *playlistHandler {
-	h := newPlaylistHandler()
+	return s.initPlaylistHandler(newPlaylistHandler())
+}
+
+func (s *Server) playlistFeedHandler() *playlistHandler {
+	return s.initPlaylistHandler(newPlaylistFeedHandler())
+}
+
+func (s *Server) initPlaylistHandler(h *playlistHandler) *playlistHandler {
 	h.baseURL = s.URL()
+	h.region = s.Region
+	h.mergedCache = s.mergedCache
 	h.playlistService = s.PlaylistService
+	h.trackService = s.TrackService
+	h.userService = s.UserService
+	return h
+}
+
+func (s *Server) opmlHandler() *opmlHandler {
+	h := newOPMLHandler()
+	h.baseURL = s.URL()
+	h.secret = s.Secret
+	h.playlistService = s.PlaylistService
+	h.userService = s.UserService
 	return h
 }
 
@@ -135,6 +220,12 @@ func (s *Server) fileHandler() *fileHandler {
 	return h
 }
 
+func (s *Server) chaptersHandler() *fileHandler {
+	h := s.fileHandler()
+	h.contentType = ChaptersContentType
+	return h
+}
+
 func (s *Server) trackHandler() *trackHandler {
 	h := newTrackHandler()
 	h.jobService = s.JobService
@@ -144,10 +235,55 @@ func (s *Server) trackHandler() *trackHandler {
 	return h
 }
 
+func (s *Server) userHandler() *userHandler {
+	h := newUserHandler()
+	h.region = s.Region
+	h.fileService = s.FileService
+	h.playlistService = s.PlaylistService
+	h.smsService = s.SMSService
+	h.userService = s.UserService
+	return h
+}
+
+func (s *Server) sessionHandler() *sessionHandler {
+	h := newSessionHandler()
+	h.region = s.Region
+	h.secureCookie = s.Autocert
+	h.sessionService = s.SessionService
+	h.smsService = s.SMSService
+	return h
+}
+
+func (s *Server) adminHandler() *adminHandler {
+	h := newAdminHandler()
+	h.token = s.AdminToken
+	h.fileService = s.FileService
+	h.jobService = s.JobService
+	h.playlistService = s.PlaylistService
+	h.trackService = s.TrackService
+	h.userService = s.UserService
+	return h
+}
+
+func (s *Server) workerHandler() *workerHandler {
+	h := newWorkerHandler()
+	h.token = s.WorkerToken
+	h.fileService = s.FileService
+	h.jobService = s.JobService
+	h.playlistService = s.PlaylistService
+	h.trackService = s.TrackService
+	h.userService = s.UserService
+	return h
+}
+
 func (s *Server) twilioHandler() *twilioHandler {
 	h := newTwilioHandler()
 	h.baseURL = s.URL()
 	h.accountSID = s.Twilio.AccountSID
+	h.authToken = s.Twilio.AuthToken
+	h.secret = s.Secret
+	h.region = s.Region
+	h.fileService = s.FileService
 	h.jobService = s.JobService
 	h.playlistService = s.PlaylistService
 	h.smsService = s.SMSService
@@ -156,9 +292,54 @@ func (s *Server) twilioHandler() *twilioHandler {
 	return h
 }
 
-func (s *Server) attachLogOutputToContext(next http.Handler) http.Handler {
+// attachSessionUserToContext adds the user of a valid session to the request
+// context. Requests without a valid session are passed through unchanged so
+// that other bearer tokens, such as the metrics token, can be used. Routes
+// which require a user reject them in authenticate.
+func (s *Server) attachSessionUserToContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := sessionToken(r)
+		if token == "" || s.SessionService == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, err := s.SessionService.FindSessionByToken(r.Context(), token)
+		if err != nil {
+			Error(w, r, err)
+			return
+		} else if session == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(peapod.NewContext(r.Context(), session.User)))
+	})
+}
+
+// attachCorrelationID adds a correlation id to the request context and
+// returns it in the response header. Twilio webhooks use the message sid so
+// they can be matched with Twilio's logs. Other requests use the id sent by
+// the client, such as a worker executing a job, or a generated id.
+func (s *Server) attachCorrelationID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(CorrelationIDHeader)
+		if strings.HasPrefix(r.URL.Path, "/twilio/") {
+			if sid := r.PostFormValue("MessageSid"); sid != "" {
+				id = sid
+			}
+		}
+		if !peapod.IsValidCorrelationID(id) {
+			id = peapod.GenerateCorrelationID()
+		}
+
+		w.Header().Set(CorrelationIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(peapod.NewCorrelationContext(r.Context(), id)))
+	})
+}
+
+func (s *Server) attachLoggerToContext(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), s.LogOutput)))
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), s.Logger)))
 	})
 }
 
@@ -169,6 +350,10 @@ func (s *Server) detectAccept(next http.Handler) http.Handler {
 			r.Header.Set("Accept", "application/json")
 		case ".rss":
 			r.Header.Set("Accept", "text/xml")
+		case ".atom":
+			r.Header.Set("Accept", AtomContentType)
+		case ".opml":
+			r.Header.Set("Accept", OPMLContentType)
 		}
 
 		next.ServeHTTP(w, r)
@@ -0,0 +1,161 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/middlemost/peapod"
+	"github.com/pressly/chi"
+)
+
+// SessionCookieName is the name of the cookie which holds the session token.
+const SessionCookieName = "peapod_session"
+
+// sessionHandler represents an HTTP handler for passwordless login.
+type sessionHandler struct {
+	router chi.Router
+
+	// Default region for mobile numbers without a country code.
+	region string
+
+	// If true, session cookies are only sent over HTTPS.
+	secureCookie bool
+
+	// Services
+	sessionService peapod.SessionService
+	smsService     peapod.SMSService
+}
+
+// newSessionHandler returns a new instance of sessionHandler.
+func newSessionHandler() *sessionHandler {
+	h := &sessionHandler{router: chi.NewRouter()}
+	h.router.Post("/code", h.handlePostCode)
+	h.router.Post("/", h.handlePost)
+	h.router.Delete("/current", h.handleDeleteCurrent)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *sessionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+// handlePostCode sends a login code to a mobile number.
+func (h *sessionHandler) handlePostCode(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req PostCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ErrInvalidJSON)
+		return
+	}
+
+	mobileNumber, err := peapod.NormalizeMobileNumber(req.MobileNumber, h.region)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	code, err := h.sessionService.CreateLoginCode(ctx, mobileNumber)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if err := h.smsService.SendSMS(ctx, &peapod.SMS{
+		To:   mobileNumber,
+		Body: fmt.Sprintf("Your Peapod login code is %s", code),
+	}); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// PostCodeRequest is the body of a request for a login code.
+type PostCodeRequest struct {
+	MobileNumber string `json:"mobile_number"`
+}
+
+// handlePost exchanges a login code for a session. The session token is
+// returned in the body for API clients and set as a cookie for browsers.
+func (h *sessionHandler) handlePost(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req PostSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ErrInvalidJSON)
+		return
+	}
+
+	mobileNumber, err := peapod.NormalizeMobileNumber(req.MobileNumber, h.region)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	session, err := h.sessionService.CreateSession(ctx, mobileNumber, strings.TrimSpace(req.Code))
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		Secure:   h.secureCookie,
+		HttpOnly: true,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+// PostSessionRequest is the body of a request to exchange a login code for a session.
+type PostSessionRequest struct {
+	MobileNumber string `json:"mobile_number"`
+	Code         string `json:"code"`
+}
+
+// handleDeleteCurrent ends the session used to make the request.
+func (h *sessionHandler) handleDeleteCurrent(w http.ResponseWriter, r *http.Request) {
+	token := sessionToken(r)
+	if token == "" {
+		Error(w, r, peapod.ErrUnauthorized)
+		return
+	}
+
+	if err := h.sessionService.DeleteSession(r.Context(), token); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	// Clear cookie.
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   h.secureCookie,
+		HttpOnly: true,
+	})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// sessionToken returns the session token from the Authorization header or
+// the session cookie. Returns a blank string if neither is set.
+func sessionToken(r *http.Request) string {
+	if v := r.Header.Get("Authorization"); strings.HasPrefix(v, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(v, "Bearer "))
+	}
+	if cookie, err := r.Cookie(SessionCookieName); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/middlemost/peapod"
+)
+
+// Signature errors.
+const (
+	ErrSignatureRequired = peapod.Error("signature required")
+	ErrInvalidSignature  = peapod.Error("invalid signature")
+	ErrSignatureExpired  = peapod.Error("signature expired")
+)
+
+// DefaultSignedURLExpiry is the default time until a signed link expires.
+const DefaultSignedURLExpiry = 7 * 24 * time.Hour
+
+// signURL returns u with a user id, expiration time & signature attached.
+// The signature is an HMAC-SHA256 of the path and the other parameters.
+func signURL(u url.URL, secret string, userID int, expiresAt time.Time) url.URL {
+	q := u.Query()
+	q.Set("user", strconv.Itoa(userID))
+	q.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	q.Set("sig", computeSignature(secret, u.Path, q.Get("user"), q.Get("expires")))
+	u.RawQuery = q.Encode()
+	return u
+}
+
+// verifySignedURL validates the signature on u and returns the signed user id.
+func verifySignedURL(u *url.URL, secret string, now time.Time) (int, error) {
+	q := u.Query()
+	sig := q.Get("sig")
+	if sig == "" {
+		return 0, ErrSignatureRequired
+	}
+
+	// Verify signature against the rest of the URL.
+	if !hmac.Equal([]byte(sig), []byte(computeSignature(secret, u.Path, q.Get("user"), q.Get("expires")))) {
+		return 0, ErrInvalidSignature
+	}
+
+	// Ensure link has not expired.
+	expiresAt, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidSignature
+	} else if now.Unix() > expiresAt {
+		return 0, ErrSignatureExpired
+	}
+
+	userID, err := strconv.Atoi(q.Get("user"))
+	if err != nil {
+		return 0, ErrInvalidSignature
+	}
+	return userID, nil
+}
+
+// computeSignature returns a hex-encoded HMAC of the signed URL fields.
+func computeSignature(secret, path, userID, expires string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(path + "\n" + userID + "\n" + expires))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// TwilioSignatureHeader is the header Twilio uses to sign webhook requests.
+const TwilioSignatureHeader = "X-Twilio-Signature"
+
+// verifyTwilioSignature validates the Twilio signature of a webhook request.
+// The request URL is rebuilt from the server's base URL as Twilio signs the
+// public URL it called.
+func verifyTwilioSignature(r *http.Request, baseURL url.URL, authToken string) error {
+	sig := r.Header.Get(TwilioSignatureHeader)
+	if sig == "" {
+		return ErrSignatureRequired
+	} else if authToken == "" {
+		return ErrInvalidSignature
+	} else if err := r.ParseForm(); err != nil {
+		return ErrInvalidSignature
+	}
+
+	u := baseURL
+	u.Path, u.RawQuery = r.URL.Path, r.URL.RawQuery
+	if !hmac.Equal([]byte(sig), []byte(computeTwilioSignature(authToken, u.String(), r.PostForm))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// computeTwilioSignature returns a base64 HMAC-SHA1 of the URL followed by
+// each POST parameter's name & value, sorted by name.
+func computeTwilioSignature(authToken, u string, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := hmac.New(sha1.New, []byte(authToken))
+	h.Write([]byte(u))
+	for _, k := range keys {
+		for _, v := range params[k] {
+			h.Write([]byte(k + v))
+		}
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -1,16 +1,23 @@
 package http
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/middlemost/peapod"
 	"github.com/pressly/chi"
 )
 
 const (
-	ErrTTSTextRequired = peapod.Error("tts text required")
+	ErrTTSTextRequired    = peapod.Error("tts text required")
+	ErrTrackURLRequired   = peapod.Error("track url required")
+	ErrInvalidTrackID     = peapod.Error("invalid track id")
+	ErrInvalidTrackFilter = peapod.Error("invalid track filter")
 )
 
 // trackHandler represents an HTTP handler for managing tracks.
@@ -27,7 +34,17 @@ type trackHandler struct {
 // newTrackHandler returns a new instance of trackHandler.
 func newTrackHandler() *trackHandler {
 	h := &trackHandler{router: chi.NewRouter()}
+	h.router.Get("/", h.handleGetIndex)
+	h.router.Post("/", h.handlePost)
+	h.router.Get("/search", h.handleGetSearch)
 	h.router.Post("/tts", h.handlePostTTS)
+	h.router.Get("/trash", h.handleGetTrash)
+	h.router.Post("/undo", h.handlePostUndo)
+	h.router.Get("/:id", h.handleGet)
+	h.router.Delete("/:id", h.handleDelete)
+	h.router.Post("/:id/restore", h.handlePostRestore)
+	h.router.Post("/:id/pin", h.handlePostPin)
+	h.router.Delete("/:id/pin", h.handleDeletePin)
 	return h
 }
 
@@ -36,6 +53,9 @@ func (h *trackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.router.ServeHTTP(w, r)
 }
 
+// handlePostTTS adds text to the job processing queue to be converted to
+// speech. The title is read from the "title" query parameter. The track is
+// added to the current user's default playlist unless "playlist_id" is set.
 func (h *trackHandler) handlePostTTS(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -58,39 +78,351 @@ func (h *trackHandler) handlePostTTS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Retrieve phone number from header.
-	// TODO: Use token auth system instead.
-	mobileNumber := r.Header.Get("X-MOBILE-NUMBER")
-
 	// Lookup user.
-	u, err := h.userService.FindUserByMobileNumber(ctx, mobileNumber)
+	ctx, err = authenticate(r)
 	if err != nil {
 		Error(w, r, err)
 		return
-	} else if u == nil {
-		Error(w, r, peapod.ErrUserNotFound)
-		return
 	}
+	u := peapod.FromContext(ctx)
 
-	// Lookup default playlist.
-	playlists, err := h.playlistService.FindPlaylistsByUserID(ctx, u.ID)
-	if err != nil {
-		Error(w, r, err)
-		return
+	// Lookup default playlist if one is not specified.
+	var playlistID int
+	if v := r.URL.Query().Get("playlist_id"); v != "" {
+		if playlistID, err = strconv.Atoi(v); err != nil {
+			Error(w, r, ErrInvalidPlaylistID)
+			return
+		}
+	} else {
+		playlists, err := h.playlistService.FindPlaylistsByUserID(ctx, u.ID)
+		if err != nil {
+			Error(w, r, err)
+			return
+		} else if len(playlists) == 0 {
+			Error(w, r, peapod.ErrPlaylistNotFound)
+			return
+		}
+		playlistID = playlists[0].ID
 	}
 
 	// Add text to job processing queue.
 	job := peapod.Job{
 		OwnerID:    u.ID,
 		Type:       peapod.JobTypeCreateTrackFromTTS,
-		PlaylistID: playlists[0].ID,
+		PlaylistID: playlistID,
 		Title:      title,
 		Text:       text,
+		Tags:       parseTags(r.URL.Query().Get("tags")),
+	}
+	if err := h.jobService.CreateJob(ctx, &job); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// handlePost adds a URL to the job processing queue. The track is added to
+// the current user's default playlist unless a playlist is specified.
+func (h *trackHandler) handlePost(w http.ResponseWriter, r *http.Request) {
+	var req PostTrackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ErrInvalidJSON)
+		return
+	}
+
+	// Validate URL & ensure it doesn't point locally.
+	if req.URL == "" {
+		Error(w, r, ErrTrackURLRequired)
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || peapod.IsLocal(u.Hostname()) {
+		Error(w, r, peapod.ErrInvalidURL)
+		return
+	}
+
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	user := peapod.FromContext(ctx)
+
+	// Lookup default playlist if one is not specified.
+	playlistID := req.PlaylistID
+	if playlistID == 0 {
+		playlists, err := h.playlistService.FindPlaylistsByUserID(ctx, user.ID)
+		if err != nil {
+			Error(w, r, err)
+			return
+		} else if len(playlists) == 0 {
+			Error(w, r, peapod.ErrPlaylistNotFound)
+			return
+		}
+		playlistID = playlists[0].ID
+	}
+
+	// Add URL to job processing queue.
+	job := peapod.Job{
+		OwnerID:    user.ID,
+		Type:       peapod.JobTypeCreateTrackFromURL,
+		PlaylistID: playlistID,
+		URL:        u.String(),
+		Tags:       req.Tags,
 	}
 	if err := h.jobService.CreateJob(ctx, &job); err != nil {
 		Error(w, r, err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// PostTrackRequest is the body of a request to add a URL as a track.
+type PostTrackRequest struct {
+	URL        string   `json:"url"`
+	PlaylistID int      `json:"playlist_id,omitempty"` // defaults to the user's playlist
+	Tags       []string `json:"tags,omitempty"`
+}
+
+// handleGetIndex returns a page of the current user's tracks. Tracks can be
+// filtered by "playlist_id", "owner_id", "after", "before" (RFC 3339),
+// "content_type", "domain" & "tag" and paginated with "cursor", "limit" &
+// "order" ("newest" or "oldest").
+func (h *trackHandler) handleGetIndex(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := peapod.TrackFilter{
+		ContentType:  q.Get("content_type"),
+		SourceDomain: q.Get("domain"),
+		Tag:          q.Get("tag"),
+		Cursor:       q.Get("cursor"),
+		Order:        q.Get("order"),
+	}
+
+	// Parse numeric & time parameters.
+	var err error
+	if v := q.Get("playlist_id"); v != "" {
+		if filter.PlaylistID, err = strconv.Atoi(v); err != nil {
+			Error(w, r, ErrInvalidPlaylistID)
+			return
+		}
+	}
+	if v := q.Get("owner_id"); v != "" {
+		if filter.OwnerID, err = strconv.Atoi(v); err != nil {
+			Error(w, r, ErrInvalidTrackFilter)
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			Error(w, r, ErrInvalidTrackFilter)
+			return
+		}
+	}
+	if v := q.Get("after"); v != "" {
+		if filter.CreatedAfter, err = time.Parse(time.RFC3339, v); err != nil {
+			Error(w, r, ErrInvalidTrackFilter)
+			return
+		}
+	}
+	if v := q.Get("before"); v != "" {
+		if filter.CreatedBefore, err = time.Parse(time.RFC3339, v); err != nil {
+			Error(w, r, ErrInvalidTrackFilter)
+			return
+		}
+	}
+
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	page, err := h.trackService.FindTracks(ctx, filter)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// handleGetSearch returns the current user's tracks matching the "q" query
+// parameter. Results are paginated with the "offset" & "limit" parameters.
+func (h *trackHandler) handleGetSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	result, err := h.trackService.SearchTracks(ctx, peapod.TrackSearch{
+		Query:  q.Get("q"),
+		Offset: offset,
+		Limit:  limit,
+	})
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleGet returns a single track. Requires a role on the track's playlist.
+func (h *trackHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidTrackID)
+		return
+	}
+
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	track, err := h.trackService.FindTrackByID(ctx, id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	} else if track == nil {
+		Error(w, r, peapod.ErrTrackNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(track)
+}
+
+// handleDelete moves a track to the current user's trash.
+func (h *trackHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidTrackID)
+		return
+	}
+
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if err := h.trackService.DeleteTrack(ctx, id, peapod.TrackRemovalReasonUser); err != nil {
+		Error(w, r, err)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
+
+// handleGetTrash returns the tracks in the current user's trash.
+func (h *trackHandler) handleGetTrash(w http.ResponseWriter, r *http.Request) {
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	tracks, err := h.trackService.FindTrashedTracks(ctx)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tracks)
+}
+
+// handlePostRestore moves a track from the current user's trash back to its playlist.
+func (h *trackHandler) handlePostRestore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidTrackID)
+		return
+	}
+
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	track, err := h.trackService.RestoreTrack(ctx, id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(track)
+}
+
+// handlePostUndo restores the track most recently deleted by the current user.
+func (h *trackHandler) handlePostUndo(w http.ResponseWriter, r *http.Request) {
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	track, err := h.trackService.UndoDeleteTrack(ctx)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(track)
+}
+
+// handlePostPin exempts a track from its playlist's retention limits.
+func (h *trackHandler) handlePostPin(w http.ResponseWriter, r *http.Request) {
+	h.setPinned(w, r, true)
+}
+
+// handleDeletePin removes a track's exemption from retention limits.
+func (h *trackHandler) handleDeletePin(w http.ResponseWriter, r *http.Request) {
+	h.setPinned(w, r, false)
+}
+
+func (h *trackHandler) setPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidTrackID)
+		return
+	}
+
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if err := h.trackService.SetTrackPinned(ctx, id, pinned); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseTags splits a comma-separated list of tags.
+func parseTags(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return peapod.NormalizeTags(strings.Split(s, ","))
+}
@@ -1,10 +1,14 @@
 package http
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/middlemost/peapod"
 	"github.com/pressly/chi"
@@ -22,10 +26,18 @@ type twilioHandler struct {
 	// The server's base URL.
 	baseURL url.URL
 
-	// Account identifier. Used to verify incoming messages.
+	// Account identifier & auth token. Used to verify incoming messages.
 	accountSID string
+	authToken  string
+
+	// Secret used to sign links.
+	secret string
+
+	// Default region for mobile numbers without a country code.
+	region string
 
 	// Services
+	fileService     peapod.FileService
 	jobService      peapod.JobService
 	playlistService peapod.PlaylistService
 	smsService      peapod.SMSService
@@ -55,40 +67,63 @@ func (h *twilioHandler) handlePostVoice(w http.ResponseWriter, r *http.Request)
 func (h *twilioHandler) handlePostSMS(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Verify incoming message matches account.
+	// Verify incoming message matches account & was signed by Twilio.
 	accountSID := r.PostFormValue("AccountSid")
 	if accountSID != h.accountSID {
 		Error(w, r, ErrTwilioAccountMismatch)
 		return
+	} else if err := verifyTwilioSignature(r, h.baseURL, h.authToken); err != nil {
+		Error(w, r, err)
+		return
 	}
 
 	// Read incoming parameters.
-	from := r.PostFormValue("From")
-	body := strings.TrimSpace(r.PostFormValue("Body"))
-
-	// Parse message as URL & ensure it doesn't point locally.
-	u, err := url.Parse(body)
+	from, err := peapod.NormalizeMobileNumber(r.PostFormValue("From"), h.region)
 	if err != nil {
-		Error(w, r, ErrInvalidSMSRequestBody)
-		return
-	} else if peapod.IsLocal(u.Hostname()) {
-		Error(w, r, peapod.ErrInvalidURL)
+		Error(w, r, err)
 		return
 	}
+	body := strings.TrimSpace(r.PostFormValue("Body"))
 
-	// Lookup user by mobile number.
-	user, err := h.userService.FindUserByMobileNumber(ctx, from)
+	// Parse message as a command. Otherwise parse as a URL with an optional
+	// playlist name & ensure it doesn't point locally.
+	cmd, args := parseSMSCommand(body)
+	var u *url.URL
+	var playlistName string
+	var tags []string
+	if cmd == "" {
+		v, name, a, err := parseSMSSubmission(body)
+		if err != nil {
+			Error(w, r, err)
+			return
+		} else if peapod.IsLocal(v.Hostname()) {
+			Error(w, r, peapod.ErrInvalidURL)
+			return
+		}
+		u, playlistName, tags = v, name, a
+	}
+
+	// Lookup user by mobile number. The sender is trusted because the
+	// request's Twilio signature was verified above.
+	sysCtx := peapod.NewSystemContext(ctx)
+	user, err := h.userService.FindUserByMobileNumber(sysCtx, from)
 	if err != nil {
 		Error(w, r, err)
 		return
 	}
 
+	// Ignore messages from disabled users.
+	if user != nil && user.Disabled {
+		Error(w, r, peapod.ErrUserDisabled)
+		return
+	}
+
 	// Create the user if they don't exist.
 	var isNewUser bool
 	if user == nil {
 		isNewUser = true
 		user = &peapod.User{MobileNumber: from}
-		if err := h.userService.CreateUser(ctx, user); err != nil {
+		if err := h.userService.CreateUser(sysCtx, user); err != nil {
 			Error(w, r, err)
 			return
 		}
@@ -107,13 +142,12 @@ func (h *twilioHandler) handlePostSMS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: Ask user which playlist if there are multiple. Currently only one can exist.
+	// Commands operate on the user's default playlist.
 	playlist := playlists[0]
 
 	// If the user is new then send them their playlist feed URL.
 	if isNewUser {
-		feedURL := h.baseURL
-		feedURL.Path = fmt.Sprintf("/p/%s.rss", playlist.Token)
+		feedURL := playlistFeedURL(h.baseURL, playlist.Token, ".rss")
 
 		sms := &peapod.SMS{
 			To:   user.MobileNumber,
@@ -125,12 +159,52 @@ func (h *twilioHandler) handlePostSMS(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Execute command, if specified.
+	switch cmd {
+	case SMSCommandFeeds:
+		h.handleFeedsCommand(w, r.WithContext(ctx), user)
+		return
+	case SMSCommandRotate:
+		h.handleRotateCommand(w, r.WithContext(ctx), user, playlist)
+		return
+	case SMSCommandInvite:
+		h.handleInviteCommand(w, r.WithContext(ctx), user, playlist, args)
+		return
+	case SMSCommandAccept:
+		h.handleAcceptCommand(w, r.WithContext(ctx), user)
+		return
+	case SMSCommandFind:
+		h.handleFindCommand(w, r.WithContext(ctx), user, args)
+		return
+	case SMSCommandUndo:
+		h.handleUndoCommand(w, r.WithContext(ctx), user)
+		return
+	case SMSCommandRestore:
+		h.handleRestoreCommand(w, r.WithContext(ctx), user)
+		return
+	case SMSCommandDelete:
+		h.handleDeleteCommand(w, r.WithContext(ctx), user, args)
+		return
+	case SMSCommandConfirm:
+		h.handleConfirmCommand(w, r.WithContext(ctx), user, args)
+		return
+	}
+
+	// Find the named playlist, if specified.
+	if playlistName != "" {
+		if playlist, err = h.findContributablePlaylist(ctx, user, playlists, playlistName); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+
 	// Add URL to job processing queue.
 	job := peapod.Job{
 		OwnerID:    user.ID,
 		Type:       peapod.JobTypeCreateTrackFromURL,
 		PlaylistID: playlist.ID,
 		URL:        u.String(),
+		Tags:       tags,
 	}
 	if err := h.jobService.CreateJob(ctx, &job); err != nil {
 		Error(w, r, err)
@@ -139,3 +213,355 @@ func (h *twilioHandler) handlePostSMS(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// handleFeedsCommand replies with a signed link to the user's OPML file.
+func (h *twilioHandler) handleFeedsCommand(w http.ResponseWriter, r *http.Request, user *peapod.User) {
+	ctx := r.Context()
+
+	// Generate a link to the OPML file which expires after a period of time.
+	opmlURL := h.baseURL
+	opmlURL.Path = "/playlists.opml"
+	opmlURL = signURL(opmlURL, h.secret, user.ID, time.Now().Add(DefaultSignedURLExpiry))
+
+	sms := &peapod.SMS{
+		To:   user.MobileNumber,
+		Body: fmt.Sprintf("Subscribe to all of your feeds at once with this link:\n\n%s", opmlURL.String()),
+	}
+	if err := h.smsService.SendSMS(ctx, sms); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleRotateCommand generates a new token for the playlist and replies
+// with the new feed URL.
+func (h *twilioHandler) handleRotateCommand(w http.ResponseWriter, r *http.Request, user *peapod.User, playlist *peapod.Playlist) {
+	ctx := r.Context()
+
+	playlist, err := h.playlistService.RotateToken(ctx, playlist.ID)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	feedURL := playlistFeedURL(h.baseURL, playlist.Token, ".rss")
+	sms := &peapod.SMS{
+		To:   user.MobileNumber,
+		Body: fmt.Sprintf("Your feed URL has been changed. Your old URL will stop working soon. Your new feed is:\n\n%s", feedURL.String()),
+	}
+	if err := h.smsService.SendSMS(ctx, sms); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleInviteCommand invites a mobile number to the user's default playlist.
+// The role defaults to contributor if not specified.
+func (h *twilioHandler) handleInviteCommand(w http.ResponseWriter, r *http.Request, user *peapod.User, playlist *peapod.Playlist, args string) {
+	ctx := r.Context()
+
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		Error(w, r, peapod.ErrInviteMobileNumberRequired)
+		return
+	}
+	role := peapod.PlaylistRoleContributor
+	if len(fields) > 1 {
+		role = strings.ToLower(fields[1])
+	}
+
+	mobileNumber, err := peapod.NormalizeMobileNumber(fields[0], h.region)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	invite := &peapod.PlaylistInvite{
+		PlaylistID:   playlist.ID,
+		MobileNumber: mobileNumber,
+		Role:         role,
+	}
+	if err := h.playlistService.CreatePlaylistInvite(ctx, invite); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	// Notify the invitee.
+	if err := h.smsService.SendSMS(ctx, &peapod.SMS{
+		To:   invite.MobileNumber,
+		Body: fmt.Sprintf("%s has invited you to join the %q playlist on Peapod as a %s. Reply ACCEPT to join.", user.MobileNumber, playlist.Name, invite.Role),
+	}); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	// Confirm with the sender.
+	if err := h.smsService.SendSMS(ctx, &peapod.SMS{
+		To:   user.MobileNumber,
+		Body: fmt.Sprintf("An invite to %q has been sent to %s.", playlist.Name, invite.MobileNumber),
+	}); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleAcceptCommand accepts all pending invites for the user's mobile
+// number and replies with the feed URL of each joined playlist.
+func (h *twilioHandler) handleAcceptCommand(w http.ResponseWriter, r *http.Request, user *peapod.User) {
+	ctx := r.Context()
+
+	invites, err := h.playlistService.FindPlaylistInvitesByMobileNumber(ctx, user.MobileNumber)
+	if err != nil {
+		Error(w, r, err)
+		return
+	} else if len(invites) == 0 {
+		Error(w, r, peapod.ErrPlaylistInviteNotFound)
+		return
+	}
+
+	for _, invite := range invites {
+		member, err := h.playlistService.AcceptPlaylistInvite(ctx, invite.ID)
+		if err != nil {
+			Error(w, r, err)
+			return
+		}
+
+		playlist, err := h.playlistService.FindPlaylistByID(ctx, member.PlaylistID)
+		if err != nil {
+			Error(w, r, err)
+			return
+		}
+
+		feedURL := playlistFeedURL(h.baseURL, playlist.Token, ".rss")
+		sms := &peapod.SMS{
+			To:   user.MobileNumber,
+			Body: fmt.Sprintf("You've joined %q. Subscribe to it here:\n\n%s", playlist.Name, feedURL.String()),
+		}
+		if err := h.smsService.SendSMS(ctx, sms); err != nil {
+			Error(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleFindCommand replies with the user's top tracks matching a query.
+func (h *twilioHandler) handleFindCommand(w http.ResponseWriter, r *http.Request, user *peapod.User, query string) {
+	ctx := r.Context()
+
+	result, err := h.trackService.SearchTracks(ctx, peapod.TrackSearch{Query: query, Limit: SMSFindLimit})
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	// Build a numbered list of matching titles.
+	var buf bytes.Buffer
+	if len(result.Tracks) == 0 {
+		fmt.Fprintf(&buf, "No tracks found matching %q.", query)
+	} else {
+		fmt.Fprintf(&buf, "Top matches for %q:\n", query)
+		for i, track := range result.Tracks {
+			fmt.Fprintf(&buf, "\n%d. %s", i+1, track.Title)
+		}
+	}
+
+	if err := h.smsService.SendSMS(ctx, &peapod.SMS{To: user.MobileNumber, Body: buf.String()}); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleRestoreCommand restores the user's most recently deleted track from
+// the trash. Other changes, such as token rotations, cannot be restored.
+func (h *twilioHandler) handleRestoreCommand(w http.ResponseWriter, r *http.Request, user *peapod.User) {
+	ctx := r.Context()
+
+	var body string
+	if track, err := h.trackService.UndoDeleteTrack(ctx); err == peapod.ErrTrashEmpty {
+		body = "There are no deleted tracks to restore."
+	} else if err != nil {
+		Error(w, r, err)
+		return
+	} else {
+		body = fmt.Sprintf("Restored %q.", track.Title)
+	}
+
+	if err := h.smsService.SendSMS(ctx, &peapod.SMS{To: user.MobileNumber, Body: body}); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleUndoCommand reverts the user's last destructive action, such as a
+// track removal or a feed token rotation.
+func (h *twilioHandler) handleUndoCommand(w http.ResponseWriter, r *http.Request, user *peapod.User) {
+	ctx := r.Context()
+
+	var body string
+	if action, err := h.userService.UndoLastAction(ctx, user.ID); err == peapod.ErrNothingToUndo {
+		body = "There is nothing to undo."
+	} else if err != nil {
+		Error(w, r, err)
+		return
+	} else if action.Type == peapod.UndoActionTypeRotateToken {
+		feedURL := playlistFeedURL(h.baseURL, action.Token, ".rss")
+		body = fmt.Sprintf("Your previous feed URL has been restored:\n\n%s", feedURL.String())
+	} else if action.Reason == peapod.TrackRemovalReasonRetention {
+		body = fmt.Sprintf("Restored & pinned %d track(s) removed by retention.", len(action.TrackIDs))
+	} else {
+		body = fmt.Sprintf("Restored %d track(s).", len(action.TrackIDs))
+	}
+
+	if err := h.smsService.SendSMS(ctx, &peapod.SMS{To: user.MobileNumber, Body: body}); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleDeleteCommand starts an account deletion. The user must reply with
+// CONFIRM & the code sent to them before the deletion is carried out.
+func (h *twilioHandler) handleDeleteCommand(w http.ResponseWriter, r *http.Request, user *peapod.User, args string) {
+	ctx := r.Context()
+
+	var body string
+	if !strings.EqualFold(args, "ACCOUNT") {
+		body = "To delete your account, reply DELETE ACCOUNT."
+	} else if code, err := h.userService.RequestUserDeletion(ctx, user.ID); err != nil {
+		Error(w, r, err)
+		return
+	} else {
+		body = fmt.Sprintf("This will permanently delete your account, playlists & tracks. Reply CONFIRM %s within %d minutes to continue.", code, int(peapod.UserDeletionConfirmPeriod/time.Minute))
+	}
+
+	if err := h.smsService.SendSMS(ctx, &peapod.SMS{To: user.MobileNumber, Body: body}); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleConfirmCommand deletes the user's account if the code matches their
+// recent deletion request. An incorrect code cancels the request.
+func (h *twilioHandler) handleConfirmCommand(w http.ResponseWriter, r *http.Request, user *peapod.User, code string) {
+	ctx := r.Context()
+
+	var body string
+	if tracks, err := h.userService.ConfirmUserDeletion(ctx, user.ID, code); err == peapod.ErrDeletionCodeRequired {
+		body = "To delete your account, reply CONFIRM with the code sent to you."
+	} else if err == peapod.ErrInvalidDeletionCode {
+		body = "That code is incorrect or has expired. Reply DELETE ACCOUNT to start again."
+	} else if err != nil {
+		Error(w, r, err)
+		return
+	} else if err := peapod.DeleteTrackFiles(ctx, h.fileService, tracks); err != nil {
+		Error(w, r, err)
+		return
+	} else {
+		body = "Your account has been deleted. Thanks for using Peapod!"
+	}
+
+	if err := h.smsService.SendSMS(ctx, &peapod.SMS{To: user.MobileNumber, Body: body}); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// SMSFindLimit is the number of matches returned by the FIND command.
+const SMSFindLimit = 5
+
+// findContributablePlaylist returns the playlist matching name that the user
+// is allowed to add tracks to. Names are matched case-insensitively.
+func (h *twilioHandler) findContributablePlaylist(ctx context.Context, user *peapod.User, playlists []*peapod.Playlist, name string) (*peapod.Playlist, error) {
+	for _, playlist := range playlists {
+		if !strings.EqualFold(playlist.Name, name) || playlist.Type != peapod.PlaylistTypeStandard {
+			continue
+		} else if playlist.OwnerID == user.ID {
+			return playlist, nil
+		}
+
+		members, err := h.playlistService.FindPlaylistMembers(ctx, playlist.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			if m.UserID == user.ID && peapod.CanContribute(m.Role) {
+				return playlist, nil
+			}
+		}
+	}
+	return nil, peapod.ErrPlaylistNotFound
+}
+
+// parseSMSSubmission returns the URL from a submission, the name of the
+// target playlist & any hashtags. The URL may appear anywhere in the message,
+// words beginning with "#" are used as tags, and the remaining words are
+// used as the playlist name.
+func parseSMSSubmission(body string) (u *url.URL, playlistName string, tags []string, err error) {
+	var words []string
+	for _, field := range strings.Fields(body) {
+		if u == nil {
+			if v, err := url.Parse(field); err == nil && (v.Scheme == "http" || v.Scheme == "https") && v.Host != "" {
+				u = v
+				continue
+			}
+		}
+		if strings.HasPrefix(field, "#") {
+			tags = append(tags, field)
+			continue
+		}
+		words = append(words, field)
+	}
+
+	if u == nil {
+		return nil, "", nil, ErrInvalidSMSRequestBody
+	}
+	return u, strings.TrimSuffix(strings.Join(words, " "), ":"), peapod.NormalizeTags(tags), nil
+}
+
+// SMS commands.
+const (
+	SMSCommandFeeds   = "FEEDS"
+	SMSCommandRotate  = "ROTATE"
+	SMSCommandInvite  = "INVITE"
+	SMSCommandAccept  = "ACCEPT"
+	SMSCommandFind    = "FIND"
+	SMSCommandUndo    = "UNDO"
+	SMSCommandRestore = "RESTORE"
+	SMSCommandDelete  = "DELETE"
+	SMSCommandConfirm = "CONFIRM"
+)
+
+// parseSMSCommand splits body into a command keyword & its arguments.
+// Returns a blank command if body does not begin with a known command.
+func parseSMSCommand(body string) (cmd, args string) {
+	body = strings.TrimSpace(body)
+	if i := strings.IndexFunc(body, unicode.IsSpace); i != -1 {
+		cmd, args = body[:i], strings.TrimSpace(body[i:])
+	} else {
+		cmd = body
+	}
+
+	switch cmd = strings.ToUpper(cmd); cmd {
+	case SMSCommandFeeds, SMSCommandRotate, SMSCommandInvite, SMSCommandAccept, SMSCommandFind, SMSCommandUndo, SMSCommandRestore, SMSCommandDelete, SMSCommandConfirm:
+		return cmd, args
+	default:
+		return "", ""
+	}
+}
@@ -0,0 +1,272 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/middlemost/peapod"
+	"github.com/pressly/chi"
+)
+
+// userHandler represents an HTTP handler for managing users. The current
+// user can be referred to by the "me" id. Access to other users is
+// authorized by the user service.
+type userHandler struct {
+	router chi.Router
+
+	// Default region for mobile numbers without a country code.
+	region string
+
+	// Services
+	fileService     peapod.FileService
+	playlistService peapod.PlaylistService
+	smsService      peapod.SMSService
+	userService     peapod.UserService
+}
+
+// newUserHandler returns a new instance of userHandler.
+func newUserHandler() *userHandler {
+	h := &userHandler{router: chi.NewRouter()}
+	h.router.Get("/", h.handleGetIndex)
+	h.router.Get("/:id", h.handleGet)
+	h.router.Patch("/:id", h.handlePatch)
+	h.router.Delete("/:id", h.handleDelete)
+	h.router.Post("/:id/deletion", h.handlePostDeletion)
+	h.router.Post("/:id/undo", h.handlePostUndo)
+	h.router.Get("/:id/playlists", h.handleGetPlaylists)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *userHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+// handleGetIndex returns the user with the "mobile_number" query parameter.
+func (h *userHandler) handleGetIndex(w http.ResponseWriter, r *http.Request) {
+	mobileNumber, err := peapod.NormalizeMobileNumber(r.URL.Query().Get("mobile_number"), h.region)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	user, err := h.userService.FindUserByMobileNumber(ctx, mobileNumber)
+	if err != nil {
+		Error(w, r, err)
+		return
+	} else if user == nil {
+		Error(w, r, peapod.ErrUserNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// handleGet returns a user's profile & settings.
+func (h *userHandler) handleGet(w http.ResponseWriter, r *http.Request) {
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	id, err := userIDParam(ctx, r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	user, err := h.userService.FindUserByID(ctx, id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	} else if user == nil {
+		Error(w, r, peapod.ErrUserNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// handlePatch updates a user's profile & settings. Fields which are
+// omitted from the JSON body are left unchanged.
+func (h *userHandler) handlePatch(w http.ResponseWriter, r *http.Request) {
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	id, err := userIDParam(ctx, r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	var upd peapod.UserUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		Error(w, r, ErrInvalidJSON)
+		return
+	}
+
+	user, err := h.userService.UpdateUser(ctx, id, upd)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// handlePostUndo reverts the user's last destructive action.
+func (h *userHandler) handlePostUndo(w http.ResponseWriter, r *http.Request) {
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	id, err := userIDParam(ctx, r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	action, err := h.userService.UndoLastAction(ctx, id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(action)
+}
+
+// handlePostDeletion starts an account deletion by sending a confirmation
+// code to the user's mobile number.
+func (h *userHandler) handlePostDeletion(w http.ResponseWriter, r *http.Request) {
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	id, err := userIDParam(ctx, r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	code, err := h.userService.RequestUserDeletion(ctx, id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	if err := h.smsService.SendSMS(ctx, &peapod.SMS{
+		To:   peapod.FromContext(ctx).MobileNumber,
+		Body: fmt.Sprintf("Your Peapod account deletion code is %s. It expires in %d minutes.", code, int(peapod.UserDeletionConfirmPeriod/time.Minute)),
+	}); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleDelete permanently deletes a user and all their data. The deletion
+// must first be requested & then confirmed with the code sent by SMS.
+func (h *userHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	id, err := userIDParam(ctx, r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	var req DeleteUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ErrInvalidJSON)
+		return
+	}
+
+	tracks, err := h.userService.ConfirmUserDeletion(ctx, id, strings.TrimSpace(req.Code))
+	if err != nil {
+		Error(w, r, err)
+		return
+	} else if err := peapod.DeleteTrackFiles(ctx, h.fileService, tracks); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteUserRequest is the body of a request to confirm an account deletion.
+type DeleteUserRequest struct {
+	Code string `json:"code"`
+}
+
+// handleGetPlaylists returns the playlists owned by a user followed by the
+// playlists they are a member of.
+func (h *userHandler) handleGetPlaylists(w http.ResponseWriter, r *http.Request) {
+	ctx, err := authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	id, err := userIDParam(ctx, r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	playlists, err := h.playlistService.FindPlaylistsByUserID(ctx, id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&GetPlaylistsResponse{Playlists: playlists})
+}
+
+// GetPlaylistsResponse is the body of a response listing playlists.
+type GetPlaylistsResponse struct {
+	Playlists []*peapod.Playlist `json:"playlists"`
+}
+
+// userIDParam returns the user id from the URL. The "me" id refers to the
+// authenticated user.
+func userIDParam(ctx context.Context, r *http.Request) (int, error) {
+	v := chi.URLParam(r, "id")
+	if v == "me" {
+		return peapod.FromContext(ctx).ID, nil
+	}
+
+	id, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, ErrInvalidUserID
+	}
+	return id, nil
+}
@@ -0,0 +1,260 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/middlemost/peapod"
+	"github.com/pressly/chi"
+)
+
+// Worker request headers.
+const (
+	// WorkerTokenHeader is the request header used to pass the worker token.
+	WorkerTokenHeader = "X-WORKER-TOKEN"
+
+	// WorkerUserHeader is the id of the user a worker is acting on behalf
+	// of. Requests without it are performed with a system context.
+	WorkerUserHeader = "X-WORKER-USER-ID"
+)
+
+// workerHandler represents an HTTP handler used by remote job workers to
+// claim & complete jobs and to store their results.
+type workerHandler struct {
+	router chi.Router
+
+	// Shared secret which grants worker access. Disabled if blank.
+	token string
+
+	// Services
+	fileService     peapod.FileService
+	jobService      peapod.JobService
+	playlistService peapod.PlaylistService
+	trackService    peapod.TrackService
+	userService     peapod.UserService
+}
+
+// newWorkerHandler returns a new instance of workerHandler.
+func newWorkerHandler() *workerHandler {
+	h := &workerHandler{router: chi.NewRouter()}
+	h.router.Post("/jobs/next", h.handlePostNextJob)
+	h.router.Post("/jobs/:id/heartbeat", h.handlePostHeartbeat)
+	h.router.Post("/jobs/:id/complete", h.handlePostComplete)
+	h.router.Put("/files/:name", h.handlePutFile)
+	h.router.Post("/tracks", h.handlePostTrack)
+	h.router.Get("/users/:id", h.handleGetUser)
+	h.router.Get("/playlists/:id", h.handleGetPlaylist)
+	h.router.Get("/playlists/:id/members", h.handleGetMembers)
+	return h
+}
+
+// ServeHTTP implements http.Handler. Requests are only routed once the
+// caller has been verified as a worker.
+func (h *workerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, err := h.authenticate(r)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+	h.router.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// authenticate verifies the worker token. Requests are performed as the
+// user in the worker user header, if set, so that jobs are authorized the
+// same as when executed by the server. Otherwise a system context is used.
+func (h *workerHandler) authenticate(r *http.Request) (context.Context, error) {
+	token := r.Header.Get(WorkerTokenHeader)
+	if h.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		return nil, peapod.ErrUnauthorized
+	}
+
+	ctx := peapod.NewSystemContext(r.Context())
+	if v := r.Header.Get(WorkerUserHeader); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, ErrInvalidUserID
+		}
+
+		user, err := h.userService.FindUserByID(ctx, id)
+		if err != nil {
+			return nil, err
+		} else if user == nil {
+			return nil, peapod.ErrUserNotFound
+		}
+		ctx = peapod.NewContext(r.Context(), user)
+	}
+	return ctx, nil
+}
+
+// PostHeartbeatRequest represents a worker's request to extend its claim.
+type PostHeartbeatRequest struct {
+	Lease string `json:"lease"`
+}
+
+// PostCompleteRequest represents the result of a job sent by a worker.
+type PostCompleteRequest struct {
+	Lease string `json:"lease"`
+	Error string `json:"error,omitempty"`
+}
+
+// handlePostNextJob claims the next job in the queue. Returns null if there
+// are no pending jobs.
+func (h *workerHandler) handlePostNextJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobService.NextJob(r.Context())
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// handlePostHeartbeat extends the worker's claim on a job.
+func (h *workerHandler) handlePostHeartbeat(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidJobID)
+		return
+	}
+
+	var req PostHeartbeatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ErrInvalidJSON)
+		return
+	}
+
+	if err := h.jobService.HeartbeatJob(r.Context(), id, req.Lease); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handlePostComplete marks a job as completed, or failed if an error is sent.
+func (h *workerHandler) handlePostComplete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidJobID)
+		return
+	}
+
+	var req PostCompleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, r, ErrInvalidJSON)
+		return
+	}
+
+	var jobErr error
+	if req.Error != "" {
+		jobErr = errors.New(req.Error)
+	}
+
+	if err := h.jobService.CompleteJob(r.Context(), id, req.Lease, jobErr); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handlePutFile stores the request body as a file. The name is generated by
+// the worker so that it can be referenced before the upload completes.
+func (h *workerHandler) handlePutFile(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !peapod.IsValidFilename(name) {
+		Error(w, r, peapod.ErrInvalidFilename)
+		return
+	}
+
+	file := &peapod.File{Name: name}
+	if err := h.fileService.CreateFile(r.Context(), file, r.Body); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(file)
+}
+
+// handlePostTrack creates a track from files which have been uploaded.
+func (h *workerHandler) handlePostTrack(w http.ResponseWriter, r *http.Request) {
+	var track peapod.Track
+	if err := json.NewDecoder(r.Body).Decode(&track); err != nil {
+		Error(w, r, ErrInvalidJSON)
+		return
+	}
+
+	if err := h.trackService.CreateTrack(r.Context(), &track); err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&track)
+}
+
+// handleGetUser returns a user by id.
+func (h *workerHandler) handleGetUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidUserID)
+		return
+	}
+
+	user, err := h.userService.FindUserByID(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	} else if user == nil {
+		Error(w, r, peapod.ErrUserNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// handleGetPlaylist returns a playlist by id.
+func (h *workerHandler) handleGetPlaylist(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidPlaylistID)
+		return
+	}
+
+	playlist, err := h.playlistService.FindPlaylistByID(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	} else if playlist == nil {
+		Error(w, r, peapod.ErrPlaylistNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(playlist)
+}
+
+// handleGetMembers returns the members of a playlist.
+func (h *workerHandler) handleGetMembers(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, r, ErrInvalidPlaylistID)
+		return
+	}
+
+	members, err := h.playlistService.FindPlaylistMembers(r.Context(), id)
+	if err != nil {
+		Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&GetMembersResponse{Members: members})
+}
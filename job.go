@@ -1,13 +1,16 @@
 package peapod
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"io"
-	"io/ioutil"
+	"log/slog"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/middlemost/peapod/metrics"
 )
 
 // Job errors.
@@ -18,6 +21,48 @@ const (
 	ErrJobOwnerNotFound = Error("job owner not found")
 	ErrInvalidJobType   = Error("invalid job type")
 	ErrInvalidJobStatus = Error("invalid job status")
+	ErrJobNotRetryable  = Error("only failed jobs can be retried")
+	ErrJobNotCancelable = Error("only pending jobs can be canceled")
+	ErrJobNotProcessing = Error("job is not processing")
+
+	ErrSchedulerNotRunning = Error("job scheduler not running")
+)
+
+// Pagination limits for listing jobs.
+const (
+	DefaultJobLimit = 50
+	MaxJobLimit     = 500
+)
+
+// Scheduler defaults.
+const (
+	DefaultJobPollInterval      = 10 * time.Second
+	DefaultJobHeartbeatInterval = 30 * time.Second
+)
+
+// JobDurationBuckets are the histogram buckets, in seconds, for job execution time.
+var JobDurationBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800}
+
+// Job metrics.
+var (
+	schedulerJobs = metrics.NewCounterVec(
+		"peapod_scheduler_jobs_total",
+		"Jobs claimed by the scheduler by type & result (completed, failed, interrupted or error).",
+		"type", "result",
+	)
+	schedulerJobsRunning = metrics.NewGaugeVec(
+		"peapod_scheduler_jobs_running",
+		"Jobs currently executing.",
+	)
+	schedulerNextJobErrors = metrics.NewCounterVec(
+		"peapod_scheduler_next_job_errors_total",
+		"Errors while claiming the next job.",
+	)
+	jobDuration = metrics.NewHistogramVec(
+		"peapod_job_duration_seconds",
+		"Job execution time by type & status.",
+		JobDurationBuckets, "type", "status",
+	)
 )
 
 // Job types.
@@ -42,12 +87,13 @@ const (
 	JobStatusProcessing = "processing"
 	JobStatusCompleted  = "completed"
 	JobStatusFailed     = "failed"
+	JobStatusCanceled   = "canceled"
 )
 
 // IsValidJobType returns true if v is a valid type.
 func IsValidJobStatus(v string) bool {
 	switch v {
-	case JobStatusPending, JobStatusProcessing, JobStatusCompleted, JobStatusFailed:
+	case JobStatusPending, JobStatusProcessing, JobStatusCompleted, JobStatusFailed, JobStatusCanceled:
 		return true
 	default:
 		return false
@@ -65,9 +111,18 @@ type Job struct {
 	Title      string    `json:"title"`
 	URL        string    `json:"url,omitempty"`
 	Text       string    `json:"text,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
 	Error      string    `json:"error,omitempty"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
+
+	// Identifies the request which created the job, such as the Twilio
+	// message sid for jobs submitted by SMS.
+	CorrelationID string `json:"correlation_id,omitempty"`
+
+	// Identifies the worker's claim on a processing job. Set by NextJob &
+	// required to extend or complete the claim.
+	Lease string `json:"lease,omitempty"`
 }
 
 // JobService manages jobs in a job queue.
@@ -77,7 +132,33 @@ type JobService interface {
 
 	CreateJob(ctx context.Context, job *Job) error
 	NextJob(ctx context.Context) (*Job, error)
-	CompleteJob(ctx context.Context, id int, err error) error
+
+	// Extends or completes the claim on a processing job identified by the
+	// lease returned from NextJob. Jobs which are not completed or extended
+	// in time are returned to the queue by NextJob & their previous lease
+	// can no longer be used.
+	HeartbeatJob(ctx context.Context, id int, lease string) error
+	CompleteJob(ctx context.Context, id int, lease string, err error) error
+
+	// Administrative methods. These require a system context.
+	FindJobs(ctx context.Context, filter JobFilter) ([]*Job, error)
+	RetryJob(ctx context.Context, id int) (*Job, error)
+	CancelJob(ctx context.Context, id int) (*Job, error)
+	FindJobQueueStats(ctx context.Context) (*JobQueueStats, error)
+}
+
+// JobFilter represents a set of filters for listing jobs.
+type JobFilter struct {
+	Status string // optional
+
+	Offset int
+	Limit  int
+}
+
+// JobQueueStats represents the number of jobs in the queue by status.
+type JobQueueStats struct {
+	Pending    int `json:"pending"`
+	Processing int `json:"processing"`
 }
 
 // JobScheduler receives new jobs and schedules them for execution.
@@ -86,128 +167,273 @@ type JobScheduler struct {
 	closing chan struct{}
 	wg      sync.WaitGroup
 
+	// Set while the monitor is running & the number of executing jobs.
+	// Accessed atomically.
+	monitoring int32
+	executing  int32
+
+	// Context used to execute jobs. Canceled to interrupt running jobs.
+	ctx    context.Context
+	cancel func()
+
 	FileService       FileService
 	JobService        JobService
+	PlaylistService   PlaylistService
 	SMSService        SMSService
 	TrackService      TrackService
 	TTSService        TTSService
 	UserService       UserService
 	URLTrackGenerator URLTrackGenerator
 
-	LogOutput io.Writer
+	// Interval between checks for new jobs. This is required for job
+	// services which do not send notifications, such as remote services.
+	PollInterval time.Duration
+
+	// Interval between heartbeats sent for each running job.
+	HeartbeatInterval time.Duration
+
+	Logger *slog.Logger
 }
 
 // NewJobScheduler returns a new instance of JobScheduler.
 func NewJobScheduler() *JobScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &JobScheduler{
-		closing:   make(chan struct{}),
-		LogOutput: ioutil.Discard,
+		closing:           make(chan struct{}),
+		ctx:               ctx,
+		cancel:            cancel,
+		PollInterval:      DefaultJobPollInterval,
+		HeartbeatInterval: DefaultJobHeartbeatInterval,
+		Logger:            DiscardLogger(),
 	}
 }
 
 // Open initializes the job processing queue.
 func (s *JobScheduler) Open() error {
+	atomic.StoreInt32(&s.monitoring, 1)
 	s.wg.Add(1)
 	go func() { defer s.wg.Done(); s.monitor() }()
 	return nil
 }
 
-// Close stops the job processing queue and waits for outstanding workers.
+// Close interrupts running jobs and waits for them to return.
 func (s *JobScheduler) Close() error {
-	s.once.Do(func() { close(s.closing) })
-	s.wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Shutdown(ctx)
 	return nil
 }
 
+// Shutdown stops claiming new jobs and waits for running jobs to complete.
+// If ctx is done first then running jobs are interrupted and ctx's error is
+// returned once they return. Interrupted jobs are not completed so they are
+// executed again once their claim is reset or expires.
+func (s *JobScheduler) Shutdown(ctx context.Context) error {
+	s.once.Do(func() { close(s.closing) })
+
+	done := make(chan struct{})
+	go func() { s.wg.Wait(); close(done) }()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// CheckHealth returns an error if the scheduler is not monitoring for jobs.
+func (s *JobScheduler) CheckHealth(ctx context.Context) (string, error) {
+	if atomic.LoadInt32(&s.monitoring) == 0 {
+		return "", ErrSchedulerNotRunning
+	}
+	return fmt.Sprintf("executing=%d", atomic.LoadInt32(&s.executing)), nil
+}
+
 // monitor waits for notifications from the job service and starts jobs.
+// The scheduler manages the queue using a system context.
 func (s *JobScheduler) monitor() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	defer atomic.StoreInt32(&s.monitoring, 0)
+
+	// Claims are interrupted on shutdown but jobs which have finished
+	// executing are always completed.
+	ctx := NewSystemContext(s.ctx)
+	completeCtx := NewSystemContext(context.Background())
 
 	// Always check once initially.
 	c := make(chan struct{}, 1)
 	c <- struct{}{}
 
+	// Poll periodically in case notifications are missed.
+	var tick <-chan time.Time
+	if s.PollInterval > 0 {
+		ticker := time.NewTicker(s.PollInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
 	for {
 		// Wait for next job or for the scheduler to close.
+		var polled bool
 		select {
 		case <-s.closing:
 			return
 
 		case <-c:
+		case <-tick:
+			polled = true
 		case <-s.JobService.C():
 		}
 
-		// Read next job.
+		// Read next job. An empty queue is expected when polling.
 		job, err := s.JobService.NextJob(ctx)
 		if err != nil {
-			fmt.Fprintf(s.LogOutput, "scheduler: next job error: err=%s\n", err)
+			schedulerNextJobErrors.Inc()
+			s.Logger.ErrorContext(ctx, "next job error", "err", err)
 			continue
 		} else if job == nil {
-			fmt.Fprintf(s.LogOutput, "scheduler: no jobs found, skipping\n")
+			if !polled {
+				s.Logger.DebugContext(ctx, "no jobs found, skipping")
+			}
 			continue
 		}
 
 		// Launch job processing in a separate goroutine.
 		s.wg.Add(1)
+		atomic.AddInt32(&s.executing, 1)
+		schedulerJobsRunning.Add(1)
 		go func(ctx context.Context, job *Job) {
 			defer s.wg.Done()
+			defer atomic.AddInt32(&s.executing, -1)
+			defer schedulerJobsRunning.Add(-1)
 			s.executeJob(ctx, job)
-		}(ctx, job)
+		}(completeCtx, job)
 	}
 }
 
-// executeJob processes a job in a separate goroutine.
+// executeJob processes a job in a separate goroutine. The job itself is
+// executed as its owner while ctx is used to complete the job.
 func (s *JobScheduler) executeJob(ctx context.Context, job *Job) {
-	// Lookup user.
-	user, err := s.UserService.FindUserByID(ctx, job.OwnerID)
-	if err != nil {
-		fmt.Fprintf(s.LogOutput, "scheduler: find job owner error: id=%d err=%q\n", job.OwnerID, err)
-		return
-	} else if user == nil {
-		fmt.Fprintf(s.LogOutput, "scheduler: job owner not found: id=%d\n", job.OwnerID)
-		return
-	}
+	ctx = newJobContext(ctx, job)
 
-	// Build context with user.
-	ctx = NewContext(ctx, user)
+	// Lookup user, if not attached by the job service.
+	user := job.Owner
+	if user == nil {
+		u, err := s.UserService.FindUserByID(ctx, job.OwnerID)
+		if err != nil {
+			schedulerJobs.Inc(job.Type, "error")
+			s.Logger.ErrorContext(ctx, "find job owner error", "err", err)
+			return
+		} else if u == nil {
+			schedulerJobs.Inc(job.Type, "error")
+			s.Logger.ErrorContext(ctx, "job owner not found")
+			return
+		}
+		user = u
+	}
 
 	// Log job start.
-	fmt.Fprintf(s.LogOutput, "scheduler: job started: id=%d user=%d\n", job.ID, job.OwnerID)
+	s.Logger.InfoContext(ctx, "job started", "type", job.Type)
+
+	// Keep the job claimed while it executes.
+	done := make(chan struct{})
+	if s.HeartbeatInterval > 0 {
+		go s.heartbeat(ctx, job, done)
+	}
 
 	// Execute job.
 	ex := JobExecutor{
-		FileService:  s.FileService,
-		SMSService:   s.SMSService,
-		TrackService: s.TrackService,
-		TTSService:   s.TTSService,
+		FileService:     s.FileService,
+		PlaylistService: s.PlaylistService,
+		SMSService:      s.SMSService,
+		TrackService:    s.TrackService,
+		TTSService:      s.TTSService,
+		UserService:     s.UserService,
 
 		URLTrackGenerator: s.URLTrackGenerator,
 	}
-	err = ex.ExecuteJob(ctx, job)
+	err := ex.ExecuteJob(newJobContext(NewContext(s.ctx, user), job), job)
+	close(done)
+
+	// Leave interrupted jobs claimed so that they are executed again.
+	if err != nil && s.ctx.Err() != nil {
+		schedulerJobs.Inc(job.Type, "interrupted")
+		s.Logger.WarnContext(ctx, "job interrupted", "err", err)
+		return
+	}
 
 	// Mark job as completed.
-	if e := s.JobService.CompleteJob(ctx, job.ID, err); e != nil {
-		fmt.Fprintf(s.LogOutput, "scheduler: complete job error: id=%d err=%s\n", job.ID, e)
+	if e := s.JobService.CompleteJob(ctx, job.ID, job.Lease, err); e != nil {
+		schedulerJobs.Inc(job.Type, "error")
+		s.Logger.ErrorContext(ctx, "complete job error", "err", e)
 		return
 	}
 
 	// Log job completion.
-	fmt.Fprintf(s.LogOutput, "scheduler: job completed: id=%d user=%d err=%q\n", job.ID, job.OwnerID, errorString(err))
+	if err != nil {
+		schedulerJobs.Inc(job.Type, "failed")
+		s.Logger.WarnContext(ctx, "job failed", "err", err)
+	} else {
+		schedulerJobs.Inc(job.Type, "completed")
+		s.Logger.InfoContext(ctx, "job completed")
+	}
+}
+
+// newJobContext returns a context which carries the job's correlation id, if
+// any. The job & user ids are attached to all records logged with it.
+func newJobContext(ctx context.Context, job *Job) context.Context {
+	if job.CorrelationID != "" {
+		ctx = NewCorrelationContext(ctx, job.CorrelationID)
+	}
+	return WithLogFields(ctx, "job_id", job.ID, "user_id", job.OwnerID)
+}
+
+// heartbeat periodically extends the claim on a job until done is closed.
+func (s *JobScheduler) heartbeat(ctx context.Context, job *Job, done <-chan struct{}) {
+	ticker := time.NewTicker(s.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := s.JobService.HeartbeatJob(ctx, job.ID, job.Lease); err != nil {
+				s.Logger.ErrorContext(ctx, "heartbeat error", "err", err)
+			}
+		}
+	}
 }
 
 // JobExecutor represents a worker that executes a job.
 type JobExecutor struct {
-	FileService  FileService
-	SMSService   SMSService
-	TrackService TrackService
-	TTSService   TTSService
+	FileService     FileService
+	PlaylistService PlaylistService
+	SMSService      SMSService
+	TrackService    TrackService
+	TTSService      TTSService
+	UserService     UserService
 
 	URLTrackGenerator URLTrackGenerator
 }
 
 // ExecuteJob processes a single job.
 func (e *JobExecutor) ExecuteJob(ctx context.Context, job *Job) error {
+	t := time.Now()
+	err := e.executeJob(ctx, job)
+
+	status := JobStatusCompleted
+	if err != nil {
+		status = JobStatusFailed
+	}
+	jobDuration.Observe(time.Since(t).Seconds(), job.Type, status)
+
+	return err
+}
+
+func (e *JobExecutor) executeJob(ctx context.Context, job *Job) error {
 	switch job.Type {
 	case JobTypeCreateTrackFromURL:
 		return e.createTrackFromURL(ctx, job)
@@ -244,9 +470,16 @@ func (e *JobExecutor) createTrackFromURL(ctx context.Context, job *Job) error {
 			return err
 		}
 
-		// Attach playlist & file to track.
+		// Attach playlist, file, source & submitted tags to track.
 		track.PlaylistID = job.PlaylistID
 		track.Filename = file.Name
+		track.SourceURL = u.String()
+		track.Tags = NormalizeTags(append(job.Tags, track.Tags...))
+
+		// Save transcript & chapters, if available.
+		if err := e.createTrackDocuments(ctx, track); err != nil {
+			return err
+		}
 
 		// Create new track.
 		if err := e.TrackService.CreateTrack(ctx, track); err != nil {
@@ -255,10 +488,15 @@ func (e *JobExecutor) createTrackFromURL(ctx context.Context, job *Job) error {
 		return nil
 	}()
 
+	// Interrupted jobs are executed again so the user is not notified.
+	if jobErr != nil && ctx.Err() != nil {
+		return jobErr
+	}
+
 	// Notify user of success/failure.
 	msg := &SMS{To: user.MobileNumber}
 	if jobErr == nil {
-		msg.Body = fmt.Sprintf(`%q has been added to your playlist.`, title)
+		msg.Body = e.successMessage(ctx, job.PlaylistID, title)
 	} else {
 		if title != "" {
 			msg.Body = fmt.Sprintf(`Unfortunately there was a problem processing %q.`, title)
@@ -267,8 +505,18 @@ func (e *JobExecutor) createTrackFromURL(ctx context.Context, job *Job) error {
 		}
 	}
 
-	if err := e.SMSService.SendSMS(ctx, msg); err != nil {
-		return err
+	// Successes are not sent if the user has muted job notifications.
+	if jobErr != nil || !user.MuteJobNotifications {
+		if err := e.SMSService.SendSMS(ctx, msg); err != nil {
+			return err
+		}
+	}
+
+	// Notify other playlist members of the new track.
+	if jobErr == nil {
+		if err := e.notifyMembers(ctx, job.PlaylistID, title); err != nil {
+			return err
+		}
 	}
 
 	return jobErr
@@ -292,37 +540,133 @@ func (e *JobExecutor) createTrackFromTTS(ctx context.Context, job *Job) error {
 			return err
 		}
 
-		// Create new track.
-		if err := e.TrackService.CreateTrack(ctx, &Track{
+		// Build track with the source text as the transcript.
+		track := &Track{
 			PlaylistID:  job.PlaylistID,
 			Filename:    file.Name,
 			Title:       job.Title,
 			ContentType: "audio/mp3",
 			Size:        int(file.Size),
-		}); err != nil {
+			Tags:        NormalizeTags(job.Tags),
+			Transcript:  &Transcript{Ext: ".txt", Body: []byte(job.Text)},
+		}
+		if err := e.createTrackDocuments(ctx, track); err != nil {
+			return err
+		}
+
+		// Create new track.
+		if err := e.TrackService.CreateTrack(ctx, track); err != nil {
 			return err
 		}
 		return nil
 	}()
 
+	// Interrupted jobs are executed again so the user is not notified.
+	if jobErr != nil && ctx.Err() != nil {
+		return jobErr
+	}
+
 	// Notify user of success/failure.
 	msg := &SMS{To: user.MobileNumber}
 	if jobErr == nil {
-		msg.Body = fmt.Sprintf(`%q has been added to your playlist.`, job.Title)
+		msg.Body = e.successMessage(ctx, job.PlaylistID, job.Title)
 	} else {
 		msg.Body = fmt.Sprintf(`Unfortunately there was a problem processing %q.`, job.Title)
 	}
 
-	if err := e.SMSService.SendSMS(ctx, msg); err != nil {
-		return err
+	// Successes are not sent if the user has muted job notifications.
+	if jobErr != nil || !user.MuteJobNotifications {
+		if err := e.SMSService.SendSMS(ctx, msg); err != nil {
+			return err
+		}
+	}
+
+	// Notify other playlist members of the new track.
+	if jobErr == nil {
+		if err := e.notifyMembers(ctx, job.PlaylistID, job.Title); err != nil {
+			return err
+		}
 	}
 
 	return jobErr
 }
 
-func errorString(err error) string {
+// successMessage returns the message sent to the submitter once a track has
+// been added. Playlists which are not owned by the user are named.
+func (e *JobExecutor) successMessage(ctx context.Context, playlistID int, title string) string {
+	if e.PlaylistService != nil {
+		if playlist, err := e.PlaylistService.FindPlaylistByID(ctx, playlistID); err == nil && playlist != nil && playlist.OwnerID != FromContext(ctx).ID {
+			return fmt.Sprintf(`%q has been added to %q.`, title, playlist.Name)
+		}
+	}
+	return fmt.Sprintf(`%q has been added to your playlist.`, title)
+}
+
+// notifyMembers sends a message to every member of a shared playlist,
+// except the submitter, when a new track has been added.
+func (e *JobExecutor) notifyMembers(ctx context.Context, playlistID int, title string) error {
+	if e.PlaylistService == nil || e.UserService == nil {
+		return nil
+	}
+	submitter := FromContext(ctx)
+
+	playlist, err := e.PlaylistService.FindPlaylistByID(ctx, playlistID)
 	if err != nil {
-		return err.Error()
+		return err
+	} else if playlist == nil {
+		return nil
+	}
+
+	members, err := e.PlaylistService.FindPlaylistMembers(ctx, playlistID)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range members {
+		if m.UserID == submitter.ID {
+			continue
+		}
+
+		// Members are looked up by the system as users can only read themselves.
+		user, err := e.UserService.FindUserByID(NewSystemContext(ctx), m.UserID)
+		if err != nil {
+			return err
+		} else if user == nil || user.MuteMemberNotifications {
+			continue
+		}
+
+		if err := e.SMSService.SendSMS(ctx, &SMS{
+			To:   user.MobileNumber,
+			Body: fmt.Sprintf(`%s added %q to %q.`, submitter.DisplayName(), title, playlist.Name),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTrackDocuments saves the track's transcript & chapters as files.
+func (e *JobExecutor) createTrackDocuments(ctx context.Context, track *Track) error {
+	if track.Transcript != nil {
+		file := &File{Name: e.FileService.GenerateName(track.Transcript.Ext)}
+		if err := e.FileService.CreateFile(ctx, file, bytes.NewReader(track.Transcript.Body)); err != nil {
+			return err
+		}
+		track.TranscriptFilename = file.Name
+	}
+
+	if len(track.Chapters) > 0 {
+		buf, err := MarshalChapters(track.Chapters)
+		if err != nil {
+			return err
+		}
+
+		file := &File{Name: e.FileService.GenerateName(ChaptersExt)}
+		if err := e.FileService.CreateFile(ctx, file, bytes.NewReader(buf)); err != nil {
+			return err
+		}
+		track.ChaptersFilename = file.Name
 	}
-	return ""
+
+	return nil
 }
@@ -2,26 +2,66 @@ package local
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 
 	"github.com/middlemost/peapod"
 )
 
+// ErrInsufficientSpace is returned by CheckHealth when the file directory's
+// filesystem has less than the minimum free space.
+const ErrInsufficientSpace = peapod.Error("insufficient free space")
+
+// DefaultMinFreeSpace is the default free space, in bytes, required to be healthy.
+const DefaultMinFreeSpace = 100 << 20
+
 // FileService represents a service for serving files from the local filesystem.
 type FileService struct {
 	Path          string
+	MinFreeSpace  int64
 	GenerateToken func() string
 }
 
 // NewFileService returns a new instance of FileService.
 func NewFileService() *FileService {
 	return &FileService{
+		MinFreeSpace:  DefaultMinFreeSpace,
 		GenerateToken: peapod.GenerateToken,
 	}
 }
 
+// CheckHealth verifies that the directory is writable and that its
+// filesystem has at least the minimum free space.
+func (s *FileService) CheckHealth(ctx context.Context) (string, error) {
+	if err := os.MkdirAll(s.Path, 0777); err != nil {
+		return "", err
+	}
+
+	// Write & remove a temporary file.
+	f, err := ioutil.TempFile(s.Path, ".peapod-health-")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	if err := os.Remove(f.Name()); err != nil {
+		return "", err
+	}
+
+	// Verify free space, if it can be determined on this platform.
+	free, err := freeSpace(s.Path)
+	if err != nil {
+		return "", err
+	} else if free < 0 {
+		return "free=unknown", nil
+	} else if free < s.MinFreeSpace {
+		return fmt.Sprintf("free=%d", free), ErrInsufficientSpace
+	}
+	return fmt.Sprintf("free=%d", free), nil
+}
+
 // GenerateName returns a randomly generated name with the given extension.
 func (s *FileService) GenerateName(ext string) string {
 	return s.GenerateToken() + ext
@@ -94,3 +134,17 @@ func (s *FileService) CreateFile(ctx context.Context, f *peapod.File, r io.Reade
 
 	return nil
 }
+
+// DeleteFile removes a file from the directory.
+func (s *FileService) DeleteFile(ctx context.Context, name string) error {
+	if name == "" {
+		return peapod.ErrFilenameRequired
+	} else if !peapod.IsValidFilename(name) {
+		return peapod.ErrInvalidFilename
+	}
+
+	if err := os.Remove(filepath.Join(s.Path, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
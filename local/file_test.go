@@ -38,6 +38,55 @@ func TestFileService(t *testing.T) {
 	}
 }
 
+// Ensure file service can delete a file.
+func TestFileService_DeleteFile(t *testing.T) {
+	s := NewFileService()
+	defer s.MustClose()
+
+	if err := s.CreateFile(context.Background(), &peapod.File{Name: "0001"}, strings.NewReader("ABC")); err != nil {
+		t.Fatal(err)
+	} else if err := s.DeleteFile(context.Background(), "0001"); err != nil {
+		t.Fatal(err)
+	}
+
+	// File should no longer exist.
+	if f, _, err := s.FindFileByName(context.Background(), "0001"); err != nil {
+		t.Fatal(err)
+	} else if f != nil {
+		t.Fatalf("unexpected file: %#v", f)
+	}
+
+	// Deleting a missing file is not an error.
+	if err := s.DeleteFile(context.Background(), "0001"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Ensure file service reports whether the directory is writable with free space.
+func TestFileService_CheckHealth(t *testing.T) {
+	s := NewFileService()
+	defer s.MustClose()
+
+	if msg, err := s.CheckHealth(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !strings.HasPrefix(msg, "free=") {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+
+	// Require more space than any filesystem has.
+	s.MinFreeSpace = 1 << 62
+	if _, err := s.CheckHealth(context.Background()); err != local.ErrInsufficientSpace {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Verify no temporary files remain.
+	if fis, err := ioutil.ReadDir(s.Path); err != nil {
+		t.Fatal(err)
+	} else if len(fis) != 0 {
+		t.Fatalf("unexpected files: %d", len(fis))
+	}
+}
+
 // FileService is a test wrapper for local.FileService.
 type FileService struct {
 	*local.FileService
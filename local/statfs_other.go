@@ -0,0 +1,7 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package local
+
+// freeSpace returns -1 as free space is not determined on this platform.
+func freeSpace(path string) (int64, error) { return -1, nil }
@@ -0,0 +1,151 @@
+package peapod
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log formats.
+const (
+	LogFormatJSON   = "json"
+	LogFormatLogfmt = "logfmt"
+)
+
+// Log errors.
+const (
+	ErrInvalidLogFormat = Error("invalid log format")
+	ErrInvalidLogLevel  = Error("invalid log level")
+)
+
+// NewLogger returns a logger which writes records to w at or above level.
+// Format is either "json" or "logfmt". Fields attached to a context by
+// WithLogFields are added to every record logged with that context.
+func NewLogger(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opt := &slog.HandlerOptions{Level: level}
+
+	var h slog.Handler
+	switch format {
+	case LogFormatJSON:
+		h = slog.NewJSONHandler(w, opt)
+	case LogFormatLogfmt:
+		h = slog.NewTextHandler(w, opt)
+	default:
+		return nil, ErrInvalidLogFormat
+	}
+	return slog.New(&contextHandler{Handler: h}), nil
+}
+
+// ParseLogLevel returns the level for a name such as "debug" or "info".
+func ParseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, ErrInvalidLogLevel
+	}
+	return level, nil
+}
+
+// DiscardLogger returns a logger which discards all records. It is used as
+// the default logger of services.
+func DiscardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(ioutil.Discard, nil))
+}
+
+// WithLogFields returns a copy of ctx with key/value pairs which are added
+// to every record logged with the returned context, such as the job & user
+// ids while a job executes.
+func WithLogFields(ctx context.Context, args ...interface{}) context.Context {
+	r := slog.NewRecord(time.Time{}, 0, "", 0)
+	r.Add(args...)
+
+	attrs := append([]slog.Attr(nil), logFields(ctx)...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, logFieldsKey, attrs)
+}
+
+// logFields returns the fields attached to ctx by WithLogFields.
+func logFields(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(logFieldsKey).([]slog.Attr)
+	return attrs
+}
+
+// contextHandler adds the fields attached to the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the context's fields to r and passes it to the wrapped handler.
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := logFields(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a handler with attrs added to every record.
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a handler which nests subsequent attributes in a group.
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// NewLogWriter returns a writer which logs each line written to it at the
+// debug level. It is used to capture the output of external commands. The
+// writer must be closed to log a final line without a trailing newline.
+func NewLogWriter(ctx context.Context, logger *slog.Logger, msg string) io.WriteCloser {
+	return &logWriter{ctx: ctx, logger: logger, msg: msg}
+}
+
+// logWriter buffers written data and logs it one line at a time.
+type logWriter struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	ctx    context.Context
+	logger *slog.Logger
+	msg    string
+}
+
+// Write logs each complete line in p and buffers any remainder.
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		w.log(string(w.buf.Next(i + 1)))
+	}
+	return len(p), nil
+}
+
+// Close logs any remaining partial line.
+func (w *logWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		w.log(w.buf.String())
+		w.buf.Reset()
+	}
+	return nil
+}
+
+func (w *logWriter) log(line string) {
+	if line = strings.TrimRight(line, "\r\n"); line != "" {
+		w.logger.DebugContext(w.ctx, w.msg, "line", line)
+	}
+}
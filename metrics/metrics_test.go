@@ -0,0 +1,74 @@
+package metrics_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/middlemost/peapod/metrics"
+)
+
+// Ensure metrics are written in the Prometheus text format.
+func TestRegistry_WriteTo(t *testing.T) {
+	r := metrics.NewRegistry()
+
+	c := r.NewCounterVec("test_requests_total", "Total requests.", "method", "code")
+	c.Inc("GET", "200")
+	c.Add(2, "GET", "200")
+	c.Inc("POST", "500")
+
+	g := r.NewGaugeVec("test_running", "Running jobs.")
+	g.Add(3)
+	g.Add(-1)
+
+	h := r.NewHistogramVec("test_duration_seconds", "Request duration.", []float64{0.1, 1}, "route")
+	h.Observe(0.05, `/a"b`)
+	h.Observe(0.5, `/a"b`)
+	h.Observe(5, `/a"b`)
+
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	} else if buf.String() != `# HELP test_duration_seconds Request duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/a\"b",le="0.1"} 1
+test_duration_seconds_bucket{route="/a\"b",le="1"} 2
+test_duration_seconds_bucket{route="/a\"b",le="+Inf"} 3
+test_duration_seconds_sum{route="/a\"b"} 5.55
+test_duration_seconds_count{route="/a\"b"} 3
+# HELP test_requests_total Total requests.
+# TYPE test_requests_total counter
+test_requests_total{method="GET",code="200"} 3
+test_requests_total{method="POST",code="500"} 1
+# HELP test_running Running jobs.
+# TYPE test_running gauge
+test_running 2
+` {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
+
+// Ensure registering a duplicate metric name panics.
+func TestRegistry_Duplicate(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.NewCounterVec("test_total", "")
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	r.NewGaugeVec("test_total", "")
+}
+
+// Ensure metrics without labels are reported before they are updated.
+func TestRegistry_WriteTo_Unlabeled(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.NewCounterVec("test_errors_total", "Errors.")
+
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	} else if buf.String() != "# HELP test_errors_total Errors.\n# TYPE test_errors_total counter\ntest_errors_total 0\n" {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
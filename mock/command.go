@@ -0,0 +1,28 @@
+package mock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// MustFakeCommand installs a shell script as name at the front of the PATH
+// until the test completes.
+func MustFakeCommand(tb testing.TB, name, script string) {
+	tb.Helper()
+
+	dir, err := ioutil.TempDir("", "peapod-bin-")
+	if err != nil {
+		tb.Fatal(err)
+	} else if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		tb.Fatal(err)
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	tb.Cleanup(func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	})
+}
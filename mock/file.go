@@ -13,6 +13,7 @@ type FileService struct {
 	GenerateNameFn   func(ext string) string
 	FindFileByNameFn func(ctx context.Context, name string) (*peapod.File, io.ReadCloser, error)
 	CreateFileFn     func(ctx context.Context, f *peapod.File, r io.Reader) error
+	DeleteFileFn     func(ctx context.Context, name string) error
 }
 
 func (s *FileService) GenerateName(ext string) string {
@@ -26,3 +27,7 @@ func (s *FileService) FindFileByName(ctx context.Context, name string) (*peapod.
 func (s *FileService) CreateFile(ctx context.Context, f *peapod.File, r io.Reader) error {
 	return s.CreateFileFn(ctx, f, r)
 }
+
+func (s *FileService) DeleteFile(ctx context.Context, name string) error {
+	return s.DeleteFileFn(ctx, name)
+}
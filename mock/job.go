@@ -10,10 +10,15 @@ var _ peapod.JobService = &JobService{}
 
 // JobService manages jobs in a job queue.
 type JobService struct {
-	CFn           func() <-chan struct{}
-	CreateJobFn   func(ctx context.Context, job *peapod.Job) error
-	NextJobFn     func(ctx context.Context) (*peapod.Job, error)
-	CompleteJobFn func(ctx context.Context, id int, err error) error
+	CFn                 func() <-chan struct{}
+	CreateJobFn         func(ctx context.Context, job *peapod.Job) error
+	NextJobFn           func(ctx context.Context) (*peapod.Job, error)
+	CompleteJobFn       func(ctx context.Context, id int, lease string, err error) error
+	HeartbeatJobFn      func(ctx context.Context, id int, lease string) error
+	FindJobsFn          func(ctx context.Context, filter peapod.JobFilter) ([]*peapod.Job, error)
+	RetryJobFn          func(ctx context.Context, id int) (*peapod.Job, error)
+	CancelJobFn         func(ctx context.Context, id int) (*peapod.Job, error)
+	FindJobQueueStatsFn func(ctx context.Context) (*peapod.JobQueueStats, error)
 }
 
 func (s *JobService) C() <-chan struct{} {
@@ -28,6 +33,26 @@ func (s *JobService) NextJob(ctx context.Context) (*peapod.Job, error) {
 	return s.NextJobFn(ctx)
 }
 
-func (s *JobService) CompleteJob(ctx context.Context, id int, err error) error {
-	return s.CompleteJobFn(ctx, id, err)
+func (s *JobService) CompleteJob(ctx context.Context, id int, lease string, err error) error {
+	return s.CompleteJobFn(ctx, id, lease, err)
+}
+
+func (s *JobService) HeartbeatJob(ctx context.Context, id int, lease string) error {
+	return s.HeartbeatJobFn(ctx, id, lease)
+}
+
+func (s *JobService) FindJobs(ctx context.Context, filter peapod.JobFilter) ([]*peapod.Job, error) {
+	return s.FindJobsFn(ctx, filter)
+}
+
+func (s *JobService) RetryJob(ctx context.Context, id int) (*peapod.Job, error) {
+	return s.RetryJobFn(ctx, id)
+}
+
+func (s *JobService) CancelJob(ctx context.Context, id int) (*peapod.Job, error) {
+	return s.CancelJobFn(ctx, id)
+}
+
+func (s *JobService) FindJobQueueStats(ctx context.Context) (*peapod.JobQueueStats, error) {
+	return s.FindJobQueueStatsFn(ctx)
 }
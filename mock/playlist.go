@@ -12,6 +12,16 @@ type PlaylistService struct {
 	FindPlaylistByIDFn      func(ctx context.Context, id int) (*peapod.Playlist, error)
 	FindPlaylistByTokenFn   func(ctx context.Context, token string) (*peapod.Playlist, error)
 	FindPlaylistsByUserIDFn func(ctx context.Context, id int) ([]*peapod.Playlist, error)
+	CreatePlaylistFn        func(ctx context.Context, playlist *peapod.Playlist) error
+	RotateTokenFn           func(ctx context.Context, id int) (*peapod.Playlist, error)
+
+	SetPlaylistRetentionFn       func(ctx context.Context, id int, retention *peapod.Retention) error
+	FindPlaylistsWithRetentionFn func(ctx context.Context) ([]*peapod.Playlist, error)
+
+	FindPlaylistMembersFn               func(ctx context.Context, playlistID int) ([]*peapod.PlaylistMember, error)
+	CreatePlaylistInviteFn              func(ctx context.Context, invite *peapod.PlaylistInvite) error
+	FindPlaylistInvitesByMobileNumberFn func(ctx context.Context, mobileNumber string) ([]*peapod.PlaylistInvite, error)
+	AcceptPlaylistInviteFn              func(ctx context.Context, id int) (*peapod.PlaylistMember, error)
 }
 
 func (s *PlaylistService) FindPlaylistByID(ctx context.Context, id int) (*peapod.Playlist, error) {
@@ -25,3 +35,35 @@ func (s *PlaylistService) FindPlaylistByToken(ctx context.Context, token string)
 func (s *PlaylistService) FindPlaylistsByUserID(ctx context.Context, id int) ([]*peapod.Playlist, error) {
 	return s.FindPlaylistsByUserIDFn(ctx, id)
 }
+
+func (s *PlaylistService) CreatePlaylist(ctx context.Context, playlist *peapod.Playlist) error {
+	return s.CreatePlaylistFn(ctx, playlist)
+}
+
+func (s *PlaylistService) SetPlaylistRetention(ctx context.Context, id int, retention *peapod.Retention) error {
+	return s.SetPlaylistRetentionFn(ctx, id, retention)
+}
+
+func (s *PlaylistService) FindPlaylistsWithRetention(ctx context.Context) ([]*peapod.Playlist, error) {
+	return s.FindPlaylistsWithRetentionFn(ctx)
+}
+
+func (s *PlaylistService) RotateToken(ctx context.Context, id int) (*peapod.Playlist, error) {
+	return s.RotateTokenFn(ctx, id)
+}
+
+func (s *PlaylistService) FindPlaylistMembers(ctx context.Context, playlistID int) ([]*peapod.PlaylistMember, error) {
+	return s.FindPlaylistMembersFn(ctx, playlistID)
+}
+
+func (s *PlaylistService) CreatePlaylistInvite(ctx context.Context, invite *peapod.PlaylistInvite) error {
+	return s.CreatePlaylistInviteFn(ctx, invite)
+}
+
+func (s *PlaylistService) FindPlaylistInvitesByMobileNumber(ctx context.Context, mobileNumber string) ([]*peapod.PlaylistInvite, error) {
+	return s.FindPlaylistInvitesByMobileNumberFn(ctx, mobileNumber)
+}
+
+func (s *PlaylistService) AcceptPlaylistInvite(ctx context.Context, id int) (*peapod.PlaylistMember, error) {
+	return s.AcceptPlaylistInviteFn(ctx, id)
+}
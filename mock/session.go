@@ -0,0 +1,32 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/middlemost/peapod"
+)
+
+var _ peapod.SessionService = &SessionService{}
+
+type SessionService struct {
+	CreateLoginCodeFn    func(ctx context.Context, mobileNumber string) (string, error)
+	CreateSessionFn      func(ctx context.Context, mobileNumber, code string) (*peapod.Session, error)
+	FindSessionByTokenFn func(ctx context.Context, token string) (*peapod.Session, error)
+	DeleteSessionFn      func(ctx context.Context, token string) error
+}
+
+func (s *SessionService) CreateLoginCode(ctx context.Context, mobileNumber string) (string, error) {
+	return s.CreateLoginCodeFn(ctx, mobileNumber)
+}
+
+func (s *SessionService) CreateSession(ctx context.Context, mobileNumber, code string) (*peapod.Session, error) {
+	return s.CreateSessionFn(ctx, mobileNumber, code)
+}
+
+func (s *SessionService) FindSessionByToken(ctx context.Context, token string) (*peapod.Session, error) {
+	return s.FindSessionByTokenFn(ctx, token)
+}
+
+func (s *SessionService) DeleteSession(ctx context.Context, token string) error {
+	return s.DeleteSessionFn(ctx, token)
+}
@@ -13,6 +13,17 @@ var _ peapod.TrackService = &TrackService{}
 type TrackService struct {
 	FindTrackByIDFn func(ctx context.Context, id int) (*peapod.Track, error)
 	CreateTrackFn   func(ctx context.Context, track *peapod.Track) error
+
+	DeleteTrackFn       func(ctx context.Context, id int, reason string) error
+	FindTrashedTracksFn func(ctx context.Context) ([]*peapod.Track, error)
+	RestoreTrackFn      func(ctx context.Context, id int) (*peapod.Track, error)
+	UndoDeleteTrackFn   func(ctx context.Context) (*peapod.Track, error)
+	PurgeTrashFn        func(ctx context.Context) ([]*peapod.Track, error)
+	PurgeTrackFn        func(ctx context.Context, id int) (*peapod.Track, error)
+	SetTrackPinnedFn    func(ctx context.Context, id int, pinned bool) error
+	FindTrackRemovalsFn func(ctx context.Context, playlistID int) ([]*peapod.TrackRemoval, error)
+	SearchTracksFn      func(ctx context.Context, search peapod.TrackSearch) (*peapod.TrackSearchResult, error)
+	FindTracksFn        func(ctx context.Context, filter peapod.TrackFilter) (*peapod.TrackPage, error)
 }
 
 func (s *TrackService) FindTrackByID(ctx context.Context, id int) (*peapod.Track, error) {
@@ -23,6 +34,46 @@ func (s *TrackService) CreateTrack(ctx context.Context, track *peapod.Track) err
 	return s.CreateTrackFn(ctx, track)
 }
 
+func (s *TrackService) DeleteTrack(ctx context.Context, id int, reason string) error {
+	return s.DeleteTrackFn(ctx, id, reason)
+}
+
+func (s *TrackService) FindTrashedTracks(ctx context.Context) ([]*peapod.Track, error) {
+	return s.FindTrashedTracksFn(ctx)
+}
+
+func (s *TrackService) RestoreTrack(ctx context.Context, id int) (*peapod.Track, error) {
+	return s.RestoreTrackFn(ctx, id)
+}
+
+func (s *TrackService) UndoDeleteTrack(ctx context.Context) (*peapod.Track, error) {
+	return s.UndoDeleteTrackFn(ctx)
+}
+
+func (s *TrackService) PurgeTrash(ctx context.Context) ([]*peapod.Track, error) {
+	return s.PurgeTrashFn(ctx)
+}
+
+func (s *TrackService) PurgeTrack(ctx context.Context, id int) (*peapod.Track, error) {
+	return s.PurgeTrackFn(ctx, id)
+}
+
+func (s *TrackService) SetTrackPinned(ctx context.Context, id int, pinned bool) error {
+	return s.SetTrackPinnedFn(ctx, id, pinned)
+}
+
+func (s *TrackService) FindTrackRemovals(ctx context.Context, playlistID int) ([]*peapod.TrackRemoval, error) {
+	return s.FindTrackRemovalsFn(ctx, playlistID)
+}
+
+func (s *TrackService) SearchTracks(ctx context.Context, search peapod.TrackSearch) (*peapod.TrackSearchResult, error) {
+	return s.SearchTracksFn(ctx, search)
+}
+
+func (s *TrackService) FindTracks(ctx context.Context, filter peapod.TrackFilter) (*peapod.TrackPage, error) {
+	return s.FindTracksFn(ctx, filter)
+}
+
 var _ peapod.URLTrackGenerator = &URLTrackGenerator{}
 
 type URLTrackGenerator struct {
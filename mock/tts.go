@@ -0,0 +1,18 @@
+package mock
+
+import (
+	"context"
+	"io"
+
+	"github.com/middlemost/peapod"
+)
+
+var _ peapod.TTSService = &TTSService{}
+
+type TTSService struct {
+	SynthesizeSpeechFn func(ctx context.Context, text string) (io.ReadCloser, error)
+}
+
+func (s *TTSService) SynthesizeSpeech(ctx context.Context, text string) (io.ReadCloser, error) {
+	return s.SynthesizeSpeechFn(ctx, text)
+}
@@ -12,6 +12,12 @@ type UserService struct {
 	FindUserByIDFn           func(ctx context.Context, id int) (*peapod.User, error)
 	FindUserByMobileNumberFn func(ctx context.Context, mobileNumber string) (*peapod.User, error)
 	CreateUserFn             func(ctx context.Context, user *peapod.User) error
+	UpdateUserFn             func(ctx context.Context, id int, upd peapod.UserUpdate) (*peapod.User, error)
+	RequestUserDeletionFn    func(ctx context.Context, id int) (string, error)
+	ConfirmUserDeletionFn    func(ctx context.Context, id int, code string) ([]*peapod.Track, error)
+	UndoLastActionFn         func(ctx context.Context, id int) (*peapod.UndoAction, error)
+	FindUsersFn              func(ctx context.Context, filter peapod.UserFilter) ([]*peapod.User, error)
+	UpdateUserAccessFn       func(ctx context.Context, id int, upd peapod.UserAccessUpdate) (*peapod.User, error)
 }
 
 func (s *UserService) FindUserByID(ctx context.Context, id int) (*peapod.User, error) {
@@ -25,3 +31,27 @@ func (s *UserService) FindUserByMobileNumber(ctx context.Context, mobileNumber s
 func (s *UserService) CreateUser(ctx context.Context, user *peapod.User) error {
 	return s.CreateUserFn(ctx, user)
 }
+
+func (s *UserService) UpdateUser(ctx context.Context, id int, upd peapod.UserUpdate) (*peapod.User, error) {
+	return s.UpdateUserFn(ctx, id, upd)
+}
+
+func (s *UserService) RequestUserDeletion(ctx context.Context, id int) (string, error) {
+	return s.RequestUserDeletionFn(ctx, id)
+}
+
+func (s *UserService) ConfirmUserDeletion(ctx context.Context, id int, code string) ([]*peapod.Track, error) {
+	return s.ConfirmUserDeletionFn(ctx, id, code)
+}
+
+func (s *UserService) UndoLastAction(ctx context.Context, id int) (*peapod.UndoAction, error) {
+	return s.UndoLastActionFn(ctx, id)
+}
+
+func (s *UserService) FindUsers(ctx context.Context, filter peapod.UserFilter) ([]*peapod.User, error) {
+	return s.FindUsersFn(ctx, filter)
+}
+
+func (s *UserService) UpdateUserAccess(ctx context.Context, id int, upd peapod.UserAccessUpdate) (*peapod.User, error) {
+	return s.UpdateUserAccessFn(ctx, id, upd)
+}
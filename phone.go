@@ -0,0 +1,89 @@
+package peapod
+
+import (
+	"strings"
+)
+
+// Phone errors.
+const (
+	ErrInvalidMobileNumber = Error("invalid mobile number")
+	ErrInvalidRegion       = Error("invalid region")
+)
+
+// DefaultRegion is the region used to interpret numbers without a country code.
+const DefaultRegion = "US"
+
+// region represents the dialing conventions of a region.
+type region struct {
+	callingCode string // country calling code
+	trunkPrefix string // national prefix dropped in international format
+}
+
+// regions maps ISO 3166 region codes to their dialing conventions.
+// Regions such as Italy & Spain have no trunk prefix so a leading zero is
+// part of the subscriber number.
+var regions = map[string]region{
+	"US": {"1", ""}, "CA": {"1", ""}, "PR": {"1", ""},
+	"GB": {"44", "0"}, "IE": {"353", "0"}, "FR": {"33", "0"}, "DE": {"49", "0"},
+	"ES": {"34", ""}, "IT": {"39", ""}, "NL": {"31", "0"}, "BE": {"32", "0"},
+	"CH": {"41", "0"}, "AT": {"43", "0"}, "SE": {"46", "0"}, "NO": {"47", ""},
+	"DK": {"45", ""}, "FI": {"358", "0"}, "PL": {"48", ""}, "PT": {"351", ""},
+	"AU": {"61", "0"}, "NZ": {"64", "0"}, "JP": {"81", "0"}, "KR": {"82", "0"},
+	"CN": {"86", "0"}, "IN": {"91", "0"}, "SG": {"65", ""}, "HK": {"852", ""},
+	"MX": {"52", ""}, "BR": {"55", "0"}, "AR": {"54", "0"}, "ZA": {"27", "0"},
+}
+
+// IsValidRegion returns true if region is a supported region code.
+func IsValidRegion(region string) bool {
+	_, ok := regions[strings.ToUpper(region)]
+	return ok
+}
+
+// NormalizeMobileNumber returns s in E.164 format (e.g. "+15555550100").
+// Numbers without a country code are interpreted using the national
+// numbering conventions of region. Formatting characters are ignored.
+func NormalizeMobileNumber(s, region string) (string, error) {
+	r, ok := regions[strings.ToUpper(region)]
+	if !ok {
+		return "", ErrInvalidRegion
+	}
+	code := r.callingCode
+
+	// Strip formatting characters & reject anything else.
+	s = strings.TrimSpace(s)
+	international := strings.HasPrefix(s, "+")
+	digits := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == ' ', c == '-', c == '.', c == '(', c == ')':
+		case c == '+' && i == 0:
+		default:
+			return "", ErrInvalidMobileNumber
+		}
+	}
+	number := string(digits)
+
+	// Determine the full number including the country code.
+	switch {
+	case international:
+	case code == "1" && strings.HasPrefix(number, "011"):
+		number = number[3:]
+	case code != "1" && strings.HasPrefix(number, "00"):
+		number = number[2:]
+	case code == "1" && len(number) == 11 && strings.HasPrefix(number, "1"):
+	case code == "1":
+		number = code + number
+	default:
+		number = code + strings.TrimPrefix(number, r.trunkPrefix)
+	}
+
+	// North American numbers have a fixed length. E.164 allows at most 15 digits.
+	if strings.HasPrefix(number, "1") && len(number) != 11 {
+		return "", ErrInvalidMobileNumber
+	} else if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return "", ErrInvalidMobileNumber
+	}
+	return "+" + number, nil
+}
@@ -0,0 +1,71 @@
+package peapod
+
+import (
+	"strings"
+	"testing"
+)
+
+// Ensure national & international numbers are normalized for every region.
+func TestNormalizeMobileNumber(t *testing.T) {
+	tested := make(map[string]bool)
+	for _, tt := range []struct {
+		region string
+		s      string
+		want   string
+		err    error
+	}{
+		{"US", "(555) 555-0100", "+15555550100", nil},
+		{"US", "1-555-555-0100", "+15555550100", nil},
+		{"US", "011 44 7700 900123", "+447700900123", nil},
+		{"US", "555-0100", "", ErrInvalidMobileNumber},
+		{"CA", "416-555-0100", "+14165550100", nil},
+		{"PR", "787 555 0100", "+17875550100", nil},
+		{"GB", "07700 900123", "+447700900123", nil},
+		{"GB", "+44 7700 900123", "+447700900123", nil},
+		{"GB", "0044 7700 900123", "+447700900123", nil},
+		{"IE", "085 123 4567", "+353851234567", nil},
+		{"FR", "06 12 34 56 78", "+33612345678", nil},
+		{"DE", "0151 23456789", "+4915123456789", nil},
+		{"ES", "612 34 56 78", "+34612345678", nil},
+		{"IT", "312 345 6789", "+393123456789", nil},
+		{"IT", "06 1234 5678", "+390612345678", nil},
+		{"NL", "06 12345678", "+31612345678", nil},
+		{"BE", "0470 12 34 56", "+32470123456", nil},
+		{"CH", "078 123 45 67", "+41781234567", nil},
+		{"AT", "0664 1234567", "+436641234567", nil},
+		{"SE", "070-123 45 67", "+46701234567", nil},
+		{"NO", "412 34 567", "+4741234567", nil},
+		{"DK", "20 12 34 56", "+4520123456", nil},
+		{"FI", "041 2345678", "+358412345678", nil},
+		{"PL", "512 345 678", "+48512345678", nil},
+		{"PT", "912 345 678", "+351912345678", nil},
+		{"AU", "0412 345 678", "+61412345678", nil},
+		{"NZ", "021 123 4567", "+64211234567", nil},
+		{"JP", "090-1234-5678", "+819012345678", nil},
+		{"KR", "010-1234-5678", "+821012345678", nil},
+		{"CN", "138 0013 8000", "+8613800138000", nil},
+		{"IN", "098765 43210", "+919876543210", nil},
+		{"SG", "8123 4567", "+6581234567", nil},
+		{"HK", "5123 4567", "+85251234567", nil},
+		{"MX", "55 1234 5678", "+525512345678", nil},
+		{"BR", "(011) 91234-5678", "+5511912345678", nil},
+		{"AR", "011 1234-5678", "+541112345678", nil},
+		{"ZA", "082 123 4567", "+27821234567", nil},
+		{"za", "082 123 4567", "+27821234567", nil},
+		{"GB", "07700 900123x", "", ErrInvalidMobileNumber},
+		{"XX", "07700 900123", "", ErrInvalidRegion},
+	} {
+		tested[strings.ToUpper(tt.region)] = true
+		if got, err := NormalizeMobileNumber(tt.s, tt.region); err != tt.err {
+			t.Errorf("%s %q: unexpected error: %v", tt.region, tt.s, err)
+		} else if got != tt.want {
+			t.Errorf("%s %q: got %q, want %q", tt.region, tt.s, got, tt.want)
+		}
+	}
+
+	for region := range regions {
+		if !tested[region] {
+			t.Errorf("region not tested: %s", region)
+		}
+	}
+}
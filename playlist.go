@@ -2,6 +2,7 @@ package peapod
 
 import (
 	"context"
+	"sort"
 	"time"
 )
 
@@ -12,18 +13,57 @@ const (
 	ErrPlaylistOwnerRequired = Error("playlist owner required")
 	ErrPlaylistTokenRequired = Error("playlist token required")
 	ErrPlaylistNameRequired  = Error("playlist name required")
+	ErrInvalidPlaylistType   = Error("invalid playlist type")
+	ErrSmartFilterRequired   = Error("smart playlist filter required")
+	ErrPlaylistNotWritable   = Error("playlist not writable")
+	ErrMergedSourcesRequired = Error("merged playlist sources required")
+	ErrInvalidMergedSource   = Error("invalid merged playlist source")
+)
+
+// Playlist membership errors.
+const (
+	ErrPlaylistInviteRequired     = Error("playlist invite required")
+	ErrPlaylistInviteNotFound     = Error("playlist invite not found")
+	ErrPlaylistMemberExists       = Error("playlist member already exists")
+	ErrInvalidPlaylistRole        = Error("invalid playlist role")
+	ErrInviteMobileNumberRequired = Error("invite mobile number required")
 )
 
 const DefaultPlaylistName = "My Peapod"
 
+// Playlist types.
+const (
+	PlaylistTypeStandard = ""
+	PlaylistTypeSmart    = "smart"
+	PlaylistTypeMerged   = "merged"
+)
+
+// IsValidPlaylistType returns true if v is a valid type.
+func IsValidPlaylistType(v string) bool {
+	switch v {
+	case PlaylistTypeStandard, PlaylistTypeSmart, PlaylistTypeMerged:
+		return true
+	default:
+		return false
+	}
+}
+
 // Playlist represents a time-ordered list of tracks.
+//
+// Smart playlists do not contain tracks directly. Instead their tracks are
+// the owner's tracks which match the playlist's filter. Merged playlists
+// combine the tracks of several standard playlists.
 type Playlist struct {
-	ID        int       `json:"id"`
-	OwnerID   int       `json:"owner_id"`
-	Token     string    `json:"token"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int          `json:"id"`
+	OwnerID   int          `json:"owner_id"`
+	Type      string       `json:"type,omitempty"`
+	Token     string       `json:"token"`
+	Name      string       `json:"name"`
+	Filter    *SmartFilter `json:"filter,omitempty"`
+	SourceIDs []int        `json:"source_ids,omitempty"`
+	Retention *Retention   `json:"retention,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
 
 	Tracks []*Track `json:"tracks,omitempty"`
 }
@@ -39,9 +79,181 @@ func (p *Playlist) LastTrackUpdatedAt() time.Time {
 	return max
 }
 
+// MergeTracks returns the tracks of all playlists interleaved by creation date.
+func MergeTracks(playlists ...*Playlist) []*Track {
+	var a []*Track
+	for _, p := range playlists {
+		a = append(a, p.Tracks...)
+	}
+	sort.SliceStable(a, func(i, j int) bool {
+		if !a[i].CreatedAt.Equal(a[j].CreatedAt) {
+			return a[i].CreatedAt.Before(a[j].CreatedAt)
+		}
+		return a[i].ID < a[j].ID
+	})
+	return a
+}
+
+// Retention represents limits on the tracks kept by a playlist. Once a limit
+// is exceeded the oldest unpinned tracks are removed. Zero values are ignored.
+type Retention struct {
+	MaxAge    time.Duration `json:"max_age,omitempty"`
+	MaxTracks int           `json:"max_tracks,omitempty"`
+	MaxBytes  int           `json:"max_bytes,omitempty"`
+}
+
+// IsZero returns true if no limits are set.
+func (r *Retention) IsZero() bool {
+	return r == nil || (r.MaxAge <= 0 && r.MaxTracks <= 0 && r.MaxBytes <= 0)
+}
+
+// ExpiredTracks returns the tracks which exceed the retention limits, oldest
+// first. Pinned tracks are never expired but still count toward the limits.
+func (r *Retention) ExpiredTracks(tracks []*Track, now time.Time) []*Track {
+	if r.IsZero() {
+		return nil
+	}
+
+	// Sort newest first so limits are applied to the oldest tracks.
+	a := make([]*Track, len(tracks))
+	copy(a, tracks)
+	sort.SliceStable(a, func(i, j int) bool {
+		if !a[i].CreatedAt.Equal(a[j].CreatedAt) {
+			return a[i].CreatedAt.After(a[j].CreatedAt)
+		}
+		return a[i].ID > a[j].ID
+	})
+
+	// Pinned tracks always count toward the limits.
+	var n, size int
+	for _, track := range a {
+		if track.Pinned {
+			n, size = n+1, size+track.Size
+		}
+	}
+
+	// Keep the newest unpinned tracks until a limit is exceeded.
+	var expired []*Track
+	for _, track := range a {
+		if track.Pinned {
+			continue
+		}
+
+		if (r.MaxAge > 0 && track.CreatedAt.Before(now.Add(-r.MaxAge))) ||
+			(r.MaxTracks > 0 && n+1 > r.MaxTracks) ||
+			(r.MaxBytes > 0 && size+track.Size > r.MaxBytes) {
+			expired = append(expired, track)
+			continue
+		}
+		n, size = n+1, size+track.Size
+	}
+
+	// Return oldest first.
+	for i, j := 0, len(expired)-1; i < j; i, j = i+1, j-1 {
+		expired[i], expired[j] = expired[j], expired[i]
+	}
+	return expired
+}
+
+// SmartFilter represents a saved query over a user's tracks.
+// All non-zero conditions must match for a track to be included.
+type SmartFilter struct {
+	Tags        []string      `json:"tags,omitempty"`         // track must have every tag
+	MaxAge      time.Duration `json:"max_age,omitempty"`      // added within duration
+	MinDuration time.Duration `json:"min_duration,omitempty"` // track length at least
+	MaxDuration time.Duration `json:"max_duration,omitempty"` // track length less than
+}
+
+// Match returns true if track matches all conditions of the filter.
+func (f *SmartFilter) Match(track *Track, now time.Time) bool {
+	for _, tag := range f.Tags {
+		if !track.HasTag(tag) {
+			return false
+		}
+	}
+	if f.MaxAge > 0 && track.CreatedAt.Before(now.Add(-f.MaxAge)) {
+		return false
+	}
+	if f.MinDuration > 0 && track.Duration < f.MinDuration {
+		return false
+	}
+	if f.MaxDuration > 0 && track.Duration >= f.MaxDuration {
+		return false
+	}
+	return true
+}
+
+// Playlist member roles.
+const (
+	PlaylistRoleOwner       = "owner"
+	PlaylistRoleContributor = "contributor"
+	PlaylistRoleListener    = "listener"
+)
+
+// IsValidPlaylistRole returns true if v is a valid role.
+func IsValidPlaylistRole(v string) bool {
+	switch v {
+	case PlaylistRoleOwner, PlaylistRoleContributor, PlaylistRoleListener:
+		return true
+	default:
+		return false
+	}
+}
+
+// CanContribute returns true if the role allows adding tracks to a playlist.
+func CanContribute(role string) bool {
+	return role == PlaylistRoleOwner || role == PlaylistRoleContributor
+}
+
+// PlaylistMember represents a user's role on a playlist.
+// The playlist owner is always a member with the owner role.
+type PlaylistMember struct {
+	PlaylistID int       `json:"playlist_id"`
+	UserID     int       `json:"user_id"`
+	Role       string    `json:"role"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// PlaylistInvite represents a pending invitation for a mobile number to
+// become a member of a playlist.
+type PlaylistInvite struct {
+	ID           int       `json:"id"`
+	PlaylistID   int       `json:"playlist_id"`
+	InviterID    int       `json:"inviter_id"`
+	MobileNumber string    `json:"mobile_number"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
 // PlaylistService represents a service for managing playlists.
 type PlaylistService interface {
 	FindPlaylistByID(ctx context.Context, id int) (*Playlist, error)
 	FindPlaylistByToken(ctx context.Context, token string) (*Playlist, error)
+
+	// Returns playlists owned by the user followed by playlists that the
+	// user is a member of. The user's default playlist is always first.
 	FindPlaylistsByUserID(ctx context.Context, id int) ([]*Playlist, error)
+
+	// Creates a new playlist owned by the current user.
+	CreatePlaylist(ctx context.Context, playlist *Playlist) error
+
+	// Sets the retention limits for a playlist. A nil value removes them.
+	// Only the owner can change the retention limits.
+	SetPlaylistRetention(ctx context.Context, id int, retention *Retention) error
+
+	// Returns all playlists which have retention limits.
+	FindPlaylistsWithRetention(ctx context.Context) ([]*Playlist, error)
+
+	// Generates a new token for a playlist. The previous token continues
+	// to resolve to the playlist for a grace period before it is removed.
+	RotateToken(ctx context.Context, id int) (*Playlist, error)
+
+	// Membership management. Invites can only be created by the owner and
+	// can only be accepted by the user with the invited mobile number.
+	FindPlaylistMembers(ctx context.Context, playlistID int) ([]*PlaylistMember, error)
+	CreatePlaylistInvite(ctx context.Context, invite *PlaylistInvite) error
+	FindPlaylistInvitesByMobileNumber(ctx context.Context, mobileNumber string) ([]*PlaylistInvite, error)
+	AcceptPlaylistInvite(ctx context.Context, id int) (*PlaylistMember, error)
 }
@@ -0,0 +1,143 @@
+package peapod
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultRetentionSweepInterval is the default time between retention sweeps.
+const DefaultRetentionSweepInterval = 1 * time.Hour
+
+// Retention errors.
+const (
+	ErrInvalidSweepInterval = Error("retention sweep interval must be positive")
+)
+
+// RetentionSweeper periodically moves tracks which exceed the retention
+// limits of their playlist to the trash and purges expired trash.
+type RetentionSweeper struct {
+	once    sync.Once
+	closing chan struct{}
+	wg      sync.WaitGroup
+
+	// Time between sweeps.
+	Interval time.Duration
+
+	FileService     FileService
+	PlaylistService PlaylistService
+	TrackService    TrackService
+	UserService     UserService
+
+	Now    func() time.Time
+	Logger *slog.Logger
+}
+
+// NewRetentionSweeper returns a new instance of RetentionSweeper.
+func NewRetentionSweeper() *RetentionSweeper {
+	return &RetentionSweeper{
+		closing:  make(chan struct{}),
+		Interval: DefaultRetentionSweepInterval,
+		Now:      time.Now,
+		Logger:   DiscardLogger(),
+	}
+}
+
+// Open starts the background sweep.
+func (s *RetentionSweeper) Open() error {
+	if s.Interval <= 0 {
+		return ErrInvalidSweepInterval
+	}
+
+	s.wg.Add(1)
+	go func() { defer s.wg.Done(); s.monitor() }()
+	return nil
+}
+
+// Close stops the background sweep and waits for it to finish.
+func (s *RetentionSweeper) Close() error {
+	s.once.Do(func() { close(s.closing) })
+	s.wg.Wait()
+	return nil
+}
+
+// monitor runs a sweep on every interval until the sweeper is closed.
+func (s *RetentionSweeper) monitor() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = NewSystemContext(ctx)
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Sweep(ctx); err != nil {
+			s.Logger.ErrorContext(ctx, "sweep error", "err", err)
+		}
+
+		select {
+		case <-s.closing:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep removes expired tracks from all playlists with retention limits and
+// then permanently deletes expired trash. Requires a system context.
+func (s *RetentionSweeper) Sweep(ctx context.Context) error {
+	playlists, err := s.PlaylistService.FindPlaylistsWithRetention(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, playlist := range playlists {
+		if err := s.sweepPlaylist(ctx, playlist); err != nil {
+			s.Logger.ErrorContext(ctx, "sweep playlist error", "playlist_id", playlist.ID, "err", err)
+		}
+	}
+
+	return s.purgeTrash(ctx)
+}
+
+// purgeTrash removes expired tracks from the trash along with their files.
+func (s *RetentionSweeper) purgeTrash(ctx context.Context) error {
+	tracks, err := s.TrackService.PurgeTrash(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, track := range tracks {
+		if err := DeleteTrackFiles(ctx, s.FileService, []*Track{track}); err != nil {
+			return err
+		}
+		s.Logger.InfoContext(ctx, "track purged", "track_id", track.ID, "playlist_id", track.PlaylistID, "size", track.Size)
+	}
+	return nil
+}
+
+// sweepPlaylist moves the expired tracks of a single playlist to the trash.
+func (s *RetentionSweeper) sweepPlaylist(ctx context.Context, playlist *Playlist) error {
+	tracks := playlist.Retention.ExpiredTracks(playlist.Tracks, s.Now())
+	if len(tracks) == 0 {
+		return nil
+	}
+
+	// Remove tracks as the playlist owner. This replaces the system context.
+	owner, err := s.UserService.FindUserByID(ctx, playlist.OwnerID)
+	if err != nil {
+		return err
+	} else if owner == nil {
+		return ErrUserNotFound
+	}
+	ctx = NewContext(ctx, owner)
+
+	for _, track := range tracks {
+		if err := s.TrackService.DeleteTrack(ctx, track.ID, TrackRemovalReasonRetention); err != nil {
+			return err
+		}
+		s.Logger.InfoContext(ctx, "track removed", "track_id", track.ID, "playlist_id", playlist.ID, "size", track.Size)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package peapod
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Session errors.
+const (
+	ErrLoginCodeRequired      = Error("login code required")
+	ErrInvalidLoginCode       = Error("invalid login code")
+	ErrLoginCodeExpired       = Error("login code expired")
+	ErrLoginAttemptsExceeded  = Error("too many login attempts")
+	ErrLoginCodeLimitExceeded = Error("too many login codes requested")
+	ErrSessionNotFound        = Error("session not found")
+)
+
+// Login & session settings.
+const (
+	// LoginCodeLength is the number of digits in a login code.
+	LoginCodeLength = 6
+
+	// LoginCodeTTL is the time a login code can be used after it is sent.
+	LoginCodeTTL = 10 * time.Minute
+
+	// MaxLoginAttempts is the number of incorrect codes allowed for a mobile
+	// number within LoginThrottleWindow. Once reached, the current code is
+	// invalidated and the number is locked out until the window ends.
+	MaxLoginAttempts = 5
+
+	// MaxLoginCodes is the number of login codes which can be sent to a
+	// mobile number within LoginThrottleWindow.
+	MaxLoginCodes = 5
+
+	// LoginThrottleWindow is the period over which login codes sent &
+	// incorrect attempts are counted for a mobile number.
+	LoginThrottleWindow = 1 * time.Hour
+
+	// SessionTTL is the time a session remains valid after login.
+	SessionTTL = 30 * 24 * time.Hour
+)
+
+// Session represents an authenticated login for a user. The token is only
+// available when the session is created.
+type Session struct {
+	Token     string    `json:"token,omitempty"`
+	UserID    int       `json:"user_id"`
+	User      *User     `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SessionService represents a service for passwordless login.
+type SessionService interface {
+	// Generates a new login code for a mobile number. Any previous code for
+	// the number is replaced. The code must be delivered to the user.
+	CreateLoginCode(ctx context.Context, mobileNumber string) (string, error)
+
+	// Exchanges a login code for a new session. The user is created if
+	// they do not already exist.
+	CreateSession(ctx context.Context, mobileNumber, code string) (*Session, error)
+
+	// Returns an unexpired session with its user attached.
+	FindSessionByToken(ctx context.Context, token string) (*Session, error)
+
+	// Removes a session, e.g. on logout.
+	DeleteSession(ctx context.Context, token string) error
+}
+
+// GenerateLoginCode returns a random numeric code of LoginCodeLength digits.
+func GenerateLoginCode() string {
+	max := big.NewInt(1)
+	for i := 0; i < LoginCodeLength; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%0*d", LoginCodeLength, n)
+}
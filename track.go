@@ -1,10 +1,16 @@
 package peapod
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"net/url"
+	"regexp"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Track errors.
@@ -14,26 +20,276 @@ const (
 	ErrTrackPlaylistRequired = Error("track playlist required")
 	ErrTrackFilenameRequired = Error("track filename required")
 	ErrTrackTitleRequired    = Error("track title required")
+	ErrTrackReasonRequired   = Error("track removal reason required")
+	ErrSearchQueryRequired   = Error("search query required")
+	ErrTrashEmpty            = Error("trash empty")
+	ErrInvalidTrackCursor    = Error("invalid track cursor")
+	ErrInvalidTrackOrder     = Error("invalid track order")
 )
 
 // Track represents an audio track.
 type Track struct {
-	ID          int           `json:"id"`
-	PlaylistID  int           `json:"playlist_id"`
-	Filename    string        `json:"filename"`
-	Title       string        `json:"title"`
-	Description string        `json:"description"`
-	Duration    time.Duration `json:"duration"`
-	ContentType string        `json:"content_type"`
-	Size        int           `json:"size"`
-	CreatedAt   time.Time     `json:"created_at"`
-	UpdatedAt   time.Time     `json:"updated_at"`
+	ID                 int           `json:"id"`
+	PlaylistID         int           `json:"playlist_id"`
+	Filename           string        `json:"filename"`
+	Title              string        `json:"title"`
+	Description        string        `json:"description"`
+	Author             string        `json:"author,omitempty"`
+	Duration           time.Duration `json:"duration"`
+	ContentType        string        `json:"content_type"`
+	Size               int           `json:"size"`
+	TranscriptFilename string        `json:"transcript_filename,omitempty"`
+	ChaptersFilename   string        `json:"chapters_filename,omitempty"`
+	Tags               []string      `json:"tags,omitempty"`
+	SourceURL          string        `json:"source_url,omitempty"` // submitted URL, if any
+	Pinned             bool          `json:"pinned,omitempty"`     // exempt from retention
+	CreatedAt          time.Time     `json:"created_at"`
+	UpdatedAt          time.Time     `json:"updated_at"`
+	DeletedAt          time.Time     `json:"deleted_at,omitempty"` // set while in the trash
+
+	// Documents generated along with the audio. These are saved as files
+	// by the JobExecutor and are not persisted with the track.
+	Transcript *Transcript `json:"-"`
+	Chapters   []*Chapter  `json:"-"`
+}
+
+// HasTag returns true if the track has been tagged with tag.
+func (t *Track) HasTag(tag string) bool {
+	for _, v := range t.Tags {
+		if v == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// SourceDomain returns the lowercase host of the track's source URL.
+// Returns a blank string if the track was not created from a URL.
+func (t *Track) SourceDomain() string {
+	u, err := url.Parse(t.SourceURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// NormalizeTags returns a lowercase, de-duplicated list of tags with any
+// leading hash symbols removed. Blank tags are dropped.
+func NormalizeTags(a []string) []string {
+	var other []string
+	seen := make(map[string]struct{})
+	for _, tag := range a {
+		tag = strings.ToLower(strings.TrimLeft(strings.TrimSpace(tag), "#"))
+		if tag == "" {
+			continue
+		} else if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		other = append(other, tag)
+	}
+	return other
+}
+
+// Transcript represents the text of a track's audio.
+type Transcript struct {
+	Ext  string // file extension (e.g. ".vtt", ".txt")
+	Body []byte
+}
+
+// Text returns the spoken text of the transcript. WebVTT headers, cue
+// timings & markup are removed.
+func (t *Transcript) Text() string {
+	if t.Ext != ".vtt" {
+		return string(t.Body)
+	}
+
+	var buf bytes.Buffer
+	for _, line := range strings.Split(string(t.Body), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "WEBVTT" || strings.Contains(line, "-->") {
+			continue
+		} else if strings.HasPrefix(line, "NOTE") || strings.HasPrefix(line, "Kind:") || strings.HasPrefix(line, "Language:") {
+			continue
+		}
+		buf.WriteString(vttTagRegex.ReplaceAllString(line, ""))
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+var vttTagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// Tokenize splits text into lowercase search terms. Single characters and
+// numbers are ignored.
+func Tokenize(text string) []string {
+	var a []string
+	for _, term := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(term) < 2 {
+			continue
+		} else if strings.IndexFunc(term, unicode.IsLetter) == -1 {
+			continue
+		}
+		a = append(a, term)
+	}
+	return a
+}
+
+// Chapter represents a marker for a section of a track.
+type Chapter struct {
+	StartTime time.Duration `json:"start_time"`
+	Title     string        `json:"title"`
+}
+
+// ChaptersExt is the file extension used for chapters documents.
+const ChaptersExt = ".json"
+
+// MarshalChapters encodes chapters as a Podcasting 2.0 JSON chapters document.
+func MarshalChapters(a []*Chapter) ([]byte, error) {
+	type chapterJSON struct {
+		StartTime float64 `json:"startTime"`
+		Title     string  `json:"title,omitempty"`
+	}
+
+	doc := struct {
+		Version  string        `json:"version"`
+		Chapters []chapterJSON `json:"chapters"`
+	}{Version: "1.2.0", Chapters: make([]chapterJSON, len(a))}
+
+	for i, c := range a {
+		doc.Chapters[i] = chapterJSON{StartTime: c.StartTime.Seconds(), Title: c.Title}
+	}
+	return json.Marshal(doc)
 }
 
 // TrackService represents a service for managing audio tracks.
 type TrackService interface {
 	FindTrackByID(ctx context.Context, id int) (*Track, error)
 	CreateTrack(ctx context.Context, track *Track) error
+
+	// Moves a track to the current user's trash & records the removal with
+	// the given reason. Trashed tracks are excluded from feeds & search.
+	DeleteTrack(ctx context.Context, id int, reason string) error
+
+	// Trash management. Tracks can be restored from the trash until they
+	// are purged. The track's files must be removed separately after purging.
+	FindTrashedTracks(ctx context.Context) ([]*Track, error)
+	RestoreTrack(ctx context.Context, id int) (*Track, error)
+	UndoDeleteTrack(ctx context.Context) (*Track, error)
+	PurgeTrash(ctx context.Context) ([]*Track, error)
+
+	// Permanently deletes a track, bypassing the trash, so that abusive
+	// content can be removed. Requires a system context.
+	PurgeTrack(ctx context.Context, id int) (*Track, error)
+
+	// Pinned tracks are exempt from playlist retention policies.
+	SetTrackPinned(ctx context.Context, id int, pinned bool) error
+
+	// Returns a history of tracks removed from a playlist.
+	FindTrackRemovals(ctx context.Context, playlistID int) ([]*TrackRemoval, error)
+
+	// Returns the current user's tracks which match a full-text query,
+	// ordered by relevance.
+	SearchTracks(ctx context.Context, search TrackSearch) (*TrackSearchResult, error)
+
+	// Returns a page of the current user's tracks which match a filter.
+	FindTracks(ctx context.Context, filter TrackFilter) (*TrackPage, error)
+}
+
+// Track list limits.
+const (
+	DefaultTrackLimit = 50
+	MaxTrackLimit     = 500
+)
+
+// Track sort orders. Tracks are ordered by creation.
+const (
+	TrackOrderNewest = "newest"
+	TrackOrderOldest = "oldest"
+)
+
+// TrackFilter represents a set of filters used to find tracks. Zero-value
+// fields are ignored. Only tracks the current user can read are returned.
+type TrackFilter struct {
+	PlaylistID    int
+	OwnerID       int       // playlist owner
+	CreatedAfter  time.Time // inclusive
+	CreatedBefore time.Time // exclusive
+	ContentType   string
+	SourceDomain  string // matches subdomains too
+	Tag           string
+
+	Cursor string // NextCursor from the previous page
+	Limit  int
+	Order  string // defaults to TrackOrderNewest
+}
+
+// Match returns true if track matches the filter's track-level fields.
+// Playlist & owner restrictions must be applied by the caller.
+func (f *TrackFilter) Match(track *Track) bool {
+	if !f.CreatedAfter.IsZero() && track.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	} else if !f.CreatedBefore.IsZero() && !track.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	} else if f.ContentType != "" && !strings.EqualFold(track.ContentType, f.ContentType) {
+		return false
+	} else if f.Tag != "" && !track.HasTag(strings.ToLower(strings.TrimLeft(f.Tag, "#"))) {
+		return false
+	}
+
+	if f.SourceDomain != "" {
+		domain, host := strings.ToLower(f.SourceDomain), track.SourceDomain()
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			return false
+		}
+	}
+	return true
+}
+
+// TrackPage represents a page of tracks returned by a filter.
+type TrackPage struct {
+	Tracks     []*Track `json:"tracks"`
+	NextCursor string   `json:"next_cursor,omitempty"` // blank on the last page
+}
+
+// Search limits.
+const (
+	DefaultSearchLimit = 10
+	MaxSearchLimit     = 100
+)
+
+// TrackSearch represents a full-text search over a user's tracks.
+type TrackSearch struct {
+	Query  string
+	Offset int
+	Limit  int
+}
+
+// TrackSearchResult represents a page of search results.
+type TrackSearchResult struct {
+	Tracks []*Track `json:"tracks"`
+	Total  int      `json:"total"` // total matches, ignoring pagination
+}
+
+// Track removal reasons.
+const (
+	TrackRemovalReasonRetention = "retention"
+	TrackRemovalReasonUser      = "user"
+	TrackRemovalReasonAdmin     = "admin"
+)
+
+// TrackRemoval represents a record of a track that has been deleted.
+type TrackRemoval struct {
+	ID         int       `json:"id"`
+	PlaylistID int       `json:"playlist_id"`
+	TrackID    int       `json:"track_id"`
+	Title      string    `json:"title"`
+	Filename   string    `json:"filename"`
+	Size       int       `json:"size"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 // URLTrackGenerator returns a track and file contents from a URL.
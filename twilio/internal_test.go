@@ -0,0 +1,34 @@
+package twilio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/middlemost/peapod"
+)
+
+// Ensure the correlation id is sent as a header on Twilio API requests.
+func TestCorrelationTransport(t *testing.T) {
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(peapod.CorrelationIDHeader)
+	}))
+	defer ts.Close()
+
+	c := &http.Client{Transport: &correlationTransport{id: "SM0001"}}
+	req, err := http.NewRequest("POST", ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if resp, err := c.Do(req); err != nil {
+		t.Fatal(err)
+	} else {
+		resp.Body.Close()
+	}
+
+	if got != "SM0001" {
+		t.Fatalf("unexpected header: %q", got)
+	} else if req.Header.Get(peapod.CorrelationIDHeader) != "" {
+		t.Fatal("expected original request to be unmodified")
+	}
+}
@@ -2,12 +2,11 @@ package twilio
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"io"
-	"io/ioutil"
+	"log/slog"
+	"net/http"
 
 	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/metrics"
 	"github.com/subosito/twilio"
 )
 
@@ -23,28 +22,55 @@ type SMSService struct {
 	// Sender phone number.
 	From string
 
-	LogOutput io.Writer
+	Logger *slog.Logger
 }
 
 // NewSMSService returns a new instance of SMSService.
 func NewSMSService() *SMSService {
-	return &SMSService{LogOutput: ioutil.Discard}
+	return &SMSService{Logger: peapod.DiscardLogger()}
 }
 
+// smsSent counts messages sent by status (success or error).
+var smsSent = metrics.NewCounterVec("peapod_sms_sent_total", "SMS messages sent by status.", "status")
+
 // SendSMS sends an SMS message.
 func (s *SMSService) SendSMS(ctx context.Context, msg *peapod.SMS) error {
-	client := twilio.NewClient(s.AccountSID, s.AuthToken, nil)
+	client := twilio.NewClient(s.AccountSID, s.AuthToken, &http.Client{
+		Transport: &correlationTransport{id: peapod.CorrelationIDFromContext(ctx)},
+	})
 
 	// Send message.
 	ret, _, err := client.Messages.SendSMS(s.From, msg.To, msg.Body)
 	if err != nil {
+		smsSent.Inc("error")
 		return err
 	}
 	msg.ID = ret.Sid
+	smsSent.Inc("success")
 
 	// Log returned message.
-	buf, _ := json.Marshal(ret)
-	fmt.Fprintf(s.LogOutput, "twilio: send: %s\n", buf)
+	s.Logger.InfoContext(ctx, "sms sent", "sid", ret.Sid, "to", msg.To)
 
 	return nil
 }
+
+// correlationTransport sends the correlation id, if set, as a header on each
+// request so that API calls can be linked to the request which caused them.
+type correlationTransport struct {
+	id   string
+	base http.RoundTripper
+}
+
+// RoundTrip executes a copy of r with the correlation id header set.
+func (t *correlationTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if t.id != "" {
+		r = r.Clone(r.Context())
+		r.Header.Set(peapod.CorrelationIDHeader, t.id)
+	}
+	return base.RoundTrip(r)
+}
@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"context"
 	"flag"
+	"log/slog"
 	"testing"
 
 	"github.com/middlemost/peapod"
@@ -37,7 +38,7 @@ func TestSMSService_SendSMS(t *testing.T) {
 	s.AccountSID = *accountSID
 	s.AuthToken = *authToken
 	s.From = *from
-	s.LogOutput = &buf
+	s.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
 
 	// Send text.
 	sms := &peapod.SMS{To: *to, Body: "TEST"}
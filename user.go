@@ -2,6 +2,7 @@ package peapod
 
 import (
 	"context"
+	"strings"
 	"time"
 )
 
@@ -11,14 +12,162 @@ const (
 	ErrUserNotFound             = Error("user not found")
 	ErrUserMobileNumberInUse    = Error("mobile number already in use")
 	ErrUserMobileNumberRequired = Error("mobile number required")
+	ErrInvalidTimezone          = Error("invalid timezone")
+	ErrInvalidVoice             = Error("invalid voice")
+	ErrUserDisabled             = Error("user disabled")
+	ErrDeletionCodeRequired     = Error("deletion code required")
+	ErrInvalidDeletionCode      = Error("invalid deletion code")
+	ErrNothingToUndo            = Error("nothing to undo")
 )
 
+// Pagination limits for listing users.
+const (
+	DefaultUserLimit = 50
+	MaxUserLimit     = 500
+)
+
+// UserDeletionConfirmPeriod is the time a user has to confirm an account
+// deletion request.
+const UserDeletionConfirmPeriod = 10 * time.Minute
+
+// Voices lists the text-to-speech voices a user can choose from.
+var Voices = []string{
+	"Amy", "Brian", "Emma", "Geraint", "Ivy", "Joanna", "Joey", "Justin",
+	"Kendra", "Kimberly", "Matthew", "Nicole", "Raveena", "Russell", "Salli",
+}
+
 // User represents a user in the system.
 type User struct {
 	ID           int       `json:"id"`
 	MobileNumber string    `json:"mobile_number,omitempty"`
+	Name         string    `json:"name,omitempty"`
+	Timezone     string    `json:"timezone,omitempty"` // IANA name, e.g. "America/Denver"
+	Voice        string    `json:"voice,omitempty"`    // text-to-speech voice
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+
+	// Notification preferences.
+	MuteJobNotifications    bool `json:"mute_job_notifications,omitempty"`
+	MuteMemberNotifications bool `json:"mute_member_notifications,omitempty"`
+
+	// Set when the user asks to delete their account. The code hash is used
+	// to confirm deletions requested over HTTP.
+	DeletionRequestedAt time.Time `json:"-"`
+	DeletionCodeHash    string    `json:"-"`
+
+	// Access controls. Only changed by administrators.
+	Admin    bool `json:"admin,omitempty"`
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// DisplayName returns the user's name or their mobile number if unset.
+func (u *User) DisplayName() string {
+	if u.Name != "" {
+		return u.Name
+	}
+	return u.MobileNumber
+}
+
+// isValidVoice returns true if voice is one of the supported voices.
+func isValidVoice(voice string) bool {
+	for _, v := range Voices {
+		if v == voice {
+			return true
+		}
+	}
+	return false
+}
+
+// UserUpdate represents a set of changes to a user's profile & settings.
+// Nil fields are left unchanged.
+type UserUpdate struct {
+	Name     *string `json:"name"`
+	Timezone *string `json:"timezone"`
+	Voice    *string `json:"voice"`
+
+	MuteJobNotifications    *bool `json:"mute_job_notifications"`
+	MuteMemberNotifications *bool `json:"mute_member_notifications"`
+}
+
+// Apply validates the update and applies it to user.
+func (upd *UserUpdate) Apply(user *User) error {
+	if upd.Timezone != nil && *upd.Timezone != "" {
+		if _, err := time.LoadLocation(*upd.Timezone); err != nil {
+			return ErrInvalidTimezone
+		}
+	}
+	if upd.Voice != nil && *upd.Voice != "" && !isValidVoice(*upd.Voice) {
+		return ErrInvalidVoice
+	}
+
+	if upd.Name != nil {
+		user.Name = *upd.Name
+	}
+	if upd.Timezone != nil {
+		user.Timezone = *upd.Timezone
+	}
+	if upd.Voice != nil {
+		user.Voice = *upd.Voice
+	}
+	if upd.MuteJobNotifications != nil {
+		user.MuteJobNotifications = *upd.MuteJobNotifications
+	}
+	if upd.MuteMemberNotifications != nil {
+		user.MuteMemberNotifications = *upd.MuteMemberNotifications
+	}
+	return nil
+}
+
+// UserFilter represents a set of filters for listing users.
+type UserFilter struct {
+	// Case-insensitive substring of the user's mobile number or name.
+	Query string
+
+	Offset int
+	Limit  int
+}
+
+// Match returns true if user matches the filter's query.
+func (f *UserFilter) Match(user *User) bool {
+	if f.Query == "" {
+		return true
+	}
+	q := strings.ToLower(f.Query)
+	return strings.Contains(user.MobileNumber, q) || strings.Contains(strings.ToLower(user.Name), q)
+}
+
+// UserAccessUpdate represents a change to a user's access by an administrator.
+// Nil fields are left unchanged.
+type UserAccessUpdate struct {
+	Admin    *bool `json:"admin"`
+	Disabled *bool `json:"disabled"`
+}
+
+// Apply applies the update to user.
+func (upd *UserAccessUpdate) Apply(user *User) {
+	if upd.Admin != nil {
+		user.Admin = *upd.Admin
+	}
+	if upd.Disabled != nil {
+		user.Disabled = *upd.Disabled
+	}
+}
+
+// Undo action types.
+const (
+	UndoActionTypeDeleteTracks = "delete_tracks"
+	UndoActionTypeRotateToken  = "rotate_token"
+)
+
+// UndoAction represents a user's most recent destructive action. Consecutive
+// retention removals from the same playlist are grouped into one action.
+type UndoAction struct {
+	Type       string    `json:"type"`
+	PlaylistID int       `json:"playlist_id"`
+	TrackIDs   []int     `json:"track_ids,omitempty"` // trashed tracks
+	Reason     string    `json:"reason,omitempty"`    // track removal reason
+	Token      string    `json:"-"`                   // token before rotation
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 // UserService represents a service for managing users.
@@ -26,4 +175,32 @@ type UserService interface {
 	FindUserByID(ctx context.Context, id int) (*User, error)
 	FindUserByMobileNumber(ctx context.Context, mobileNumber string) (*User, error)
 	CreateUser(ctx context.Context, user *User) error
+
+	// Updates the profile & settings of the current user.
+	UpdateUser(ctx context.Context, id int, upd UserUpdate) (*User, error)
+
+	// Marks the current user as wanting to delete their account & returns
+	// a code which must be delivered to the user. The deletion must be
+	// confirmed within UserDeletionConfirmPeriod.
+	RequestUserDeletion(ctx context.Context, id int) (string, error)
+
+	// Deletes the current user along with their playlists, tracks, jobs &
+	// memberships if code matches their pending deletion request. Returns
+	// the removed tracks so their files can be removed. An incorrect code
+	// cancels the request.
+	ConfirmUserDeletion(ctx context.Context, id int, code string) ([]*Track, error)
+
+	// Reverts the current user's last destructive action by restoring the
+	// tracks it moved to the trash or by restoring a rotated playlist token
+	// within its grace period. Restored retention removals are pinned so
+	// they are not removed again. Returns ErrNothingToUndo if there is no
+	// action or it can no longer be reverted.
+	UndoLastAction(ctx context.Context, id int) (*UndoAction, error)
+
+	// Returns users matching filter, ordered by id. Requires a system context.
+	FindUsers(ctx context.Context, filter UserFilter) ([]*User, error)
+
+	// Grants or revokes admin access & disables or enables a user.
+	// Disabling a user ends their sessions. Requires a system context.
+	UpdateUserAccess(ctx context.Context, id int, upd UserAccessUpdate) (*User, error)
 }
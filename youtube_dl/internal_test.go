@@ -0,0 +1,21 @@
+package youtube_dl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/mock"
+)
+
+// Ensure the correlation id is passed to youtube-dl.
+func TestURLTrackGenerator_CorrelationID(t *testing.T) {
+	mock.MustFakeCommand(t, "youtube-dl", `echo "$PEAPOD_CORRELATION_ID"`)
+
+	ctx := peapod.NewCorrelationContext(context.Background(), "SM0001")
+	if msg, err := NewURLTrackGenerator().CheckHealth(ctx); err != nil {
+		t.Fatal(err)
+	} else if msg != "youtube-dl SM0001" {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+}
@@ -3,26 +3,51 @@ package youtube_dl
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"log/slog"
 	"net/url"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/middlemost/peapod"
+	"github.com/middlemost/peapod/metrics"
+)
+
+// SubtitleLanguage is the language of subtitles downloaded as transcripts.
+const SubtitleLanguage = "en"
+
+// downloadDuration tracks youtube-dl execution time by status (success or error).
+var downloadDuration = metrics.NewHistogramVec(
+	"peapod_youtube_dl_duration_seconds",
+	"Time spent downloading & extracting audio with youtube-dl by status.",
+	peapod.JobDurationBuckets, "status",
 )
 
 // URLTrackGenerator generates audio tracks from a URL.
 type URLTrackGenerator struct {
 	Proxy string
 
-	LogOutput io.Writer
+	Logger *slog.Logger
 }
 
 // NewURLTrackGenerator returns a new instance of URLTrackGenerator.
 func NewURLTrackGenerator() *URLTrackGenerator {
-	return &URLTrackGenerator{}
+	return &URLTrackGenerator{Logger: peapod.DiscardLogger()}
+}
+
+// CheckHealth verifies that youtube-dl is installed and returns its version.
+func (g *URLTrackGenerator) CheckHealth(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, "youtube-dl", "--version")
+	cmd.Env = peapod.CommandEnv(ctx)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("youtube-dl: %s", err)
+	}
+	return "youtube-dl " + strings.TrimSpace(string(out)), nil
 }
 
 // GenerateTrackFromURL fetches an audio stream from a given URL.
@@ -53,6 +78,9 @@ func (g *URLTrackGenerator) GenerateTrackFromURL(ctx context.Context, u url.URL)
 		"--audio-quality", "128K",
 		"-o", path + ".%(ext)s",
 		"--write-info-json",
+		"--write-sub", "--write-auto-sub",
+		"--sub-lang", SubtitleLanguage,
+		"--sub-format", "vtt",
 	}
 	if g.Proxy != "" {
 		args = append(args, "--proxy", g.Proxy)
@@ -60,12 +88,20 @@ func (g *URLTrackGenerator) GenerateTrackFromURL(ctx context.Context, u url.URL)
 	args = append(args, u.String())
 
 	// Execute command.
-	cmd := exec.Command("youtube-dl", args...)
-	cmd.Stdout = g.LogOutput
-	cmd.Stderr = g.LogOutput
+	output := peapod.NewLogWriter(ctx, g.Logger, "youtube-dl output")
+	defer output.Close()
+
+	cmd := exec.CommandContext(ctx, "youtube-dl", args...)
+	cmd.Env = peapod.CommandEnv(ctx)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	g.Logger.InfoContext(ctx, "downloading", "url", u.String())
+	t := time.Now()
 	if err := cmd.Run(); err != nil {
+		downloadDuration.Observe(time.Since(t).Seconds(), "error")
 		return nil, nil, err
 	}
+	downloadDuration.Observe(time.Since(t).Seconds(), "success")
 
 	// Read info file.
 	var info infoFile
@@ -79,9 +115,28 @@ func (g *URLTrackGenerator) GenerateTrackFromURL(ctx context.Context, u url.URL)
 	track := &peapod.Track{
 		Title:       info.Title,
 		Description: info.Description,
+		Author:      info.Uploader,
 		Duration:    time.Duration(info.Duration) * time.Second,
 		ContentType: "audio/mp3",
 		Size:        info.Size,
+		Tags:        peapod.NormalizeTags(append(info.Tags, info.Categories...)),
+	}
+
+	// Attach chapters, if available.
+	for _, c := range info.Chapters {
+		track.Chapters = append(track.Chapters, &peapod.Chapter{
+			StartTime: time.Duration(c.StartTime * float64(time.Second)),
+			Title:     c.Title,
+		})
+	}
+
+	// Attach subtitles as the transcript, if available.
+	subtitlePath := path + "." + SubtitleLanguage + ".vtt"
+	if buf, err := ioutil.ReadFile(subtitlePath); err == nil {
+		track.Transcript = &peapod.Transcript{Ext: ".vtt", Body: buf}
+		os.Remove(subtitlePath)
+	} else if !os.IsNotExist(err) {
+		return nil, nil, err
 	}
 
 	// Open file handle to return for reading.
@@ -95,10 +150,17 @@ func (g *URLTrackGenerator) GenerateTrackFromURL(ctx context.Context, u url.URL)
 
 // infoFile represents a partial structure of the youtube-dl info JSON file.
 type infoFile struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Duration    int    `json:"duration"`
-	Size        int    `json:"filesize"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Uploader    string   `json:"uploader"`
+	Duration    int      `json:"duration"`
+	Size        int      `json:"filesize"`
+	Tags        []string `json:"tags"`
+	Categories  []string `json:"categories"`
+	Chapters    []struct {
+		StartTime float64 `json:"start_time"`
+		Title     string  `json:"title"`
+	} `json:"chapters"`
 }
 
 // oneTimeReader allows the reader to read once and then it deletes on close.
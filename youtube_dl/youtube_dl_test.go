@@ -1,3 +1,4 @@
+//go:build integration
 // +build integration
 
 package youtube_dl_test
@@ -8,6 +9,7 @@ import (
 	"flag"
 	"io"
 	"io/ioutil"
+	"log/slog"
 	"net/url"
 	"testing"
 
@@ -29,7 +31,7 @@ func TestURLTrackGenerator_GenerateTrackFromURL(t *testing.T) {
 	var buf bytes.Buffer
 	g := youtube_dl.NewURLTrackGenerator()
 	g.Proxy = *proxy
-	g.LogOutput = &buf
+	g.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
 
 	// Parse URL.
 	u, err := url.Parse(*videoURL)